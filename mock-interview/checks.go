@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/winfr1th/mock-interview/internal/health"
+	"github.com/winfr1th/mock-interview/internal/server"
+	"github.com/winfr1th/mock-interview/migrations"
+)
+
+// readinessChecks are run by /readyz and reported by /status
+func readinessChecks(store storage, srv *server.Server) []health.Check {
+	return []health.Check{
+		{
+			Name: "shutdown",
+			Run: func(ctx context.Context) error {
+				if !srv.Ready() {
+					return errors.New("shutting down")
+				}
+				return nil
+			},
+		},
+		{
+			Name:    "database",
+			Timeout: time.Second,
+			Run:     store.ping,
+		},
+		{
+			Name:    "migrations",
+			Timeout: time.Second,
+			Run: func(ctx context.Context) error {
+				version, err := store.schemaVersion(ctx)
+				if err != nil {
+					return err
+				}
+				if latest := migrations.Latest(); version < latest {
+					return fmt.Errorf("schema is at version %d, migrations up to %d are pending", version, latest)
+				}
+				return nil
+			},
+		},
+	}
+}
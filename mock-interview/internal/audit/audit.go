@@ -0,0 +1,221 @@
+package audit
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/winfr1th/mock-interview/internal/lockout"
+	"github.com/winfr1th/mock-interview/internal/logging"
+	model "github.com/winfr1th/mock-interview/internal/models"
+	"github.com/winfr1th/mock-interview/internal/repository"
+)
+
+// Event types
+const (
+	EventUserRegistered   = "user.registered"
+	EventUserCreated      = "user.created"
+	EventUserUpdated      = "user.updated"
+	EventUserDeleted      = "user.deleted"
+	EventAPIKeyCreated    = "api_key.created"
+	EventAPIKeyRotated    = "api_key.rotated"
+	EventAPIKeyRevoked    = "api_key.revoked"
+	EventIdentityLinked   = "identity.linked"
+	EventSessionCreated   = "session.created"
+	EventSessionReused    = "session.refresh_reused"
+	EventAuthFailed       = "auth.failed"
+	EventAuthBlocked      = "auth.blocked"
+	EventAuthBlockCleared = "admin.auth_block_cleared"
+)
+
+// Target types
+const (
+	TargetUser        = "user"
+	TargetAuthSubject = "auth_subject"
+)
+
+type contextKey string
+
+const requestInfoKey contextKey = "auditRequestInfo"
+
+// RequestInfo is the request context stored on every event
+type RequestInfo struct {
+	IP        string
+	UserAgent string
+	RequestID string
+}
+
+// WithRequestInfo stores r's client IP, user agent and request ID in the context for later events
+func WithRequestInfo(ctx context.Context, r *http.Request) context.Context {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return context.WithValue(ctx, requestInfoKey, RequestInfo{
+		IP:        host,
+		UserAgent: r.UserAgent(),
+		RequestID: logging.RequestID(r.Context()),
+	})
+}
+
+// FailureFlushInterval is how often Run writes counted authentication failures as events
+const FailureFlushInterval = 10 * time.Second
+
+// finalFlushTimeout is how long Run's last flush may take once its context is done
+const finalFlushTimeout = 5 * time.Second
+
+// maxPendingFailures bounds the groups of failures counted between flushes. Failures that
+// would start another group are only counted in the next flush's dropped_attempts event.
+const maxPendingFailures = 10000
+
+// Recorder appends audit events. Failures to write are logged, not returned,
+// so auditing never fails the request being audited.
+//
+// Appending takes the hash chain's lock, so failed authentication attempts, which anyone can
+// cause, aren't appended one by one: they are counted in memory and written as one auth.failed
+// event per subject and client by Run, every FailureFlushInterval.
+type Recorder struct {
+	repo repository.AuditRepository
+	now  func() time.Time
+
+	mu       sync.Mutex
+	failures map[string]*failureCount
+	dropped  int
+}
+
+// failureCount is the failed attempts of one set of subjects from one client since the last flush
+type failureCount struct {
+	subjects    map[string]string
+	info        RequestInfo // Of the last attempt
+	attempts    int
+	first, last time.Time
+}
+
+func NewRecorder(repo repository.AuditRepository) *Recorder {
+	return &Recorder{
+		repo:     repo,
+		now:      time.Now,
+		failures: make(map[string]*failureCount),
+	}
+}
+
+// Record appends an event; actorID may be nil for anonymous actions. Appending holds the chain's
+// lock until the unit of work commits, so record after a unit of work rather than inside it.
+func (rec *Recorder) Record(ctx context.Context, eventType string, actorID *uuid.UUID,
+	targetType, targetID string, metadata map[string]string) {
+	info, _ := ctx.Value(requestInfoKey).(RequestInfo)
+	rec.append(ctx, info, eventType, actorID, targetType, targetID, metadata)
+}
+
+func (rec *Recorder) append(ctx context.Context, info RequestInfo, eventType string, actorID *uuid.UUID,
+	targetType, targetID string, metadata map[string]string) {
+	event := model.AuditEvent{
+		// Postgres stores microseconds; truncate so the hash matches what's read back
+		OccurredAt: rec.now().UTC().Truncate(time.Microsecond),
+		EventType:  eventType,
+		ActorID:    actorID,
+		TargetType: targetType,
+		TargetID:   targetID,
+		IP:         info.IP,
+		UserAgent:  info.UserAgent,
+		RequestID:  info.RequestID,
+		Metadata:   metadata,
+	}
+
+	if _, err := rec.repo.AppendEvent(ctx, event); err != nil {
+		slog.ErrorContext(ctx, "audit: failed to record event", "event_type", eventType, "error", err)
+	}
+}
+
+// RecordFailure implements lockout.Recorder. The attempt is only counted; Run writes the counts.
+func (rec *Recorder) RecordFailure(ctx context.Context, subjects []lockout.Subject) {
+	info, _ := ctx.Value(requestInfoKey).(RequestInfo)
+	keys := make([]string, len(subjects))
+	for i, subject := range subjects {
+		keys[i] = string(subject.Kind) + ":" + subject.Value
+	}
+	sort.Strings(keys)
+	key := strings.Join(keys, ",") + "|" + info.IP + "|" + info.UserAgent
+
+	now := rec.now()
+	rec.mu.Lock()
+	count, ok := rec.failures[key]
+	switch {
+	case ok:
+	case len(rec.failures) < maxPendingFailures:
+		count = &failureCount{subjects: make(map[string]string, len(subjects)), first: now}
+		for _, subject := range subjects {
+			count.subjects[string(subject.Kind)] = subject.Value
+		}
+		rec.failures[key] = count
+	default:
+		rec.dropped++
+	}
+	if count != nil {
+		count.attempts++
+		count.last = now
+		count.info = info
+	}
+	rec.mu.Unlock()
+}
+
+// Run writes the counted failures every FailureFlushInterval until ctx is done, then writes the
+// rest. It's meant to run as a server worker, so shutdown waits for the last flush.
+func (rec *Recorder) Run(ctx context.Context) {
+	ticker := time.NewTicker(FailureFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			rec.FlushFailures(ctx)
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), finalFlushTimeout)
+			defer cancel()
+			rec.FlushFailures(flushCtx)
+			return
+		}
+	}
+}
+
+// FlushFailures writes the failures counted since the last flush, one auth.failed event per
+// set of subjects and client with the number of attempts and when the first and last were made
+func (rec *Recorder) FlushFailures(ctx context.Context) {
+	rec.mu.Lock()
+	failures, dropped := rec.failures, rec.dropped
+	rec.failures, rec.dropped = make(map[string]*failureCount), 0
+	rec.mu.Unlock()
+
+	keys := make([]string, 0, len(failures))
+	for key := range failures {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return failures[keys[i]].first.Before(failures[keys[j]].first) })
+	for _, key := range keys {
+		count := failures[key]
+		metadata := count.subjects
+		metadata["attempts"] = strconv.Itoa(count.attempts)
+		metadata["first_attempt_at"] = count.first.UTC().Format(time.RFC3339Nano)
+		metadata["last_attempt_at"] = count.last.UTC().Format(time.RFC3339Nano)
+		rec.append(ctx, count.info, EventAuthFailed, nil, "", "", metadata)
+	}
+	if dropped > 0 {
+		rec.append(ctx, RequestInfo{}, EventAuthFailed, nil, "", "",
+			map[string]string{"dropped_attempts": strconv.Itoa(dropped)})
+	}
+}
+
+// RecordBlock implements lockout.Recorder
+func (rec *Recorder) RecordBlock(ctx context.Context, status lockout.Status) {
+	rec.Record(ctx, EventAuthBlocked, nil, TargetAuthSubject, string(status.Kind)+":"+status.Value,
+		map[string]string{
+			"failures":      strconv.Itoa(status.Failures),
+			"blocked_until": status.BlockedUntil.UTC().Format(time.RFC3339),
+		})
+}
@@ -0,0 +1,126 @@
+package audit
+
+import (
+	"context"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/winfr1th/mock-interview/internal/lockout"
+	model "github.com/winfr1th/mock-interview/internal/models"
+	"github.com/winfr1th/mock-interview/internal/repository/memory"
+)
+
+// newTestRecorder returns a recorder on an in-memory repository whose clock is *now
+func newTestRecorder(now *time.Time) (*Recorder, func(t *testing.T) []model.AuditEvent) {
+	repo := memory.NewAuditRepository(memory.NewStore())
+	rec := NewRecorder(repo)
+	rec.now = func() time.Time { return *now }
+	return rec, func(t *testing.T) []model.AuditEvent {
+		t.Helper()
+		events, err := repo.ListEvents(context.Background(), model.AuditFilter{}, 0, maxPendingFailures+10)
+		if err != nil {
+			t.Fatalf("ListEvents: %v", err)
+		}
+		return events
+	}
+}
+
+func requestFrom(ip string) context.Context {
+	r := httptest.NewRequest("GET", "/v1/movies", nil)
+	r.RemoteAddr = ip + ":1234"
+	r.Header.Set("User-Agent", "test")
+	return WithRequestInfo(context.Background(), r)
+}
+
+func TestFailuresAreCountedNotAppended(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	rec, events := newTestRecorder(&now)
+	key := lockout.Subject{Kind: lockout.KindKeyPrefix, Value: "abcd1234"}
+	ip := lockout.Subject{Kind: lockout.KindIP, Value: "192.0.2.1"}
+
+	for range 3 {
+		rec.RecordFailure(requestFrom("192.0.2.1"), []lockout.Subject{key, ip})
+		now = now.Add(time.Second)
+	}
+	rec.RecordFailure(requestFrom("192.0.2.2"), []lockout.Subject{ip, key})
+	if got := events(t); len(got) != 0 {
+		t.Fatalf("failures appended %d events before a flush, want 0", len(got))
+	}
+
+	now = now.Add(time.Second)
+	rec.RecordFailure(requestFrom("192.0.2.1"), []lockout.Subject{ip, key})
+	rec.FlushFailures(context.Background())
+	got := events(t)
+	if len(got) != 2 {
+		t.Fatalf("flush appended %d events, want one per client: %+v", len(got), got)
+	}
+	first, second := got[1], got[0] // Newest first
+	if first.EventType != EventAuthFailed || first.IP != "192.0.2.1" || first.Metadata["attempts"] != "4" ||
+		first.Metadata["key_prefix"] != "abcd1234" || first.Metadata["ip"] != "192.0.2.1" {
+		t.Errorf("first client's event = %+v, want 4 attempts on the key and IP", first)
+	}
+	if first.Metadata["first_attempt_at"] != "2026-01-02T03:04:05Z" ||
+		first.Metadata["last_attempt_at"] != now.Format(time.RFC3339Nano) {
+		t.Errorf("first client's attempts span %s to %s", first.Metadata["first_attempt_at"], first.Metadata["last_attempt_at"])
+	}
+	if second.IP != "192.0.2.2" || second.Metadata["attempts"] != "1" {
+		t.Errorf("second client's event = %+v, want 1 attempt", second)
+	}
+
+	// Counts start over after a flush
+	rec.FlushFailures(context.Background())
+	if got := events(t); len(got) != 2 {
+		t.Errorf("flush with nothing counted appended %d events", len(got)-2)
+	}
+}
+
+func TestFailuresBeyondTheLimitAreCountedAsDropped(t *testing.T) {
+	now := time.Now()
+	rec, events := newTestRecorder(&now)
+	for i := range maxPendingFailures + 5 {
+		subject := lockout.Subject{Kind: lockout.KindKeyPrefix, Value: strconv.Itoa(i)}
+		rec.RecordFailure(requestFrom("192.0.2.1"), []lockout.Subject{subject})
+	}
+
+	rec.FlushFailures(context.Background())
+	got := events(t)
+	if len(got) != maxPendingFailures+1 {
+		t.Fatalf("flush appended %d events, want %d and one for the dropped attempts", len(got), maxPendingFailures+1)
+	}
+	if got[0].Metadata["dropped_attempts"] != "5" {
+		t.Errorf("last event = %+v, want 5 dropped attempts", got[0])
+	}
+}
+
+func TestBlocksAreAppendedAtOnce(t *testing.T) {
+	now := time.Now()
+	rec, events := newTestRecorder(&now)
+	status := lockout.Status{Subject: lockout.Subject{Kind: lockout.KindIP, Value: "192.0.2.1"}, Failures: 10}
+	rec.RecordBlock(requestFrom("192.0.2.1"), status)
+
+	got := events(t)
+	if len(got) != 1 || got[0].EventType != EventAuthBlocked || got[0].TargetID != "ip:192.0.2.1" {
+		t.Errorf("events = %+v, want the block", got)
+	}
+}
+
+func TestRunFlushesWhenItsContextEnds(t *testing.T) {
+	now := time.Now()
+	rec, events := newTestRecorder(&now)
+	rec.RecordFailure(requestFrom("192.0.2.1"), []lockout.Subject{{Kind: lockout.KindIP, Value: "192.0.2.1"}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		rec.Run(ctx)
+		close(done)
+	}()
+	cancel()
+	<-done
+
+	if got := events(t); len(got) != 1 || got[0].Metadata["attempts"] != "1" {
+		t.Errorf("events after Run returned = %+v, want the counted failure", got)
+	}
+}
@@ -1,15 +1,106 @@
 package auth
 
 import (
+	"crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/hex"
+	"errors"
+	"hash/crc32"
+	"math/big"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 )
 
-// GenerateAPIKey generates a UUID-based API key
-func GenerateAPIKey() (string, error) {
-	return uuid.New().String(), nil
+// API keys look like mvk_live_<id>_<secret><checksum>:
+//   - id: 12 base62 characters, stored in plain text to find the key's row
+//   - secret: 32 base62 characters (~190 bits) from crypto/rand
+//   - checksum: 6 base62 characters of the CRC-32 of everything before it, so typos and
+//     secret scanners can reject a key without a database lookup
+const (
+	APIKeyPrefix       = "mvk"
+	KeyEnvironmentLive = "live"
+	KeyEnvironmentTest = "test"
+
+	keyIDLength     = 12
+	keySecretLength = 32
+	checksumLength  = 6
+)
+
+const base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+var ErrMalformedAPIKey = errors.New("malformed API key")
+
+// GeneratedAPIKey is a newly issued key. Key is returned to the user once; ID and Hash are stored.
+type GeneratedAPIKey struct {
+	Key  string
+	ID   string
+	Hash string
+}
+
+// GenerateAPIKey generates a prefixed, checksummed API key from crypto/rand
+func GenerateAPIKey() (GeneratedAPIKey, error) {
+	id, err := randomBase62(keyIDLength)
+	if err != nil {
+		return GeneratedAPIKey{}, err
+	}
+	secret, err := randomBase62(keySecretLength)
+	if err != nil {
+		return GeneratedAPIKey{}, err
+	}
+
+	keyID := APIKeyPrefix + "_" + KeyEnvironmentLive + "_" + id
+	body := keyID + "_" + secret
+	key := body + checksum(body)
+
+	return GeneratedAPIKey{
+		Key:  key,
+		ID:   keyID,
+		Hash: HashAPIKey(key),
+	}, nil
+}
+
+// ParseAPIKey checks a key's format and checksum and returns its key ID (mvk_<env>_<id>)
+func ParseAPIKey(apiKey string) (string, error) {
+	parts := strings.Split(apiKey, "_")
+	if len(parts) != 4 || parts[0] != APIKeyPrefix {
+		return "", ErrMalformedAPIKey
+	}
+	if parts[1] != KeyEnvironmentLive && parts[1] != KeyEnvironmentTest {
+		return "", ErrMalformedAPIKey
+	}
+	if len(parts[2]) != keyIDLength || !isBase62(parts[2]) {
+		return "", ErrMalformedAPIKey
+	}
+	if len(parts[3]) != keySecretLength+checksumLength || !isBase62(parts[3]) {
+		return "", ErrMalformedAPIKey
+	}
+
+	body := apiKey[:len(apiKey)-checksumLength]
+	if checksum(body) != apiKey[len(apiKey)-checksumLength:] {
+		return "", ErrMalformedAPIKey
+	}
+
+	return strings.Join(parts[:3], "_"), nil
+}
+
+// IsLegacyAPIKey reports whether the key is a pre-prefix UUID key
+func IsLegacyAPIKey(apiKey string) bool {
+	_, err := uuid.Parse(apiKey)
+	return err == nil
+}
+
+// LegacyKeyPolicy controls the deprecation window for legacy UUID keys
+type LegacyKeyPolicy struct {
+	// Until is when legacy keys stop working; zero keeps accepting them
+	Until time.Time
+}
+
+// Accepts reports whether legacy keys are still accepted at now
+func (p LegacyKeyPolicy) Accepts(now time.Time) bool {
+	return p.Until.IsZero() || now.Before(p.Until)
 }
 
 // HashAPIKey creates a SHA-256 hash of the API key for storage
@@ -18,8 +109,42 @@ func HashAPIKey(apiKey string) string {
 	return hex.EncodeToString(hash[:])
 }
 
-// VerifyAPIKey compares a plain API key with a stored hash
+// VerifyAPIKey compares a plain API key with a stored hash in constant time
 func VerifyAPIKey(apiKey, storedHash string) bool {
 	hash := HashAPIKey(apiKey)
-	return hash == storedHash
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(storedHash)) == 1
+}
+
+// checksum encodes the CRC-32 of s as fixed-width base62
+func checksum(s string) string {
+	n := crc32.ChecksumIEEE([]byte(s))
+	out := make([]byte, checksumLength)
+	for i := checksumLength - 1; i >= 0; i-- {
+		out[i] = base62Alphabet[n%62]
+		n /= 62
+	}
+	return string(out)
+}
+
+// randomBase62 returns n uniformly random base62 characters
+func randomBase62(n int) (string, error) {
+	max := big.NewInt(int64(len(base62Alphabet)))
+	out := make([]byte, n)
+	for i := range out {
+		idx, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		out[i] = base62Alphabet[idx.Int64()]
+	}
+	return string(out), nil
+}
+
+func isBase62(s string) bool {
+	for _, c := range s {
+		if !strings.ContainsRune(base62Alphabet, c) {
+			return false
+		}
+	}
+	return true
 }
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGeneratedAPIKeysParseToTheirID(t *testing.T) {
+	generated, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(generated.Key, generated.ID+"_") || len(generated.ID) != len("mvk_live_")+keyIDLength {
+		t.Errorf("key %q doesn't start with its ID %q", generated.Key, generated.ID)
+	}
+	keyID, err := ParseAPIKey(generated.Key)
+	if err != nil || keyID != generated.ID {
+		t.Errorf("ParseAPIKey = %q, %v; want %q", keyID, err, generated.ID)
+	}
+	if !VerifyAPIKey(generated.Key, generated.Hash) || VerifyAPIKey(generated.Key+"x", generated.Hash) {
+		t.Error("VerifyAPIKey doesn't match the key to its hash only")
+	}
+	if IsLegacyAPIKey(generated.Key) {
+		t.Error("generated key taken for a legacy key")
+	}
+
+	other, _ := GenerateAPIKey()
+	if other.Key == generated.Key || other.ID == generated.ID {
+		t.Error("two generated keys are the same")
+	}
+}
+
+func TestParseAPIKeyRejectsMalformedKeys(t *testing.T) {
+	body := "mvk_live_AbCdEfGhIjKl_" + strings.Repeat("x", keySecretLength)
+	valid := body + checksum(body)
+	if _, err := ParseAPIKey(valid); err != nil {
+		t.Fatalf("ParseAPIKey(%q) = %v", valid, err)
+	}
+	testBody := strings.Replace(body, "_live_", "_test_", 1)
+	if keyID, err := ParseAPIKey(testBody + checksum(testBody)); err != nil || keyID != "mvk_test_AbCdEfGhIjKl" {
+		t.Errorf("test key: ParseAPIKey = %q, %v", keyID, err)
+	}
+
+	// A typo anywhere changes the CRC-32, so the checksum no longer matches
+	typo := []byte(valid)
+	typo[20] = 'Z'
+	tests := []struct {
+		name string
+		key  string
+	}{
+		{"empty", ""},
+		{"legacy UUID", "550e8400-e29b-41d4-a716-446655440000"},
+		{"other prefix", "sk" + valid[3:]},
+		{"unknown environment", strings.Replace(valid, "_live_", "_prod_", 1)},
+		{"short ID", "mvk_live_AbCdEfGhIjK_" + valid[len("mvk_live_AbCdEfGhIjKl_"):]},
+		{"non-base62 ID", "mvk_live_AbCdEfGhIj-l_" + valid[len("mvk_live_AbCdEfGhIjKl_"):]},
+		{"short secret", valid[:len(valid)-1]},
+		{"extra part", valid + "_x"},
+		{"typo", string(typo)},
+		{"wrong checksum", body + "000000"},
+	}
+	for _, tt := range tests {
+		if _, err := ParseAPIKey(tt.key); err != ErrMalformedAPIKey {
+			t.Errorf("%s: ParseAPIKey(%q) = %v, want ErrMalformedAPIKey", tt.name, tt.key, err)
+		}
+	}
+}
+
+func TestChecksumIsFixedWidthBase62(t *testing.T) {
+	for _, s := range []string{"", "a", "mvk_live_AbCdEfGhIjKl_" + strings.Repeat("9", keySecretLength)} {
+		sum := checksum(s)
+		if len(sum) != checksumLength || !isBase62(sum) {
+			t.Errorf("checksum(%q) = %q, want %d base62 characters", s, sum, checksumLength)
+		}
+	}
+	// The CRC-32 of the empty string is 0
+	if got := checksum(""); got != "000000" {
+		t.Errorf(`checksum("") = %q, want "000000"`, got)
+	}
+}
+
+func TestLegacyAPIKeys(t *testing.T) {
+	if !IsLegacyAPIKey("550e8400-e29b-41d4-a716-446655440000") || IsLegacyAPIKey("not-a-uuid") {
+		t.Error("IsLegacyAPIKey doesn't tell UUIDs apart")
+	}
+	until := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	if !(LegacyKeyPolicy{}).Accepts(until.AddDate(10, 0, 0)) {
+		t.Error("policy without an end rejected a legacy key")
+	}
+	policy := LegacyKeyPolicy{Until: until}
+	if !policy.Accepts(until.Add(-time.Second)) || policy.Accepts(until) {
+		t.Error("policy doesn't end at its Until")
+	}
+}
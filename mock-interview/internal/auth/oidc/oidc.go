@@ -0,0 +1,199 @@
+// Package oidc runs the OpenID Connect authorization-code flow with PKCE against a configurable provider
+package oidc
+
+import (
+	"container/list"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// LoginTTL is how long a started login can be completed
+const LoginTTL = 10 * time.Minute
+
+// maxPendingLogins caps the logins waiting for their callback, so starting logins can't use up memory
+const maxPendingLogins = 10000
+
+var (
+	ErrInvalidState  = errors.New("unknown or expired login state")
+	ErrTooManyLogins = errors.New("too many logins in progress")
+	ErrInvalidNonce  = errors.New("ID token nonce does not match the login")
+	ErrMissingToken  = errors.New("token response has no id_token")
+)
+
+// Config configures the identity provider
+type Config struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string // Empty for public clients, which rely on PKCE alone
+	RedirectURL  string
+	Scopes       []string // openid is always requested
+}
+
+// Identity is the verified end user returned by the provider
+type Identity struct {
+	Issuer    string
+	Subject   string
+	Name      string
+	Email     string
+	Birthdate string // OIDC birthdate claim, YYYY-MM-DD when the provider shares it
+}
+
+// pendingLogin is what a login needs to remember until its callback
+type pendingLogin struct {
+	state     string
+	verifier  string
+	nonce     string
+	expiresAt time.Time
+}
+
+// Provider starts logins and exchanges their callbacks for verified identities.
+// Pending logins are kept in memory, so a callback must reach the instance that started it.
+// The caller ties each login to the browser that started it, by comparing the callback's
+// state with one kept in that browser.
+type Provider struct {
+	oauth2   oauth2.Config
+	verifier *gooidc.IDTokenVerifier
+
+	mu sync.Mutex
+	// pending finds a login by state in started, which holds the logins oldest first,
+	// so expired ones are dropped from its front
+	pending map[string]*list.Element
+	started *list.List
+	now     func() time.Time
+}
+
+// NewProvider discovers the provider's endpoints and signing keys from cfg.IssuerURL
+func NewProvider(ctx context.Context, cfg Config) (*Provider, error) {
+	provider, err := gooidc.NewProvider(ctx, cfg.IssuerURL)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := []string{gooidc.ScopeOpenID}
+	for _, scope := range cfg.Scopes {
+		if scope != gooidc.ScopeOpenID {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	return &Provider{
+		oauth2: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       scopes,
+		},
+		verifier: provider.Verifier(&gooidc.Config{ClientID: cfg.ClientID}),
+		pending:  make(map[string]*list.Element),
+		started:  list.New(),
+		now:      time.Now,
+	}, nil
+}
+
+// AuthCodeURL starts a login and returns the provider URL to send the user to, and the state
+// its callback comes back with. It fails with ErrTooManyLogins while maxPendingLogins are pending.
+func (p *Provider) AuthCodeURL() (authURL, state string, err error) {
+	state, err = randomToken()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		return "", "", err
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	p.mu.Lock()
+	now := p.now()
+	// Logins all last LoginTTL, so the expired ones are the oldest
+	for front := p.started.Front(); front != nil; front = p.started.Front() {
+		login := front.Value.(pendingLogin)
+		if !now.After(login.expiresAt) {
+			break
+		}
+		p.started.Remove(front)
+		delete(p.pending, login.state)
+	}
+	if len(p.pending) >= maxPendingLogins {
+		p.mu.Unlock()
+		return "", "", ErrTooManyLogins
+	}
+	login := pendingLogin{state: state, verifier: verifier, nonce: nonce, expiresAt: now.Add(LoginTTL)}
+	p.pending[state] = p.started.PushBack(login)
+	p.mu.Unlock()
+
+	return p.oauth2.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier), gooidc.Nonce(nonce)), state, nil
+}
+
+// SecureCallback reports whether the callback is served over HTTPS, so cookies for it can be Secure
+func (p *Provider) SecureCallback() bool {
+	return strings.HasPrefix(p.oauth2.RedirectURL, "https://")
+}
+
+// Exchange completes the login started with state, redeeming code and verifying the ID token.
+// Each state can be used once.
+func (p *Provider) Exchange(ctx context.Context, state, code string) (Identity, error) {
+	p.mu.Lock()
+	element, ok := p.pending[state]
+	var login pendingLogin
+	if ok {
+		login = p.started.Remove(element).(pendingLogin)
+		delete(p.pending, state)
+	}
+	p.mu.Unlock()
+	if !ok || p.now().After(login.expiresAt) {
+		return Identity{}, ErrInvalidState
+	}
+
+	token, err := p.oauth2.Exchange(ctx, code, oauth2.VerifierOption(login.verifier))
+	if err != nil {
+		return Identity{}, err
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return Identity{}, ErrMissingToken
+	}
+
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return Identity{}, err
+	}
+	if idToken.Nonce != login.nonce {
+		return Identity{}, ErrInvalidNonce
+	}
+
+	var claims struct {
+		Name      string `json:"name"`
+		Email     string `json:"email"`
+		Birthdate string `json:"birthdate"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return Identity{}, err
+	}
+
+	return Identity{
+		Issuer:    idToken.Issuer,
+		Subject:   idToken.Subject,
+		Name:      claims.Name,
+		Email:     claims.Email,
+		Birthdate: claims.Birthdate,
+	}, nil
+}
+
+// randomToken returns 32 random bytes, base64url encoded
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
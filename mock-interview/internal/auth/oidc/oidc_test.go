@@ -0,0 +1,274 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testClientID = "movies-web"
+
+// fakeIDP is an in-process OIDC provider serving discovery, JWKS and a token endpoint that checks PKCE
+type fakeIDP struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]authorizedCode
+}
+
+// authorizedCode is what the provider remembers for an issued authorization code
+type authorizedCode struct {
+	challenge string
+	nonce     string
+	subject   string
+}
+
+func newFakeIDP(t *testing.T) *fakeIDP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &fakeIDP{key: key, codes: make(map[string]authorizedCode)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{
+			"issuer":                                idp.server.URL,
+			"authorization_endpoint":                idp.server.URL + "/authorize",
+			"token_endpoint":                        idp.server.URL + "/token",
+			"jwks_uri":                              idp.server.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test",
+				"alg": "RS256",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", idp.token)
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+// authorize stands in for the user approving the login at authURL and returns the callback's state and code
+func (idp *fakeIDP) authorize(t *testing.T, authURL, subject string) (state, code string) {
+	t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		t.Fatalf("auth URL has no S256 PKCE challenge: %s", authURL)
+	}
+	if q.Get("client_id") != testClientID {
+		t.Fatalf("client_id = %q, want %q", q.Get("client_id"), testClientID)
+	}
+
+	code = "code-" + subject
+	idp.mu.Lock()
+	idp.codes[code] = authorizedCode{challenge: q.Get("code_challenge"), nonce: q.Get("nonce"), subject: subject}
+	idp.mu.Unlock()
+	return q.Get("state"), code
+}
+
+func (idp *fakeIDP) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	idp.mu.Lock()
+	authorized, ok := idp.codes[r.PostForm.Get("code")]
+	delete(idp.codes, r.PostForm.Get("code"))
+	idp.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != authorized.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":       idp.server.URL,
+		"aud":       testClientID,
+		"sub":       authorized.subject,
+		"nonce":     authorized.nonce,
+		"iat":       now.Unix(),
+		"exp":       now.Add(time.Hour).Unix(),
+		"name":      "Ada Lovelace",
+		"email":     "ada@example.com",
+		"birthdate": "1990-12-10",
+	})
+	idToken.Header["kid"] = "test"
+	signed, err := idToken.SignedString(idp.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"access_token": "idp-access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     signed,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func newTestProvider(t *testing.T, idp *fakeIDP) *Provider {
+	t.Helper()
+	provider, err := NewProvider(context.Background(), Config{
+		IssuerURL:   idp.server.URL,
+		ClientID:    testClientID,
+		RedirectURL: "http://localhost:8080/auth/oidc/callback",
+		Scopes:      []string{"profile", "email"},
+	})
+	if err != nil {
+		t.Fatalf("NewProvider: %v", err)
+	}
+	return provider
+}
+
+func TestLoginReturnsVerifiedIdentity(t *testing.T) {
+	idp := newFakeIDP(t)
+	provider := newTestProvider(t, idp)
+
+	authURL, started, err := provider.AuthCodeURL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, code := idp.authorize(t, authURL, "user-123")
+	if state != started {
+		t.Errorf("callback state %q, want the started login's %q", state, started)
+	}
+
+	identity, err := provider.Exchange(context.Background(), state, code)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	want := Identity{
+		Issuer:    idp.server.URL,
+		Subject:   "user-123",
+		Name:      "Ada Lovelace",
+		Email:     "ada@example.com",
+		Birthdate: "1990-12-10",
+	}
+	if identity != want {
+		t.Errorf("identity = %+v, want %+v", identity, want)
+	}
+
+	// States are single use
+	if _, err := provider.Exchange(context.Background(), state, code); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("reused state: err = %v, want ErrInvalidState", err)
+	}
+}
+
+func TestExchangeRejectsUnknownState(t *testing.T) {
+	idp := newFakeIDP(t)
+	provider := newTestProvider(t, idp)
+
+	authURL, _, err := provider.AuthCodeURL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, code := idp.authorize(t, authURL, "user-123")
+
+	if _, err := provider.Exchange(context.Background(), "forged-state", code); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("err = %v, want ErrInvalidState", err)
+	}
+}
+
+func TestExchangeRejectsMismatchedPKCEVerifier(t *testing.T) {
+	idp := newFakeIDP(t)
+	provider := newTestProvider(t, idp)
+
+	// Two logins: the code issued for the first is redeemed with the second's verifier
+	firstURL, _, err := provider.AuthCodeURL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondURL, _, err := provider.AuthCodeURL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, code := idp.authorize(t, firstURL, "user-123")
+	state, _ := idp.authorize(t, secondURL, "user-456")
+
+	if _, err := provider.Exchange(context.Background(), state, code); err == nil {
+		t.Error("Exchange succeeded with a mismatched code verifier")
+	}
+}
+
+func TestPendingLoginsAreCappedAndExpire(t *testing.T) {
+	idp := newFakeIDP(t)
+	provider := newTestProvider(t, idp)
+	now := time.Now()
+	provider.now = func() time.Time { return now }
+
+	for i := 0; i < maxPendingLogins; i++ {
+		if _, _, err := provider.AuthCodeURL(); err != nil {
+			t.Fatalf("login %d: %v", i, err)
+		}
+	}
+	if _, _, err := provider.AuthCodeURL(); !errors.Is(err, ErrTooManyLogins) {
+		t.Fatalf("login past the cap: err = %v, want ErrTooManyLogins", err)
+	}
+
+	// Completing a login frees its place, even when the provider rejects it
+	now = now.Add(LoginTTL / 2)
+	provider.mu.Lock()
+	oldest := provider.started.Front().Value.(pendingLogin).state
+	provider.mu.Unlock()
+	if _, err := provider.Exchange(context.Background(), oldest, "no-such-code"); err == nil || errors.Is(err, ErrInvalidState) {
+		t.Fatalf("Exchange of a pending login: err = %v, want the provider to reject the code", err)
+	}
+	authURL, _, err := provider.AuthCodeURL()
+	if err != nil {
+		t.Fatalf("login after one completed: %v", err)
+	}
+
+	// Once the first logins expire, they're dropped and new ones can start
+	now = now.Add(LoginTTL/2 + time.Second)
+	if _, _, err := provider.AuthCodeURL(); err != nil {
+		t.Fatalf("login after the first ones expired: %v", err)
+	}
+	provider.mu.Lock()
+	pending := len(provider.pending)
+	provider.mu.Unlock()
+	if pending != 2 {
+		t.Errorf("%d pending logins, want the 2 started after the first ones expired", pending)
+	}
+	state, code := idp.authorize(t, authURL, "user-123")
+	if _, err := provider.Exchange(context.Background(), state, code); err != nil {
+		t.Errorf("Exchange of a login that hasn't expired: %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"errors"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	minPINLength = 4
+	maxPINLength = 8
+)
+
+// ValidatePIN checks a profile PIN is 4 to 8 digits
+func ValidatePIN(pin string) error {
+	if len(pin) < minPINLength || len(pin) > maxPINLength {
+		return errors.New("pin must be 4 to 8 digits")
+	}
+	for _, c := range pin {
+		if c < '0' || c > '9' {
+			return errors.New("pin must be 4 to 8 digits")
+		}
+	}
+	return nil
+}
+
+// HashPIN creates a bcrypt hash of a profile PIN for storage.
+// PINs are low-entropy, so unlike API keys they get a slow, salted hash.
+func HashPIN(pin string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(pin), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// VerifyPIN compares a plain PIN with a stored hash
+func VerifyPIN(pin, storedHash string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(storedHash), []byte(pin)) == nil
+}
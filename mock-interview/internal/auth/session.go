@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// RefreshTokenPrefix starts every refresh token so it can't be mistaken for an API key
+	RefreshTokenPrefix = "mvr_"
+	// DefaultRefreshTokenTTL is how long an unused refresh token stays valid
+	DefaultRefreshTokenTTL = 30 * 24 * time.Hour
+
+	refreshTokenLength = 43 // ~256 bits of base62
+)
+
+// GeneratedRefreshToken is a newly issued refresh token. Token is returned to the client once; Hash is stored.
+type GeneratedRefreshToken struct {
+	Token string
+	Hash  string
+}
+
+// GenerateRefreshToken generates an opaque refresh token from crypto/rand
+func GenerateRefreshToken() (GeneratedRefreshToken, error) {
+	secret, err := randomBase62(refreshTokenLength)
+	if err != nil {
+		return GeneratedRefreshToken{}, err
+	}
+	token := RefreshTokenPrefix + secret
+	return GeneratedRefreshToken{Token: token, Hash: HashRefreshToken(token)}, nil
+}
+
+// HashRefreshToken creates the SHA-256 hash a refresh token is stored and looked up by
+func HashRefreshToken(token string) string {
+	return HashAPIKey(token)
+}
+
+// KeyRevocations remembers API keys that were rotated or revoked, so access tokens exchanged
+// from them stop working before they expire. Entries are only needed for one access token
+// lifetime and are kept in memory per instance: other instances accept the tokens until they
+// expire. Tokens of OIDC logins have no key and aren't covered.
+type KeyRevocations struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	revoked map[string]time.Time // key ID -> when the entry can be forgotten
+	now     func() time.Time
+}
+
+// NewKeyRevocations creates a revocation list for access tokens valid for ttl
+func NewKeyRevocations(ttl time.Duration) *KeyRevocations {
+	return &KeyRevocations{
+		ttl:     ttl,
+		revoked: make(map[string]time.Time),
+		now:     time.Now,
+	}
+}
+
+// Revoke rejects access tokens exchanged from keyID from now on
+func (k *KeyRevocations) Revoke(keyID string) {
+	if keyID == "" {
+		return
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	now := k.now()
+	for id, forgetAt := range k.revoked {
+		if now.After(forgetAt) {
+			delete(k.revoked, id)
+		}
+	}
+	k.revoked[keyID] = now.Add(k.ttl)
+}
+
+// IsRevoked reports whether keyID has been revoked
+func (k *KeyRevocations) IsRevoked(keyID string) bool {
+	if keyID == "" {
+		return false
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	_, ok := k.revoked[keyID]
+	return ok
+}
@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignRequestIsVerifiedAndTamperingIsNot(t *testing.T) {
+	secrets, err := NewSigningSecrets(bytes.Repeat([]byte("s"), MinTokenSigningKeyLength))
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := secrets.For(HashAPIKey("mvk_live_AbCdEfGhIjKl_secret"))
+
+	req := httptest.NewRequest("POST", "/v1/users/42/movies?country=US", strings.NewReader(`{"movie_id": "1"}`))
+	if err := SignRequest(req, "mvk_live_AbCdEfGhIjKl", secret, time.Unix(1735689600, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if req.Header.Get(SignatureKeyIDHeader) != "mvk_live_AbCdEfGhIjKl" || req.Header.Get(SignatureTimestampHeader) != "1735689600" {
+		t.Errorf("headers = %v", req.Header)
+	}
+	body, err := ReadSignedBody(req)
+	if err != nil || string(body) != `{"movie_id": "1"}` {
+		t.Fatalf("body after signing = %q, %v; want it readable again", body, err)
+	}
+
+	signature := req.Header.Get(SignatureHeader)
+	nonce := req.Header.Get(SignatureNonceHeader)
+	tests := []struct {
+		name       string
+		secret     string
+		method     string
+		requestURI string
+		timestamp  int64
+		nonce      string
+		body       string
+		want       bool
+	}{
+		{"as signed", secret, "POST", "/v1/users/42/movies?country=US", 1735689600, nonce, `{"movie_id": "1"}`, true},
+		{"method", secret, "DELETE", "/v1/users/42/movies?country=US", 1735689600, nonce, `{"movie_id": "1"}`, false},
+		{"path", secret, "POST", "/v1/users/43/movies?country=US", 1735689600, nonce, `{"movie_id": "1"}`, false},
+		{"query", secret, "POST", "/v1/users/42/movies?country=GB", 1735689600, nonce, `{"movie_id": "1"}`, false},
+		{"timestamp", secret, "POST", "/v1/users/42/movies?country=US", 1735689601, nonce, `{"movie_id": "1"}`, false},
+		{"nonce", secret, "POST", "/v1/users/42/movies?country=US", 1735689600, nonce + "0", `{"movie_id": "1"}`, false},
+		{"body", secret, "POST", "/v1/users/42/movies?country=US", 1735689600, nonce, `{"movie_id": "2"}`, false},
+		{"key hash as the secret", HashAPIKey("mvk_live_AbCdEfGhIjKl_secret"), "POST", "/v1/users/42/movies?country=US", 1735689600, nonce, `{"movie_id": "1"}`, false},
+	}
+	for _, tt := range tests {
+		signingString := SigningString(tt.method, tt.requestURI, tt.timestamp, tt.nonce, []byte(tt.body))
+		if got := VerifySignature(tt.secret, signingString, signature); got != tt.want {
+			t.Errorf("%s: VerifySignature = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSigningSecretsDependOnTheServerKey(t *testing.T) {
+	if _, err := NewSigningSecrets([]byte("short")); err == nil {
+		t.Error("NewSigningSecrets accepted a short key")
+	}
+	a, _ := NewSigningSecrets(bytes.Repeat([]byte("a"), MinTokenSigningKeyLength))
+	b, _ := NewSigningSecrets(bytes.Repeat([]byte("b"), MinTokenSigningKeyLength))
+	hash := HashAPIKey("mvk_live_AbCdEfGhIjKl_secret")
+	if a.For(hash) == b.For(hash) || a.For(hash) == hash || a.For(hash) != a.For(hash) {
+		t.Errorf("secrets of one hash: %s under a, %s under b", a.For(hash), b.For(hash))
+	}
+	var disabled *SigningSecrets
+	if got := disabled.For(hash); got != "" {
+		t.Errorf("nil SigningSecrets derived %q", got)
+	}
+}
+
+func TestReadSignedBodyRejectsLargeBodies(t *testing.T) {
+	req := httptest.NewRequest("POST", "/", bytes.NewReader(make([]byte, MaxSignedBodyBytes+1)))
+	if _, err := ReadSignedBody(req); err != ErrSignedBodyTooLarge {
+		t.Errorf("ReadSignedBody = %v, want ErrSignedBodyTooLarge", err)
+	}
+}
+
+func TestNonceCacheRejectsReuseWithinTheSkewWindow(t *testing.T) {
+	now := time.Unix(1735689600, 0)
+	cache := NewNonceCache(time.Minute)
+	cache.now = func() time.Time { return now }
+
+	if !cache.Use("key", "n1") {
+		t.Fatal("first use rejected")
+	}
+	if cache.Use("key", "n1") {
+		t.Error("reused nonce accepted")
+	}
+	if !cache.Use("other-key", "n1") {
+		t.Error("another key's nonce rejected")
+	}
+
+	// A timestamp can be up to the skew in the future, so nonces are kept for twice the skew
+	now = now.Add(2 * time.Minute)
+	if cache.Use("key", "n1") {
+		t.Error("nonce accepted again within twice the skew")
+	}
+	now = now.Add(2*time.Minute + time.Second)
+	if !cache.Use("key", "n1") {
+		t.Error("nonce still rejected after it was forgotten")
+	}
+
+	// Sweeps drop forgotten nonces; attempts count towards the next sweep whether they're accepted or not
+	for i := range nonceSweepInterval {
+		cache.Use("key", strconv.Itoa(i))
+	}
+	now = now.Add(5 * time.Minute)
+	for range nonceSweepInterval {
+		cache.Use("key", "recent")
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if len(cache.seen) != 1 {
+		t.Errorf("%d nonces kept after a sweep, want only the recent one", len(cache.seen))
+	}
+}
@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	model "github.com/winfr1th/mock-interview/internal/models"
+)
+
+const (
+	// TokenIssuer is the iss and aud claim of access tokens issued by this API
+	TokenIssuer = "movies-api"
+	// DefaultAccessTokenTTL is how long an access token is valid
+	DefaultAccessTokenTTL = 15 * time.Minute
+	// MaxAccessTokenTTL bounds how long a token's snapshot of its user can be out of date
+	MaxAccessTokenTTL = time.Hour
+	// MinTokenSigningKeyLength is the shortest accepted HS256 signing key, in bytes
+	MinTokenSigningKeyLength = 32
+)
+
+// Authentication methods recorded in access tokens
+const (
+	MethodOIDC   = "oidc"
+	MethodAPIKey = "api_key"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid access token")
+	ErrTokenExpired = errors.New("access token expired")
+)
+
+// AccessToken is a newly issued access token
+type AccessToken struct {
+	Token     string
+	ExpiresAt time.Time
+}
+
+// AccessTokenClaims are the verified claims of an access token.
+// User is the snapshot taken when the token was issued, so requests don't need a database lookup.
+// Changes to the user's admin flag, date of birth, household or plan, and the user's deletion,
+// only reach tokens issued after them; the token's TTL is how long they can go unnoticed.
+type AccessTokenClaims struct {
+	User      model.User
+	Method    string
+	ExpiresAt time.Time
+}
+
+type accessTokenClaims struct {
+	jwt.RegisteredClaims
+	Method      string     `json:"auth_method"`
+	KeyID       string     `json:"kid,omitempty"` // API key the session was exchanged from
+	HouseholdID uuid.UUID  `json:"hid"`
+	Name        string     `json:"name"`
+	DateOfBirth model.Date `json:"dob"`
+	HomeCountry *string    `json:"ctry,omitempty"`
+	IsAdmin     bool       `json:"adm,omitempty"`
+	Plan        string     `json:"plan,omitempty"`
+}
+
+// Tokens issues and verifies short-lived HS256 JWT access tokens
+type Tokens struct {
+	key []byte
+	ttl time.Duration
+	now func() time.Time
+}
+
+// NewTokens creates a token issuer signing with key; ttl <= 0 uses DefaultAccessTokenTTL, and
+// ttl can't exceed MaxAccessTokenTTL
+func NewTokens(key []byte, ttl time.Duration) (*Tokens, error) {
+	if len(key) < MinTokenSigningKeyLength {
+		return nil, errors.New("token signing key must be at least 32 bytes")
+	}
+	if ttl > MaxAccessTokenTTL {
+		return nil, errors.New("access token TTL must be at most " + MaxAccessTokenTTL.String())
+	}
+	if ttl <= 0 {
+		ttl = DefaultAccessTokenTTL
+	}
+	return &Tokens{key: key, ttl: ttl, now: time.Now}, nil
+}
+
+// TTL is how long issued access tokens are valid
+func (t *Tokens) TTL() time.Duration {
+	return t.ttl
+}
+
+// Issue signs an access token carrying a snapshot of the user.
+// Sessions exchanged from an API key record its key ID so rotating the key can revoke them.
+func (t *Tokens) Issue(user model.User, method string) (AccessToken, error) {
+	now := t.now()
+	expiresAt := now.Add(t.ttl)
+	claims := accessTokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    TokenIssuer,
+			Audience:  jwt.ClaimStrings{TokenIssuer},
+			Subject:   user.ID.String(),
+			ID:        uuid.NewString(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+		Method:      method,
+		HouseholdID: user.HouseholdID,
+		Name:        user.Name,
+		DateOfBirth: user.DateOfBirth,
+		HomeCountry: user.HomeCountry,
+		IsAdmin:     user.IsAdmin,
+		Plan:        user.Plan,
+	}
+	if method == MethodAPIKey {
+		claims.KeyID = user.APIKeyID
+	}
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(t.key)
+	if err != nil {
+		return AccessToken{}, err
+	}
+	return AccessToken{Token: signed, ExpiresAt: expiresAt}, nil
+}
+
+// Verify checks an access token's signature, issuer, audience and expiry and returns its claims
+func (t *Tokens) Verify(token string) (AccessTokenClaims, error) {
+	var claims accessTokenClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (interface{}, error) {
+		return t.key, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(TokenIssuer),
+		jwt.WithAudience(TokenIssuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(t.now),
+	)
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return AccessTokenClaims{}, ErrTokenExpired
+		}
+		return AccessTokenClaims{}, ErrInvalidToken
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return AccessTokenClaims{}, ErrInvalidToken
+	}
+	return AccessTokenClaims{
+		User: model.User{
+			ID:          userID,
+			HouseholdID: claims.HouseholdID,
+			Name:        claims.Name,
+			DateOfBirth: claims.DateOfBirth,
+			HomeCountry: claims.HomeCountry,
+			IsAdmin:     claims.IsAdmin,
+			Plan:        claims.Plan,
+			APIKeyID:    claims.KeyID,
+		},
+		Method:    claims.Method,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}, nil
+}
+
+// LooksLikeJWT reports whether s has the three dot-separated parts of a compact JWT.
+// API keys never contain dots.
+func LooksLikeJWT(s string) bool {
+	return strings.Count(s, ".") == 2
+}
@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	model "github.com/winfr1th/mock-interview/internal/models"
+)
+
+func newTestTokens(t *testing.T, now *time.Time) *Tokens {
+	t.Helper()
+	tokens, err := NewTokens(bytes.Repeat([]byte("k"), MinTokenSigningKeyLength), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens.now = func() time.Time { return *now }
+	return tokens
+}
+
+func TestAccessTokensCarryTheUserUntilTheyExpire(t *testing.T) {
+	now := time.Now()
+	tokens := newTestTokens(t, &now)
+	country := "GB"
+	user := model.User{ID: uuid.New(), HouseholdID: uuid.New(), Name: "Ada", HomeCountry: &country,
+		IsAdmin: true, Plan: "pro", APIKeyID: "mvk_live_AbCdEfGhIjKl"}
+
+	token, err := tokens.Issue(user, MethodAPIKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := tokens.Verify(token.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := claims.User
+	if got.ID != user.ID || got.HouseholdID != user.HouseholdID || !got.IsAdmin || got.Plan != "pro" ||
+		got.APIKeyID != user.APIKeyID || got.HomeCountry == nil || *got.HomeCountry != "GB" || claims.Method != MethodAPIKey {
+		t.Errorf("claims = %+v, want the issued user", claims)
+	}
+
+	// Only sessions exchanged from a key are tied to it
+	oidcToken, _ := tokens.Issue(user, MethodOIDC)
+	if claims, err := tokens.Verify(oidcToken.Token); err != nil || claims.User.APIKeyID != "" {
+		t.Errorf("OIDC token: key ID %q, err %v; want no key", claims.User.APIKeyID, err)
+	}
+
+	now = now.Add(time.Minute + time.Second)
+	if _, err := tokens.Verify(token.Token); err != ErrTokenExpired {
+		t.Errorf("Verify after the TTL = %v, want ErrTokenExpired", err)
+	}
+}
+
+func TestTamperedOrForeignAccessTokensAreInvalid(t *testing.T) {
+	now := time.Now()
+	tokens := newTestTokens(t, &now)
+	token, err := tokens.Issue(model.User{ID: uuid.New()}, MethodAPIKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, _ := NewTokens(bytes.Repeat([]byte("o"), MinTokenSigningKeyLength), time.Minute)
+	foreign, _ := other.Issue(model.User{ID: uuid.New()}, MethodAPIKey)
+	tampered := []byte(token.Token)
+	tampered[len(tampered)-2] ^= 1
+	for name, token := range map[string]string{
+		"other key": foreign.Token,
+		"tampered":  string(tampered),
+		"not a JWT": "mvk_live_AbCdEfGhIjKl",
+	} {
+		if _, err := tokens.Verify(token); err != ErrInvalidToken {
+			t.Errorf("%s: Verify = %v, want ErrInvalidToken", name, err)
+		}
+	}
+}
+
+func TestAccessTokenTTLIsCapped(t *testing.T) {
+	key := bytes.Repeat([]byte("k"), MinTokenSigningKeyLength)
+	if _, err := NewTokens(key, MaxAccessTokenTTL+time.Second); err == nil {
+		t.Error("NewTokens accepted a TTL above MaxAccessTokenTTL")
+	}
+	if tokens, err := NewTokens(key, 0); err != nil || tokens.TTL() != DefaultAccessTokenTTL {
+		t.Errorf("NewTokens with no TTL = %v, %v; want DefaultAccessTokenTTL", tokens, err)
+	}
+	if _, err := NewTokens(key[:MinTokenSigningKeyLength-1], time.Minute); err == nil {
+		t.Error("NewTokens accepted a short key")
+	}
+}
+
+func TestKeyRevocationsLastOneTokenLifetime(t *testing.T) {
+	now := time.Now()
+	revocations := NewKeyRevocations(time.Minute)
+	revocations.now = func() time.Time { return now }
+
+	revocations.Revoke("mvk_live_AbCdEfGhIjKl")
+	revocations.Revoke("")
+	if !revocations.IsRevoked("mvk_live_AbCdEfGhIjKl") || revocations.IsRevoked("") {
+		t.Error("revoked key not reported, or the empty key reported")
+	}
+
+	// Entries are forgotten once every token they cover has expired
+	now = now.Add(2 * time.Minute)
+	revocations.Revoke("mvk_live_ZZZZZZZZZZZZ")
+	if revocations.IsRevoked("mvk_live_AbCdEfGhIjKl") {
+		t.Error("revocation kept after the token lifetime")
+	}
+}
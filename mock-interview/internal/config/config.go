@@ -0,0 +1,342 @@
+// Package config is the service's typed configuration. It is loaded once at startup from
+// defaults, an optional YAML file, environment variables and command-line flags, each
+// overriding the one before, and validated before anything else starts.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/winfr1th/mock-interview/internal/auth"
+	"github.com/winfr1th/mock-interview/internal/country"
+	"github.com/winfr1th/mock-interview/internal/database"
+	"github.com/winfr1th/mock-interview/internal/handler"
+	"github.com/winfr1th/mock-interview/internal/middleware"
+	"github.com/winfr1th/mock-interview/internal/ratelimit"
+	"github.com/winfr1th/mock-interview/internal/server"
+	"github.com/winfr1th/mock-interview/internal/validate"
+)
+
+// Config is the whole configuration. Each field's yaml tag is its key in the file and, with
+// dots between sections and - for _, its flag. The env tag names its environment variable.
+type Config struct {
+	HTTP       HTTP       `yaml:"http"`
+	Database   Database   `yaml:"database"`
+	Log        Log        `yaml:"log"`
+	Tracing    Tracing    `yaml:"tracing"`
+	Pagination Pagination `yaml:"pagination"`
+	CORS       CORS       `yaml:"cors"`
+	RateLimit  RateLimit  `yaml:"rate_limit"`
+	Auth       Auth       `yaml:"auth"`
+	OIDC       OIDC       `yaml:"oidc"`
+	Content    Content    `yaml:"content"`
+	Features   Features   `yaml:"features"`
+}
+
+type HTTP struct {
+	Addr                  string        `yaml:"addr" env:"HTTP_ADDR" help:"Address to listen on"`
+	ReadHeaderTimeout     time.Duration `yaml:"read_header_timeout" env:"HTTP_READ_HEADER_TIMEOUT" help:"Time to read request headers"`
+	ReadTimeout           time.Duration `yaml:"read_timeout" env:"HTTP_READ_TIMEOUT" help:"Time to read a whole request"`
+	WriteTimeout          time.Duration `yaml:"write_timeout" env:"HTTP_WRITE_TIMEOUT" help:"Time to write a response"`
+	IdleTimeout           time.Duration `yaml:"idle_timeout" env:"HTTP_IDLE_TIMEOUT" help:"Time a keep-alive connection may idle"`
+	RequestTimeout        time.Duration `yaml:"request_timeout" env:"HTTP_REQUEST_TIMEOUT" help:"Deadline of a request's work, such as its queries; 0 for none"`
+	ShutdownDrainDelay    time.Duration `yaml:"shutdown_drain_delay" env:"SHUTDOWN_DRAIN_DELAY" help:"Time to keep serving after readiness fails on shutdown"`
+	ShutdownTimeout       time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" help:"Time in-flight requests get to finish on shutdown"`
+	ShutdownWorkerTimeout time.Duration `yaml:"shutdown_worker_timeout" env:"SHUTDOWN_WORKER_TIMEOUT" help:"Time background workers then get to return"`
+	TrustProxyHeaders     bool          `yaml:"trust_proxy_headers" env:"TRUST_PROXY_HEADERS" help:"Take the client IP from X-Real-IP and X-Forwarded-For"`
+	UnversionedSunset     Date          `yaml:"unversioned_sunset" env:"UNVERSIONED_SUNSET" help:"Removal date announced for the unversioned paths (YYYY-MM-DD)"`
+}
+
+// Timeouts returns the server timeouts
+func (h HTTP) Timeouts() server.Timeouts {
+	return server.Timeouts{
+		ReadHeader:  h.ReadHeaderTimeout,
+		Read:        h.ReadTimeout,
+		Write:       h.WriteTimeout,
+		Idle:        h.IdleTimeout,
+		DrainDelay:  h.ShutdownDrainDelay,
+		Shutdown:    h.ShutdownTimeout,
+		WorkerDrain: h.ShutdownWorkerTimeout,
+	}
+}
+
+type Database struct {
+	URL               URL           `yaml:"url" env:"DATABASE_URL" help:"PostgreSQL connection URL, or sqlite:<path> for an embedded SQLite database"`
+	MaxConns          int32         `yaml:"max_conns" env:"DATABASE_MAX_CONNS" help:"Maximum open connections"`
+	MinConns          int32         `yaml:"min_conns" env:"DATABASE_MIN_CONNS" help:"Connections kept open when idle"`
+	MaxConnLifetime   time.Duration `yaml:"max_conn_lifetime" env:"DATABASE_MAX_CONN_LIFETIME" help:"Age at which a connection is closed"`
+	MaxConnIdleTime   time.Duration `yaml:"max_conn_idle_time" env:"DATABASE_MAX_CONN_IDLE_TIME" help:"Idle time after which a connection is closed"`
+	HealthCheckPeriod time.Duration `yaml:"health_check_period" env:"DATABASE_HEALTH_CHECK_PERIOD" help:"Interval between checks of idle connections"`
+	StatementTimeout  time.Duration `yaml:"statement_timeout" env:"DATABASE_STATEMENT_TIMEOUT" help:"Longest a statement may run on the server; 0 keeps the server's setting"`
+	RetryAttempts     int           `yaml:"retry_attempts" env:"DATABASE_RETRY_ATTEMPTS" help:"Tries of a read-only query failing transiently; 1 disables retries"`
+	RetryBaseDelay    time.Duration `yaml:"retry_base_delay" env:"DATABASE_RETRY_BASE_DELAY" help:"Longest wait before the first retry; doubles for each later one"`
+	RetryMaxDelay     time.Duration `yaml:"retry_max_delay" env:"DATABASE_RETRY_MAX_DELAY" help:"Longest wait before any retry"`
+}
+
+// Pool returns the connection pool settings
+func (d Database) Pool() database.PoolConfig {
+	return database.PoolConfig{
+		URL:               string(d.URL),
+		MaxConns:          d.MaxConns,
+		MinConns:          d.MinConns,
+		MaxConnLifetime:   d.MaxConnLifetime,
+		MaxConnIdleTime:   d.MaxConnIdleTime,
+		HealthCheckPeriod: d.HealthCheckPeriod,
+		StatementTimeout:  d.StatementTimeout,
+	}
+}
+
+// Retry returns how read-only queries are retried
+func (d Database) Retry() database.RetryPolicy {
+	return database.RetryPolicy{Attempts: d.RetryAttempts, BaseDelay: d.RetryBaseDelay, MaxDelay: d.RetryMaxDelay}
+}
+
+type Log struct {
+	Level slog.Level `yaml:"level" env:"LOG_LEVEL" help:"Lowest level logged: debug, info, warn or error"`
+}
+
+type Tracing struct {
+	Exporter string `yaml:"exporter" env:"OTEL_TRACES_EXPORTER" help:"Span exporter: otlp, stdout or off"`
+}
+
+type Pagination struct {
+	DefaultPageSize int `yaml:"default_page_size" env:"DEFAULT_PAGE_SIZE" help:"page_size of paged endpoints when not given"`
+	MaxPageSize     int `yaml:"max_page_size" env:"MAX_PAGE_SIZE" help:"Largest page_size accepted"`
+}
+
+// CORS lets browser apps on other origins call the API. It is off while AllowedOrigins is empty.
+type CORS struct {
+	AllowedOrigins   []string      `yaml:"allowed_origins" env:"CORS_ALLOWED_ORIGINS" help:"Origins allowed to call the API, or *"`
+	AllowedMethods   []string      `yaml:"allowed_methods" env:"CORS_ALLOWED_METHODS" help:"Methods allowed in cross-origin requests"`
+	AllowedHeaders   []string      `yaml:"allowed_headers" env:"CORS_ALLOWED_HEADERS" help:"Request headers allowed in cross-origin requests"`
+	ExposedHeaders   []string      `yaml:"exposed_headers" env:"CORS_EXPOSED_HEADERS" help:"Response headers readable by cross-origin scripts"`
+	AllowCredentials bool          `yaml:"allow_credentials" env:"CORS_ALLOW_CREDENTIALS" help:"Allow cookies and Authorization in cross-origin requests"`
+	MaxAge           time.Duration `yaml:"max_age" env:"CORS_MAX_AGE" help:"How long browsers may cache a preflight response"`
+}
+
+// Policy returns the CORS middleware's policy
+func (c CORS) Policy() middleware.CORSPolicy {
+	return middleware.CORSPolicy{
+		AllowedOrigins:   c.AllowedOrigins,
+		AllowedMethods:   c.AllowedMethods,
+		AllowedHeaders:   c.AllowedHeaders,
+		ExposedHeaders:   c.ExposedHeaders,
+		AllowCredentials: c.AllowCredentials,
+		MaxAge:           c.MaxAge,
+	}
+}
+
+type RateLimit struct {
+	IPRate        float64 `yaml:"ip_rps" env:"RATE_LIMIT_IP_RPS" help:"Requests per second per client IP on public endpoints"`
+	IPBurst       int     `yaml:"ip_burst" env:"RATE_LIMIT_IP_BURST" help:"Burst per client IP on public endpoints"`
+	ClientIPRate  float64 `yaml:"client_ip_rps" env:"RATE_LIMIT_CLIENT_IP_RPS" help:"Requests per second per client IP on protected endpoints, authenticated or not"`
+	ClientIPBurst int     `yaml:"client_ip_burst" env:"RATE_LIMIT_CLIENT_IP_BURST" help:"Burst per client IP on protected endpoints"`
+	UserRate      float64 `yaml:"user_rps" env:"RATE_LIMIT_USER_RPS" help:"Requests per second per user on protected endpoints"`
+	UserBurst     int     `yaml:"user_burst" env:"RATE_LIMIT_USER_BURST" help:"Burst per user on protected endpoints"`
+	Plans         Plans   `yaml:"plans" env:"RATE_LIMIT_PLANS" help:"Per-user limits of plans, as plan=rps:burst separated by commas"`
+}
+
+// IP returns the limit of public endpoints
+func (r RateLimit) IP() ratelimit.Limit {
+	return ratelimit.Limit{Rate: r.IPRate, Burst: r.IPBurst}
+}
+
+// ClientIP returns the limit of protected endpoints per client IP
+func (r RateLimit) ClientIP() ratelimit.Limit {
+	return ratelimit.Limit{Rate: r.ClientIPRate, Burst: r.ClientIPBurst}
+}
+
+// User returns the limit of protected endpoints per user on no plan of their own
+func (r RateLimit) User() ratelimit.Limit {
+	return ratelimit.Limit{Rate: r.UserRate, Burst: r.UserBurst}
+}
+
+type Auth struct {
+	TokenSigningKey    Secret        `yaml:"token_signing_key" env:"TOKEN_SIGNING_KEY" help:"HS256 key of access tokens; random when empty"`
+	AccessTokenTTL     time.Duration `yaml:"access_token_ttl" env:"ACCESS_TOKEN_TTL" help:"Lifetime of access tokens"`
+	RefreshTokenTTL    time.Duration `yaml:"refresh_token_ttl" env:"REFRESH_TOKEN_TTL" help:"Lifetime of unused refresh tokens"`
+	RequestSigningKey  Secret        `yaml:"request_signing_key" env:"REQUEST_SIGNING_KEY" help:"Key deriving each API key's request signing secret; signed requests are off when empty"`
+	SignatureMaxSkew   time.Duration `yaml:"signature_max_skew" env:"SIGNATURE_MAX_SKEW" help:"How far a signed request's timestamp may be from the server clock"`
+	LegacyAPIKeysUntil Date          `yaml:"legacy_api_keys_until" env:"LEGACY_API_KEYS_UNTIL" help:"Last day legacy UUID API keys are accepted (YYYY-MM-DD); forever when empty"`
+}
+
+// OIDC login is enabled when IssuerURL is set
+type OIDC struct {
+	IssuerURL    string   `yaml:"issuer_url" env:"OIDC_ISSUER_URL" help:"OpenID Connect issuer; empty disables OIDC login"`
+	ClientID     string   `yaml:"client_id" env:"OIDC_CLIENT_ID" help:"OIDC client ID"`
+	ClientSecret Secret   `yaml:"client_secret" env:"OIDC_CLIENT_SECRET" help:"OIDC client secret"`
+	RedirectURL  string   `yaml:"redirect_url" env:"OIDC_REDIRECT_URL" help:"OIDC callback URL registered with the provider"`
+	Scopes       []string `yaml:"scopes" env:"OIDC_SCOPES" help:"Scopes requested besides openid"`
+}
+
+type Content struct {
+	AnonymousViewerAge int    `yaml:"anonymous_viewer_age" env:"ANONYMOUS_VIEWER_AGE" help:"Age assumed for callers without a profile"`
+	CountryHeader      string `yaml:"country_header" env:"COUNTRY_HEADER,allowempty" help:"Header with the client's country from a CDN; empty to ignore"`
+	GeoIPDBPath        string `yaml:"geoip_db_path" env:"GEOIP_DB_PATH" help:"MaxMind country database for clients without the header"`
+}
+
+// Features turn optional parts of the API on and off
+type Features struct {
+	SignedRequests bool `yaml:"signed_requests" env:"FEATURE_SIGNED_REQUESTS" help:"Accept HMAC-signed requests"`
+	APIDocs        bool `yaml:"api_docs" env:"FEATURE_API_DOCS" help:"Serve /openapi.json and /docs"`
+}
+
+// Default returns the configuration used for everything not set elsewhere
+func Default() Config {
+	return Config{
+		HTTP: HTTP{
+			Addr:                  ":8080",
+			ReadHeaderTimeout:     server.DefaultTimeouts.ReadHeader,
+			ReadTimeout:           server.DefaultTimeouts.Read,
+			WriteTimeout:          server.DefaultTimeouts.Write,
+			IdleTimeout:           server.DefaultTimeouts.Idle,
+			RequestTimeout:        25 * time.Second,
+			ShutdownDrainDelay:    server.DefaultTimeouts.DrainDelay,
+			ShutdownTimeout:       server.DefaultTimeouts.Shutdown,
+			ShutdownWorkerTimeout: server.DefaultTimeouts.WorkerDrain,
+		},
+		Database: Database{
+			MaxConns:          10,
+			MaxConnLifetime:   time.Hour,
+			MaxConnIdleTime:   30 * time.Minute,
+			HealthCheckPeriod: time.Minute,
+			StatementTimeout:  30 * time.Second,
+			RetryAttempts:     database.DefaultRetryPolicy.Attempts,
+			RetryBaseDelay:    database.DefaultRetryPolicy.BaseDelay,
+			RetryMaxDelay:     database.DefaultRetryPolicy.MaxDelay,
+		},
+		Log:     Log{Level: slog.LevelInfo},
+		Tracing: Tracing{Exporter: "off"},
+		Pagination: Pagination{
+			DefaultPageSize: validate.DefaultPageSize,
+			MaxPageSize:     validate.MaxPageSize,
+		},
+		CORS: CORS{
+			AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+			AllowedHeaders: []string{
+				"Accept", "Authorization", "Content-Type", "X-API-Key", "X-Request-ID",
+				auth.SignatureHeader, auth.SignatureKeyIDHeader, auth.SignatureTimestampHeader, auth.SignatureNonceHeader,
+			},
+			ExposedHeaders: []string{
+				"X-Request-ID", "Retry-After", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset",
+				"Deprecation", "Sunset", "Link",
+			},
+			MaxAge: 10 * time.Minute,
+		},
+		RateLimit: RateLimit{
+			IPRate:        ratelimit.DefaultIPLimit.Rate,
+			IPBurst:       ratelimit.DefaultIPLimit.Burst,
+			ClientIPRate:  ratelimit.DefaultClientIPLimit.Rate,
+			ClientIPBurst: ratelimit.DefaultClientIPLimit.Burst,
+			UserRate:      ratelimit.DefaultUserLimit.Rate,
+			UserBurst:     ratelimit.DefaultUserLimit.Burst,
+		},
+		Auth: Auth{
+			AccessTokenTTL:   auth.DefaultAccessTokenTTL,
+			RefreshTokenTTL:  auth.DefaultRefreshTokenTTL,
+			SignatureMaxSkew: auth.DefaultSignatureMaxSkew,
+		},
+		OIDC: OIDC{Scopes: []string{"profile", "email"}},
+		Content: Content{
+			AnonymousViewerAge: handler.DefaultAnonymousViewerAge,
+			CountryHeader:      country.DefaultHeader,
+		},
+		Features: Features{SignedRequests: true, APIDocs: true},
+	}
+}
+
+// Validate reports every invalid setting at once
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.HTTP.Addr != "", "http.addr is required")
+	for _, d := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"http.read_header_timeout", c.HTTP.ReadHeaderTimeout},
+		{"http.read_timeout", c.HTTP.ReadTimeout},
+		{"http.write_timeout", c.HTTP.WriteTimeout},
+		{"http.idle_timeout", c.HTTP.IdleTimeout},
+		{"http.shutdown_timeout", c.HTTP.ShutdownTimeout},
+		{"http.shutdown_worker_timeout", c.HTTP.ShutdownWorkerTimeout},
+		{"auth.access_token_ttl", c.Auth.AccessTokenTTL},
+		{"auth.refresh_token_ttl", c.Auth.RefreshTokenTTL},
+		{"auth.signature_max_skew", c.Auth.SignatureMaxSkew},
+	} {
+		check(d.value > 0, "%s must be positive, got %s", d.name, d.value)
+	}
+	check(c.Auth.AccessTokenTTL <= auth.MaxAccessTokenTTL,
+		"auth.access_token_ttl must be at most %s, got %s", auth.MaxAccessTokenTTL, c.Auth.AccessTokenTTL)
+	check(c.HTTP.ShutdownDrainDelay >= 0, "http.shutdown_drain_delay must not be negative")
+	// The handler needs time left to write the error after its work times out
+	check(c.HTTP.RequestTimeout >= 0 && c.HTTP.RequestTimeout < c.HTTP.WriteTimeout,
+		"http.request_timeout must be at least 0 and less than http.write_timeout, got %s", c.HTTP.RequestTimeout)
+
+	check(c.Database.URL != "", "database.url is required (DATABASE_URL)")
+	if c.Database.URL != "" {
+		_, err := url.Parse(string(c.Database.URL))
+		check(err == nil, "database.url is not a URL")
+	}
+	check(c.Database.MaxConns >= 1, "database.max_conns must be at least 1, got %d", c.Database.MaxConns)
+	check(c.Database.MinConns >= 0 && c.Database.MinConns <= c.Database.MaxConns,
+		"database.min_conns must be between 0 and database.max_conns, got %d", c.Database.MinConns)
+	check(c.Database.MaxConnLifetime > 0, "database.max_conn_lifetime must be positive")
+	check(c.Database.MaxConnIdleTime > 0, "database.max_conn_idle_time must be positive")
+	check(c.Database.HealthCheckPeriod > 0, "database.health_check_period must be positive")
+	check(c.Database.StatementTimeout >= 0, "database.statement_timeout must not be negative")
+	check(c.Database.RetryAttempts >= 1, "database.retry_attempts must be at least 1, got %d", c.Database.RetryAttempts)
+	check(c.Database.RetryBaseDelay >= 0 && c.Database.RetryBaseDelay <= c.Database.RetryMaxDelay,
+		"database.retry_base_delay must be between 0 and database.retry_max_delay, got %s", c.Database.RetryBaseDelay)
+
+	check(c.Pagination.MaxPageSize >= 1, "pagination.max_page_size must be at least 1, got %d", c.Pagination.MaxPageSize)
+	check(c.Pagination.DefaultPageSize >= 1 && c.Pagination.DefaultPageSize <= c.Pagination.MaxPageSize,
+		"pagination.default_page_size must be between 1 and pagination.max_page_size, got %d", c.Pagination.DefaultPageSize)
+
+	for _, origin := range c.CORS.AllowedOrigins {
+		if origin == "*" {
+			check(!c.CORS.AllowCredentials, "cors.allowed_origins can't be * with cors.allow_credentials")
+			continue
+		}
+		u, err := url.Parse(origin)
+		check(err == nil && u.Scheme != "" && u.Host != "" && u.Path == "",
+			"cors.allowed_origins: %q is not an origin like https://app.example.com", origin)
+	}
+	check(c.CORS.MaxAge >= 0, "cors.max_age must not be negative")
+
+	check(c.RateLimit.IPRate > 0, "rate_limit.ip_rps must be positive")
+	check(c.RateLimit.IPBurst >= 1, "rate_limit.ip_burst must be at least 1")
+	check(c.RateLimit.ClientIPRate > 0, "rate_limit.client_ip_rps must be positive")
+	check(c.RateLimit.ClientIPBurst >= 1, "rate_limit.client_ip_burst must be at least 1")
+	check(c.RateLimit.UserRate > 0, "rate_limit.user_rps must be positive")
+	check(c.RateLimit.UserBurst >= 1, "rate_limit.user_burst must be at least 1")
+
+	check(c.Auth.TokenSigningKey == "" || len(c.Auth.TokenSigningKey) >= auth.MinTokenSigningKeyLength,
+		"auth.token_signing_key must be at least %d bytes", auth.MinTokenSigningKeyLength)
+	check(c.Auth.RequestSigningKey == "" || len(c.Auth.RequestSigningKey) >= auth.MinTokenSigningKeyLength,
+		"auth.request_signing_key must be at least %d bytes", auth.MinTokenSigningKeyLength)
+
+	if c.OIDC.IssuerURL != "" {
+		check(c.OIDC.ClientID != "", "oidc.client_id is required with oidc.issuer_url")
+		check(c.OIDC.RedirectURL != "", "oidc.redirect_url is required with oidc.issuer_url")
+	}
+
+	check(c.Content.AnonymousViewerAge >= 0, "content.anonymous_viewer_age must not be negative")
+
+	switch strings.ToLower(c.Tracing.Exporter) {
+	case "otlp", "stdout", "console", "off", "none", "":
+	default:
+		check(false, "tracing.exporter must be otlp, stdout or off, got %q", c.Tracing.Exporter)
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,154 @@
+package config_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/winfr1th/mock-interview/internal/config"
+)
+
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		v, ok := vars[name]
+		return v, ok
+	}
+}
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFlagsOverrideEnvironmentOverrideFile(t *testing.T) {
+	path := writeFile(t, `
+http:
+  addr: ":9000"
+  shutdown_timeout: 10s
+database:
+  url: postgres://app@db/mock_interview
+  max_conns: 20
+  min_conns: 5
+pagination:
+  max_page_size: 50
+`)
+	cfg, err := config.Load("test", []string{"-config", path, "-database.min-conns", "2", "-http.trust-proxy-headers"},
+		env(map[string]string{
+			"HTTP_ADDR":          ":9001",
+			"DATABASE_MIN_CONNS": "4",
+			"COUNTRY_HEADER":     "",
+			"OIDC_SCOPES":        "",
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.HTTP.Addr != ":9001" {
+		t.Errorf("addr = %q, want the environment's", cfg.HTTP.Addr)
+	}
+	if cfg.HTTP.ShutdownTimeout != 10*time.Second || cfg.Database.MaxConns != 20 || cfg.Pagination.MaxPageSize != 50 {
+		t.Errorf("file settings not applied: %+v", cfg)
+	}
+	if cfg.Database.MinConns != 2 || !cfg.HTTP.TrustProxyHeaders {
+		t.Errorf("flags not applied last: min_conns %d, trust_proxy_headers %v", cfg.Database.MinConns, cfg.HTTP.TrustProxyHeaders)
+	}
+	if cfg.Content.CountryHeader != "" {
+		t.Errorf("empty COUNTRY_HEADER should turn the header off, got %q", cfg.Content.CountryHeader)
+	}
+	if len(cfg.OIDC.Scopes) != 2 {
+		t.Errorf("empty OIDC_SCOPES should keep the default scopes, got %v", cfg.OIDC.Scopes)
+	}
+	if cfg.RateLimit.IPBurst != config.Default().RateLimit.IPBurst {
+		t.Errorf("unset settings should keep their defaults")
+	}
+}
+
+func TestInvalidSettingsAreAllReported(t *testing.T) {
+	_, err := config.Load("test", []string{"-pagination.default-page-size", "500"}, env(map[string]string{
+		"DATABASE_MAX_CONNS":     "0",
+		"CORS_ALLOWED_ORIGINS":   "*",
+		"CORS_ALLOW_CREDENTIALS": "true",
+		"ACCESS_TOKEN_TTL":       "2h",
+	}))
+	if err == nil {
+		t.Fatal("want an error")
+	}
+	for _, want := range []string{"database.url", "database.max_conns", "pagination.default_page_size", "cors.allowed_origins",
+		"auth.access_token_ttl"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error doesn't mention %s: %v", want, err)
+		}
+	}
+}
+
+func TestUnknownFileKeysAreErrors(t *testing.T) {
+	path := writeFile(t, "database:\n  max_connections: 20\n")
+	_, err := config.Load("test", []string{"-config", path}, env(map[string]string{"DATABASE_URL": "postgres://db/app"}))
+	if err == nil || !strings.Contains(err.Error(), "max_connections") {
+		t.Errorf("err = %v, want the unknown key", err)
+	}
+}
+
+func TestPrintedConfigRedactsSecrets(t *testing.T) {
+	cfg, err := config.Load("test", nil, env(map[string]string{
+		"DATABASE_URL":        "postgres://app:db-password@db/mock_interview",
+		"TOKEN_SIGNING_KEY":   "signing-key-signing-key-signing-key",
+		"REQUEST_SIGNING_KEY": "request-key-request-key-request-key",
+		"OIDC_CLIENT_SECRET":  "client-secret",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := config.Write(&out, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, secret := range []string{"db-password", "signing-key", "request-key", "client-secret"} {
+		if strings.Contains(out.String(), secret) {
+			t.Errorf("%s printed:\n%s", secret, out.String())
+		}
+	}
+	if !strings.Contains(out.String(), "postgres://app:xxxxx@db/mock_interview") {
+		t.Errorf("database URL should keep everything but the password:\n%s", out.String())
+	}
+}
+
+func TestRateLimitPlans(t *testing.T) {
+	path := writeFile(t, "rate_limit:\n  plans: pro=50:200\n")
+	cfg, err := config.Load("test", []string{"-config", path}, env(map[string]string{"DATABASE_URL": "postgres://db/app"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pro := cfg.RateLimit.Plans["pro"]; pro.Rate != 50 || pro.Burst != 200 {
+		t.Errorf("plans from the file = %v", cfg.RateLimit.Plans)
+	}
+
+	cfg, err = config.Load("test", []string{"-config", path}, env(map[string]string{
+		"DATABASE_URL":     "postgres://db/app",
+		"RATE_LIMIT_PLANS": "partner=0.5:10, pro=100:400",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := config.Write(&out, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "plans: partner=0.5:10,pro=100:400") {
+		t.Errorf("plans from the environment should replace the file's:\n%s", out.String())
+	}
+
+	for _, plans := range []string{"pro", "pro=50", "pro=0:10", "pro=5:0", "=5:10"} {
+		if _, err := config.Load("test", nil, env(map[string]string{"DATABASE_URL": "postgres://db/app", "RATE_LIMIT_PLANS": plans})); err == nil {
+			t.Errorf("RATE_LIMIT_PLANS=%s: want an error", plans)
+		}
+	}
+}
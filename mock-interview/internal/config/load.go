@@ -0,0 +1,227 @@
+package config
+
+import (
+	"bytes"
+	"encoding"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv names the configuration file when the -config flag doesn't
+const FileEnv = "CONFIG_FILE"
+
+// setting is one leaf of Config with where it can be set from
+type setting struct {
+	key       string // Dotted YAML path, e.g. http.addr
+	env       string
+	emptyEnv  bool // An empty variable sets the value instead of being ignored
+	help      string
+	value     reflect.Value
+	fieldType reflect.Type
+}
+
+// flagName is the setting's command-line flag, e.g. database.max-conns
+func (s setting) flagName() string {
+	return strings.ReplaceAll(s.key, "_", "-")
+}
+
+// Load builds the configuration from defaults, the YAML file named by -config or CONFIG_FILE,
+// environment variables and then args, and validates it. lookupEnv is usually os.LookupEnv.
+// -h and -help return flag.ErrHelp.
+func Load(name string, args []string, lookupEnv func(string) (string, bool)) (Config, error) {
+	cfg := Default()
+	settings := settingsOf(&cfg)
+
+	// Flags are applied last, so they're collected first and set once the rest is loaded
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	file := fs.String("config", "", "YAML configuration `file` (default $"+FileEnv+")")
+	type flagValue struct {
+		setting setting
+		raw     string
+	}
+	var flagValues []flagValue
+	for _, s := range settings {
+		collect := func(raw string) error {
+			flagValues = append(flagValues, flagValue{s, raw})
+			return nil
+		}
+		if s.fieldType.Kind() == reflect.Bool {
+			fs.BoolFunc(s.flagName(), s.help, collect)
+		} else {
+			fs.Func(s.flagName(), s.help, collect)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+	if fs.NArg() > 0 {
+		return Config{}, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	if *file == "" {
+		*file, _ = lookupEnv(FileEnv)
+	}
+	if *file != "" {
+		if err := loadFile(&cfg, *file); err != nil {
+			return Config{}, err
+		}
+	}
+
+	var errs []error
+	for _, s := range settings {
+		raw, ok := lookupEnv(s.env)
+		if !ok || (raw == "" && !s.emptyEnv) {
+			continue
+		}
+		if err := set(s.value, raw); err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s: %q: %w", s.env, raw, err))
+		}
+	}
+	for _, fv := range flagValues {
+		if err := set(fv.setting.value, fv.raw); err != nil {
+			errs = append(errs, fmt.Errorf("invalid -%s: %q: %w", fv.setting.flagName(), fv.raw, err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return Config{}, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// loadFile decodes a YAML file over cfg. Unknown keys are errors, so typos aren't ignored.
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Write prints cfg as YAML, in the format of the configuration file. Secrets are redacted.
+func Write(w io.Writer, cfg Config) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(cfg); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// Usage lists every flag with its environment variable
+func Usage(name string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Usage of %s:\n  -config file\n    \tYAML configuration file (default $%s)\n", name, FileEnv)
+	for _, s := range settingsOf(&Config{}) {
+		fmt.Fprintf(&b, "  -%s %s\n    \t%s ($%s)\n", s.flagName(), typeName(s.fieldType), s.help, s.env)
+	}
+	return b.String()
+}
+
+// settingsOf lists the leaves of cfg in field order
+func settingsOf(cfg *Config) []setting {
+	var settings []setting
+	var walk func(v reflect.Value, prefix string)
+	walk = func(v reflect.Value, prefix string) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			key := prefix + strings.Split(sf.Tag.Get("yaml"), ",")[0]
+			if _, ok := sf.Tag.Lookup("env"); !ok {
+				walk(v.Field(i), key+".")
+				continue
+			}
+			env, opt, _ := strings.Cut(sf.Tag.Get("env"), ",")
+			settings = append(settings, setting{
+				key:       key,
+				env:       env,
+				emptyEnv:  opt == "allowempty",
+				help:      sf.Tag.Get("help"),
+				value:     v.Field(i),
+				fieldType: sf.Type,
+			})
+		}
+	}
+	walk(reflect.ValueOf(cfg).Elem(), "")
+	return settings
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// set parses raw into v. Lists are separated by commas or spaces.
+func set(v reflect.Value, raw string) error {
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(raw))
+	}
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		items := strings.FieldsFunc(raw, func(r rune) bool { return r == ',' || r == ' ' })
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
+
+// typeName describes a flag's value in Usage
+func typeName(t reflect.Type) string {
+	switch {
+	case t == durationType:
+		return "duration"
+	case t.Kind() == reflect.Bool:
+		return "bool"
+	case t.Kind() == reflect.Slice:
+		return "list"
+	case t.Kind() == reflect.Float64:
+		return "float"
+	case t.Kind() == reflect.Int || t.Kind() == reflect.Int32:
+		return "int"
+	}
+	return "string"
+}
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/winfr1th/mock-interview/internal/ratelimit"
+)
+
+// redacted replaces secrets when the configuration is printed
+const redacted = "[REDACTED]"
+
+// Secret is a setting that must never be printed or logged
+type Secret string
+
+// String keeps the secret out of fmt output
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
+
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Secret) UnmarshalText(text []byte) error {
+	*s = Secret(text)
+	return nil
+}
+
+// URL is a connection URL whose password is redacted when printed
+type URL string
+
+func (u URL) String() string {
+	parsed, err := url.Parse(string(u))
+	if err != nil {
+		return redacted
+	}
+	return parsed.Redacted()
+}
+
+func (u URL) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+func (u *URL) UnmarshalText(text []byte) error {
+	*u = URL(text)
+	return nil
+}
+
+// dateLayout is the format of Date settings
+const dateLayout = "2006-01-02"
+
+// Date is a day, written YYYY-MM-DD. The zero Date means not set.
+type Date struct {
+	time.Time
+}
+
+func (d Date) MarshalText() ([]byte, error) {
+	if d.IsZero() {
+		return nil, nil
+	}
+	return []byte(d.Format(dateLayout)), nil
+}
+
+func (d *Date) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*d = Date{}
+		return nil
+	}
+	t, err := time.Parse(dateLayout, string(text))
+	if err != nil {
+		return err
+	}
+	*d = Date{t}
+	return nil
+}
+
+// Plans are the rate limits of user plans, written plan=rps:burst separated by commas, as in
+// pro=50:200,partner=100:400
+type Plans map[string]ratelimit.Limit
+
+func (p Plans) MarshalText() ([]byte, error) {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	plans := make([]string, len(names))
+	for i, name := range names {
+		limit := p[name]
+		plans[i] = name + "=" + strconv.FormatFloat(limit.Rate, 'f', -1, 64) + ":" + strconv.Itoa(limit.Burst)
+	}
+	return []byte(strings.Join(plans, ",")), nil
+}
+
+func (p *Plans) UnmarshalText(text []byte) error {
+	plans := Plans{}
+	for _, plan := range strings.FieldsFunc(string(text), func(r rune) bool { return r == ',' || r == ' ' }) {
+		name, limit, ok := strings.Cut(plan, "=")
+		rate, burst, ok2 := strings.Cut(limit, ":")
+		if !ok || !ok2 || name == "" {
+			return fmt.Errorf("plan %q is not plan=rps:burst", plan)
+		}
+		r, err := strconv.ParseFloat(rate, 64)
+		if err != nil || r <= 0 {
+			return fmt.Errorf("plan %s: rps must be a positive number", name)
+		}
+		b, err := strconv.Atoi(burst)
+		if err != nil || b < 1 {
+			return fmt.Errorf("plan %s: burst must be at least 1", name)
+		}
+		plans[name] = ratelimit.Limit{Rate: r, Burst: b}
+	}
+	*p = plans
+	return nil
+}
@@ -0,0 +1,132 @@
+package country
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// Source names where the effective country came from
+type Source string
+
+const (
+	SourceQuery          Source = "query"
+	SourceHomeCountry    Source = "home_country"
+	SourceHeader         Source = "header"
+	SourceGeoIP          Source = "geoip"
+	SourceAcceptLanguage Source = "accept_language"
+)
+
+const (
+	// DefaultHeader is the CDN header carrying the client's country
+	DefaultHeader = "CF-IPCountry"
+
+	// SourceResponseHeader reports which source the effective country came from
+	SourceResponseHeader = "X-Country-Source"
+	// CountryResponseHeader reports the effective country
+	CountryResponseHeader = "X-Country"
+)
+
+var (
+	ErrInvalidCode = errors.New("invalid country code: must be ISO-3166-1 alpha-2 format (2 characters)")
+	ErrUnresolved  = errors.New("country could not be determined")
+)
+
+// Normalize upper-cases and validates an ISO-3166-1 alpha-2 code
+func Normalize(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 2 || code[0] < 'A' || code[0] > 'Z' || code[1] < 'A' || code[1] > 'Z' {
+		return "", ErrInvalidCode
+	}
+	return code, nil
+}
+
+// Resolver determines the effective country for a request. Sources are tried in order:
+// the ?country= query parameter, the user's home country, the CDN country header,
+// the GeoIP database and finally the Accept-Language region.
+type Resolver struct {
+	// Header is the request header carrying the client's country (e.g. CF-IPCountry); empty disables it
+	Header string
+	// GeoIP looks up the client IP's country; nil disables it
+	GeoIP GeoIPLookup
+}
+
+// NewResolver creates a resolver reading header and, when geoIP is non-nil, the GeoIP database
+func NewResolver(header string, geoIP GeoIPLookup) *Resolver {
+	return &Resolver{
+		Header: header,
+		GeoIP:  geoIP,
+	}
+}
+
+// Resolve returns the effective country and its source. An invalid ?country= is an error
+// (ErrInvalidCode) rather than falling through; ErrUnresolved means no source had a country.
+func (res *Resolver) Resolve(r *http.Request, homeCountry *string) (string, Source, error) {
+	if param := strings.TrimSpace(r.URL.Query().Get("country")); param != "" {
+		code, err := Normalize(param)
+		if err != nil {
+			return "", "", err
+		}
+		return code, SourceQuery, nil
+	}
+
+	if homeCountry != nil {
+		if code, err := Normalize(*homeCountry); err == nil {
+			return code, SourceHomeCountry, nil
+		}
+	}
+
+	if res.Header != "" {
+		// CDNs send XX for unknown and T1 for Tor. Both are well-formed codes that Normalize
+		// accepts, so they're skipped here to fall through to the next source
+		if code, err := Normalize(r.Header.Get(res.Header)); err == nil && code != "XX" && code != "T1" {
+			return code, SourceHeader, nil
+		}
+	}
+
+	if res.GeoIP != nil {
+		if ip := clientIP(r); ip != nil {
+			if code, err := res.GeoIP.CountryForIP(ip); err == nil {
+				if code, err := Normalize(code); err == nil {
+					return code, SourceGeoIP, nil
+				}
+			}
+		}
+	}
+
+	if code, ok := fromAcceptLanguage(r.Header.Get("Accept-Language")); ok {
+		return code, SourceAcceptLanguage, nil
+	}
+
+	return "", "", ErrUnresolved
+}
+
+// fromAcceptLanguage returns the region of the most preferred language tag that names one,
+// e.g. "pt-BR,en;q=0.8" gives BR. Bare languages ("en") are ignored.
+func fromAcceptLanguage(header string) (string, bool) {
+	if header == "" {
+		return "", false
+	}
+	tags, _, err := language.ParseAcceptLanguage(header)
+	if err != nil {
+		return "", false
+	}
+	for _, tag := range tags {
+		region, confidence := tag.Region()
+		if confidence != language.Exact {
+			continue
+		}
+		if code, err := Normalize(region.String()); err == nil {
+			return code, true
+		}
+	}
+	return "", false
+}
+
+// WriteSourceHeaders reports the effective country and its source on the response
+func WriteSourceHeaders(w http.ResponseWriter, code string, source Source) {
+	w.Header().Set(CountryResponseHeader, code)
+	w.Header().Set(SourceResponseHeader, string(source))
+}
@@ -0,0 +1,105 @@
+package country
+
+import (
+	"errors"
+	"net"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeGeoIP maps client IPs to countries; other IPs aren't found
+type fakeGeoIP map[string]string
+
+func (f fakeGeoIP) CountryForIP(ip net.IP) (string, error) {
+	code, ok := f[ip.String()]
+	if !ok {
+		return "", errors.New("ip not found in GeoIP database")
+	}
+	return code, nil
+}
+
+func TestResolve(t *testing.T) {
+	geoIP := fakeGeoIP{"203.0.113.7": "de", "203.0.113.8": "not a country"}
+	ptr := func(s string) *string { return &s }
+
+	tests := []struct {
+		name           string
+		query          string
+		home           *string
+		header         string
+		remoteIP       string
+		acceptLanguage string
+		want           string
+		source         Source
+		err            error
+	}{
+		{"query over every other source", "?country=gb", ptr("US"), "FR", "203.0.113.7", "pt-BR", "GB", SourceQuery, nil},
+		{"query trimmed", "?country=%20fr%20", nil, "", "", "", "FR", SourceQuery, nil},
+		{"invalid query is an error", "?country=GBR", ptr("US"), "FR", "203.0.113.7", "pt-BR", "", "", ErrInvalidCode},
+		{"query of digits is an error", "?country=12", nil, "", "", "", "", "", ErrInvalidCode},
+		{"home country over header", "", ptr("us"), "FR", "203.0.113.7", "pt-BR", "US", SourceHomeCountry, nil},
+		{"invalid home country falls through", "", ptr("USA"), "FR", "", "", "FR", SourceHeader, nil},
+		{"header over GeoIP", "", nil, "fr", "203.0.113.7", "pt-BR", "FR", SourceHeader, nil},
+		{"CDN unknown XX falls through", "", nil, "XX", "203.0.113.7", "", "DE", SourceGeoIP, nil},
+		{"CDN Tor T1 falls through", "", nil, "T1", "", "pt-BR", "BR", SourceAcceptLanguage, nil},
+		{"invalid header falls through", "", nil, "France", "203.0.113.7", "", "DE", SourceGeoIP, nil},
+		{"GeoIP over Accept-Language", "", nil, "", "203.0.113.7", "pt-BR", "DE", SourceGeoIP, nil},
+		{"IP not in GeoIP", "", nil, "", "198.51.100.1", "pt-BR", "BR", SourceAcceptLanguage, nil},
+		{"invalid GeoIP code falls through", "", nil, "", "203.0.113.8", "pt-BR", "BR", SourceAcceptLanguage, nil},
+		{"Accept-Language by q weight", "", nil, "", "", "en-GB;q=0.5, pt-BR;q=0.9, fr-CA;q=0.7", "BR", SourceAcceptLanguage, nil},
+		{"Accept-Language skips bare languages", "", nil, "", "", "en, de;q=0.9, es-MX;q=0.8", "MX", SourceAcceptLanguage, nil},
+		{"Accept-Language without a region", "", nil, "", "", "en, de;q=0.9", "", "", ErrUnresolved},
+		{"no source", "", nil, "", "", "", "", "", ErrUnresolved},
+	}
+	resolver := NewResolver(DefaultHeader, geoIP)
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/movies"+tt.query, nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		if tt.remoteIP != "" {
+			r.RemoteAddr = tt.remoteIP + ":1234"
+		}
+		if tt.header != "" {
+			r.Header.Set(DefaultHeader, tt.header)
+		}
+		if tt.acceptLanguage != "" {
+			r.Header.Set("Accept-Language", tt.acceptLanguage)
+		}
+
+		code, source, err := resolver.Resolve(r, tt.home)
+		if code != tt.want || source != tt.source || !errors.Is(err, tt.err) {
+			t.Errorf("%s: Resolve = %q, %q, %v, want %q, %q, %v", tt.name, code, source, err, tt.want, tt.source, tt.err)
+		}
+	}
+}
+
+func TestResolveWithoutHeaderOrGeoIP(t *testing.T) {
+	r := httptest.NewRequest("GET", "/movies", nil)
+	r.RemoteAddr = "203.0.113.7:1234"
+	r.Header.Set(DefaultHeader, "FR")
+	r.Header.Set("Accept-Language", "pt-BR")
+
+	code, source, err := NewResolver("", nil).Resolve(r, nil)
+	if code != "BR" || source != SourceAcceptLanguage || err != nil {
+		t.Errorf("Resolve = %q, %q, %v, want BR from Accept-Language", code, source, err)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		code, want string
+		err        error
+	}{
+		{"gb", "GB", nil},
+		{" us ", "US", nil},
+		{"XX", "XX", nil}, // Well-formed; only the CDN header treats it as unknown
+		{"GBR", "", ErrInvalidCode},
+		{"G", "", ErrInvalidCode},
+		{"1A", "", ErrInvalidCode},
+		{"", "", ErrInvalidCode},
+	}
+	for _, tt := range tests {
+		if got, err := Normalize(tt.code); got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("Normalize(%q) = %q, %v, want %q, %v", tt.code, got, err, tt.want, tt.err)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package country
+
+import (
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// GeoIPLookup maps a client IP to an ISO-3166-1 alpha-2 country code
+type GeoIPLookup interface {
+	CountryForIP(ip net.IP) (string, error)
+}
+
+// MaxMindDB reads countries from a local MaxMind-format (GeoIP2/GeoLite2 Country or City) database file
+type MaxMindDB struct {
+	reader *maxminddb.Reader
+}
+
+// OpenMaxMindDB opens the database file at path
+func OpenMaxMindDB(path string) (*MaxMindDB, error) {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &MaxMindDB{reader: reader}, nil
+}
+
+func (db *MaxMindDB) CountryForIP(ip net.IP) (string, error) {
+	var record struct {
+		Country struct {
+			ISOCode string `maxminddb:"iso_code"`
+		} `maxminddb:"country"`
+	}
+	if err := db.reader.Lookup(ip, &record); err != nil {
+		return "", err
+	}
+	if record.Country.ISOCode == "" {
+		return "", errors.New("ip not found in GeoIP database")
+	}
+	return record.Country.ISOCode, nil
+}
+
+// Close releases the database file
+func (db *MaxMindDB) Close() error {
+	return db.reader.Close()
+}
+
+// clientIP returns the IP of the connection's remote address
+func clientIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestStatementName(t *testing.T) {
+	tests := []struct {
+		sql, want string
+	}{
+		{"SELECT id, name FROM users WHERE id = $1", "select users"},
+		{"\n\t\tINSERT INTO saved_movies (profile_id, movie_id) VALUES ($1, $2)", "insert saved_movies"},
+		{"UPDATE users SET name = $1 WHERE id = $2", "update users"},
+		{"DELETE FROM refresh_tokens WHERE family_id = $1", "delete refresh_tokens"},
+		{"select count(*) from genres", "select genres"},
+		{"SELECT 1", "select"},
+		{"BEGIN", "other"},
+	}
+	for _, tt := range tests {
+		if got := StatementName(tt.sql); got != tt.want {
+			t.Errorf("StatementName(%q) = %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want bool
+	}{
+		{"\n\t\tSELECT id FROM users WHERE id = $1", true},
+		{"select count(*) from genres", true},
+		{"SELECT id FROM refresh_tokens WHERE token_hash = $1 FOR UPDATE", false},
+		{"SELECT hash FROM audit_events ORDER BY id DESC LIMIT 1 FOR NO KEY UPDATE", false},
+		{"UPDATE refresh_tokens SET used_at = now() WHERE token_hash = $1 RETURNING id", false},
+		{"INSERT INTO saved_movies (profile_id, movie_id) VALUES ($1, $2)", false},
+		{"WITH deleted AS (DELETE FROM users RETURNING id) SELECT count(*) FROM deleted", false},
+	}
+	for _, tt := range tests {
+		if got := ReadOnly(tt.sql); got != tt.want {
+			t.Errorf("ReadOnly(%q) = %v, want %v", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"serialization failure", &pgconn.PgError{Code: "40001"}, true},
+		{"admin shutdown", fmt.Errorf("query: %w", &pgconn.PgError{Code: "57P01"}), true},
+		{"connection failure", &pgconn.PgError{Code: "08006"}, true},
+		{"dropped connection", io.ErrUnexpectedEOF, true},
+		{"unique violation", &pgconn.PgError{Code: "23505"}, false},
+		{"statement canceled at the deadline", fmt.Errorf("%w: %w", context.DeadlineExceeded, &pgconn.PgError{Code: "57014"}), false},
+		{"no rows", pgx.ErrNoRows, false},
+	}
+	for _, tt := range tests {
+		if got := Retryable(tt.err); got != tt.want {
+			t.Errorf("%s: Retryable = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRetryWaitStopsAtDeadline(t *testing.T) {
+	policy := RetryPolicy{Attempts: 3, BaseDelay: time.Second, MaxDelay: time.Second}
+	ctx, cancel := context.WithTimeout(t.Context(), time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if policy.wait(ctx, 1) && ctx.Err() == nil {
+		t.Error("wait should give up when the deadline comes first")
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("wait took %s past a 1ms deadline", elapsed)
+	}
+}
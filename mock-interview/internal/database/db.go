@@ -0,0 +1,208 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/winfr1th/mock-interview/internal/repository"
+)
+
+// DB is the pool the repositories query. Read-only statements are retried on transient errors
+// as Retry says; anything that may write runs once, since a write whose connection dropped
+// may have been committed. Errors of statements cut off by the context's deadline match
+// context.DeadlineExceeded, however pgx reported them. Statements run with a context from
+// WithTx are part of its transaction and are never retried.
+type DB struct {
+	*pgxpool.Pool
+	Retry RetryPolicy
+}
+
+// NewDB wraps pool
+func NewDB(pool *pgxpool.Pool, retry RetryPolicy) *DB {
+	return &DB{Pool: pool, Retry: retry}
+}
+
+// Dialect is Postgres, for the repositories
+func (db *DB) Dialect() repository.Dialect {
+	return repository.Postgres
+}
+
+var lockingClause = regexp.MustCompile(`(?i)\bfor\s+(update|no\s+key\s+update|share|key\s+share)\b`)
+
+// ReadOnly reports whether sql is a plain SELECT, which can run again without side effects.
+// WITH statements may contain writes, so they are not.
+func ReadOnly(sql string) bool {
+	kind := statementKind.FindStringSubmatch(strings.TrimSpace(sql))
+	return kind != nil && strings.EqualFold(kind[1], "select") && !lockingClause.MatchString(sql)
+}
+
+// txKey is the context key of the transaction WithTx runs its function in
+type txKey struct{}
+
+// WithTx runs fn as one unit of work: everything the repositories do with the context fn is
+// given runs in a single transaction, committed when fn returns nil and rolled back otherwise.
+// Called inside a unit of work, WithTx joins it.
+func (db *DB) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return fn(ctx)
+	}
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return contextError(ctx, err)
+	}
+	defer tx.Rollback(context.WithoutCancel(ctx))
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+	return contextError(ctx, tx.Commit(ctx))
+}
+
+// Begin starts a transaction, or a savepoint of the unit of work ctx is in
+func (db *DB) Begin(ctx context.Context) (pgx.Tx, error) {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return tx.Begin(ctx)
+	}
+	tx, err := db.Pool.Begin(ctx)
+	return tx, contextError(ctx, err)
+}
+
+func (db *DB) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		tag, err := tx.Exec(ctx, sql, args...)
+		return tag, contextError(ctx, err)
+	}
+	tag, err := db.Pool.Exec(ctx, sql, args...)
+	return tag, contextError(ctx, err)
+}
+
+func (db *DB) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		rows, err := tx.Query(ctx, sql, args...)
+		return rows, contextError(ctx, err)
+	}
+	if !ReadOnly(sql) {
+		rows, err := db.Pool.Query(ctx, sql, args...)
+		return rows, contextError(ctx, err)
+	}
+	for attempt := 1; ; attempt++ {
+		rows, err := db.Pool.Query(ctx, sql, args...)
+		if err == nil {
+			return &retryRows{Rows: rows, db: db, ctx: ctx, sql: sql, args: args, attempt: attempt}, nil
+		}
+		if !db.canRetry(ctx, sql, attempt, err) {
+			return nil, contextError(ctx, err)
+		}
+	}
+}
+
+func (db *DB) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return contextRow{row: tx.QueryRow(ctx, sql, args...), ctx: ctx}
+	}
+	if !ReadOnly(sql) {
+		return contextRow{row: db.Pool.QueryRow(ctx, sql, args...), ctx: ctx}
+	}
+	return retryRow{db: db, ctx: ctx, sql: sql, args: args}
+}
+
+// canRetry logs and waits before attempt+1 when err allows one
+func (db *DB) canRetry(ctx context.Context, sql string, attempt int, err error) bool {
+	if attempt >= db.Retry.Attempts || !Retryable(err) {
+		return false
+	}
+	slog.WarnContext(ctx, "retrying query", "statement", StatementName(sql), "attempt", attempt+1, "error", err)
+	return db.Retry.wait(ctx, attempt)
+}
+
+// retryRows runs the query again when it fails before its first row reached the caller.
+// Once a row has been read, a failure is returned: the caller may already have used it.
+type retryRows struct {
+	pgx.Rows
+	db       *DB
+	ctx      context.Context
+	sql      string
+	args     []any
+	attempt  int // Of the query being read
+	started  bool
+	queryErr error
+}
+
+func (r *retryRows) Next() bool {
+	if r.queryErr != nil {
+		return false
+	}
+	for {
+		if r.Rows.Next() {
+			r.started = true
+			return true
+		}
+		err := r.Rows.Err()
+		if err == nil || r.started || !r.db.canRetry(r.ctx, r.sql, r.attempt, err) {
+			return false
+		}
+		r.Rows.Close()
+		for {
+			r.attempt++
+			rows, err := r.db.Pool.Query(r.ctx, r.sql, r.args...)
+			if err == nil {
+				r.Rows = rows
+				break
+			}
+			if !r.db.canRetry(r.ctx, r.sql, r.attempt, err) {
+				r.queryErr = err
+				return false
+			}
+		}
+	}
+}
+
+func (r *retryRows) Err() error {
+	if r.queryErr != nil {
+		return contextError(r.ctx, r.queryErr)
+	}
+	return contextError(r.ctx, r.Rows.Err())
+}
+
+// retryRow runs a read-only QueryRow when scanned, as many times as the policy allows
+type retryRow struct {
+	db   *DB
+	ctx  context.Context
+	sql  string
+	args []any
+}
+
+func (r retryRow) Scan(dest ...any) error {
+	for attempt := 1; ; attempt++ {
+		err := r.db.Pool.QueryRow(r.ctx, r.sql, r.args...).Scan(dest...)
+		if err == nil || !r.db.canRetry(r.ctx, r.sql, attempt, err) {
+			return contextError(r.ctx, err)
+		}
+	}
+}
+
+// contextRow reports deadline errors of a row that isn't retried
+type contextRow struct {
+	row pgx.Row
+	ctx context.Context
+}
+
+func (r contextRow) Scan(dest ...any) error {
+	return contextError(r.ctx, r.row.Scan(dest...))
+}
+
+// contextError makes an error caused by the context ending match the context's error. When the
+// deadline passes, Postgres may report the statement canceled (57014) rather than pgx the deadline.
+func contextError(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil || errors.Is(err, ctx.Err()) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ctx.Err(), err)
+}
@@ -0,0 +1,76 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// RetryPolicy is how read-only statements are retried on transient errors. Attempts counts
+// the first try, so 1 turns retries off. Waits grow exponentially from BaseDelay up to
+// MaxDelay, with full jitter so instances that failed together don't retry together.
+type RetryPolicy struct {
+	Attempts  int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// DefaultRetryPolicy retries twice within about half a second
+var DefaultRetryPolicy = RetryPolicy{Attempts: 3, BaseDelay: 20 * time.Millisecond, MaxDelay: 500 * time.Millisecond}
+
+// Postgres error codes of transient failures
+const (
+	pgSerializationFailure = "40001"
+	pgAdminShutdown        = "57P01"
+	pgConnectionException  = "08" // Class of connection errors, e.g. 08006 connection_failure
+)
+
+// Retryable reports whether err is transient: a serialization failure, a server shutting
+// down or a dropped connection. Only statements without side effects may be run again after
+// one of these, unless pgconn says the statement was never sent.
+func Retryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if pgconn.SafeToRetry(err) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == pgSerializationFailure || pgErr.Code == pgAdminShutdown ||
+			strings.HasPrefix(pgErr.Code, pgConnectionException)
+	}
+	var netErr net.Error
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &netErr)
+}
+
+// wait sleeps before retry number attempt (1 for the first retry). It returns false, without
+// waiting, when the context would end first.
+func (p RetryPolicy) wait(ctx context.Context, attempt int) bool {
+	ceiling := p.BaseDelay << (attempt - 1)
+	if ceiling > p.MaxDelay || ceiling <= 0 {
+		ceiling = p.MaxDelay
+	}
+	var delay time.Duration
+	if ceiling > 0 {
+		delay = rand.N(ceiling)
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+		return false
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,313 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// txKey is the context key of the transaction WithTx runs its function in
+type txKey struct{}
+
+// SQLSTATE codes of the constraint violations the repositories tell apart, as Postgres reports them
+var constraintStates = map[int]string{
+	sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY: "23505",
+	sqlite3.SQLITE_CONSTRAINT_UNIQUE:     "23505",
+	sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY: "23503",
+}
+
+// querier is what *sql.DB and *sql.Tx have in common
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// WithTx runs fn as one unit of work: everything the repositories do with the context fn is
+// given runs in a single transaction, committed when fn returns nil and rolled back otherwise.
+// Called inside a unit of work, WithTx joins it.
+func (db *DB) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return contextError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+	return contextError(ctx, tx.Commit())
+}
+
+func (db *DB) querier(ctx context.Context) querier {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	return db.db
+}
+
+func (db *DB) Exec(ctx context.Context, query string, args ...any) (pgconn.CommandTag, error) {
+	result, err := db.querier(ctx).ExecContext(ctx, query, bindArgs(args)...)
+	if err != nil {
+		return pgconn.CommandTag{}, mapError(ctx, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	command, _, _ := strings.Cut(strings.TrimSpace(query), " ")
+	return pgconn.NewCommandTag(fmt.Sprintf("%s %d", strings.ToUpper(command), affected)), nil
+}
+
+func (db *DB) Query(ctx context.Context, query string, args ...any) (pgx.Rows, error) {
+	sqlRows, err := db.querier(ctx).QueryContext(ctx, query, bindArgs(args)...)
+	if err != nil {
+		return nil, mapError(ctx, err)
+	}
+	return &rows{rows: sqlRows, ctx: ctx}, nil
+}
+
+func (db *DB) QueryRow(ctx context.Context, query string, args ...any) pgx.Row {
+	sqlRows, err := db.querier(ctx).QueryContext(ctx, query, bindArgs(args)...)
+	return row{rows: &rows{rows: sqlRows, ctx: ctx}, err: err}
+}
+
+// rows reads a result as pgx.Rows. Values, FieldDescriptions and the rest are there for the
+// interface; the repositories only scan.
+type rows struct {
+	rows *sql.Rows
+	ctx  context.Context
+	err  error // Of the last Scan
+}
+
+func (r *rows) Close() {
+	r.rows.Close()
+}
+
+func (r *rows) Err() error {
+	if r.err != nil {
+		return r.err
+	}
+	return mapError(r.ctx, r.rows.Err())
+}
+
+func (r *rows) CommandTag() pgconn.CommandTag {
+	return pgconn.CommandTag{}
+}
+
+func (r *rows) FieldDescriptions() []pgconn.FieldDescription {
+	columns, _ := r.rows.Columns()
+	fields := make([]pgconn.FieldDescription, len(columns))
+	for i, column := range columns {
+		fields[i] = pgconn.FieldDescription{Name: column}
+	}
+	return fields
+}
+
+func (r *rows) Next() bool {
+	return r.err == nil && r.rows.Next()
+}
+
+func (r *rows) Scan(dest ...any) error {
+	if err := r.rows.Scan(scanDests(dest)...); err != nil {
+		r.err = mapError(r.ctx, err)
+		return r.err
+	}
+	return nil
+}
+
+func (r *rows) Values() ([]any, error) {
+	columns, err := r.rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]any, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	return values, r.Scan(dest...)
+}
+
+func (r *rows) RawValues() [][]byte {
+	return nil
+}
+
+func (r *rows) Conn() *pgx.Conn {
+	return nil
+}
+
+// row is the first row of a result, read as pgx.Row: no row is pgx.ErrNoRows
+type row struct {
+	rows *rows
+	err  error // Of the query
+}
+
+func (r row) Scan(dest ...any) error {
+	if r.err != nil {
+		return mapError(r.rows.ctx, r.err)
+	}
+	defer r.rows.Close()
+	if !r.rows.Next() {
+		if err := r.rows.Err(); err != nil {
+			return err
+		}
+		return pgx.ErrNoRows
+	}
+	return r.rows.Scan(dest...)
+}
+
+// bindArgs converts the arguments pgx would encode itself: times are stored in UTC so their text
+// sorts in time order, and maps are stored as JSON.
+func bindArgs(args []any) []any {
+	bound := make([]any, len(args))
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case time.Time:
+			bound[i] = v.UTC()
+		case *time.Time:
+			if v != nil {
+				bound[i] = v.UTC()
+			}
+		case map[string]string:
+			data, err := json.Marshal(v)
+			if err != nil {
+				bound[i] = arg // database/sql reports the unsupported type
+				continue
+			}
+			bound[i] = string(data)
+		default:
+			bound[i] = arg
+		}
+	}
+	return bound
+}
+
+// scanDests wraps the destinations database/sql can't scan into: maps decode JSON, and times
+// accept the text of expressions SQLite doesn't know to be times.
+func scanDests(dest []any) []any {
+	wrapped := make([]any, len(dest))
+	for i, d := range dest {
+		switch v := d.(type) {
+		case *map[string]string:
+			wrapped[i] = jsonDest{v}
+		case *time.Time:
+			wrapped[i] = timeDest{v}
+		case **time.Time:
+			wrapped[i] = nullTimeDest{v}
+		default:
+			wrapped[i] = d
+		}
+	}
+	return wrapped
+}
+
+type jsonDest struct {
+	dest *map[string]string
+}
+
+func (d jsonDest) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*d.dest = nil
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), d.dest)
+	case []byte:
+		return json.Unmarshal(v, d.dest)
+	default:
+		return fmt.Errorf("cannot scan %T into a map", src)
+	}
+}
+
+type timeDest struct {
+	dest *time.Time
+}
+
+func (d timeDest) Scan(src any) error {
+	switch v := src.(type) {
+	case time.Time:
+		*d.dest = v
+		return nil
+	case string:
+		t, err := parseTime(v)
+		if err != nil {
+			return err
+		}
+		*d.dest = t
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into a time", src)
+	}
+}
+
+type nullTimeDest struct {
+	dest **time.Time
+}
+
+func (d nullTimeDest) Scan(src any) error {
+	if src == nil {
+		*d.dest = nil
+		return nil
+	}
+	var t time.Time
+	if err := (timeDest{&t}).Scan(src); err != nil {
+		return err
+	}
+	*d.dest = &t
+	return nil
+}
+
+// timeLayouts are the layouts times are stored in: the driver's and strftime's
+var timeLayouts = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+}
+
+func parseTime(s string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse %q as a time", s)
+}
+
+// constraintError is a constraint violation with the SQLSTATE Postgres would report, so the
+// repositories map both backends' violations to the same error kinds
+type constraintError struct {
+	err   *sqlite.Error
+	state string
+}
+
+func (e *constraintError) Error() string    { return e.err.Error() }
+func (e *constraintError) Unwrap() error    { return e.err }
+func (e *constraintError) SQLState() string { return e.state }
+
+// mapError translates SQLite's errors into what the repositories expect of pgx
+func mapError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return pgx.ErrNoRows
+	}
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		if state, ok := constraintStates[sqliteErr.Code()]; ok {
+			return &constraintError{err: sqliteErr, state: state}
+		}
+	}
+	return contextError(ctx, err)
+}
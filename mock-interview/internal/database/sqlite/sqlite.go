@@ -0,0 +1,160 @@
+// Package sqlite runs the repositories on an embedded SQLite database, for demos, kiosks and local
+// development where running Postgres isn't worth it. DB speaks the repositories' Executor
+// interface, pgx's types included, so the same repositories serve both backends.
+package sqlite
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/winfr1th/mock-interview/internal/repository"
+	"github.com/winfr1th/mock-interview/migrations"
+	"modernc.org/sqlite"
+)
+
+// Scheme is the DATABASE_URL scheme selecting SQLite, as in sqlite:data/movies.db,
+// sqlite:///var/lib/movies.db or sqlite::memory:
+const Scheme = "sqlite"
+
+// connectionParams are set on every connection: foreign keys are enforced as in Postgres, a
+// writer waits for the lock rather than failing, transactions take the write lock when they
+// begin, and times are stored as text that sorts in time order. In-memory databases keep their
+// own journal mode.
+var connectionParams = []string{
+	"_pragma=foreign_keys(1)",
+	"_pragma=busy_timeout(5000)",
+	"_pragma=journal_mode(WAL)",
+	"_txlock=immediate",
+	"_time_format=sqlite",
+}
+
+// sha256_hex is Postgres's encode(sha256(...), 'hex') for the migrations, which SQLite lacks
+func init() {
+	err := sqlite.RegisterDeterministicScalarFunction("sha256_hex", 1,
+		func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+			var data []byte
+			switch v := args[0].(type) {
+			case nil:
+				return nil, nil
+			case string:
+				data = []byte(v)
+			case []byte:
+				data = v
+			default:
+				return nil, fmt.Errorf("sha256_hex: unsupported argument %T", v)
+			}
+			sum := sha256.Sum256(data)
+			return hex.EncodeToString(sum[:]), nil
+		})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// IsURL reports whether url selects SQLite
+func IsURL(url string) bool {
+	return strings.HasPrefix(url, Scheme+":")
+}
+
+// DB is an SQLite database. It has a single connection, so statements and units of work run
+// one at a time, which also keeps an in-memory database alive and shared.
+type DB struct {
+	db *sql.DB
+}
+
+// Open opens the database url names, creating it if it doesn't exist
+func Open(ctx context.Context, url string) (*DB, error) {
+	if !IsURL(url) {
+		return nil, fmt.Errorf("unable to open database: %q is not a %s: URL", url, Scheme)
+	}
+	name := strings.TrimPrefix(strings.TrimPrefix(url, Scheme+":"), "//")
+	separator := "?"
+	if strings.Contains(name, "?") {
+		separator = "&"
+	}
+	db, err := sql.Open("sqlite", name+separator+strings.Join(connectionParams, "&"))
+	if err != nil {
+		return nil, fmt.Errorf("unable to open database: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+	db.SetConnMaxIdleTime(0)
+	db.SetConnMaxLifetime(0)
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("unable to open database: %w", err)
+	}
+	return &DB{db: db}, nil
+}
+
+func (db *DB) Close() error {
+	return db.db.Close()
+}
+
+func (db *DB) Ping(ctx context.Context) error {
+	return db.db.PingContext(ctx)
+}
+
+// Dialect is SQLite, for the repositories
+func (db *DB) Dialect() repository.Dialect {
+	return repository.SQLite
+}
+
+// Migrate applies the SQLite migrations newer than the database's schema, each in a transaction
+func (db *DB) Migrate(ctx context.Context) error {
+	all, err := migrations.SQLite()
+	if err != nil {
+		return err
+	}
+	current, err := db.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	for _, migration := range all {
+		if migration.Version <= current {
+			continue
+		}
+		err := db.WithTx(ctx, func(ctx context.Context) error {
+			_, err := db.Exec(ctx, migration.SQL)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("migration %s: %w", migration.Name, err)
+		}
+	}
+	return nil
+}
+
+// SchemaVersion returns the newest migration version recorded in schema_migrations, or 0 for a
+// database without one
+func (db *DB) SchemaVersion(ctx context.Context) (int, error) {
+	var exists bool
+	err := db.db.QueryRowContext(ctx,
+		`SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations')`).Scan(&exists)
+	if err != nil {
+		return 0, fmt.Errorf("unable to read schema version: %w", err)
+	}
+	if !exists {
+		return 0, nil
+	}
+	var version int
+	if err := db.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("unable to read schema version: %w", err)
+	}
+	return version, nil
+}
+
+// contextError makes an error caused by the context ending match the context's error. SQLite
+// reports a statement interrupted at the deadline as an error of its own.
+func contextError(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil || errors.Is(err, ctx.Err()) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ctx.Err(), err)
+}
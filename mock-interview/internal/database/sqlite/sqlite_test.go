@@ -0,0 +1,149 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/winfr1th/mock-interview/internal/auth"
+	"github.com/winfr1th/mock-interview/internal/repository"
+	"github.com/winfr1th/mock-interview/migrations"
+)
+
+func TestIsURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"sqlite:movies.db", true},
+		{"sqlite:///var/lib/movies.db", true},
+		{"sqlite::memory:", true},
+		{"postgres://localhost/movies", false},
+		{"movies.db", false},
+	}
+	for _, tt := range tests {
+		if got := IsURL(tt.url); got != tt.want {
+			t.Errorf("IsURL(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := t.Context()
+	url := "sqlite://" + filepath.Join(t.TempDir(), "movies.db")
+	db, err := Open(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version, err := db.SchemaVersion(ctx); err != nil || version != 0 {
+		t.Fatalf("SchemaVersion() of a new database = %d, %v, want 0", version, err)
+	}
+	if err := db.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	// Reopened, nothing is pending and the schema is at the version the readiness check wants
+	db, err = Open(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate() again: %v", err)
+	}
+	version, err := db.SchemaVersion(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest := migrations.Latest(); version != latest {
+		t.Errorf("SchemaVersion() = %d, want %d, the latest Postgres migration", version, latest)
+	}
+}
+
+func TestLegacyAPIKeysAreHashed(t *testing.T) {
+	ctx := t.Context()
+	db, err := Open(ctx, "sqlite::memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	all, err := migrations.SQLite()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hashKeys migrations.Migration
+	for _, migration := range all {
+		if migration.Version >= 13 {
+			hashKeys = migration
+			break
+		}
+		if _, err := db.Exec(ctx, migration.SQL); err != nil {
+			t.Fatalf("migration %s: %v", migration.Name, err)
+		}
+	}
+	const household = "550e8400-e29b-41d4-a716-446655440001"
+	if _, err := db.Exec(ctx, `INSERT INTO households (id, name) VALUES ($1, 'Test')`, household); err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(ctx, `INSERT INTO users (id, household_id, name, date_of_birth, api_key_hash)
+		VALUES ($1, $1, 'Test', '1990-01-01', '550e8400-e29b-41d4-a716-446655440000')`, household)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// Running 013 again, as applying every file again would, leaves the hash alone
+	if _, err := db.Exec(ctx, hashKeys.SQL); err != nil {
+		t.Fatalf("migration %s again: %v", hashKeys.Name, err)
+	}
+	var hash string
+	if err := db.QueryRow(ctx, `SELECT api_key_hash FROM users`).Scan(&hash); err != nil {
+		t.Fatal(err)
+	}
+	// The SHA-256 of the key, as auth.HashAPIKey computes it
+	if want := "a3a9e1ed9732cab28868127be00f1ce921acaefdd5c3b23a6e9e0072bd9c1a34"; hash != want {
+		t.Errorf("api_key_hash = %s, want %s", hash, want)
+	}
+	user, err := repository.NewUserRepository(db).FindUserByAPIKeyHash(ctx, auth.HashAPIKey("550e8400-e29b-41d4-a716-446655440000"))
+	if err != nil || user.ID.String() != household {
+		t.Errorf("FindUserByAPIKeyHash = %v, %v; want the legacy key's user", user.ID, err)
+	}
+}
+
+func TestAuditEventsAreAppendOnly(t *testing.T) {
+	ctx := t.Context()
+	db, err := Open(ctx, "sqlite::memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(ctx, `INSERT INTO audit_events (occurred_at, event_type, prev_hash, hash) VALUES (CURRENT_TIMESTAMP, 'test', '', 'h')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(ctx, `UPDATE audit_events SET event_type = 'changed'`); err == nil {
+		t.Error("UPDATE of audit_events succeeded")
+	}
+	if _, err := db.Exec(ctx, `DELETE FROM audit_events`); err == nil {
+		t.Error("DELETE from audit_events succeeded")
+	}
+}
+
+func TestStatementsStopWithTheirContext(t *testing.T) {
+	db, err := Open(t.Context(), "sqlite::memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	if _, err := db.Exec(ctx, `SELECT 1`); !errors.Is(err, context.Canceled) {
+		t.Errorf("Exec() with a canceled context = %v, want context.Canceled", err)
+	}
+}
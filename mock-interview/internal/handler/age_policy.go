@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/winfr1th/mock-interview/internal/middleware"
+)
+
+// DefaultAnonymousViewerAge is the age assumed for callers without an API key
+const DefaultAnonymousViewerAge = 18
+
+const ErrorCodeAgeRestricted = "AGE_RESTRICTED"
+
+// AgePolicy decides which viewer age is used for age-gated content
+type AgePolicy struct {
+	// AnonymousAge applies to unauthenticated callers
+	AnonymousAge int
+}
+
+// ViewerAge returns the authenticated user's age, or the anonymous default,
+// capped by the active profile's content limit
+func (p AgePolicy) ViewerAge(r *http.Request) int {
+	age := p.AnonymousAge
+	if user, ok := middleware.GetUser(r); ok && !user.DateOfBirth.IsZero() {
+		age = user.DateOfBirth.AgeOn(time.Now().UTC())
+	}
+	if profile, ok := middleware.GetProfile(r); ok && profile.MaxAge != nil && *profile.MaxAge < age {
+		age = *profile.MaxAge
+	}
+	return age
+}
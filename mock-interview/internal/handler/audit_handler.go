@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	model "github.com/winfr1th/mock-interview/internal/models"
+	"github.com/winfr1th/mock-interview/internal/repository"
+	"github.com/winfr1th/mock-interview/internal/utils"
+	"github.com/winfr1th/mock-interview/internal/validate"
+)
+
+// AuditPage is a cursor-paged list of audit events
+type AuditPage struct {
+	Data       []model.AuditEvent `json:"data"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
+
+// listAuditEventsRequest is the query of GET /admin/audit.
+// The cursor is the ID of the last event on the previous page.
+type listAuditEventsRequest struct {
+	EventType string     `query:"event_type"`
+	TargetID  string     `query:"target_id"`
+	ActorID   *uuid.UUID `query:"actor_id"`
+	Since     *time.Time `query:"since"`
+	Until     *time.Time `query:"until"`
+	Cursor    int64      `query:"cursor" validate:"min=1" code:"INVALID_CURSOR"`
+	PageSize  int        `query:"page_size" default:"$default_page_size" validate:"min=1,max=$max_page_size" code:"INVALID_PAGE_SIZE,max=PAGE_SIZE_TOO_LARGE"`
+}
+
+// ListAuditEvents handles GET /admin/audit - List audit events newest first with filters and cursor paging
+func ListAuditEvents(repo repository.AuditRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			utils.WriteErrorResponse(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED",
+				"Method not allowed", nil)
+			return
+		}
+
+		var req listAuditEventsRequest
+		if !validate.Bind(w, r, &req) {
+			return
+		}
+		filter := model.AuditFilter{
+			EventType: req.EventType,
+			TargetID:  req.TargetID,
+			ActorID:   req.ActorID,
+			Since:     req.Since,
+			Until:     req.Until,
+		}
+		cursor, pageSize := req.Cursor, req.PageSize
+
+		// Fetch one extra event to know whether there's a next page
+		events, err := repo.ListEvents(r.Context(), filter, cursor, pageSize+1)
+		if err != nil {
+			writeError(w, r, err, "Failed to fetch audit events")
+			return
+		}
+
+		response := AuditPage{Data: events}
+		if len(events) > pageSize {
+			response.Data = events[:pageSize]
+			response.NextCursor = strconv.FormatInt(events[pageSize-1].ID, 10)
+		}
+		if response.Data == nil {
+			response.Data = []model.AuditEvent{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// VerifyAuditChain handles GET /admin/audit/verify - Check the audit hash chain for tampering
+func VerifyAuditChain(repo repository.AuditRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			utils.WriteErrorResponse(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED",
+				"Method not allowed", nil)
+			return
+		}
+
+		result, err := repo.VerifyChain(r.Context())
+		if err != nil {
+			writeError(w, r, err, "Failed to verify audit chain")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/winfr1th/mock-interview/internal/audit"
+	"github.com/winfr1th/mock-interview/internal/lockout"
+	"github.com/winfr1th/mock-interview/internal/middleware"
+	"github.com/winfr1th/mock-interview/internal/utils"
+	"github.com/winfr1th/mock-interview/internal/validate"
+)
+
+// AuthBlockResponse is a subject with failed authentication attempts
+type AuthBlockResponse struct {
+	lockout.Status
+	Blocked bool `json:"blocked"`
+}
+
+// listAuthBlocksRequest is the query of GET /admin/auth-blocks.
+// blocked=true narrows the list to active blocks.
+type listAuthBlocksRequest struct {
+	Blocked bool `query:"blocked"`
+}
+
+// ListAuthBlocks handles GET /admin/auth-blocks - List subjects with recent failed authentication attempts
+func ListAuthBlocks(tracker *lockout.Tracker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			utils.WriteErrorResponse(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED",
+				"Method not allowed", nil)
+			return
+		}
+
+		var req listAuthBlocksRequest
+		if !validate.Bind(w, r, &req) {
+			return
+		}
+
+		now := time.Now()
+		response := []AuthBlockResponse{}
+		for _, status := range tracker.List() {
+			blocked := status.Blocked(now)
+			if req.Blocked && !blocked {
+				continue
+			}
+			response = append(response, AuthBlockResponse{Status: status, Blocked: blocked})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": response})
+	}
+}
+
+// clearAuthBlockRequest is the query of DELETE /admin/auth-blocks
+type clearAuthBlockRequest struct {
+	Kind  lockout.Kind `query:"kind" validate:"required,enum=ip|key_prefix|profile" code:"INVALID_PARAMETER"`
+	Value string       `query:"value" validate:"required" code:"INVALID_PARAMETER"`
+}
+
+// ClearAuthBlock handles DELETE /admin/auth-blocks?kind={ip|key_prefix|profile}&value={value} - Clear a subject's failures and block
+func ClearAuthBlock(tracker *lockout.Tracker, recorder *audit.Recorder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			utils.WriteErrorResponse(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED",
+				"Method not allowed", nil)
+			return
+		}
+
+		var req clearAuthBlockRequest
+		if !validate.Bind(w, r, &req) {
+			return
+		}
+		kind, value := req.Kind, req.Value
+
+		if !tracker.Clear(lockout.Subject{Kind: kind, Value: value}) {
+			utils.WriteErrorResponse(w, http.StatusNotFound, "NOT_FOUND",
+				"No failed attempts recorded for this subject", nil)
+			return
+		}
+
+		admin, _ := middleware.GetUser(r)
+		recorder.Record(r.Context(), audit.EventAuthBlockCleared, &admin.ID, audit.TargetAuthSubject,
+			string(kind)+":"+value, nil)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
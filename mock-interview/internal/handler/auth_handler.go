@@ -5,14 +5,29 @@ import (
 	"net/http"
 
 	"github.com/google/uuid"
+	"github.com/winfr1th/mock-interview/internal/audit"
 	"github.com/winfr1th/mock-interview/internal/auth"
+	"github.com/winfr1th/mock-interview/internal/metrics"
 	model "github.com/winfr1th/mock-interview/internal/models"
 	"github.com/winfr1th/mock-interview/internal/repository"
 	"github.com/winfr1th/mock-interview/internal/utils"
+	"github.com/winfr1th/mock-interview/internal/validate"
 )
 
-// Register handles user registration and returns an API key
-func Register(repo repository.UserRepository) http.HandlerFunc {
+// registerRequest is the schema of POST /register and POST /users
+type registerRequest struct {
+	Body        model.RegisterRequest `body:"strict"`
+	dateOfBirth model.Date
+}
+
+func (req *registerRequest) Check(v *validate.Validator) {
+	if req.Body.DateOfBirth != "" {
+		req.dateOfBirth = v.DateOfBirth(validate.Field("date_of_birth"), req.Body.DateOfBirth)
+	}
+}
+
+// Register handles user registration and returns an API key with its signing secret
+func Register(repo repository.UserRepository, signing *auth.SigningSecrets, recorder *audit.Recorder) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			utils.WriteErrorResponse(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED",
@@ -20,18 +35,15 @@ func Register(repo repository.UserRepository) http.HandlerFunc {
 			return
 		}
 
-		var req model.RegisterRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST",
-				"Invalid request body", nil)
+		var req registerRequest
+		if !validate.Bind(w, r, &req) {
 			return
 		}
 
-		// Validate required fields
-		if req.Name == "" || req.DateOfBirth == "" {
-			utils.WriteErrorResponse(w, http.StatusBadRequest, "MISSING_FIELDS",
-				"Name and date_of_birth are required", nil)
-			return
+		// home_country is optional
+		var homeCountry *string
+		if req.Body.HomeCountry != "" {
+			homeCountry = &req.Body.HomeCountry
 		}
 
 		// Generate API key
@@ -45,21 +57,28 @@ func Register(repo repository.UserRepository) http.HandlerFunc {
 		// Create user
 		user := model.User{
 			ID:          uuid.New(),
-			Name:        req.Name,
-			DateOfBirth: req.DateOfBirth,
-			APIKeyHash:  apiKey, // Store plain API key
+			HouseholdID: uuid.New(),
+			Name:        req.Body.Name,
+			DateOfBirth: req.dateOfBirth,
+			HomeCountry: homeCountry,
+			APIKeyID:    apiKey.ID,
+			APIKeyHash:  apiKey.Hash, // Only the hash is stored
 		}
 
 		if err := repo.CreateUser(r.Context(), user); err != nil {
-			utils.WriteErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR",
-				"Failed to create user: "+err.Error(), nil)
+			writeError(w, r, err, "Failed to create user")
 			return
 		}
 
+		recorder.Record(r.Context(), audit.EventUserRegistered, &user.ID, audit.TargetUser, user.ID.String(), nil)
+		recorder.Record(r.Context(), audit.EventAPIKeyCreated, &user.ID, audit.TargetUser, user.ID.String(), nil)
+		metrics.UserRegistrations.WithLabelValues(metrics.RegistrationRegister).Inc()
+
 		// Return response with API key (only time it's returned)
 		response := model.RegisterResponse{
-			UserID: user.ID,
-			APIKey: apiKey,
+			UserID:        user.ID,
+			APIKey:        apiKey.Key,
+			SigningSecret: signing.For(apiKey.Hash),
 		}
 
 		w.Header().Set("Content-Type", "application/json")
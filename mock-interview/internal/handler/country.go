@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/winfr1th/mock-interview/internal/country"
+	"github.com/winfr1th/mock-interview/internal/middleware"
+	"github.com/winfr1th/mock-interview/internal/utils"
+	"github.com/winfr1th/mock-interview/internal/validate"
+)
+
+// resolveCountry determines the effective country for the request and reports its source in
+// the response headers, writing an error response when there is none
+func resolveCountry(w http.ResponseWriter, r *http.Request, resolver *country.Resolver) (string, bool) {
+	var homeCountry *string
+	if user, ok := middleware.GetUser(r); ok {
+		homeCountry = user.HomeCountry
+	}
+
+	countryCode, source, err := resolver.Resolve(r, homeCountry)
+	if err != nil {
+		if errors.Is(err, country.ErrInvalidCode) {
+			// Only the ?country= parameter can be invalid; the other sources fall through
+			var v validate.Validator
+			v.Country(validate.Param("country"), r.URL.Query().Get("country"))
+			v.Failed(w)
+			return "", false
+		}
+		utils.WriteErrorResponse(w, http.StatusBadRequest, "MISSING_COUNTRY",
+			"Country could not be determined: pass the country parameter or set a home_country", nil)
+		return "", false
+	}
+
+	country.WriteSourceHeaders(w, countryCode, source)
+	return countryCode, true
+}
@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/winfr1th/mock-interview/internal/repository"
+	"github.com/winfr1th/mock-interview/internal/utils"
+)
+
+// errorMapping is how a repository error is reported to clients
+type errorMapping struct {
+	err     error
+	status  int
+	code    string
+	message string
+}
+
+// errorMappings are checked in order, so specific errors come before their kinds
+var errorMappings = []errorMapping{
+	{repository.ErrMovieAlreadySaved, http.StatusConflict, ErrorCodeDuplicateSave, "Movie is already saved"},
+	{repository.ErrMovieNotSaved, http.StatusNotFound, ErrorCodeNotSaved, "Movie is not saved"},
+	{repository.ErrUserNotFound, http.StatusNotFound, "USER_NOT_FOUND", "User not found"},
+	{repository.ErrInvalidUserID, http.StatusBadRequest, "INVALID_USER_ID", "Invalid user ID format"},
+	{repository.ErrMovieNotFound, http.StatusNotFound, "MOVIE_NOT_FOUND", "Movie not found"},
+	{repository.ErrProfileNotFound, http.StatusNotFound, "PROFILE_NOT_FOUND", "Profile not found"},
+	{repository.ErrNotFound, http.StatusNotFound, "NOT_FOUND", "Resource not found"},
+	{repository.ErrAlreadyExists, http.StatusConflict, "ALREADY_EXISTS", "Resource already exists"},
+	{repository.ErrInvalidReference, http.StatusUnprocessableEntity, "INVALID_REFERENCE", "Referenced resource does not exist"},
+	{repository.ErrInvalidID, http.StatusBadRequest, "INVALID_ID", "Invalid ID format"},
+	{context.DeadlineExceeded, http.StatusServiceUnavailable, "TIMEOUT", "The request took too long, try again later"},
+}
+
+// MapError returns the HTTP status and error detail for err. Errors without a mapping are
+// 500 INTERNAL_ERROR with message, so their text never reaches the client.
+func MapError(err error, message string) (int, utils.ErrorDetail) {
+	for _, m := range errorMappings {
+		if errors.Is(err, m.err) {
+			return m.status, utils.ErrorDetail{Code: m.code, Message: m.message}
+		}
+	}
+	return http.StatusInternalServerError, utils.ErrorDetail{Code: "INTERNAL_ERROR", Message: message}
+}
+
+// writeError writes the mapped response for err, logging errors that become a 5xx
+func writeError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	status, detail := MapError(err, message)
+	if status >= http.StatusInternalServerError {
+		slog.ErrorContext(r.Context(), message, "method", r.Method, "path", r.URL.Path, "error", err)
+	}
+	utils.WriteErrorResponse(w, status, detail.Code, detail.Message, detail.Details)
+}
@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/winfr1th/mock-interview/internal/repository"
+)
+
+func TestMapError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{"duplicate save", repository.ErrMovieAlreadySaved, http.StatusConflict, ErrorCodeDuplicateSave},
+		{"not saved", repository.ErrMovieNotSaved, http.StatusNotFound, ErrorCodeNotSaved},
+		{"wrapped user not found", fmt.Errorf("lookup: %w", repository.ErrUserNotFound), http.StatusNotFound, "USER_NOT_FOUND"},
+		{"invalid user ID", repository.ErrInvalidUserID, http.StatusBadRequest, "INVALID_USER_ID"},
+		{"other not found", repository.ErrIdentityNotFound, http.StatusNotFound, "NOT_FOUND"},
+		{"unique violation", fmt.Errorf("%w: duplicate key", repository.ErrAlreadyExists), http.StatusConflict, "ALREADY_EXISTS"},
+		{"foreign key violation", repository.ErrInvalidReference, http.StatusUnprocessableEntity, "INVALID_REFERENCE"},
+		{"request deadline", fmt.Errorf("%w: canceling statement due to user request", context.DeadlineExceeded), http.StatusServiceUnavailable, "TIMEOUT"},
+		{"unknown", errors.New("connection refused"), http.StatusInternalServerError, "INTERNAL_ERROR"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, detail := MapError(tt.err, "Failed to do it")
+			if status != tt.wantStatus || detail.Code != tt.wantCode {
+				t.Errorf("MapError() = %d %s, want %d %s", status, detail.Code, tt.wantStatus, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestMapErrorDoesNotLeakInternalErrors(t *testing.T) {
+	_, detail := MapError(errors.New("pq: password authentication failed"), "Failed to save movie")
+	if detail.Message != "Failed to save movie" {
+		t.Errorf("message = %q, want the caller's message", detail.Message)
+	}
+}
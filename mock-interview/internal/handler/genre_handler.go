@@ -6,8 +6,14 @@ import (
 
 	"github.com/winfr1th/mock-interview/internal/repository"
 	"github.com/winfr1th/mock-interview/internal/utils"
+	"github.com/winfr1th/mock-interview/internal/validate"
 )
 
+// listGenresRequest is the query of GET /genres
+type listGenresRequest struct {
+	validate.Pagination
+}
+
 // ListGenres handles GET /genres - List all genres with pagination
 func ListGenres(repo repository.GenreRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -17,27 +23,20 @@ func ListGenres(repo repository.GenreRepository) http.HandlerFunc {
 			return
 		}
 
-		// Parse pagination parameters
-		page, pageSize, err := utils.ParsePaginationParams(r)
-		if err != nil {
-			if pagErr, ok := err.(*utils.PaginationError); ok {
-				utils.WriteErrorResponse(w, http.StatusBadRequest, pagErr.Code, pagErr.Message, nil)
-			} else {
-				utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_PARAMETER", err.Error(), nil)
-			}
+		var req listGenresRequest
+		if !validate.Bind(w, r, &req) {
 			return
 		}
 
 		// Get genres from repository
-		genres, total, err := repo.ListGenres(r.Context(), page, pageSize)
+		genres, total, err := repo.ListGenres(r.Context(), req.Page, req.PageSize)
 		if err != nil {
-			utils.WriteErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR",
-				"Failed to fetch genres: "+err.Error(), nil)
+			writeError(w, r, err, "Failed to fetch genres")
 			return
 		}
 
 		// Create paginated response
-		response := utils.CreatePagedResponse(genres, total, page, pageSize)
+		response := utils.CreatePagedResponse(genres, total, req.Page, req.PageSize)
 
 		// Return JSON response
 		w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/winfr1th/mock-interview/internal/health"
+	"github.com/winfr1th/mock-interview/internal/utils"
+)
+
+// HealthResponse is the body of GET /healthz
+type HealthResponse struct {
+	Status string `json:"status"`
+}
+
+// StatusResponse is the body of GET /status
+type StatusResponse struct {
+	health.Report
+	StartedAt     time.Time  `json:"started_at"`
+	UptimeSeconds int64      `json:"uptime_seconds"`
+	Build         BuildInfo  `json:"build"`
+	Database      *PoolStats `json:"database,omitempty"`
+}
+
+// BuildInfo identifies the running binary
+type BuildInfo struct {
+	GoVersion string `json:"go_version"`
+	Module    string `json:"module"`
+	Version   string `json:"version"`
+	Revision  string `json:"vcs_revision,omitempty"`
+	Time      string `json:"vcs_time,omitempty"`
+	Modified  bool   `json:"vcs_modified,omitempty"`
+}
+
+// PoolStats are the database pool's connection statistics
+type PoolStats struct {
+	MaxConns                int32   `json:"max_conns"`
+	TotalConns              int32   `json:"total_conns"`
+	AcquiredConns           int32   `json:"acquired_conns"`
+	IdleConns               int32   `json:"idle_conns"`
+	ConstructingConns       int32   `json:"constructing_conns"`
+	AcquireCount            int64   `json:"acquire_count"`
+	AcquireDurationMS       float64 `json:"acquire_duration_ms"`
+	EmptyAcquireCount       int64   `json:"empty_acquire_count"`
+	CanceledAcquireCount    int64   `json:"canceled_acquire_count"`
+	NewConnsCount           int64   `json:"new_conns_count"`
+	MaxLifetimeDestroyCount int64   `json:"max_lifetime_destroy_count"`
+	MaxIdleDestroyCount     int64   `json:"max_idle_destroy_count"`
+}
+
+// Healthz handles GET /healthz - Report that the process is alive. It checks no dependencies,
+// so a failing database doesn't get the process restarted.
+func Healthz() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			utils.WriteErrorResponse(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED",
+				"Method not allowed", nil)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(HealthResponse{Status: health.StatusOK})
+	}
+}
+
+// Readyz handles GET /readyz - Run the readiness checks; 503 when any fails. The probe is public,
+// so it reports each check's status and duration only; GET /status has their errors.
+func Readyz(checks []health.Check) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			utils.WriteErrorResponse(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED",
+				"Method not allowed", nil)
+			return
+		}
+
+		report := health.Run(r.Context(), checks)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if !report.OK() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(report.Redacted())
+	}
+}
+
+// Status handles GET /status - Report dependency checks, pool statistics, build info and uptime.
+// pool may be nil.
+func Status(checks []health.Check, pool *pgxpool.Pool, startedAt time.Time) http.HandlerFunc {
+	build := readBuildInfo()
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			utils.WriteErrorResponse(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED",
+				"Method not allowed", nil)
+			return
+		}
+
+		response := StatusResponse{
+			Report:        health.Run(r.Context(), checks),
+			StartedAt:     startedAt.UTC(),
+			UptimeSeconds: int64(time.Since(startedAt).Seconds()),
+			Build:         build,
+		}
+		if pool != nil {
+			response.Database = poolStats(pool.Stat())
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+func poolStats(stat *pgxpool.Stat) *PoolStats {
+	return &PoolStats{
+		MaxConns:                stat.MaxConns(),
+		TotalConns:              stat.TotalConns(),
+		AcquiredConns:           stat.AcquiredConns(),
+		IdleConns:               stat.IdleConns(),
+		ConstructingConns:       stat.ConstructingConns(),
+		AcquireCount:            stat.AcquireCount(),
+		AcquireDurationMS:       float64(stat.AcquireDuration().Microseconds()) / 1000,
+		EmptyAcquireCount:       stat.EmptyAcquireCount(),
+		CanceledAcquireCount:    stat.CanceledAcquireCount(),
+		NewConnsCount:           stat.NewConnsCount(),
+		MaxLifetimeDestroyCount: stat.MaxLifetimeDestroyCount(),
+		MaxIdleDestroyCount:     stat.MaxIdleDestroyCount(),
+	}
+}
+
+func readBuildInfo() BuildInfo {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return BuildInfo{}
+	}
+	build := BuildInfo{
+		GoVersion: info.GoVersion,
+		Module:    info.Main.Path,
+		Version:   info.Main.Version,
+	}
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			build.Revision = setting.Value
+		case "vcs.time":
+			build.Time = setting.Value
+		case "vcs.modified":
+			build.Modified = setting.Value == "true"
+		}
+	}
+	return build
+}
@@ -3,15 +3,39 @@ package handler
 import (
 	"encoding/json"
 	"net/http"
-	"strings"
 
 	"github.com/google/uuid"
+	model "github.com/winfr1th/mock-interview/internal/models"
 	"github.com/winfr1th/mock-interview/internal/repository"
 	"github.com/winfr1th/mock-interview/internal/utils"
+	"github.com/winfr1th/mock-interview/internal/validate"
 )
 
+// MovieResponse is the simplified movie in lists and save responses (only id, title, year)
+type MovieResponse struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	Year  int    `json:"year"`
+}
+
+func toMovieResponse(movie model.Movie) MovieResponse {
+	return MovieResponse{
+		ID:    movie.ID.String(),
+		Title: movie.Title,
+		Year:  movie.Year,
+	}
+}
+
+// listMoviesRequest is the query of GET /movies
+type listMoviesRequest struct {
+	validate.Pagination
+	Country *string    `query:"country" validate:"iso3166"`
+	Genre   *uuid.UUID `query:"genre" code:"INVALID_GENRE_ID"`
+	Sort    string     `query:"sort" default:"-year" validate:"enum=year|-year" code:"INVALID_SORT_PARAMETER"` // Default: newest first
+}
+
 // ListMovies handles GET /movies - List movies with filtering, sorting, and pagination
-func ListMovies(repo repository.MovieRepository) http.HandlerFunc {
+func ListMovies(repo repository.MovieRepository, agePolicy AgePolicy) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			utils.WriteErrorResponse(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED",
@@ -19,83 +43,29 @@ func ListMovies(repo repository.MovieRepository) http.HandlerFunc {
 			return
 		}
 
-		// Parse pagination parameters
-		page, pageSize, err := utils.ParsePaginationParams(r)
-		if err != nil {
-			if pagErr, ok := err.(*utils.PaginationError); ok {
-				utils.WriteErrorResponse(w, http.StatusBadRequest, pagErr.Code, pagErr.Message, nil)
-			} else {
-				utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_PARAMETER", err.Error(), nil)
-			}
+		var req listMoviesRequest
+		if !validate.Bind(w, r, &req) {
 			return
 		}
 
-		// Parse and validate country filter (optional)
-		var countryCode *string
-		countryParam := strings.TrimSpace(r.URL.Query().Get("country"))
-		if countryParam != "" {
-			// Validate country code format (ISO-3166-1 alpha-2: 2 uppercase letters)
-			countryParam = strings.ToUpper(countryParam)
-			if len(countryParam) != 2 {
-				utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_COUNTRY_CODE",
-					"Invalid country code: must be ISO-3166-1 alpha-2 format (2 characters)", nil)
-				return
-			}
-			countryCode = &countryParam
-		}
-
-		// Parse and validate genre filter (optional)
-		var genreID *uuid.UUID
-		genreParam := strings.TrimSpace(r.URL.Query().Get("genre"))
-		if genreParam != "" {
-			parsedGenreID, err := uuid.Parse(genreParam)
-			if err != nil {
-				utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_GENRE_ID",
-					"Invalid genre ID: must be a valid UUID", nil)
-				return
-			}
-			genreID = &parsedGenreID
-		}
-
-		// Parse sort parameter (optional, default: -year)
-		sortBy := strings.TrimSpace(r.URL.Query().Get("sort"))
-		if sortBy == "" {
-			sortBy = "-year" // Default: newest first
-		} else {
-			// Validate sort parameter
-			if sortBy != "year" && sortBy != "-year" {
-				utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_SORT_PARAMETER",
-					"Invalid sort parameter: must be 'year' or '-year'", nil)
-				return
-			}
-		}
+		// Hide movies certified above the viewer's age
+		maxAge := agePolicy.ViewerAge(r)
 
 		// Get movies from repository
-		movies, total, err := repo.ListMovies(r.Context(), countryCode, genreID, page, pageSize, sortBy)
+		movies, total, err := repo.ListMovies(r.Context(), req.Country, req.Genre, &maxAge, req.Page, req.PageSize, req.Sort)
 		if err != nil {
-			utils.WriteErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR",
-				"Failed to fetch movies: "+err.Error(), nil)
+			writeError(w, r, err, "Failed to fetch movies")
 			return
 		}
 
 		// Create simplified movie response (only id, title, year)
-		type MovieResponse struct {
-			ID    string `json:"id"`
-			Title string `json:"title"`
-			Year  int    `json:"year"`
-		}
-
 		movieResponses := make([]MovieResponse, len(movies))
 		for i, movie := range movies {
-			movieResponses[i] = MovieResponse{
-				ID:    movie.ID.String(),
-				Title: movie.Title,
-				Year:  movie.Year,
-			}
+			movieResponses[i] = toMovieResponse(movie)
 		}
 
 		// Create paginated response
-		response := utils.CreatePagedResponse(movieResponses, total, page, pageSize)
+		response := utils.CreatePagedResponse(movieResponses, total, req.Page, req.PageSize)
 
 		// Return JSON response
 		w.Header().Set("Content-Type", "application/json")
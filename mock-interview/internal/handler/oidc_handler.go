@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/winfr1th/mock-interview/internal/audit"
+	"github.com/winfr1th/mock-interview/internal/auth"
+	"github.com/winfr1th/mock-interview/internal/auth/oidc"
+	"github.com/winfr1th/mock-interview/internal/metrics"
+	model "github.com/winfr1th/mock-interview/internal/models"
+	"github.com/winfr1th/mock-interview/internal/repository"
+	"github.com/winfr1th/mock-interview/internal/utils"
+	"github.com/winfr1th/mock-interview/internal/validate"
+)
+
+// oidcStateCookie holds the state of the login a browser started. The callback only completes a
+// login whose state matches it, so another site can't finish its own login in the user's browser.
+const oidcStateCookie = "oidc_state"
+
+// setOIDCStateCookie keeps state in the browser for maxAge seconds, or removes it when maxAge is negative
+func setOIDCStateCookie(w http.ResponseWriter, r *http.Request, provider *oidc.Provider, state string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   r.TLS != nil || provider.SecureCallback(),
+		// Lax still sends the cookie on the provider's top-level redirect back to the callback
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// OIDCLogin starts an OIDC login and redirects to the identity provider
+func OIDCLogin(provider *oidc.Provider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			utils.WriteErrorResponse(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED",
+				"Method not allowed", nil)
+			return
+		}
+
+		authURL, state, err := provider.AuthCodeURL()
+		if errors.Is(err, oidc.ErrTooManyLogins) {
+			w.Header().Set("Retry-After", strconv.Itoa(oidcRetryAfterSeconds))
+			utils.WriteErrorResponse(w, http.StatusServiceUnavailable, "OIDC_BUSY",
+				"Too many logins in progress, try again later", map[string]interface{}{"retry_after_seconds": oidcRetryAfterSeconds})
+			return
+		}
+		if err != nil {
+			utils.WriteErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR",
+				"Failed to start login", nil)
+			return
+		}
+
+		setOIDCStateCookie(w, r, provider, state, int(oidc.LoginTTL/time.Second))
+		http.Redirect(w, r, authURL, http.StatusFound)
+	}
+}
+
+// oidcRetryAfterSeconds is when to retry starting a login while too many are pending
+const oidcRetryAfterSeconds = 60
+
+// oidcCallbackRequest is the query the identity provider redirects back with
+type oidcCallbackRequest struct {
+	State string `query:"state" validate:"required"`
+	Code  string `query:"code" validate:"required"`
+}
+
+// OIDCCallback completes an OIDC login and returns an access token and refresh token.
+// The first login of an external subject creates a user with its own household and links the subject to it.
+func OIDCCallback(provider *oidc.Provider, tx repository.Transactor, userRepo repository.UserRepository, identityRepo repository.IdentityRepository,
+	sessions Sessions, recorder *audit.Recorder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			utils.WriteErrorResponse(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED",
+				"Method not allowed", nil)
+			return
+		}
+
+		query := r.URL.Query()
+		if errCode := query.Get("error"); errCode != "" {
+			utils.WriteErrorResponse(w, http.StatusUnauthorized, "OIDC_LOGIN_FAILED",
+				"Identity provider returned an error", map[string]interface{}{"error": errCode})
+			return
+		}
+		var req oidcCallbackRequest
+		if !validate.Bind(w, r, &req) {
+			return
+		}
+
+		cookie, err := r.Cookie(oidcStateCookie)
+		if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(req.State)) != 1 {
+			utils.WriteErrorResponse(w, http.StatusUnauthorized, "OIDC_LOGIN_FAILED",
+				"Login was not started in this browser", nil)
+			return
+		}
+		setOIDCStateCookie(w, r, provider, "", -1)
+
+		identity, err := provider.Exchange(r.Context(), req.State, req.Code)
+		if err != nil {
+			utils.WriteErrorResponse(w, http.StatusUnauthorized, "OIDC_LOGIN_FAILED",
+				"Login could not be verified", nil)
+			return
+		}
+
+		user, err := identityRepo.FindUserByIdentity(r.Context(), identity.Issuer, identity.Subject)
+		if err != nil {
+			if !errors.Is(err, repository.ErrIdentityNotFound) {
+				utils.WriteErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR",
+					"Failed to find user", nil)
+				return
+			}
+
+			user, err = createOIDCUser(r, tx, userRepo, identityRepo, recorder, identity)
+			if errors.Is(err, repository.ErrAlreadyExists) {
+				// A concurrent first login linked the subject first; its user is the subject's
+				user, err = identityRepo.FindUserByIdentity(r.Context(), identity.Issuer, identity.Subject)
+			}
+			if err != nil {
+				writeError(w, r, err, "Failed to create user")
+				return
+			}
+		}
+
+		response, err := sessions.issue(r.Context(), user, auth.MethodOIDC, uuid.New())
+		if err != nil {
+			utils.WriteErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR",
+				"Failed to issue tokens", nil)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// createOIDCUser registers a user for a new external subject. The birthdate claim is used as the
+// date of birth when the provider shares a plausible one. The user is created and linked in one
+// unit of work, so a subject that is already linked leaves no user behind: the error is ErrAlreadyExists.
+func createOIDCUser(r *http.Request, tx repository.Transactor, userRepo repository.UserRepository, identityRepo repository.IdentityRepository,
+	recorder *audit.Recorder, identity oidc.Identity) (model.User, error) {
+	name := identity.Name
+	if name == "" {
+		name = identity.Email
+	}
+	if name == "" {
+		name = "User"
+	}
+
+	user := model.User{
+		ID:          uuid.New(),
+		HouseholdID: uuid.New(),
+		Name:        name,
+	}
+	if identity.Birthdate != "" {
+		if dateOfBirth, err := model.ParseDateOfBirth(identity.Birthdate); err == nil {
+			user.DateOfBirth = dateOfBirth
+		}
+	}
+
+	link := model.UserIdentity{Issuer: identity.Issuer, Subject: identity.Subject, UserID: user.ID}
+	err := tx.WithTx(r.Context(), func(ctx context.Context) error {
+		if err := userRepo.CreateUser(ctx, user); err != nil {
+			return err
+		}
+		return identityRepo.LinkIdentity(ctx, link)
+	})
+	if err != nil {
+		return model.User{}, err
+	}
+
+	metadata := map[string]string{"method": auth.MethodOIDC}
+	recorder.Record(r.Context(), audit.EventUserRegistered, &user.ID, audit.TargetUser, user.ID.String(), metadata)
+	recorder.Record(r.Context(), audit.EventIdentityLinked, &user.ID, audit.TargetUser, user.ID.String(),
+		map[string]string{"issuer": identity.Issuer})
+	metrics.UserRegistrations.WithLabelValues(metrics.RegistrationOIDC).Inc()
+
+	return user, nil
+}
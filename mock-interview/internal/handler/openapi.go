@@ -0,0 +1,210 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/winfr1th/mock-interview/internal/health"
+	model "github.com/winfr1th/mock-interview/internal/models"
+	"github.com/winfr1th/mock-interview/internal/openapi"
+	"github.com/winfr1th/mock-interview/internal/utils"
+)
+
+// Endpoints documents every route for the OpenAPI document, keyed by method and path template.
+// A route registered without an entry here fails openapi.Build.
+var Endpoints = map[string]openapi.Endpoint{
+	"GET /openapi.json": {
+		ID: "getOpenAPI", Summary: "This OpenAPI document", Tag: "docs",
+		Response: map[string]interface{}{},
+	},
+	"GET /docs": {
+		ID: "getDocs", Summary: "API reference page rendering the OpenAPI document", Tag: "docs",
+		Response: "", ContentType: "text/html",
+	},
+
+	// Probes and status
+	"GET /healthz": {
+		ID: "getHealth", Summary: "Liveness probe", Tag: "operations",
+		Description: "Reports that the process is alive without checking its dependencies.",
+		Response:    HealthResponse{},
+	},
+	"GET /readyz": {
+		ID: "getReadiness", Summary: "Readiness probe", Tag: "operations",
+		Description: "Checks the database, the schema version and whether the server is shutting down. Each check has its own timeout.",
+		Response:    health.Report{},
+		Responses:   map[int]interface{}{http.StatusServiceUnavailable: health.Report{}},
+	},
+	"GET /metrics": {
+		ID: "getMetrics", Summary: "Prometheus metrics", Tag: "operations",
+		Response: "", ContentType: "text/plain; version=0.0.4",
+	},
+	"GET /status": {
+		ID: "getStatus", Summary: "Dependency checks, database pool statistics, build info and uptime", Tag: "operations",
+		Auth: openapi.AuthRequired, Response: StatusResponse{},
+		Errors: []int{401, 429},
+	},
+
+	// Registration and sessions
+	"POST /register": {
+		ID: "register", Summary: "Register a user and receive an API key", Tag: "users",
+		Request: registerRequest{}, Status: http.StatusCreated, Response: model.RegisterResponse{},
+		Errors: []int{400, 413, 429},
+	},
+	"POST /auth/token": {
+		ID: "issueToken", Summary: "Exchange the request's API key for an access token and refresh token", Tag: "sessions",
+		Auth: openapi.AuthRequired, Response: model.AccessTokenResponse{},
+		Errors: []int{400, 401, 403, 429},
+	},
+	"POST /auth/refresh": {
+		ID: "refreshToken", Summary: "Replace a refresh token with a new access token and refresh token", Tag: "sessions",
+		Description: "Presenting a refresh token that was already used revokes every token of its session.",
+		Request:     refreshTokenRequest{},
+		Response:    model.AccessTokenResponse{},
+		Errors:      []int{400, 401, 413, 429},
+	},
+	"GET /auth/oidc/login": {
+		ID: "oidcLogin", Summary: "Start an OIDC login by redirecting to the identity provider", Tag: "sessions",
+		Description: "Sets the oidc_state cookie, which the callback must come back with.",
+		Status:      http.StatusFound, Errors: []int{429, 503},
+	},
+	"GET /auth/oidc/callback": {
+		ID: "oidcCallback", Summary: "Complete an OIDC login", Tag: "sessions",
+		Description: "The state must match the oidc_state cookie set when the login started. The first login of a provider account creates a user for it.",
+		Request:     oidcCallbackRequest{},
+		Response:    model.AccessTokenResponse{},
+		Errors:      []int{400, 401, 429},
+	},
+
+	// Users
+	"POST /users": {
+		ID: "createUser", Summary: "Create a user and receive its API key", Tag: "users",
+		Auth: openapi.AuthRequired, Request: registerRequest{}, Status: http.StatusCreated, Response: model.RegisterResponse{},
+		Errors: []int{400, 401, 403, 413, 429},
+	},
+	"GET /users/{id}": {
+		ID: "getUser", Summary: "Get a user", Tag: "users",
+		Auth: openapi.AuthRequired, Request: getUserRequest{}, Response: model.User{},
+		Errors: []int{400, 401, 403, 404, 429},
+	},
+	"PATCH /users/{id}": {
+		ID: "updateUser", Summary: "Update the caller's name, date of birth or home country", Tag: "users",
+		Auth: openapi.AuthRequired, Request: updateUserRequest{}, Response: model.User{},
+		Errors: []int{400, 401, 403, 404, 413, 429},
+	},
+	"DELETE /users/{id}": {
+		ID: "deleteUser", Summary: "Delete the caller's account and household", Tag: "users",
+		Auth: openapi.AuthRequired, Status: http.StatusNoContent,
+		Errors: []int{401, 403, 404, 429},
+	},
+	"POST /users/{id}/api-key": {
+		ID: "rotateAPIKey", Summary: "Replace the caller's API key", Tag: "users",
+		Auth: openapi.AuthRequired, Response: model.RegisterResponse{},
+		Errors: []int{401, 403, 404, 429},
+	},
+	"DELETE /users/{id}/api-key": {
+		ID: "revokeAPIKey", Summary: "Revoke the caller's API key", Tag: "users",
+		Auth: openapi.AuthRequired, Status: http.StatusNoContent,
+		Errors: []int{401, 403, 404, 429},
+	},
+
+	// Household profiles
+	"GET /profiles": {
+		ID: "listProfiles", Summary: "List the profiles of the caller's household", Tag: "profiles",
+		Auth: openapi.AuthRequired, Response: openapi.List(model.ProfileResponse{}),
+		Errors: []int{401, 403, 429},
+	},
+	"POST /profiles": {
+		ID: "createProfile", Summary: "Add a profile to the caller's household", Tag: "profiles",
+		Auth: openapi.AuthRequired, Request: createProfileRequest{}, Status: http.StatusCreated, Response: model.ProfileResponse{},
+		Errors: []int{400, 401, 403, 413, 429},
+	},
+	"PATCH /profiles/{profile_id}": {
+		ID: "updateProfile", Summary: "Change a profile's name, content limit or PIN", Tag: "profiles",
+		Description: "Give the default profile a PIN so that requests without X-Profile-ID can't use it without one.",
+		Auth:        openapi.AuthRequired, Request: updateProfileRequest{}, Response: model.ProfileResponse{},
+		Errors: []int{400, 401, 403, 404, 413, 429},
+	},
+	"DELETE /profiles/{profile_id}": {
+		ID: "deleteProfile", Summary: "Remove a non-default profile", Tag: "profiles",
+		Auth: openapi.AuthRequired, Request: deleteProfileRequest{}, Status: http.StatusNoContent,
+		Errors: []int{400, 401, 403, 404, 429},
+	},
+
+	// Catalog and saved movies
+	"GET /genres": {
+		ID: "listGenres", Summary: "List genres", Tag: "movies",
+		Request: listGenresRequest{}, Response: openapi.Paged(model.Genre{}),
+		Errors: []int{400, 429},
+	},
+	"GET /movies": {
+		ID: "listMovies", Summary: "List movies with filtering, sorting and pagination", Tag: "movies",
+		Description: "Movies certified above the viewer's age are hidden. Anonymous viewers are treated as a configured age.",
+		Auth:        openapi.AuthOptional, Request: listMoviesRequest{}, Response: openapi.Paged(MovieResponse{}),
+		Errors: []int{400, 401, 429},
+	},
+	"GET /users/{user_id}/movies": {
+		ID: "listSavedMovies", Summary: "List the active profile's saved movies", Tag: "movies",
+		Auth: openapi.AuthRequired, Request: listSavedMoviesRequest{}, Response: openapi.Paged(MovieResponse{}),
+		Errors: []int{400, 401, 403, 429},
+	},
+	"POST /users/{user_id}/movies": {
+		ID: "saveMovie", Summary: "Save a movie for the active profile", Tag: "movies",
+		Auth: openapi.AuthRequired, Request: saveMovieRequest{}, Response: MovieResponse{},
+		Errors: []int{400, 401, 403, 404, 409, 413, 422, 429},
+	},
+	"DELETE /users/{user_id}/movies/{movie_id}": {
+		ID: "removeSavedMovie", Summary: "Remove a saved movie", Tag: "movies",
+		Auth: openapi.AuthRequired, Request: removeSavedMovieRequest{}, Status: http.StatusNoContent,
+		Errors: []int{400, 401, 403, 404, 429},
+	},
+
+	// Admin
+	"GET /admin/auth-blocks": {
+		ID: "listAuthBlocks", Summary: "List subjects with recent failed authentication attempts", Tag: "admin",
+		Auth: openapi.AuthAdmin, Request: listAuthBlocksRequest{}, Response: openapi.List(AuthBlockResponse{}),
+		Errors: []int{400, 401, 403, 429},
+	},
+	"DELETE /admin/auth-blocks": {
+		ID: "clearAuthBlock", Summary: "Clear a subject's failures and block", Tag: "admin",
+		Auth: openapi.AuthAdmin, Request: clearAuthBlockRequest{}, Status: http.StatusNoContent,
+		Errors: []int{400, 401, 403, 404, 429},
+	},
+	"GET /admin/audit": {
+		ID: "listAuditEvents", Summary: "List audit events newest first", Tag: "admin",
+		Auth: openapi.AuthAdmin, Request: listAuditEventsRequest{}, Response: AuditPage{},
+		Errors: []int{400, 401, 403, 429},
+	},
+	"GET /admin/audit/verify": {
+		ID: "verifyAuditChain", Summary: "Check the audit hash chain for tampering", Tag: "admin",
+		Auth: openapi.AuthAdmin, Response: model.AuditVerification{},
+		Errors: []int{401, 403, 429},
+	},
+}
+
+// OpenAPISpec handles GET /openapi.json - Serve the OpenAPI document
+func OpenAPISpec(doc *openapi.Document) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			utils.WriteErrorResponse(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED",
+				"Method not allowed", nil)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(doc)
+	}
+}
+
+// APIDocs handles GET /docs - Serve the API reference page
+func APIDocs() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			utils.WriteErrorResponse(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED",
+				"Method not allowed", nil)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(openapi.DocsPage)
+	}
+}
@@ -0,0 +1,237 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/winfr1th/mock-interview/internal/auth"
+	"github.com/winfr1th/mock-interview/internal/middleware"
+	model "github.com/winfr1th/mock-interview/internal/models"
+	"github.com/winfr1th/mock-interview/internal/repository"
+	"github.com/winfr1th/mock-interview/internal/utils"
+	"github.com/winfr1th/mock-interview/internal/validate"
+)
+
+// ListProfiles handles GET /profiles - List the profiles of the caller's household
+func ListProfiles(repo repository.ProfileRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			utils.WriteErrorResponse(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED",
+				"Method not allowed", nil)
+			return
+		}
+
+		user, _ := middleware.GetUser(r)
+		profiles, err := repo.ListProfiles(r.Context(), user.HouseholdID)
+		if err != nil {
+			writeError(w, r, err, "Failed to fetch profiles")
+			return
+		}
+
+		response := make([]model.ProfileResponse, len(profiles))
+		for i, profile := range profiles {
+			response[i] = toProfileResponse(profile)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": response})
+	}
+}
+
+// createProfileRequest is the schema of POST /profiles
+type createProfileRequest struct {
+	Body model.CreateProfileRequest `body:"strict"`
+}
+
+func (req *createProfileRequest) Check(v *validate.Validator) {
+	if req.Body.PIN != "" {
+		if err := auth.ValidatePIN(req.Body.PIN); err != nil {
+			v.Add(validate.Field("pin"), "INVALID_PIN", err.Error())
+		}
+	}
+}
+
+// CreateProfile handles POST /profiles - Add a profile to the caller's household
+func CreateProfile(repo repository.ProfileRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			utils.WriteErrorResponse(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED",
+				"Method not allowed", nil)
+			return
+		}
+
+		if !requireDefaultProfile(w, r) {
+			return
+		}
+
+		var req createProfileRequest
+		if !validate.Bind(w, r, &req) {
+			return
+		}
+
+		user, _ := middleware.GetUser(r)
+		profile := model.Profile{
+			ID:          uuid.New(),
+			HouseholdID: user.HouseholdID,
+			Name:        req.Body.Name,
+			MaxAge:      req.Body.MaxAge,
+		}
+
+		if req.Body.PIN != "" {
+			pinHash, err := auth.HashPIN(req.Body.PIN)
+			if err != nil {
+				utils.WriteErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR",
+					"Failed to hash PIN", nil)
+				return
+			}
+			profile.PINHash = pinHash
+		}
+
+		if err := repo.CreateProfile(r.Context(), profile); err != nil {
+			writeError(w, r, err, "Failed to create profile")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(toProfileResponse(profile))
+	}
+}
+
+// updateProfileRequest is the schema of PATCH /profiles/{profile_id}
+type updateProfileRequest struct {
+	ProfileID uuid.UUID                  `path:"profile_id" code:"INVALID_PROFILE_ID"`
+	Body      model.UpdateProfileRequest `body:"strict"`
+}
+
+func (req *updateProfileRequest) Check(v *validate.Validator) {
+	v.Check(req.Body.MaxAge == nil || !req.Body.ClearMaxAge, validate.Field("clear_max_age"), "CONFLICTING_FIELDS",
+		"max_age and clear_max_age can't both be given")
+	if req.Body.PIN == nil {
+		return
+	}
+	if !v.Check(!req.Body.ClearPIN, validate.Field("clear_pin"), "CONFLICTING_FIELDS",
+		"pin and clear_pin can't both be given") {
+		return
+	}
+	if err := auth.ValidatePIN(*req.Body.PIN); err != nil {
+		v.Add(validate.Field("pin"), "INVALID_PIN", err.Error())
+	}
+}
+
+// UpdateProfile handles PATCH /profiles/{profile_id} - Change a profile's name, content limit or
+// PIN. The default profile can be given a PIN too, so other profiles can't fall back to it.
+func UpdateProfile(repo repository.ProfileRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch {
+			utils.WriteErrorResponse(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED",
+				"Method not allowed", nil)
+			return
+		}
+
+		if !requireDefaultProfile(w, r) {
+			return
+		}
+
+		var req updateProfileRequest
+		if !validate.Bind(w, r, &req) {
+			return
+		}
+
+		user, _ := middleware.GetUser(r)
+		profile, err := repo.GetProfile(r.Context(), user.HouseholdID, req.ProfileID)
+		if err != nil {
+			writeError(w, r, err, "Failed to fetch profile")
+			return
+		}
+
+		if req.Body.Name != nil {
+			profile.Name = *req.Body.Name
+		}
+		if req.Body.MaxAge != nil || req.Body.ClearMaxAge {
+			profile.MaxAge = req.Body.MaxAge
+		}
+		if req.Body.ClearPIN {
+			profile.PINHash = ""
+		}
+		if req.Body.PIN != nil {
+			pinHash, err := auth.HashPIN(*req.Body.PIN)
+			if err != nil {
+				utils.WriteErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR",
+					"Failed to hash PIN", nil)
+				return
+			}
+			profile.PINHash = pinHash
+		}
+
+		if err := repo.UpdateProfile(r.Context(), profile); err != nil {
+			writeError(w, r, err, "Failed to update profile")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(toProfileResponse(profile))
+	}
+}
+
+// deleteProfileRequest is the path of DELETE /profiles/{profile_id}
+type deleteProfileRequest struct {
+	ProfileID uuid.UUID `path:"profile_id" code:"INVALID_PROFILE_ID"`
+}
+
+// DeleteProfile handles DELETE /profiles/{profile_id} - Remove a non-default profile
+func DeleteProfile(repo repository.ProfileRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			utils.WriteErrorResponse(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED",
+				"Method not allowed", nil)
+			return
+		}
+
+		if !requireDefaultProfile(w, r) {
+			return
+		}
+
+		var req deleteProfileRequest
+		if !validate.Bind(w, r, &req) {
+			return
+		}
+
+		user, _ := middleware.GetUser(r)
+		if err := repo.DeleteProfile(r.Context(), user.HouseholdID, req.ProfileID); err != nil {
+			if errors.Is(err, repository.ErrProfileNotFound) {
+				utils.WriteErrorResponse(w, http.StatusNotFound, "PROFILE_NOT_FOUND",
+					"Profile not found or is the default profile", nil)
+				return
+			}
+			writeError(w, r, err, "Failed to delete profile")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// requireDefaultProfile only lets the household's default profile manage profiles,
+// so a child profile can't create itself an unrestricted one
+func requireDefaultProfile(w http.ResponseWriter, r *http.Request) bool {
+	profile, ok := middleware.GetProfile(r)
+	if !ok || !profile.IsDefault {
+		utils.WriteErrorResponse(w, http.StatusForbidden, "FORBIDDEN",
+			"Only the household's default profile can manage profiles", nil)
+		return false
+	}
+	return true
+}
+
+func toProfileResponse(profile model.Profile) model.ProfileResponse {
+	return model.ProfileResponse{
+		ID:        profile.ID,
+		Name:      profile.Name,
+		MaxAge:    profile.MaxAge,
+		HasPIN:    profile.HasPIN(),
+		IsDefault: profile.IsDefault,
+	}
+}
@@ -1,14 +1,20 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
-	"strings"
 
 	"github.com/google/uuid"
-	"github.com/gorilla/mux"
+	"github.com/winfr1th/mock-interview/internal/country"
+	"github.com/winfr1th/mock-interview/internal/metrics"
+	"github.com/winfr1th/mock-interview/internal/middleware"
+	model "github.com/winfr1th/mock-interview/internal/models"
 	"github.com/winfr1th/mock-interview/internal/repository"
 	"github.com/winfr1th/mock-interview/internal/utils"
+	"github.com/winfr1th/mock-interview/internal/validate"
 )
 
 const (
@@ -17,8 +23,48 @@ const (
 	ErrorCodeNotSaved             = "NOT_SAVED"
 )
 
+// errUnavailableInCountry ends a save of a movie that isn't available in the effective country
+var errUnavailableInCountry = errors.New("movie not available in country")
+
+// ageRestrictedError ends a save of a movie whose certification the viewer is too young for
+type ageRestrictedError struct {
+	cert string
+}
+
+func (e ageRestrictedError) Error() string {
+	return "movie rated " + e.cert + " is restricted for the viewer"
+}
+
+// activeProfile returns the caller's active profile after checking the path's user_id is the caller
+func activeProfile(w http.ResponseWriter, r *http.Request, userID uuid.UUID) (model.Profile, bool) {
+	user, ok := middleware.GetUser(r)
+	if !ok || user.ID != userID {
+		utils.WriteErrorResponse(w, http.StatusForbidden, "FORBIDDEN",
+			"Cannot access another user's saved movies", nil)
+		return model.Profile{}, false
+	}
+
+	profile, ok := middleware.GetProfile(r)
+	if !ok {
+		utils.WriteErrorResponse(w, http.StatusForbidden, "PROFILE_NOT_FOUND",
+			"No active profile", nil)
+		return model.Profile{}, false
+	}
+
+	return profile, true
+}
+
+// listSavedMoviesRequest is the schema of GET /users/{user_id}/movies. The country
+// parameter is checked here and read by the country resolver.
+type listSavedMoviesRequest struct {
+	UserID uuid.UUID `path:"user_id" code:"INVALID_USER_ID"`
+	validate.Pagination
+	Country string `query:"country" validate:"iso3166"`
+	Sort    string `query:"sort" default:"-date_added" validate:"enum=date_added|-date_added" code:"INVALID_SORT_PARAMETER"` // Default: newest first
+}
+
 // ListSavedMovies handles GET /users/{user_id}/movies - List saved movies by user
-func ListSavedMovies(saveRepo repository.SaveMoviesRepository, movieRepo repository.MovieRepository) http.HandlerFunc {
+func ListSavedMovies(saveRepo repository.SaveMoviesRepository, movieRepo repository.MovieRepository, agePolicy AgePolicy, countryResolver *country.Resolver) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			utils.WriteErrorResponse(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED",
@@ -26,80 +72,39 @@ func ListSavedMovies(saveRepo repository.SaveMoviesRepository, movieRepo reposit
 			return
 		}
 
-		// Parse user_id from URL path
-		vars := mux.Vars(r)
-		userIDStr := vars["user_id"]
-		userID, err := uuid.Parse(userIDStr)
-		if err != nil {
-			utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_USER_ID",
-				"Invalid user ID format", nil)
+		var req listSavedMoviesRequest
+		if !validate.Bind(w, r, &req) {
 			return
 		}
 
-		// Parse and validate country parameter (required)
-		countryCode := strings.TrimSpace(r.URL.Query().Get("country"))
-		if countryCode == "" {
-			utils.WriteErrorResponse(w, http.StatusBadRequest, "MISSING_COUNTRY",
-				"Country parameter is required", nil)
-			return
-		}
-		countryCode = strings.ToUpper(countryCode)
-		if len(countryCode) != 2 {
-			utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_COUNTRY_CODE",
-				"Invalid country code: must be ISO-3166-1 alpha-2 format (2 characters)", nil)
+		// Saved movies belong to the caller's active profile
+		profile, ok := activeProfile(w, r, req.UserID)
+		if !ok {
 			return
 		}
 
-		// Parse pagination parameters
-		page, pageSize, err := utils.ParsePaginationParams(r)
-		if err != nil {
-			if pagErr, ok := err.(*utils.PaginationError); ok {
-				utils.WriteErrorResponse(w, http.StatusBadRequest, pagErr.Code, pagErr.Message, nil)
-			} else {
-				utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_PARAMETER", err.Error(), nil)
-			}
+		// Resolve the effective country (query, home country, country header, GeoIP, Accept-Language)
+		countryCode, ok := resolveCountry(w, r, countryResolver)
+		if !ok {
 			return
 		}
 
-		// Parse sort parameter (optional, default: -date_added)
-		sortBy := strings.TrimSpace(r.URL.Query().Get("sort"))
-		if sortBy == "" {
-			sortBy = "-date_added" // Default: newest first
-		} else {
-			// Validate sort parameter
-			if sortBy != "date_added" && sortBy != "-date_added" {
-				utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_SORT_PARAMETER",
-					"Invalid sort parameter: must be 'date_added' or '-date_added'", nil)
-				return
-			}
-		}
-
 		// Get saved movies from repository
-		movies, total, err := saveRepo.ListSavedMovies(r.Context(), userID, countryCode, page, pageSize, sortBy)
+		maxAge := agePolicy.ViewerAge(r)
+		movies, total, err := saveRepo.ListSavedMovies(r.Context(), profile.ID, countryCode, &maxAge, req.Page, req.PageSize, req.Sort)
 		if err != nil {
-			utils.WriteErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR",
-				"Failed to fetch saved movies: "+err.Error(), nil)
+			writeError(w, r, err, "Failed to fetch saved movies")
 			return
 		}
 
 		// Create simplified movie response (only id, title, year)
-		type MovieResponse struct {
-			ID    string `json:"id"`
-			Title string `json:"title"`
-			Year  int    `json:"year"`
-		}
-
 		movieResponses := make([]MovieResponse, len(movies))
 		for i, movie := range movies {
-			movieResponses[i] = MovieResponse{
-				ID:    movie.ID.String(),
-				Title: movie.Title,
-				Year:  movie.Year,
-			}
+			movieResponses[i] = toMovieResponse(movie)
 		}
 
 		// Create paginated response
-		response := utils.CreatePagedResponse(movieResponses, total, page, pageSize)
+		response := utils.CreatePagedResponse(movieResponses, total, req.Page, req.PageSize)
 
 		// Return JSON response
 		w.Header().Set("Content-Type", "application/json")
@@ -107,8 +112,17 @@ func ListSavedMovies(saveRepo repository.SaveMoviesRepository, movieRepo reposit
 	}
 }
 
+// saveMovieRequest is the schema of POST /users/{user_id}/movies
+type saveMovieRequest struct {
+	UserID  uuid.UUID `path:"user_id" code:"INVALID_USER_ID"`
+	Country string    `query:"country" validate:"iso3166"` // Read by the country resolver
+	Body    struct {
+		MovieID uuid.UUID `json:"movie_id" validate:"required" code:"INVALID_MOVIE_ID"`
+	} `body:"strict"`
+}
+
 // SaveMovie handles POST /users/{user_id}/movies - Save a movie for a user
-func SaveMovie(saveRepo repository.SaveMoviesRepository, movieRepo repository.MovieRepository) http.HandlerFunc {
+func SaveMovie(tx repository.Transactor, saveRepo repository.SaveMoviesRepository, movieRepo repository.MovieRepository, agePolicy AgePolicy, countryResolver *country.Resolver) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			utils.WriteErrorResponse(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED",
@@ -116,105 +130,94 @@ func SaveMovie(saveRepo repository.SaveMoviesRepository, movieRepo repository.Mo
 			return
 		}
 
-		// Parse user_id from URL path
-		vars := mux.Vars(r)
-		userIDStr := vars["user_id"]
-		userID, err := uuid.Parse(userIDStr)
-		if err != nil {
-			utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_USER_ID",
-				"Invalid user ID format", nil)
+		var req saveMovieRequest
+		if !validate.Bind(w, r, &req) {
 			return
 		}
+		movieID := req.Body.MovieID
 
-		// Parse and validate country parameter (required)
-		countryCode := strings.TrimSpace(r.URL.Query().Get("country"))
-		if countryCode == "" {
-			utils.WriteErrorResponse(w, http.StatusBadRequest, "MISSING_COUNTRY",
-				"Country parameter is required", nil)
-			return
-		}
-		countryCode = strings.ToUpper(countryCode)
-		if len(countryCode) != 2 {
-			utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_COUNTRY_CODE",
-				"Invalid country code: must be ISO-3166-1 alpha-2 format (2 characters)", nil)
+		// Saved movies belong to the caller's active profile
+		profile, ok := activeProfile(w, r, req.UserID)
+		if !ok {
 			return
 		}
 
-		// Parse request body
-		var req struct {
-			MovieID string `json:"movie_id"`
-		}
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST",
-				"Invalid request body", nil)
+		// Resolve the effective country (query, home country, country header, GeoIP, Accept-Language)
+		countryCode, ok := resolveCountry(w, r, countryResolver)
+		if !ok {
 			return
 		}
 
-		// Parse and validate movie_id
-		movieID, err := uuid.Parse(req.MovieID)
-		if err != nil {
-			utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_MOVIE_ID",
-				"Invalid movie_id: must be a valid UUID", nil)
-			return
-		}
+		// The checks, the save and the read of the saved movie see the same movie, even if it's
+		// changed or deleted meanwhile
+		var movie model.Movie
+		err := tx.WithTx(r.Context(), func(ctx context.Context) error {
+			// Validate movie exists
+			if _, err := movieRepo.GetMovieByID(ctx, movieID); err != nil {
+				return fmt.Errorf("fetch movie: %w", err)
+			}
 
-		// Validate movie exists
-		_, err = movieRepo.GetMovieByID(r.Context(), movieID)
-		if err != nil {
-			utils.WriteErrorResponse(w, http.StatusNotFound, "MOVIE_NOT_FOUND",
-				"Movie not found", nil)
-			return
-		}
+			// Validate movie is available in country
+			available, err := movieRepo.IsMovieAvailableInCountry(ctx, movieID, countryCode)
+			if err != nil {
+				return fmt.Errorf("check movie availability: %w", err)
+			}
+			if !available {
+				return errUnavailableInCountry
+			}
 
-		// Validate movie is available in country
-		available, err := movieRepo.IsMovieAvailableInCountry(r.Context(), movieID, countryCode)
-		if err != nil {
-			utils.WriteErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR",
-				"Failed to check movie availability: "+err.Error(), nil)
-			return
-		}
-		if !available {
+			// Validate the viewer is old enough for the movie's certification in country
+			cert, err := movieRepo.GetCertification(ctx, movieID, countryCode)
+			if err != nil {
+				return fmt.Errorf("check movie certification: %w", err)
+			}
+			if cert != nil && cert.MinAge > agePolicy.ViewerAge(r) {
+				return ageRestrictedError{cert: cert.Code}
+			}
+
+			// Save the movie; a duplicate save is 409 DUPLICATE_SAVE
+			if err := saveRepo.SaveMovie(ctx, profile.ID, movieID); err != nil {
+				return fmt.Errorf("save movie: %w", err)
+			}
+
+			// Get movie details to return
+			movie, err = movieRepo.GetMovieByID(ctx, movieID)
+			if err != nil {
+				return fmt.Errorf("fetch movie details: %w", err)
+			}
+			return nil
+		})
+		var restricted ageRestrictedError
+		switch {
+		case errors.Is(err, errUnavailableInCountry):
 			// Return 422 Unprocessable Entity with error code
+			metrics.UnavailableInCountry.WithLabelValues(countryCode).Inc()
 			utils.WriteErrorResponse(w, http.StatusUnprocessableEntity, ErrorCodeUnavailableInCountry,
 				"Movie is not available in the specified country", nil)
 			return
-		}
-
-		// Save the movie
-		err = saveRepo.SaveMovie(r.Context(), userID, movieID)
-		if err != nil {
-			// Check for duplicate save error
-			if strings.Contains(err.Error(), "already saved") {
-				utils.WriteErrorResponse(w, http.StatusConflict, ErrorCodeDuplicateSave,
-					"Movie is already saved", nil)
-				return
-			}
-			utils.WriteErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR",
-				"Failed to save movie: "+err.Error(), nil)
+		case errors.As(err, &restricted):
+			utils.WriteErrorResponse(w, http.StatusForbidden, ErrorCodeAgeRestricted,
+				"Movie is rated "+restricted.cert+" in the specified country and is restricted for this user", nil)
 			return
-		}
-
-		// Get movie details to return
-		movie, err := movieRepo.GetMovieByID(r.Context(), movieID)
-		if err != nil {
-			utils.WriteErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR",
-				"Failed to fetch movie details: "+err.Error(), nil)
+		case err != nil:
+			writeError(w, r, err, "Failed to save movie")
 			return
 		}
+		metrics.MoviesSaved.Inc()
 
 		// Return movie detail
-		response := map[string]interface{}{
-			"id":    movie.ID.String(),
-			"title": movie.Title,
-			"year":  movie.Year,
-		}
-
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(response)
+		json.NewEncoder(w).Encode(toMovieResponse(movie))
 	}
 }
 
+// removeSavedMovieRequest is the path of DELETE /users/{user_id}/movies/{movie_id}
+type removeSavedMovieRequest struct {
+	UserID  uuid.UUID `path:"user_id" code:"INVALID_USER_ID"`
+	MovieID uuid.UUID `path:"movie_id" code:"INVALID_MOVIE_ID"`
+}
+
 // RemoveSavedMovie handles DELETE /users/{user_id}/movies/{movie_id} - Remove a saved movie
 func RemoveSavedMovie(saveRepo repository.SaveMoviesRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -224,38 +227,25 @@ func RemoveSavedMovie(saveRepo repository.SaveMoviesRepository) http.HandlerFunc
 			return
 		}
 
-		// Parse user_id and movie_id from URL path
-		vars := mux.Vars(r)
-		userIDStr := vars["user_id"]
-		movieIDStr := vars["movie_id"]
-
-		userID, err := uuid.Parse(userIDStr)
-		if err != nil {
-			utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_USER_ID",
-				"Invalid user ID format", nil)
+		var req removeSavedMovieRequest
+		if !validate.Bind(w, r, &req) {
 			return
 		}
 
-		movieID, err := uuid.Parse(movieIDStr)
-		if err != nil {
-			utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_MOVIE_ID",
-				"Invalid movie ID format", nil)
+		// Saved movies belong to the caller's active profile
+		profile, ok := activeProfile(w, r, req.UserID)
+		if !ok {
 			return
 		}
 
 		// Remove the saved movie
-		err = saveRepo.RemoveSavedMovie(r.Context(), userID, movieID)
+		err := saveRepo.RemoveSavedMovie(r.Context(), profile.ID, req.MovieID)
 		if err != nil {
-			// Check for "not saved" error
-			if strings.Contains(err.Error(), "not saved") {
-				utils.WriteErrorResponse(w, http.StatusNotFound, ErrorCodeNotSaved,
-					"Movie is not saved", nil)
-				return
-			}
-			utils.WriteErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR",
-				"Failed to remove saved movie: "+err.Error(), nil)
+			// A movie that isn't saved is 404 NOT_SAVED
+			writeError(w, r, err, "Failed to remove saved movie")
 			return
 		}
+		metrics.MoviesRemoved.Inc()
 
 		// Return 204 No Content
 		w.WriteHeader(http.StatusNoContent)
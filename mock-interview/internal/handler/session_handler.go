@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/winfr1th/mock-interview/internal/audit"
+	"github.com/winfr1th/mock-interview/internal/auth"
+	"github.com/winfr1th/mock-interview/internal/middleware"
+	model "github.com/winfr1th/mock-interview/internal/models"
+	"github.com/winfr1th/mock-interview/internal/repository"
+	"github.com/winfr1th/mock-interview/internal/utils"
+	"github.com/winfr1th/mock-interview/internal/validate"
+)
+
+// Sessions issues short-lived access tokens with rotating refresh tokens
+type Sessions struct {
+	Tokens      *auth.Tokens
+	Refresh     repository.RefreshTokenRepository
+	Revocations *auth.KeyRevocations
+	// RefreshTTL is how long an unused refresh token stays valid
+	RefreshTTL time.Duration
+}
+
+// issue creates an access token and a refresh token in familyID for the user
+func (s Sessions) issue(ctx context.Context, user model.User, method string, familyID uuid.UUID) (model.AccessTokenResponse, error) {
+	accessToken, err := s.Tokens.Issue(user, method)
+	if err != nil {
+		return model.AccessTokenResponse{}, err
+	}
+
+	refreshToken, err := auth.GenerateRefreshToken()
+	if err != nil {
+		return model.AccessTokenResponse{}, err
+	}
+	stored := model.RefreshToken{
+		ID:         uuid.New(),
+		UserID:     user.ID,
+		FamilyID:   familyID,
+		TokenHash:  refreshToken.Hash, // Only the hash is stored
+		AuthMethod: method,
+		ExpiresAt:  time.Now().Add(s.RefreshTTL),
+	}
+	if method == auth.MethodAPIKey {
+		stored.APIKeyID = user.APIKeyID
+	}
+	if err := s.Refresh.CreateRefreshToken(ctx, stored); err != nil {
+		return model.AccessTokenResponse{}, err
+	}
+
+	return model.AccessTokenResponse{
+		AccessToken:  accessToken.Token,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.Tokens.TTL().Seconds()),
+		RefreshToken: refreshToken.Token,
+		UserID:       user.ID,
+	}, nil
+}
+
+// revokeAPIKey ends every session exchanged from the API key
+func (s Sessions) revokeAPIKey(ctx context.Context, keyID string) error {
+	if keyID == "" {
+		return nil
+	}
+	s.Revocations.Revoke(keyID)
+	return s.Refresh.RevokeAPIKeyTokens(ctx, keyID)
+}
+
+// IssueToken handles POST /auth/token - Exchange the caller's API key for an access token and refresh token
+func IssueToken(sessions Sessions, recorder *audit.Recorder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			utils.WriteErrorResponse(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED",
+				"Method not allowed", nil)
+			return
+		}
+
+		// Access tokens can't be exchanged for new ones; that's what refresh tokens are for
+		if kind := middleware.GetCredentialKind(r); kind != middleware.CredentialAPIKey && kind != middleware.CredentialSignature {
+			utils.WriteErrorResponse(w, http.StatusForbidden, "API_KEY_REQUIRED",
+				"Only an API key or signed request can be exchanged for tokens", nil)
+			return
+		}
+		caller, _ := middleware.GetUser(r)
+		if caller.APIKeyID == "" {
+			utils.WriteErrorResponse(w, http.StatusBadRequest, "LEGACY_API_KEY",
+				"Rotate your legacy API key before exchanging it for tokens", nil)
+			return
+		}
+
+		response, err := sessions.issue(r.Context(), caller, auth.MethodAPIKey, uuid.New())
+		if err != nil {
+			utils.WriteErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR",
+				"Failed to issue tokens", nil)
+			return
+		}
+
+		recorder.Record(r.Context(), audit.EventSessionCreated, &caller.ID, audit.TargetUser, caller.ID.String(),
+			map[string]string{"method": auth.MethodAPIKey})
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// refreshTokenRequest is the body of POST /auth/refresh
+type refreshTokenRequest struct {
+	Body model.RefreshTokenRequest `body:"strict"`
+}
+
+// RefreshToken handles POST /auth/refresh - Replace a refresh token with a new access token and refresh token.
+// Presenting a refresh token that was already used revokes every token descended from the same session.
+func RefreshToken(userRepo repository.UserRepository, sessions Sessions, recorder *audit.Recorder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			utils.WriteErrorResponse(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED",
+				"Method not allowed", nil)
+			return
+		}
+
+		var req refreshTokenRequest
+		if !validate.Bind(w, r, &req) {
+			return
+		}
+
+		stored, err := sessions.Refresh.UseRefreshToken(r.Context(), auth.HashRefreshToken(req.Body.RefreshToken))
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrRefreshTokenReused):
+				// Someone holds a copy of this session's tokens; end it for everyone
+				if err := sessions.Refresh.RevokeFamily(r.Context(), stored.FamilyID); err != nil {
+					utils.WriteErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR",
+						"Failed to revoke session", nil)
+					return
+				}
+				recorder.Record(r.Context(), audit.EventSessionReused, &stored.UserID, audit.TargetUser,
+					stored.UserID.String(), map[string]string{"family_id": stored.FamilyID.String()})
+				utils.WriteErrorResponse(w, http.StatusUnauthorized, "REFRESH_TOKEN_REUSED",
+					"Refresh token was already used; the session has been revoked", nil)
+			case errors.Is(err, repository.ErrRefreshTokenNotFound):
+				utils.WriteErrorResponse(w, http.StatusUnauthorized, "INVALID_REFRESH_TOKEN",
+					"Invalid, expired or revoked refresh token", nil)
+			default:
+				utils.WriteErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR",
+					"Failed to refresh session", nil)
+			}
+			return
+		}
+
+		// Pick up changes to the user since the session started
+		user, err := userRepo.FindUserByID(r.Context(), stored.UserID.String())
+		if err != nil {
+			utils.WriteErrorResponse(w, http.StatusUnauthorized, "INVALID_REFRESH_TOKEN",
+				"Invalid, expired or revoked refresh token", nil)
+			return
+		}
+		if stored.AuthMethod == auth.MethodAPIKey && stored.APIKeyID != user.APIKeyID {
+			utils.WriteErrorResponse(w, http.StatusUnauthorized, "INVALID_REFRESH_TOKEN",
+				"Invalid, expired or revoked refresh token", nil)
+			return
+		}
+
+		response, err := sessions.issue(r.Context(), user, stored.AuthMethod, stored.FamilyID)
+		if err != nil {
+			utils.WriteErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR",
+				"Failed to issue tokens", nil)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(response)
+	}
+}
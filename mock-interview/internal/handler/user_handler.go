@@ -1,18 +1,24 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"github.com/winfr1th/mock-interview/internal/audit"
 	"github.com/winfr1th/mock-interview/internal/auth"
+	"github.com/winfr1th/mock-interview/internal/metrics"
+	"github.com/winfr1th/mock-interview/internal/middleware"
 	model "github.com/winfr1th/mock-interview/internal/models"
 	"github.com/winfr1th/mock-interview/internal/repository"
 	"github.com/winfr1th/mock-interview/internal/utils"
+	"github.com/winfr1th/mock-interview/internal/validate"
 )
 
-func CreateUser(repo repository.UserRepository) http.HandlerFunc {
+func CreateUser(repo repository.UserRepository, signing *auth.SigningSecrets, recorder *audit.Recorder) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			utils.WriteErrorResponse(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED",
@@ -20,18 +26,15 @@ func CreateUser(repo repository.UserRepository) http.HandlerFunc {
 			return
 		}
 
-		var req model.RegisterRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_REQUEST",
-				"Invalid request body", nil)
+		var req registerRequest
+		if !validate.Bind(w, r, &req) {
 			return
 		}
 
-		// Validate required fields
-		if req.Name == "" || req.DateOfBirth == "" {
-			utils.WriteErrorResponse(w, http.StatusBadRequest, "MISSING_FIELDS",
-				"Name and date_of_birth are required", nil)
-			return
+		// home_country is optional
+		var homeCountry *string
+		if req.Body.HomeCountry != "" {
+			homeCountry = &req.Body.HomeCountry
 		}
 
 		// Generate API key
@@ -45,21 +48,29 @@ func CreateUser(repo repository.UserRepository) http.HandlerFunc {
 		// Create user
 		user := model.User{
 			ID:          uuid.New(),
-			Name:        req.Name,
-			DateOfBirth: req.DateOfBirth,
-			APIKeyHash:  apiKey, // Store plain API key
+			HouseholdID: uuid.New(),
+			Name:        req.Body.Name,
+			DateOfBirth: req.dateOfBirth,
+			HomeCountry: homeCountry,
+			APIKeyID:    apiKey.ID,
+			APIKeyHash:  apiKey.Hash, // Only the hash is stored
 		}
 
 		if err := repo.CreateUser(r.Context(), user); err != nil {
-			utils.WriteErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR",
-				"Failed to create user: "+err.Error(), nil)
+			writeError(w, r, err, "Failed to create user")
 			return
 		}
 
+		caller, _ := middleware.GetUser(r)
+		recorder.Record(r.Context(), audit.EventUserCreated, &caller.ID, audit.TargetUser, user.ID.String(), nil)
+		recorder.Record(r.Context(), audit.EventAPIKeyCreated, &caller.ID, audit.TargetUser, user.ID.String(), nil)
+		metrics.UserRegistrations.WithLabelValues(metrics.RegistrationCreateUser).Inc()
+
 		// Return response with API key (only time it's returned)
 		response := model.RegisterResponse{
-			UserID: user.ID,
-			APIKey: apiKey,
+			UserID:        user.ID,
+			APIKey:        apiKey.Key,
+			SigningSecret: signing.For(apiKey.Hash),
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -68,19 +79,239 @@ func CreateUser(repo repository.UserRepository) http.HandlerFunc {
 	}
 }
 
+// getUserRequest is the path of GET /users/{id}
+type getUserRequest struct {
+	ID uuid.UUID `path:"id" code:"INVALID_USER_ID"`
+}
+
 func GetUserByID(repo repository.UserRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		vars := mux.Vars(r)
-		id := vars["id"]
+		var req getUserRequest
+		if !validate.Bind(w, r, &req) {
+			return
+		}
+
+		user, err := repo.FindUserByID(r.Context(), req.ID.String())
+		if err != nil {
+			writeError(w, r, err, "Failed to fetch user")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(user)
+	}
+}
+
+// updateUserRequest is the schema of PATCH /users/{id}
+type updateUserRequest struct {
+	Body        model.UpdateUserRequest `body:"strict"`
+	dateOfBirth model.Date
+}
+
+func (req *updateUserRequest) Check(v *validate.Validator) {
+	if req.Body.DateOfBirth != nil {
+		req.dateOfBirth = v.DateOfBirth(validate.Field("date_of_birth"), *req.Body.DateOfBirth)
+	}
+}
+
+// UpdateUser handles PATCH /users/{id} - Update the caller's name, date of birth or home country
+func UpdateUser(repo repository.UserRepository, recorder *audit.Recorder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch {
+			utils.WriteErrorResponse(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED",
+				"Method not allowed", nil)
+			return
+		}
+
+		// Users can only update themselves
+		caller, ok := requireSelf(w, r)
+		if !ok {
+			return
+		}
+		id := caller.ID.String()
+
+		var req updateUserRequest
+		if !validate.Bind(w, r, &req) {
+			return
+		}
 
 		user, err := repo.FindUserByID(r.Context(), id)
 		if err != nil {
-			utils.WriteErrorResponse(w, http.StatusNotFound, "USER_NOT_FOUND",
-				"User not found", nil)
+			writeError(w, r, err, "Failed to fetch user")
+			return
+		}
+
+		if req.Body.Name != nil {
+			user.Name = *req.Body.Name
+		}
+		if req.Body.DateOfBirth != nil {
+			user.DateOfBirth = req.dateOfBirth
+		}
+
+		// An empty home_country clears it
+		if req.Body.HomeCountry != nil {
+			if *req.Body.HomeCountry == "" {
+				user.HomeCountry = nil
+			} else {
+				user.HomeCountry = req.Body.HomeCountry
+			}
+		}
+
+		if err := repo.UpdateUser(r.Context(), user); err != nil {
+			writeError(w, r, err, "Failed to update user")
 			return
 		}
 
+		// Record which fields changed, not their values
+		var fields []string
+		if req.Body.Name != nil {
+			fields = append(fields, "name")
+		}
+		if req.Body.DateOfBirth != nil {
+			fields = append(fields, "date_of_birth")
+		}
+		if req.Body.HomeCountry != nil {
+			fields = append(fields, "home_country")
+		}
+		recorder.Record(r.Context(), audit.EventUserUpdated, &caller.ID, audit.TargetUser, user.ID.String(),
+			map[string]string{"fields": strings.Join(fields, ",")})
+
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(user)
 	}
 }
+
+// DeleteUser handles DELETE /users/{id} - Delete the caller's account and household
+func DeleteUser(tx repository.Transactor, repo repository.UserRepository, sessions Sessions, recorder *audit.Recorder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			utils.WriteErrorResponse(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED",
+				"Method not allowed", nil)
+			return
+		}
+
+		caller, ok := requireSelf(w, r)
+		if !ok {
+			return
+		}
+
+		// The key revoked is the one the deleted user had, even if it's rotated meanwhile
+		var current model.User
+		err := tx.WithTx(r.Context(), func(ctx context.Context) error {
+			var err error
+			if current, err = repo.FindUserByIDForUpdate(ctx, caller.ID.String()); err != nil {
+				return err
+			}
+			return repo.DeleteUser(ctx, caller.ID.String())
+		})
+		if err != nil {
+			writeError(w, r, err, "Failed to delete user")
+			return
+		}
+		// Refresh tokens go with the user; access tokens from its key stop working now
+		sessions.Revocations.Revoke(current.APIKeyID)
+
+		recorder.Record(r.Context(), audit.EventUserDeleted, &caller.ID, audit.TargetUser, caller.ID.String(), nil)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// RotateAPIKey handles POST /users/{id}/api-key - Replace the caller's API key
+func RotateAPIKey(repo repository.UserRepository, signing *auth.SigningSecrets, sessions Sessions, recorder *audit.Recorder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			utils.WriteErrorResponse(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED",
+				"Method not allowed", nil)
+			return
+		}
+
+		caller, ok := requireSelf(w, r)
+		if !ok {
+			return
+		}
+
+		current, err := repo.FindUserByID(r.Context(), caller.ID.String())
+		if err != nil {
+			writeError(w, r, err, "Failed to fetch user")
+			return
+		}
+
+		apiKey, err := auth.GenerateAPIKey()
+		if err != nil {
+			utils.WriteErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR",
+				"Failed to generate API key", nil)
+			return
+		}
+
+		if err := repo.UpdateAPIKey(r.Context(), caller.ID, apiKey.ID, apiKey.Hash); err != nil {
+			writeError(w, r, err, "Failed to rotate API key")
+			return
+		}
+
+		// Sessions exchanged from the old key end with it
+		if err := sessions.revokeAPIKey(r.Context(), current.APIKeyID); err != nil {
+			writeError(w, r, err, "Failed to revoke sessions of the old API key")
+			return
+		}
+
+		recorder.Record(r.Context(), audit.EventAPIKeyRotated, &caller.ID, audit.TargetUser, caller.ID.String(), nil)
+
+		// Return the new key (only time it's returned)
+		response := model.RegisterResponse{
+			UserID:        caller.ID,
+			APIKey:        apiKey.Key,
+			SigningSecret: signing.For(apiKey.Hash),
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// RevokeAPIKey handles DELETE /users/{id}/api-key - Revoke the caller's API key
+func RevokeAPIKey(repo repository.UserRepository, sessions Sessions, recorder *audit.Recorder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			utils.WriteErrorResponse(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED",
+				"Method not allowed", nil)
+			return
+		}
+
+		caller, ok := requireSelf(w, r)
+		if !ok {
+			return
+		}
+
+		current, err := repo.FindUserByID(r.Context(), caller.ID.String())
+		if err != nil {
+			writeError(w, r, err, "Failed to fetch user")
+			return
+		}
+
+		if err := repo.RevokeAPIKey(r.Context(), caller.ID); err != nil {
+			writeError(w, r, err, "Failed to revoke API key")
+			return
+		}
+
+		if err := sessions.revokeAPIKey(r.Context(), current.APIKeyID); err != nil {
+			writeError(w, r, err, "Failed to revoke sessions of the API key")
+			return
+		}
+
+		recorder.Record(r.Context(), audit.EventAPIKeyRevoked, &caller.ID, audit.TargetUser, caller.ID.String(), nil)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// requireSelf returns the caller when the path's {id} is the caller, writing 403 otherwise
+func requireSelf(w http.ResponseWriter, r *http.Request) (model.User, bool) {
+	caller, ok := middleware.GetUser(r)
+	if !ok || caller.ID.String() != mux.Vars(r)["id"] {
+		utils.WriteErrorResponse(w, http.StatusForbidden, "FORBIDDEN",
+			"Cannot act on another user", nil)
+		return model.User{}, false
+	}
+	return caller, true
+}
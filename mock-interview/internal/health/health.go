@@ -0,0 +1,113 @@
+// Package health runs dependency checks for probes and status reports. Checks run concurrently,
+// each under its own timeout, so one slow dependency doesn't hang the others or the probe.
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout bounds a check that doesn't set its own
+const DefaultTimeout = 2 * time.Second
+
+// Statuses of a check, and of a set of checks
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Check is one dependency check
+type Check struct {
+	Name    string
+	Timeout time.Duration // DefaultTimeout when zero
+	Run     func(ctx context.Context) error
+}
+
+// Result is the outcome of a check
+type Result struct {
+	Status     string  `json:"status"`
+	Error      string  `json:"error,omitempty"`
+	DurationMS float64 `json:"duration_ms"`
+}
+
+// Report is the outcome of a set of checks; its status is ok only when every check's is
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// OK reports whether every check passed
+func (r Report) OK() bool {
+	return r.Status == StatusOK
+}
+
+// Redacted returns the report without the checks' errors, which can name hosts, users or
+// schema details, for unauthenticated callers
+func (r Report) Redacted() Report {
+	redacted := Report{Status: r.Status, Checks: make(map[string]Result, len(r.Checks))}
+	for name, result := range r.Checks {
+		result.Error = ""
+		redacted.Checks[name] = result
+	}
+	return redacted
+}
+
+// Run runs checks concurrently and waits for each to pass, fail or time out
+func Run(ctx context.Context, checks []Check) Report {
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := run(ctx, check)
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[check.Name] = result
+			if result.Status != StatusOK {
+				report.Status = StatusFail
+			}
+		}()
+	}
+	wg.Wait()
+	return report
+}
+
+func run(ctx context.Context, check Check) Result {
+	timeout := check.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- check.Run(ctx)
+	}()
+
+	// Don't wait past the timeout for a check that ignores its context
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = errors.New("timed out after " + timeout.String())
+	}
+
+	result := Result{
+		Status:     StatusOK,
+		DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -0,0 +1,59 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSlowCheckTimesOutAlone(t *testing.T) {
+	hang := make(chan struct{})
+	defer close(hang)
+
+	checks := []Check{
+		{Name: "fast", Run: func(ctx context.Context) error { return nil }},
+		{Name: "failing", Run: func(ctx context.Context) error { return errors.New("connection refused") }},
+		{
+			// Ignores its context, like a driver call stuck on the network
+			Name:    "slow",
+			Timeout: 20 * time.Millisecond,
+			Run: func(ctx context.Context) error {
+				<-hang
+				return nil
+			},
+		},
+	}
+
+	start := time.Now()
+	report := Run(context.Background(), checks)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Run took %s", elapsed)
+	}
+
+	if report.OK() {
+		t.Error("report is ok with failing checks")
+	}
+	if got := report.Checks["fast"]; got.Status != StatusOK {
+		t.Errorf("fast: %+v", got)
+	}
+	if got := report.Checks["failing"]; got.Status != StatusFail || got.Error != "connection refused" {
+		t.Errorf("failing: %+v", got)
+	}
+	if got := report.Checks["slow"]; got.Status != StatusFail || got.Error != "timed out after 20ms" {
+		t.Errorf("slow: %+v", got)
+	}
+
+	redacted := report.Redacted()
+	if redacted.OK() || len(redacted.Checks) != len(checks) {
+		t.Errorf("redacted report %+v, want the statuses of every check", redacted)
+	}
+	for name, result := range redacted.Checks {
+		if result.Error != "" || result.Status != report.Checks[name].Status || result.DurationMS != report.Checks[name].DurationMS {
+			t.Errorf("redacted %s: %+v, want %+v without its error", name, result, report.Checks[name])
+		}
+	}
+	if report.Checks["failing"].Error == "" {
+		t.Error("Redacted changed the report it was called on")
+	}
+}
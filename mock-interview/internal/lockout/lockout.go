@@ -0,0 +1,230 @@
+package lockout
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Kind is what a failed attempt is tracked by
+type Kind string
+
+const (
+	KindIP        Kind = "ip"
+	KindKeyPrefix Kind = "key_prefix"
+	KindProfile   Kind = "profile" // Wrong PINs of a profile, by profile ID
+)
+
+// Subject is one thing failed attempts are counted against, e.g. an IP or an API key prefix
+type Subject struct {
+	Kind  Kind   `json:"kind"`
+	Value string `json:"value"`
+}
+
+// Config controls how quickly failed attempts are slowed down and blocked
+type Config struct {
+	// DelayAfter is the number of failures allowed before delays start
+	DelayAfter int
+	// BaseDelay is the first delay; it doubles with each further failure up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// BlockAfter is the number of failures that blocks the subject for BlockDuration
+	BlockAfter    int
+	BlockDuration time.Duration
+	// Window is how long failures are remembered after the last one
+	Window time.Duration
+}
+
+var DefaultConfig = Config{
+	DelayAfter:    3,
+	BaseDelay:     time.Second,
+	MaxDelay:      30 * time.Second,
+	BlockAfter:    10,
+	BlockDuration: 15 * time.Minute,
+	Window:        15 * time.Minute,
+}
+
+// Status is the failure state of a subject
+type Status struct {
+	Subject
+	Failures     int       `json:"failures"`
+	LastFailure  time.Time `json:"last_failure"`
+	BlockedUntil time.Time `json:"blocked_until,omitempty"`
+}
+
+// Blocked reports whether the subject is blocked at now
+func (s Status) Blocked(now time.Time) bool {
+	return now.Before(s.BlockedUntil)
+}
+
+// Recorder is told about every failed attempt and every new block, e.g. to write audit entries
+type Recorder interface {
+	RecordFailure(ctx context.Context, subjects []Subject)
+	RecordBlock(ctx context.Context, status Status)
+}
+
+// LogRecorder writes blocks to the standard logger and ignores single failures
+type LogRecorder struct{}
+
+func (LogRecorder) RecordFailure(ctx context.Context, subjects []Subject) {}
+
+func (LogRecorder) RecordBlock(ctx context.Context, status Status) {
+	slog.WarnContext(ctx, "audit: auth blocked", "kind", status.Kind, "value", status.Value,
+		"failures", status.Failures, "blocked_until", status.BlockedUntil.Format(time.RFC3339))
+}
+
+// Tracker counts failed authentication attempts in memory. Counts are per instance.
+type Tracker struct {
+	mu        sync.Mutex
+	cfg       Config
+	entries   map[Subject]*Status
+	recorder  Recorder
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewTracker(cfg Config, recorder Recorder) *Tracker {
+	return &Tracker{
+		cfg:      cfg,
+		entries:  make(map[Subject]*Status),
+		recorder: recorder,
+		now:      time.Now,
+	}
+}
+
+// Check returns how long the caller must wait before another attempt, and whether that's
+// because of a block rather than a delay. A zero wait means the attempt may proceed.
+func (t *Tracker) Check(subjects ...Subject) (wait time.Duration, blocked bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	for _, subject := range subjects {
+		status, ok := t.entries[subject]
+		if !ok {
+			continue
+		}
+		if status.Blocked(now) {
+			if w := status.BlockedUntil.Sub(now); w > wait || !blocked {
+				wait = w
+			}
+			blocked = true
+			continue
+		}
+		if blocked {
+			continue
+		}
+		if w := status.LastFailure.Add(t.delay(status.Failures)).Sub(now); w > wait {
+			wait = w
+		}
+	}
+	return wait, blocked
+}
+
+// RecordFailure counts a failed attempt against every subject, blocking those that reach the threshold
+func (t *Tracker) RecordFailure(ctx context.Context, subjects ...Subject) {
+	t.mu.Lock()
+	now := t.now()
+	t.sweep(now)
+
+	var newBlocks []Status
+	for _, subject := range subjects {
+		status, ok := t.entries[subject]
+		if !ok || now.Sub(status.LastFailure) > t.cfg.Window {
+			status = &Status{Subject: subject}
+			t.entries[subject] = status
+		}
+		status.Failures++
+		status.LastFailure = now
+
+		if status.Failures >= t.cfg.BlockAfter && !status.Blocked(now) {
+			status.BlockedUntil = now.Add(t.cfg.BlockDuration)
+			newBlocks = append(newBlocks, *status)
+		}
+	}
+	t.mu.Unlock()
+
+	if t.recorder != nil {
+		t.recorder.RecordFailure(ctx, subjects)
+		for _, status := range newBlocks {
+			t.recorder.RecordBlock(ctx, status)
+		}
+	}
+}
+
+// RecordSuccess forgets earlier failures of the subjects
+func (t *Tracker) RecordSuccess(subjects ...Subject) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, subject := range subjects {
+		if status, ok := t.entries[subject]; ok && !status.Blocked(t.now()) {
+			delete(t.entries, subject)
+		}
+	}
+}
+
+// List returns the subjects with recent failures, blocked ones first
+func (t *Tracker) List() []Status {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	t.sweep(now)
+
+	statuses := make([]Status, 0, len(t.entries))
+	for _, status := range t.entries {
+		statuses = append(statuses, *status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		bi, bj := statuses[i].Blocked(now), statuses[j].Blocked(now)
+		if bi != bj {
+			return bi
+		}
+		return statuses[i].LastFailure.After(statuses[j].LastFailure)
+	})
+	return statuses
+}
+
+// Clear removes a subject's failures and block. It reports whether there was anything to clear.
+func (t *Tracker) Clear(subject Subject) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.entries[subject]; !ok {
+		return false
+	}
+	delete(t.entries, subject)
+	return true
+}
+
+// delay is the wait required after the given number of failures
+func (t *Tracker) delay(failures int) time.Duration {
+	if failures <= t.cfg.DelayAfter {
+		return 0
+	}
+	delay := t.cfg.BaseDelay
+	for i := t.cfg.DelayAfter + 1; i < failures && delay < t.cfg.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > t.cfg.MaxDelay {
+		delay = t.cfg.MaxDelay
+	}
+	return delay
+}
+
+// sweep drops subjects whose failures have expired and that are no longer blocked
+func (t *Tracker) sweep(now time.Time) {
+	if now.Sub(t.lastSweep) < time.Minute {
+		return
+	}
+	t.lastSweep = now
+
+	for subject, status := range t.entries {
+		if !status.Blocked(now) && now.Sub(status.LastFailure) > t.cfg.Window {
+			delete(t.entries, subject)
+		}
+	}
+}
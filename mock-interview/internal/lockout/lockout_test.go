@@ -0,0 +1,123 @@
+package lockout
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// countingRecorder counts what the tracker records
+type countingRecorder struct {
+	failures int
+	blocks   []Status
+}
+
+func (r *countingRecorder) RecordFailure(ctx context.Context, subjects []Subject) { r.failures++ }
+func (r *countingRecorder) RecordBlock(ctx context.Context, status Status) {
+	r.blocks = append(r.blocks, status)
+}
+
+func newTestTracker(now *time.Time) (*Tracker, *countingRecorder) {
+	recorder := &countingRecorder{}
+	t := NewTracker(DefaultConfig, recorder)
+	t.now = func() time.Time { return *now }
+	return t, recorder
+}
+
+func TestDelayDoublesUpToTheMaximum(t *testing.T) {
+	tracker := NewTracker(DefaultConfig, nil)
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{3, 0},
+		{4, time.Second},
+		{5, 2 * time.Second},
+		{6, 4 * time.Second},
+		{8, 16 * time.Second},
+		{9, 30 * time.Second},
+		{50, 30 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := tracker.delay(tt.failures); got != tt.want {
+			t.Errorf("delay(%d) = %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestFailuresDelayThenBlock(t *testing.T) {
+	now := time.Unix(1735689600, 0)
+	tracker, recorder := newTestTracker(&now)
+	ip := Subject{Kind: KindIP, Value: "192.0.2.1"}
+	key := Subject{Kind: KindKeyPrefix, Value: "mvk_live_AbCdEfGhIjKl"}
+
+	for range DefaultConfig.DelayAfter {
+		tracker.RecordFailure(context.Background(), ip, key)
+	}
+	if wait, blocked := tracker.Check(ip, key); wait != 0 || blocked {
+		t.Errorf("Check after %d failures = %s, %v; want no wait", DefaultConfig.DelayAfter, wait, blocked)
+	}
+	tracker.RecordFailure(context.Background(), ip, key)
+	if wait, blocked := tracker.Check(ip); wait != time.Second || blocked {
+		t.Errorf("Check after a failure past the delay threshold = %s, %v; want 1s", wait, blocked)
+	}
+	now = now.Add(time.Second)
+	if wait, _ := tracker.Check(ip); wait != 0 {
+		t.Errorf("Check once the delay passed = %s, want 0", wait)
+	}
+
+	for range DefaultConfig.BlockAfter - DefaultConfig.DelayAfter - 1 {
+		tracker.RecordFailure(context.Background(), ip)
+	}
+	wait, blocked := tracker.Check(ip, key)
+	if !blocked || wait != DefaultConfig.BlockDuration {
+		t.Errorf("Check after %d failures = %s, %v; want blocked for %s", DefaultConfig.BlockAfter, wait, blocked, DefaultConfig.BlockDuration)
+	}
+	if recorder.failures != DefaultConfig.BlockAfter || len(recorder.blocks) != 1 || recorder.blocks[0].Subject != ip {
+		t.Errorf("recorded %d failures and blocks %+v, want %d and the IP's block", recorder.failures, recorder.blocks, DefaultConfig.BlockAfter)
+	}
+
+	// Failures while blocked don't extend or re-record the block, and success doesn't lift it
+	tracker.RecordFailure(context.Background(), ip)
+	tracker.RecordSuccess(ip)
+	if _, blocked := tracker.Check(ip); !blocked || len(recorder.blocks) != 1 {
+		t.Errorf("blocked %v with %d blocks recorded, want still blocked and one block", blocked, len(recorder.blocks))
+	}
+
+	// The block ends, and the failures are forgotten a window after the last one
+	now = now.Add(DefaultConfig.BlockDuration)
+	if _, blocked := tracker.Check(ip); blocked {
+		t.Error("still blocked after the block duration")
+	}
+	now = now.Add(DefaultConfig.Window)
+	tracker.RecordFailure(context.Background(), ip)
+	if statuses := tracker.List(); len(statuses) != 1 || statuses[0].Failures != 1 {
+		t.Errorf("List = %+v, want the IP's count to start over", statuses)
+	}
+}
+
+func TestSuccessAndClearForgetFailures(t *testing.T) {
+	now := time.Unix(1735689600, 0)
+	tracker, _ := newTestTracker(&now)
+	key := Subject{Kind: KindKeyPrefix, Value: "mvk_live_AbCdEfGhIjKl"}
+	profile := Subject{Kind: KindProfile, Value: "d9428888-122b-11e1-b85c-61cd3cbb3210"}
+
+	for range 5 {
+		tracker.RecordFailure(context.Background(), key)
+	}
+	tracker.RecordSuccess(key)
+	if wait, _ := tracker.Check(key); wait != 0 {
+		t.Errorf("Check after a success = %s, want 0", wait)
+	}
+
+	for range DefaultConfig.BlockAfter {
+		tracker.RecordFailure(context.Background(), profile)
+	}
+	if !tracker.Clear(profile) || tracker.Clear(profile) {
+		t.Error("Clear should report the block once")
+	}
+	if _, blocked := tracker.Check(profile); blocked {
+		t.Error("still blocked after Clear")
+	}
+}
@@ -0,0 +1,133 @@
+// Package logging sets up structured JSON logging with log/slog. Records logged with a
+// request's context carry its request ID and trace, and credentials are redacted from
+// every record before it is written.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Redacted replaces values that must never reach logs
+const Redacted = "[REDACTED]"
+
+// RedactedKeys are attribute keys whose values are always redacted, compared case-insensitively.
+// Legacy UUID API keys can't be told apart from other UUIDs by value, so they are only kept out
+// of logs by being logged under one of these keys, if at all.
+var RedactedKeys = []string{
+	"authorization",
+	"x-api-key",
+	"api_key",
+	"x-signature",
+	"cookie",
+	"set-cookie",
+	"password",
+	"pin",
+	"token",
+	"access_token",
+	"refresh_token",
+	"client_secret",
+	"signing_key",
+}
+
+// secretPatterns find credentials in messages and string values. API keys keep their key ID,
+// which is stored in plain text and identifies the key without granting access.
+var secretPatterns = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`\b(mvk_[a-z]+_[0-9A-Za-z]+_)[0-9A-Za-z]+`), "${1}" + Redacted},
+	{regexp.MustCompile(`\bmvr_[0-9A-Za-z]+`), "mvr_" + Redacted},
+	{regexp.MustCompile(`\beyJ[0-9A-Za-z_-]*\.[0-9A-Za-z_-]*\.[0-9A-Za-z_-]*`), Redacted},
+	{regexp.MustCompile(`(?i)\b(bearer\s+)\S+`), "${1}" + Redacted},
+}
+
+// Attribute keys added to records logged with a request's context
+const (
+	RequestIDKey = "request_id"
+	TraceIDKey   = "trace_id"
+	SpanIDKey    = "span_id"
+)
+
+type requestIDKey struct{}
+
+// WithRequestID stores the request ID in the context, for logs and error responses
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID stored by WithRequestID, or ""
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// New returns a logger writing JSON lines to w at level and above
+func New(w io.Writer, level slog.Leveler) *slog.Logger {
+	return slog.New(NewHandler(slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redactAttr,
+	})))
+}
+
+// Redact replaces the credentials found in s
+func Redact(s string) string {
+	for _, p := range secretPatterns {
+		s = p.pattern.ReplaceAllString(s, p.replacement)
+	}
+	return s
+}
+
+// redactAttr is the ReplaceAttr of the JSON handler. It also sees the message, so
+// credentials formatted into it by log.Printf are redacted too.
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	for _, key := range RedactedKeys {
+		if strings.EqualFold(a.Key, key) {
+			return slog.String(a.Key, Redacted)
+		}
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(Redact(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			a.Value = slog.StringValue(Redact(err.Error()))
+		}
+	}
+	return a
+}
+
+// Handler adds the request ID and trace of the record's context to each record
+type Handler struct {
+	slog.Handler
+}
+
+// NewHandler wraps next
+func NewHandler(next slog.Handler) *Handler {
+	return &Handler{Handler: next}
+}
+
+func (h *Handler) Handle(ctx context.Context, record slog.Record) error {
+	if ctx != nil {
+		if id := RequestID(ctx); id != "" {
+			record.AddAttrs(slog.String(RequestIDKey, id))
+		}
+		if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+			record.AddAttrs(slog.String(TraceIDKey, sc.TraceID().String()), slog.String(SpanIDKey, sc.SpanID().String()))
+		}
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &Handler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{Handler: h.Handler.WithGroup(name)}
+}
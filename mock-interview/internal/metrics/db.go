@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/winfr1th/mock-interview/internal/database"
+)
+
+// QueryTracer is a pgx tracer timing every query into DBQueryDuration
+type QueryTracer struct{}
+
+type queryStartKey struct{}
+
+type queryStart struct {
+	name  string
+	start time.Time
+}
+
+func (QueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, queryStartKey{}, queryStart{name: database.StatementName(data.SQL), start: time.Now()})
+}
+
+func (QueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	started, ok := ctx.Value(queryStartKey{}).(queryStart)
+	if !ok {
+		return
+	}
+	status := "ok"
+	if data.Err != nil && !errors.Is(data.Err, pgx.ErrNoRows) {
+		status = "error"
+	}
+	DBQueryDuration.WithLabelValues(started.name, status).Observe(time.Since(started.start).Seconds())
+}
+
+// PoolCollector reports a pgx pool's connections and acquisitions
+type PoolCollector struct {
+	pool *pgxpool.Pool
+
+	maxConns, totalConns, acquiredConns, idleConns, constructingConns *prometheus.Desc
+	acquires, acquireDuration, emptyAcquires, canceledAcquires        *prometheus.Desc
+	newConns, lifetimeDestroys, idleDestroys                          *prometheus.Desc
+}
+
+func NewPoolCollector(pool *pgxpool.Pool) *PoolCollector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", name), help, nil, nil)
+	}
+	return &PoolCollector{
+		pool:              pool,
+		maxConns:          desc("max_conns", "Maximum size of the pool."),
+		totalConns:        desc("conns", "Connections in the pool: acquired, idle and being constructed."),
+		acquiredConns:     desc("acquired_conns", "Connections acquired by queries."),
+		idleConns:         desc("idle_conns", "Idle connections."),
+		constructingConns: desc("constructing_conns", "Connections being established."),
+		acquires:          desc("acquires_total", "Successful connection acquisitions."),
+		acquireDuration:   desc("acquire_duration_seconds_total", "Time spent acquiring connections."),
+		emptyAcquires:     desc("empty_acquires_total", "Acquisitions that waited because no connection was idle."),
+		canceledAcquires:  desc("canceled_acquires_total", "Acquisitions canceled by their context."),
+		newConns:          desc("new_conns_total", "Connections opened."),
+		lifetimeDestroys:  desc("max_lifetime_destroys_total", "Connections closed for reaching their maximum lifetime."),
+		idleDestroys:      desc("max_idle_destroys_total", "Connections closed for idling too long."),
+	}
+}
+
+func (c *PoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c *PoolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.pool.Stat()
+	gauge := func(desc *prometheus.Desc, v int32) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(v))
+	}
+	counter := func(desc *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, v)
+	}
+	gauge(c.maxConns, stat.MaxConns())
+	gauge(c.totalConns, stat.TotalConns())
+	gauge(c.acquiredConns, stat.AcquiredConns())
+	gauge(c.idleConns, stat.IdleConns())
+	gauge(c.constructingConns, stat.ConstructingConns())
+	counter(c.acquires, float64(stat.AcquireCount()))
+	counter(c.acquireDuration, stat.AcquireDuration().Seconds())
+	counter(c.emptyAcquires, float64(stat.EmptyAcquireCount()))
+	counter(c.canceledAcquires, float64(stat.CanceledAcquireCount()))
+	counter(c.newConns, float64(stat.NewConnsCount()))
+	counter(c.lifetimeDestroys, float64(stat.MaxLifetimeDestroyCount()))
+	counter(c.idleDestroys, float64(stat.MaxIdleDestroyCount()))
+}
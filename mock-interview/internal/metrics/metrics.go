@@ -0,0 +1,90 @@
+// Package metrics defines the Prometheus metrics served at /metrics: HTTP requests by route
+// template, database queries and pool connections, and business events.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+const namespace = "mock_interview"
+
+// Registry holds every metric; it's what /metrics serves
+var Registry = prometheus.NewRegistry()
+
+// HTTP metrics, labeled with the mux route template so path parameters don't create series
+var (
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by route template, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	HTTPRequestsInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_in_flight",
+		Help:      "HTTP requests being served by route template and method.",
+	}, []string{"route", "method"})
+)
+
+// Database query metrics; see QueryTracer
+var (
+	DBQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "query_duration_seconds",
+		Help:      "Database query latency by statement kind and table, and whether it failed.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"query", "status"})
+)
+
+// Business events
+var (
+	UserRegistrations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "user_registrations_total",
+		Help:      "Users created, by how: register, create_user or oidc.",
+	}, []string{"method"})
+
+	MoviesSaved = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "movies_saved_total",
+		Help:      "Movies saved to a profile.",
+	})
+
+	MoviesRemoved = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "movies_removed_total",
+		Help:      "Saved movies removed from a profile.",
+	})
+
+	UnavailableInCountry = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "unavailable_in_country_total",
+		Help:      "Saves rejected with UNAVAILABLE_IN_COUNTRY, by country.",
+	}, []string{"country"})
+)
+
+// Registration methods for UserRegistrations
+const (
+	RegistrationRegister   = "register"
+	RegistrationCreateUser = "create_user"
+	RegistrationOIDC       = "oidc"
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestDuration,
+		HTTPRequestsInFlight,
+		DBQueryDuration,
+		UserRegistrations,
+		MoviesSaved,
+		MoviesRemoved,
+		UnavailableInCountry,
+	)
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type accessLogKey struct{}
+
+// accessLogEntry collects what handlers further down learn about the request,
+// such as the authenticated user
+type accessLogEntry struct {
+	userID uuid.UUID
+}
+
+// AccessLog middleware logs one line per request with its route template, status, latency,
+// response size and authenticated user. Server errors are logged at error level.
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entry := &accessLogEntry{}
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), accessLogKey{}, entry)))
+
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("route", routeTemplate(r)),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", sw.status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.Int64("bytes", sw.bytes),
+		}
+		if entry.userID != uuid.Nil {
+			attrs = append(attrs, slog.String("user_id", entry.userID.String()))
+		}
+		level := slog.LevelInfo
+		if sw.status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		slog.LogAttrs(r.Context(), level, "request", attrs...)
+	})
+}
+
+// logUser records the authenticated user in the request's access log line
+func logUser(ctx context.Context, userID uuid.UUID) {
+	if entry, ok := ctx.Value(accessLogKey{}).(*accessLogEntry); ok {
+		entry.userID = userID
+	}
+}
@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/winfr1th/mock-interview/internal/utils"
+)
+
+// RequireAdmin middleware only lets admin users through. It must run after Auth.Required.
+func RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := GetUser(r)
+		if !ok || !user.IsAdmin {
+			utils.WriteErrorResponse(w, http.StatusForbidden, "FORBIDDEN",
+				"Admin access required", nil)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
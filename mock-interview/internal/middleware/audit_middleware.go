@@ -0,0 +1,14 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/winfr1th/mock-interview/internal/audit"
+)
+
+// AuditRequestInfo middleware stores the client IP, user agent and request ID for audit events
+func AuditRequestInfo(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(audit.WithRequestInfo(r.Context(), r)))
+	})
+}
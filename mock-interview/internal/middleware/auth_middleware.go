@@ -2,56 +2,466 @@ package middleware
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/winfr1th/mock-interview/internal/auth"
+	"github.com/winfr1th/mock-interview/internal/lockout"
+	model "github.com/winfr1th/mock-interview/internal/models"
 	"github.com/winfr1th/mock-interview/internal/repository"
 	"github.com/winfr1th/mock-interview/internal/utils"
 )
 
 type contextKey string
 
-const UserIDKey contextKey = "userID"
-
-// APIKeyAuth middleware validates API key and adds user to context
-func APIKeyAuth(repo repository.UserRepository) func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Get API key from header
-			apiKey := r.Header.Get("X-API-Key")
-			if apiKey == "" {
-				// Try Authorization header with Bearer token
-				authHeader := r.Header.Get("Authorization")
-				if authHeader != "" {
-					parts := strings.Split(authHeader, " ")
-					if len(parts) == 2 && parts[0] == "Bearer" {
-						apiKey = parts[1]
-					}
-				}
-			}
+const (
+	UserIDKey         contextKey = "userID"
+	UserKey           contextKey = "user"
+	ProfileKey        contextKey = "profile"
+	CredentialKindKey contextKey = "credentialKind"
+)
 
-			if apiKey == "" {
-				utils.WriteErrorResponse(w, http.StatusUnauthorized, "UNAUTHORIZED",
-					"API key required", nil)
-				return
-			}
+// Credential kinds
+const (
+	CredentialAPIKey      = "API key"
+	CredentialAccessToken = "access token"
+	CredentialSignature   = "request signature"
+)
+
+const (
+	// ProfileIDHeader selects the active household profile; the default profile is used when absent
+	ProfileIDHeader = "X-Profile-ID"
+	// ProfilePINHeader carries the PIN of a PIN-protected profile
+	ProfilePINHeader = "X-Profile-PIN"
+)
+
+// ErrInvalidCredential is wrapped by Authenticator errors for credentials that don't authenticate.
+// Any other error is a server failure and isn't counted against the client.
+var ErrInvalidCredential = errors.New("invalid credential")
+
+// Authenticator checks one kind of credential, such as an API key or a bearer access token
+type Authenticator interface {
+	// Credential returns the request's credential of this kind, or ok=false when it carries none
+	Credential(r *http.Request) (credential Credential, ok bool)
+	// Authenticate resolves the user a credential belongs to
+	Authenticate(w http.ResponseWriter, r *http.Request, credential Credential) (model.User, error)
+}
+
+// Credential is a credential found on a request
+type Credential struct {
+	// Kind names the credential in error messages, e.g. "API key"
+	Kind  string
+	Value string
+	// LockoutKey identifies the credential for failed-attempt tracking; empty tracks only the client IP
+	LockoutKey string
+}
+
+// Auth authenticates requests with the first authenticator whose credential is present,
+// and adds the user and active profile to context
+type Auth struct {
+	authenticators []Authenticator
+	profileRepo    repository.ProfileRepository
+	tracker        *lockout.Tracker
+}
+
+// NewAuth creates the auth middleware; authenticators are tried in order
+func NewAuth(profileRepo repository.ProfileRepository, tracker *lockout.Tracker, authenticators ...Authenticator) *Auth {
+	return &Auth{
+		authenticators: authenticators,
+		profileRepo:    profileRepo,
+		tracker:        tracker,
+	}
+}
+
+// Required rejects requests without a valid credential
+func (a *Auth) Required(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authenticator, credential, ok := a.credential(r)
+		if !ok {
+			utils.WriteErrorResponse(w, http.StatusUnauthorized, "UNAUTHORIZED",
+				"API key or access token required", nil)
+			return
+		}
+
+		ctx, ok := a.authenticate(w, r, authenticator, credential)
+		if !ok {
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Optional adds the user and active profile to context when a credential is given,
+// and lets anonymous requests through. An invalid credential is still rejected.
+func (a *Auth) Optional(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authenticator, credential, ok := a.credential(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, ok := a.authenticate(w, r, authenticator, credential)
+		if !ok {
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// credential finds the first authenticator with a credential on the request
+func (a *Auth) credential(r *http.Request) (Authenticator, Credential, bool) {
+	for _, authenticator := range a.authenticators {
+		if credential, ok := authenticator.Credential(r); ok {
+			return authenticator, credential, true
+		}
+	}
+	return nil, Credential{}, false
+}
+
+// authenticate resolves the user for credential and the active profile, writing an error response on failure.
+// Failed attempts are tracked per client IP and credential, and throttled or blocked by tracker.
+// The credential's own subject is only checked once the credential fails to verify: lockout keys
+// such as key IDs aren't secret, so anyone could otherwise lock a key's owner out.
+func (a *Auth) authenticate(w http.ResponseWriter, r *http.Request, authenticator Authenticator,
+	credential Credential) (context.Context, bool) {
+	subjects := lockoutSubjects(r, credential.LockoutKey)
+	client, keys := subjects[:1], subjects[1:]
+	if wait, blocked := a.tracker.Check(client...); wait > 0 {
+		writeThrottled(w, wait, blocked, "AUTH", "authentication attempts")
+		return nil, false
+	}
 
-			// Find user by API key (plain comparison)
-			user, err := repo.FindUserByAPIKey(r.Context(), apiKey)
-			if err != nil {
-				utils.WriteErrorResponse(w, http.StatusUnauthorized, "UNAUTHORIZED",
-					"Invalid API key", nil)
-				return
+	user, err := authenticator.Authenticate(w, r, credential)
+	if err != nil && !errors.Is(err, ErrInvalidCredential) {
+		slog.ErrorContext(r.Context(), "authenticating", "credential", credential.Kind, "error", err)
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR",
+			"Failed to authenticate", nil)
+		return nil, false
+	}
+	if err != nil {
+		// Guesses at a throttled or blocked key aren't counted again, only against the IP
+		wait, blocked := a.tracker.Check(keys...)
+		// An expired token was valid once, so it isn't counted as a guess
+		if !errors.Is(err, auth.ErrTokenExpired) {
+			if wait > 0 {
+				a.tracker.RecordFailure(r.Context(), client...)
+			} else {
+				a.tracker.RecordFailure(r.Context(), subjects...)
 			}
+		}
+		if wait > 0 {
+			writeThrottled(w, wait, blocked, "AUTH", "authentication attempts")
+			return nil, false
+		}
+		utils.WriteErrorResponse(w, http.StatusUnauthorized, "UNAUTHORIZED",
+			"Invalid "+credential.Kind, nil)
+		return nil, false
+	}
+	// A valid credential clears its own subject, but not the IP, which may be trying others
+	a.tracker.RecordSuccess(keys...)
+
+	profile, ok := a.resolveProfile(w, r, user)
+	if !ok {
+		return nil, false
+	}
+
+	ctx := withUser(r.Context(), user)
+	ctx = context.WithValue(ctx, CredentialKindKey, credential.Kind)
+	return context.WithValue(ctx, ProfileKey, profile), true
+}
+
+// APIKeyAuthenticator accepts API keys from X-API-Key or an Authorization Bearer token
+type APIKeyAuthenticator struct {
+	Users  repository.UserRepository
+	Legacy auth.LegacyKeyPolicy
+}
+
+func (a APIKeyAuthenticator) Credential(r *http.Request) (Credential, bool) {
+	apiKey := apiKeyFromRequest(r)
+	if apiKey == "" {
+		return Credential{}, false
+	}
+	return Credential{Kind: CredentialAPIKey, Value: apiKey, LockoutKey: apiKeyLockoutKey(apiKey)}, true
+}
+
+// Authenticate looks a prefixed key up by its key ID and verifies it against the stored hash.
+// Legacy UUID keys are looked up directly while the deprecation window lasts, and the response
+// is marked deprecated.
+func (a APIKeyAuthenticator) Authenticate(w http.ResponseWriter, r *http.Request, credential Credential) (model.User, error) {
+	apiKey := credential.Value
+	if auth.IsLegacyAPIKey(apiKey) {
+		if !a.Legacy.Accepts(time.Now()) {
+			return model.User{}, invalidCredential("legacy API keys are no longer accepted")
+		}
+		user, err := a.Users.FindUserByAPIKeyHash(r.Context(), auth.HashAPIKey(apiKey))
+		if err != nil {
+			return model.User{}, lookupError(err)
+		}
+		w.Header().Set("Deprecation", "true")
+		if !a.Legacy.Until.IsZero() {
+			w.Header().Set("Sunset", a.Legacy.Until.UTC().Format(http.TimeFormat))
+		}
+		return user, nil
+	}
 
-			// Add user ID to context
-			ctx := context.WithValue(r.Context(), UserIDKey, user.ID)
-			next.ServeHTTP(w, r.WithContext(ctx))
-		})
+	keyID, err := auth.ParseAPIKey(apiKey)
+	if err != nil {
+		return model.User{}, fmt.Errorf("%w: %w", ErrInvalidCredential, err)
 	}
+	user, err := a.Users.FindUserByAPIKeyID(r.Context(), keyID)
+	if err != nil {
+		return model.User{}, lookupError(err)
+	}
+	if !auth.VerifyAPIKey(apiKey, user.APIKeyHash) {
+		return model.User{}, invalidCredential("API key does not match")
+	}
+	return user, nil
+}
+
+// SignatureAuthenticator accepts requests signed with an API key's signing secret, so the key itself
+// is never sent. The timestamp must be within MaxSkew of the server clock and each nonce can only be
+// used once.
+type SignatureAuthenticator struct {
+	Users   repository.UserRepository
+	Secrets *auth.SigningSecrets
+	Nonces  *auth.NonceCache
+	MaxSkew time.Duration
+}
+
+func (a SignatureAuthenticator) Credential(r *http.Request) (Credential, bool) {
+	signature := r.Header.Get(auth.SignatureHeader)
+	if signature == "" {
+		return Credential{}, false
+	}
+	return Credential{Kind: CredentialSignature, Value: signature, LockoutKey: r.Header.Get(auth.SignatureKeyIDHeader)}, true
+}
+
+func (a SignatureAuthenticator) Authenticate(w http.ResponseWriter, r *http.Request, credential Credential) (model.User, error) {
+	keyID := r.Header.Get(auth.SignatureKeyIDHeader)
+	nonce := r.Header.Get(auth.SignatureNonceHeader)
+	if keyID == "" || nonce == "" {
+		return model.User{}, invalidCredential("signed request is missing its key ID or nonce")
+	}
+	timestamp, err := strconv.ParseInt(r.Header.Get(auth.SignatureTimestampHeader), 10, 64)
+	if err != nil {
+		return model.User{}, invalidCredential("invalid signature timestamp")
+	}
+	if skew := time.Since(time.Unix(timestamp, 0)); skew > a.MaxSkew || skew < -a.MaxSkew {
+		return model.User{}, invalidCredential("signature timestamp outside the allowed clock skew")
+	}
+
+	body, err := auth.ReadSignedBody(r)
+	if errors.Is(err, auth.ErrSignedBodyTooLarge) {
+		return model.User{}, fmt.Errorf("%w: %w", ErrInvalidCredential, err)
+	}
+	if err != nil {
+		return model.User{}, err
+	}
+
+	user, err := a.Users.FindUserByAPIKeyID(r.Context(), keyID)
+	if err != nil {
+		return model.User{}, lookupError(err)
+	}
+	signingString := auth.SigningString(r.Method, r.URL.RequestURI(), timestamp, nonce, body)
+	if !auth.VerifySignature(a.Secrets.For(user.APIKeyHash), signingString, credential.Value) {
+		return model.User{}, invalidCredential("signature does not match")
+	}
+
+	// Only a correctly signed request uses up its nonce, so others can't burn it
+	if !a.Nonces.Use(keyID, nonce) {
+		return model.User{}, invalidCredential("nonce already used")
+	}
+	return user, nil
+}
+
+// AccessTokenAuthenticator accepts JWT access tokens in an Authorization Bearer token.
+// The user comes from the token's claims, so no database lookup is needed.
+type AccessTokenAuthenticator struct {
+	Tokens      *auth.Tokens
+	Revocations *auth.KeyRevocations
+}
+
+func (a AccessTokenAuthenticator) Credential(r *http.Request) (Credential, bool) {
+	token := bearerToken(r)
+	if token == "" || !auth.LooksLikeJWT(token) {
+		return Credential{}, false
+	}
+	// Tokens are signed, so guessing is only tracked per client IP
+	return Credential{Kind: CredentialAccessToken, Value: token}, true
+}
+
+func (a AccessTokenAuthenticator) Authenticate(w http.ResponseWriter, r *http.Request, credential Credential) (model.User, error) {
+	claims, err := a.Tokens.Verify(credential.Value)
+	if err != nil {
+		return model.User{}, fmt.Errorf("%w: %w", ErrInvalidCredential, err)
+	}
+	if a.Revocations.IsRevoked(claims.User.APIKeyID) {
+		return model.User{}, invalidCredential("API key of this session was rotated or revoked")
+	}
+	return claims.User, nil
+}
+
+// writeThrottled answers an attempt made before the tracker allows another one
+func writeThrottled(w http.ResponseWriter, wait time.Duration, blocked bool, codePrefix, attempts string) {
+	retryAfter := ceilSeconds(wait)
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	code, message := codePrefix+"_THROTTLED", "Too many failed "+attempts+", slow down"
+	if blocked {
+		code, message = codePrefix+"_BLOCKED", "Too many failed "+attempts+", temporarily blocked"
+	}
+	utils.WriteErrorResponse(w, http.StatusTooManyRequests, code, message,
+		map[string]interface{}{"retry_after_seconds": retryAfter})
+}
+
+// resolveProfile picks the profile named by X-Profile-ID, or the household default,
+// and checks its PIN when it has one. Wrong PINs are tracked per profile, so guessing
+// them is throttled and blocked like guessing credentials.
+func (a *Auth) resolveProfile(w http.ResponseWriter, r *http.Request, user model.User) (model.Profile, bool) {
+	var profile model.Profile
+	var err error
+
+	profileIDStr := strings.TrimSpace(r.Header.Get(ProfileIDHeader))
+	if profileIDStr == "" {
+		profile, err = a.profileRepo.GetDefaultProfile(r.Context(), user.HouseholdID)
+	} else {
+		profileID, parseErr := uuid.Parse(profileIDStr)
+		if parseErr != nil {
+			utils.WriteErrorResponse(w, http.StatusBadRequest, "INVALID_PROFILE_ID",
+				"Invalid profile ID format", nil)
+			return model.Profile{}, false
+		}
+		profile, err = a.profileRepo.GetProfile(r.Context(), user.HouseholdID, profileID)
+	}
+	if errors.Is(err, repository.ErrProfileNotFound) {
+		utils.WriteErrorResponse(w, http.StatusForbidden, "PROFILE_NOT_FOUND",
+			"Profile not found in this household", nil)
+		return model.Profile{}, false
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "resolving profile", "error", err)
+		utils.WriteErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR",
+			"Failed to resolve profile", nil)
+		return model.Profile{}, false
+	}
+
+	if profile.HasPIN() {
+		pin := r.Header.Get(ProfilePINHeader)
+		if pin == "" {
+			utils.WriteErrorResponse(w, http.StatusForbidden, "PROFILE_PIN_REQUIRED",
+				"This profile requires a PIN", nil)
+			return model.Profile{}, false
+		}
+		subject := lockout.Subject{Kind: lockout.KindProfile, Value: profile.ID.String()}
+		if wait, blocked := a.tracker.Check(subject); wait > 0 {
+			writeThrottled(w, wait, blocked, "PIN", "profile PIN attempts")
+			return model.Profile{}, false
+		}
+		if !auth.VerifyPIN(pin, profile.PINHash) {
+			a.tracker.RecordFailure(r.Context(), subject)
+			utils.WriteErrorResponse(w, http.StatusForbidden, "INVALID_PROFILE_PIN",
+				"Invalid profile PIN", nil)
+			return model.Profile{}, false
+		}
+		a.tracker.RecordSuccess(subject)
+	}
+
+	return profile, true
+}
+
+// invalidCredential is an ErrInvalidCredential with the reason it failed
+func invalidCredential(reason string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidCredential, reason)
+}
+
+// lookupError treats an unknown key as an invalid credential and anything else as a server failure
+func lookupError(err error) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return fmt.Errorf("%w: %w", ErrInvalidCredential, err)
+	}
+	return err
+}
+
+// keyPrefixLength is how much of a legacy or malformed key identifies it for lockout tracking
+const keyPrefixLength = 8
+
+// apiKeyLockoutKey is the key ID for prefixed keys, and the first characters of any other key
+func apiKeyLockoutKey(apiKey string) string {
+	if keyID, err := auth.ParseAPIKey(apiKey); err == nil {
+		return keyID
+	}
+	if len(apiKey) > keyPrefixLength {
+		return apiKey[:keyPrefixLength]
+	}
+	return apiKey
+}
+
+// lockoutSubjects lists what a failed attempt counts against: the client IP, then the credential's
+// lockout key when it has one
+func lockoutSubjects(r *http.Request, lockoutKey string) []lockout.Subject {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	subjects := []lockout.Subject{{Kind: lockout.KindIP, Value: host}}
+	if lockoutKey != "" {
+		subjects = append(subjects, lockout.Subject{Kind: lockout.KindKeyPrefix, Value: lockoutKey})
+	}
+	return subjects
+}
+
+// apiKeyFromRequest reads the API key from X-API-Key or an Authorization Bearer token
+func apiKeyFromRequest(r *http.Request) string {
+	if apiKey := r.Header.Get("X-API-Key"); apiKey != "" {
+		return apiKey
+	}
+	return bearerToken(r)
+}
+
+// bearerToken reads the token from an Authorization Bearer header
+func bearerToken(r *http.Request) string {
+	parts := strings.Split(r.Header.Get("Authorization"), " ")
+	if len(parts) == 2 && parts[0] == "Bearer" {
+		return parts[1]
+	}
+	return ""
+}
+
+// withUser adds the authenticated user and their ID to the context
+func withUser(ctx context.Context, user model.User) context.Context {
+	logUser(ctx, user.ID)
+	ctx = context.WithValue(ctx, UserIDKey, user.ID)
+	return context.WithValue(ctx, UserKey, user)
 }
 
 // GetUserID extracts user ID from request context
 func GetUserID(r *http.Request) interface{} {
 	return r.Context().Value(UserIDKey)
 }
+
+// GetUser extracts the authenticated user from request context
+func GetUser(r *http.Request) (model.User, bool) {
+	user, ok := r.Context().Value(UserKey).(model.User)
+	return user, ok
+}
+
+// GetCredentialKind returns which kind of credential authenticated the request
+func GetCredentialKind(r *http.Request) string {
+	kind, _ := r.Context().Value(CredentialKindKey).(string)
+	return kind
+}
+
+// GetProfile extracts the active household profile from request context
+func GetProfile(r *http.Request) (model.Profile, bool) {
+	profile, ok := r.Context().Value(ProfileKey).(model.Profile)
+	return profile, ok
+}
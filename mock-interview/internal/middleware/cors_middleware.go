@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSPolicy says which cross-origin requests browsers may make
+type CORSPolicy struct {
+	AllowedOrigins   []string // Origins such as https://app.example.com, or * for any
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string // Response headers scripts may read
+	AllowCredentials bool
+	MaxAge           time.Duration // How long a preflight response may be cached
+}
+
+// CORS middleware answers preflight requests and adds CORS headers to responses for allowed
+// origins. It must wrap the router: preflight OPTIONS requests match no route.
+func CORS(policy CORSPolicy) func(http.Handler) http.Handler {
+	anyOrigin := slices.Contains(policy.AllowedOrigins, "*")
+	methods := strings.Join(policy.AllowedMethods, ", ")
+	headers := strings.Join(policy.AllowedHeaders, ", ")
+	exposed := strings.Join(policy.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(policy.MaxAge.Seconds()))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Add("Vary", "Origin")
+			if !anyOrigin && !slices.Contains(policy.AllowedOrigins, origin) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if anyOrigin && !policy.AllowCredentials {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+			if policy.AllowCredentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				w.Header().Add("Vary", "Access-Control-Request-Method")
+				w.Header().Add("Vary", "Access-Control-Request-Headers")
+				w.Header().Set("Access-Control-Allow-Methods", methods)
+				w.Header().Set("Access-Control-Allow-Headers", headers)
+				if policy.MaxAge > 0 {
+					w.Header().Set("Access-Control-Max-Age", maxAge)
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			if exposed != "" {
+				w.Header().Set("Access-Control-Expose-Headers", exposed)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
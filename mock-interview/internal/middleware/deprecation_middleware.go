@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Deprecation middleware marks responses of deprecated routes with a Deprecation header (RFC 9745)
+// and a Sunset header (RFC 8594), and links the same path under successorPrefix as the
+// successor version
+func Deprecation(deprecatedAt, sunset time.Time, successorPrefix string) func(http.Handler) http.Handler {
+	deprecation := "@" + strconv.FormatInt(deprecatedAt.Unix(), 10)
+	sunsetDate := sunset.UTC().Format(http.TimeFormat)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Deprecation", deprecation)
+			w.Header().Set("Sunset", sunsetDate)
+			w.Header().Add("Link", "<"+successorPrefix+r.URL.EscapedPath()+`>; rel="successor-version"`)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/winfr1th/mock-interview/internal/metrics"
+)
+
+// UnmatchedRoute labels requests that matched no route
+const UnmatchedRoute = "unmatched"
+
+// Metrics middleware records request latency and in-flight requests by the matched route's
+// template, e.g. /v1/users/{user_id}/movies, never the raw path
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeTemplate(r)
+		method := methodLabel(r.Method)
+
+		inFlight := metrics.HTTPRequestsInFlight.WithLabelValues(route, method)
+		inFlight.Inc()
+		defer inFlight.Dec()
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(sw, r)
+		metrics.HTTPRequestDuration.WithLabelValues(route, method, strconv.Itoa(sw.status)).
+			Observe(time.Since(start).Seconds())
+	})
+}
+
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return UnmatchedRoute
+}
+
+// methodLabel keeps clients from creating series with made-up methods
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodOptions:
+		return method
+	}
+	return "OTHER"
+}
+
+// statusWriter records the response status and body size
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+func (sw *statusWriter) WriteHeader(status int) {
+	if !sw.wroteHeader {
+		sw.status = status
+		sw.wroteHeader = true
+	}
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	sw.wroteHeader = true
+	n, err := sw.ResponseWriter.Write(b)
+	sw.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController and utils.WriteErrorResponse reach the underlying writer
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
@@ -0,0 +1,15 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/winfr1th/mock-interview/internal/utils"
+)
+
+// ProblemDetails middleware makes error responses RFC 9457 problem details for clients whose
+// Accept header prefers application/problem+json. Other clients keep the standard error format.
+func ProblemDetails(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(utils.WithProblemDetails(w, r), r)
+	})
+}
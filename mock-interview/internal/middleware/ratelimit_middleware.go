@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/winfr1th/mock-interview/internal/ratelimit"
+	"github.com/winfr1th/mock-interview/internal/utils"
+)
+
+// RateLimitKeyFunc picks the bucket a request counts against
+type RateLimitKeyFunc func(r *http.Request) string
+
+// RateLimitByIP keys requests by client IP
+func RateLimitByIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// RateLimit middleware enforces the scope's token bucket and sends RateLimit-* headers.
+// Store errors fail open so a broken backend doesn't take the API down.
+func RateLimit(store ratelimit.Store, scope ratelimit.Scope, keyFunc RateLimitKeyFunc) func(http.Handler) http.Handler {
+	return rateLimit(store, scope, func(r *http.Request) (string, ratelimit.Limit) {
+		return keyFunc(r), scope.Limit
+	})
+}
+
+// RateLimitByUser middleware limits authenticated requests per user, with the limit of the
+// user's plan. It goes after authentication: keys and access tokens change when they are
+// rotated or refreshed, and unauthenticated requests are limited per client IP before it.
+func RateLimitByUser(store ratelimit.Store, scope ratelimit.Scope) func(http.Handler) http.Handler {
+	return rateLimit(store, scope, func(r *http.Request) (string, ratelimit.Limit) {
+		user, ok := GetUser(r)
+		if !ok {
+			return RateLimitByIP(r), scope.Limit
+		}
+		return "user:" + user.ID.String(), scope.LimitFor(user.Plan)
+	})
+}
+
+func rateLimit(store ratelimit.Store, scope ratelimit.Scope, bucket func(r *http.Request) (string, ratelimit.Limit)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, limit := bucket(r)
+			result, err := store.Take(r.Context(), scope.Name+":"+key, limit)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "rate limit store error", "scope", scope.Name, "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+
+			if !result.Allowed {
+				retryAfter := ceilSeconds(result.RetryAfter)
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+				utils.WriteErrorResponse(w, http.StatusTooManyRequests, "RATE_LIMITED",
+					"Too many requests", map[string]interface{}{"retry_after_seconds": retryAfter})
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ceilSeconds rounds up to whole seconds, as the headers require
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
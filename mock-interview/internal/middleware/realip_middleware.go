@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// RealIP middleware sets RemoteAddr from X-Real-IP or X-Forwarded-For.
+// Only use it behind a proxy that sets these headers, since clients can forge them.
+func RealIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ip := realIP(r); ip != "" {
+			r.RemoteAddr = net.JoinHostPort(ip, "0")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// realIP returns X-Real-IP, or the last X-Forwarded-For hop, which is the one our proxy appended
+func realIP(r *http.Request) string {
+	if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(ip) != nil {
+		return ip
+	}
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		hops := strings.Split(forwarded, ",")
+		if ip := strings.TrimSpace(hops[len(hops)-1]); net.ParseIP(ip) != nil {
+			return ip
+		}
+	}
+	return ""
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"net/http"
+	"regexp"
+
+	"github.com/google/uuid"
+	"github.com/winfr1th/mock-interview/internal/logging"
+	"github.com/winfr1th/mock-interview/internal/utils"
+)
+
+// validRequestID accepts the request IDs of proxies and clients without letting them inject
+// arbitrary text into logs
+var validRequestID = regexp.MustCompile(`^[0-9A-Za-z._:-]{1,128}$`)
+
+// RequestID middleware propagates a valid X-Request-ID header, or assigns a random UUID.
+// The ID is echoed in the response header, attached to error bodies and added to every log
+// record written with the request's context.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(utils.RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = uuid.NewString()
+		}
+		w.Header().Set(utils.RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), id)))
+	})
+}
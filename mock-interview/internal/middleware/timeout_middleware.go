@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// RequestTimeout middleware gives each request's context a deadline, so its database statements
+// are canceled once it passes and the handler still has time to write an error
+func RequestTimeout(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
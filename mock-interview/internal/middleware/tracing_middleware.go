@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/winfr1th/mock-interview/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing middleware starts a server span per request, continuing the trace of an incoming
+// traceparent header. The span is named by method and route template, e.g.
+// GET /v1/users/{user_id}/movies.
+func Tracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+		route := routeTemplate(r)
+		method := methodLabel(r.Method)
+		ctx, span := tracing.Tracer().Start(ctx, method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(r.URL.Path),
+				semconv.UserAgentOriginal(r.UserAgent()),
+			),
+		)
+		defer span.End()
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r.WithContext(ctx))
+
+		span.SetAttributes(semconv.HTTPResponseStatusCode(sw.status))
+		if sw.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(sw.status))
+		}
+	})
+}
@@ -0,0 +1,16 @@
+package model
+
+import "github.com/google/uuid"
+
+// Certification is a content rating issued by a country's rating board (e.g. PG-13 in US, 15 in GB)
+type Certification struct {
+	CountryCode string `json:"country_code"`
+	Code        string `json:"code"`
+	MinAge      int    `json:"min_age"`
+}
+
+type MovieCertification struct {
+	MovieID       uuid.UUID `json:"movie_id"`
+	CountryCode   string    `json:"country_code"`
+	Certification string    `json:"certification"`
+}
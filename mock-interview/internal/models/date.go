@@ -0,0 +1,98 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DateLayout is the ISO-8601 calendar date format used in requests, responses and storage
+const DateLayout = "2006-01-02"
+
+// Date is a calendar date without a time-of-day component
+type Date struct {
+	time.Time
+}
+
+// ParseDate parses an ISO-8601 date (YYYY-MM-DD)
+func ParseDate(s string) (Date, error) {
+	t, err := time.Parse(DateLayout, s)
+	if err != nil {
+		return Date{}, fmt.Errorf("invalid date %q: must be in YYYY-MM-DD format", s)
+	}
+	return Date{Time: t}, nil
+}
+
+// String formats the date as YYYY-MM-DD
+func (d Date) String() string {
+	if d.IsZero() {
+		return ""
+	}
+	return d.Format(DateLayout)
+}
+
+// AgeOn returns the number of full years between the date and the given day
+func (d Date) AgeOn(day time.Time) int {
+	age := day.Year() - d.Year()
+	if day.Month() < d.Month() || (day.Month() == d.Month() && day.Day() < d.Day()) {
+		age--
+	}
+	return age
+}
+
+// MarshalJSON encodes the date as "YYYY-MM-DD", or null when unset
+func (d Date) MarshalJSON() ([]byte, error) {
+	if d.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON decodes a "YYYY-MM-DD" string
+func (d *Date) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*d = Date{}
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := ParseDate(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// Scan implements sql.Scanner for DATE columns
+func (d *Date) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*d = Date{}
+		return nil
+	case time.Time:
+		*d = Date{Time: time.Date(v.Year(), v.Month(), v.Day(), 0, 0, 0, 0, time.UTC)}
+		return nil
+	case string:
+		parsed, err := ParseDate(v)
+		if err != nil {
+			return err
+		}
+		*d = parsed
+		return nil
+	default:
+		return errors.New("unsupported type for Date")
+	}
+}
+
+// Value implements driver.Valuer for DATE columns
+func (d Date) Value() (driver.Value, error) {
+	if d.IsZero() {
+		return nil, nil
+	}
+	return d.Time, nil
+}
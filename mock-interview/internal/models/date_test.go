@@ -0,0 +1,111 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestAgeOn(t *testing.T) {
+	day := func(s string) time.Time {
+		d, err := ParseDate(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d.Time
+	}
+
+	tests := []struct {
+		dob, on string
+		want    int
+	}{
+		{"1990-05-17", "2026-05-16", 35},
+		{"1990-05-17", "2026-05-17", 36},
+		{"1990-05-17", "2026-05-18", 36},
+		{"1990-05-17", "2026-04-30", 35},
+		{"1990-05-17", "2026-06-01", 36},
+		{"2026-05-17", "2026-05-17", 0},
+		// A 29 February birthday comes round on 1 March outside leap years
+		{"2008-02-29", "2025-02-28", 16},
+		{"2008-02-29", "2025-03-01", 17},
+		{"2008-02-29", "2028-02-28", 19},
+		{"2008-02-29", "2028-02-29", 20},
+		{"1900-01-01", "2026-01-01", 126},
+	}
+	for _, tt := range tests {
+		dob, err := ParseDate(tt.dob)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := dob.AgeOn(day(tt.on)); got != tt.want {
+			t.Errorf("%s AgeOn(%s) = %d, want %d", tt.dob, tt.on, got, tt.want)
+		}
+	}
+}
+
+func TestParseDateOfBirth(t *testing.T) {
+	today := time.Now().UTC().Format(DateLayout)
+	tomorrow := time.Now().UTC().AddDate(0, 0, 1).Format(DateLayout)
+
+	tests := []struct {
+		dob string
+		ok  bool
+	}{
+		{"1990-05-17", true},
+		{"1900-01-01", true},
+		{"1899-12-31", false},
+		{"0001-01-01", false},
+		{"2008-02-29", true},
+		{"2007-02-29", false},
+		{today, true},
+		{tomorrow, false},
+		{"2999-01-01", false},
+		{"17/05/1990", false},
+		{"1990-5-17", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		dob, err := ParseDateOfBirth(tt.dob)
+		if (err == nil) != tt.ok {
+			t.Errorf("ParseDateOfBirth(%q) error = %v, want ok %v", tt.dob, err, tt.ok)
+			continue
+		}
+		if tt.ok && dob.String() != tt.dob {
+			t.Errorf("ParseDateOfBirth(%q) = %s", tt.dob, dob)
+		}
+	}
+}
+
+func TestDateJSON(t *testing.T) {
+	var body struct {
+		DateOfBirth Date  `json:"date_of_birth"`
+		Unset       Date  `json:"unset"`
+		Pointer     *Date `json:"pointer"`
+	}
+	if err := json.Unmarshal([]byte(`{"date_of_birth": "2008-02-29", "unset": null, "pointer": null}`), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.DateOfBirth.String() != "2008-02-29" || !body.Unset.IsZero() || body.Pointer != nil {
+		t.Fatalf("decoded %+v", body)
+	}
+
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"date_of_birth":"2008-02-29","unset":null,"pointer":null}`; string(encoded) != want {
+		t.Errorf("encoded %s, want %s", encoded, want)
+	}
+
+	// null clears a date that was set
+	if err := json.Unmarshal([]byte(`{"date_of_birth": null}`), &body); err != nil || !body.DateOfBirth.IsZero() {
+		t.Errorf("null decoded as %v, %v", body.DateOfBirth, err)
+	}
+
+	for _, bad := range []string{`"2008-02-30"`, `"29/02/2008"`, `20080229`, `"2008-02-29T00:00:00Z"`} {
+		var d Date
+		if err := json.Unmarshal([]byte(bad), &d); err == nil {
+			t.Errorf("%s decoded as %s, want an error", bad, d)
+		}
+	}
+}
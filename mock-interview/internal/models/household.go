@@ -0,0 +1,14 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Household groups the profiles that share one login
+type Household struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
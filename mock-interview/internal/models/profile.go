@@ -0,0 +1,42 @@
+package model
+
+import "github.com/google/uuid"
+
+// Profile is a member of a household with its own saved movies and content limits
+type Profile struct {
+	ID          uuid.UUID `json:"id"`
+	HouseholdID uuid.UUID `json:"household_id"`
+	Name        string    `json:"name"`
+	MaxAge      *int      `json:"max_age"` // Highest certification age allowed; nil means unrestricted
+	PINHash     string    `json:"-"`       // Don't expose hash in JSON responses
+	IsDefault   bool      `json:"is_default"`
+}
+
+// HasPIN reports whether switching to the profile requires a PIN
+func (p Profile) HasPIN() bool {
+	return p.PINHash != ""
+}
+
+type CreateProfileRequest struct {
+	Name   string `json:"name" validate:"trim,required"`
+	MaxAge *int   `json:"max_age" validate:"min=0" code:"INVALID_MAX_AGE"`
+	PIN    string `json:"pin"`
+}
+
+// UpdateProfileRequest changes the given fields of a profile. clear_max_age removes the content
+// limit and clear_pin the PIN.
+type UpdateProfileRequest struct {
+	Name        *string `json:"name" validate:"trim,nonempty"`
+	MaxAge      *int    `json:"max_age" validate:"min=0" code:"INVALID_MAX_AGE"`
+	ClearMaxAge bool    `json:"clear_max_age"`
+	PIN         *string `json:"pin"`
+	ClearPIN    bool    `json:"clear_pin"`
+}
+
+type ProfileResponse struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	MaxAge    *int      `json:"max_age"`
+	HasPIN    bool      `json:"has_pin"`
+	IsDefault bool      `json:"is_default"`
+}
@@ -0,0 +1,26 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RefreshToken is a stored refresh token. Each refresh replaces it with a new token in the
+// same family; presenting a used token again revokes the whole family.
+type RefreshToken struct {
+	ID         uuid.UUID  `json:"id"`
+	UserID     uuid.UUID  `json:"user_id"`
+	FamilyID   uuid.UUID  `json:"family_id"`
+	TokenHash  string     `json:"-"`
+	AuthMethod string     `json:"auth_method"` // How the session started: api_key or oidc
+	APIKeyID   string     `json:"-"`           // API key the session was exchanged from; empty for OIDC
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	UsedAt     *time.Time `json:"used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
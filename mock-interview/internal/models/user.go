@@ -1,20 +1,58 @@
 package model
 
-import "github.com/google/uuid"
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type User struct {
 	ID          uuid.UUID `json:"id"`
+	HouseholdID uuid.UUID `json:"household_id"`
 	Name        string    `json:"name"`
-	DateOfBirth string    `json:"date_of_birth"`
-	APIKeyHash  string    `json:"-"` // Don't expose hash in JSON responses
+	DateOfBirth Date      `json:"date_of_birth"`
+	HomeCountry *string   `json:"home_country"` // ISO-3166-1 alpha-2, used when a request has no ?country=
+	IsAdmin     bool      `json:"-"`            // Grants access to /admin endpoints
+	Plan        string    `json:"-"`            // Rate limit plan; empty for the default limits
+	APIKeyID    string    `json:"-"`            // Key ID part of the API key; empty for legacy UUID keys
+	APIKeyHash  string    `json:"-"`            // Don't expose hash in JSON responses
 }
 
 type RegisterRequest struct {
-	Name        string `json:"name"`
-	DateOfBirth string `json:"date_of_birth"`
+	Name        string `json:"name" validate:"required"`
+	DateOfBirth string `json:"date_of_birth" validate:"required"`
+	HomeCountry string `json:"home_country" validate:"iso3166"`
+}
+
+// UpdateUserRequest changes only the fields that are present
+type UpdateUserRequest struct {
+	Name        *string `json:"name" validate:"nonempty"`
+	DateOfBirth *string `json:"date_of_birth"`
+	HomeCountry *string `json:"home_country" validate:"iso3166"` // An empty home_country clears it
 }
 
 type RegisterResponse struct {
 	UserID uuid.UUID `json:"user_id"`
 	APIKey string    `json:"api_key"` // Only returned once during registration
+	// SigningSecret signs requests with the key; omitted when signed requests are disabled
+	SigningSecret string `json:"signing_secret,omitempty"`
+}
+
+// earliestDateOfBirth rejects obviously bogus birth dates
+var earliestDateOfBirth = time.Date(1900, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// ParseDateOfBirth parses an ISO-8601 date of birth and checks it is plausible
+func ParseDateOfBirth(s string) (Date, error) {
+	dob, err := ParseDate(s)
+	if err != nil {
+		return Date{}, err
+	}
+	if dob.Before(earliestDateOfBirth) {
+		return Date{}, errors.New("date_of_birth must not be before 1900-01-01")
+	}
+	if dob.After(time.Now().UTC()) {
+		return Date{}, errors.New("date_of_birth must not be in the future")
+	}
+	return dob, nil
 }
@@ -0,0 +1,24 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserIdentity links an external OIDC subject to a user
+type UserIdentity struct {
+	Issuer    string    `json:"issuer"`
+	Subject   string    `json:"subject"`
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AccessTokenResponse is returned when a login or refresh issues an access token
+type AccessTokenResponse struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type"`
+	ExpiresIn    int       `json:"expires_in"`    // Seconds
+	RefreshToken string    `json:"refresh_token"` // Only returned once; each refresh replaces it
+	UserID       uuid.UUID `json:"user_id"`
+}
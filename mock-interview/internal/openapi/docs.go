@@ -0,0 +1,8 @@
+package openapi
+
+import _ "embed"
+
+// DocsPage is a Redoc page rendering the document served next to it at openapi.json
+//
+//go:embed docs.html
+var DocsPage []byte
@@ -0,0 +1,370 @@
+// Package openapi builds an OpenAPI 3.1 document from the routes registered on a mux router
+// and the endpoint descriptions kept next to their handlers.
+package openapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/winfr1th/mock-interview/internal/auth"
+	"github.com/winfr1th/mock-interview/internal/utils"
+	"github.com/winfr1th/mock-interview/internal/validate"
+)
+
+// Version is the OpenAPI version of the documents built here
+const Version = "3.1.0"
+
+// Auth is how an endpoint authenticates callers
+type Auth int
+
+const (
+	AuthNone     Auth = iota // Public
+	AuthOptional             // Public, but an API key or access token changes the response
+	AuthRequired             // API key, access token or request signature
+	AuthAdmin                // Like AuthRequired, for admin users only
+)
+
+// Endpoint describes a route for the document
+type Endpoint struct {
+	ID          string // operationId
+	Summary     string
+	Description string
+	Tag         string
+	Auth        Auth
+	// Request is the endpoint's validate.Bind schema; its path, query and body tags
+	// become parameters and the request body
+	Request interface{}
+	// Status is the success status; 200 when zero
+	Status int
+	// Response is the success body, or nil when there is none. Use Paged and List for lists.
+	Response interface{}
+	// ContentType is the success body's media type; application/json when empty
+	ContentType string
+	// Errors are the error statuses the endpoint returns besides 500
+	Errors []int
+	// Responses are other statuses with their own bodies rather than an error's
+	Responses map[int]interface{}
+}
+
+// Paged describes a utils.PagedResponse of item
+func Paged(item interface{}) interface{} {
+	return paged{item: item}
+}
+
+// List describes a {"data": [item]} response
+func List(item interface{}) interface{} {
+	return list{item: item}
+}
+
+type paged struct{ item interface{} }
+
+type list struct{ item interface{} }
+
+// Document is an OpenAPI document
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+// Info describes the API
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem holds a path's operations by lower-case method
+type PathItem map[string]*Operation
+
+// Operation is one method of a path
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+}
+
+// Parameter is a path or query parameter
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+// RequestBody is an operation's body
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response is an operation's response for one status, or a reference to a shared one
+type Response struct {
+	Ref         string               `json:"$ref,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType is the schema of a body in one media type
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components are the schemas, responses and security schemes operations refer to
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	Responses       map[string]*Response      `json:"responses"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+// SecurityScheme is a way to authenticate
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Description  string `json:"description,omitempty"`
+	Name         string `json:"name,omitempty"`
+	In           string `json:"in,omitempty"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// Security scheme names
+const (
+	SchemeAPIKey    = "apiKey"
+	SchemeBearer    = "bearer"
+	SchemeSignature = "signature"
+)
+
+// errorResponse names the shared error response
+const errorResponse = "Error"
+
+// versionPrefix matches a path's leading version segment, as in /v1/movies
+var versionPrefix = regexp.MustCompile(`^/(v[0-9]+)(/.*)$`)
+
+// pathVar matches a mux path variable, with or without a pattern
+var pathVar = regexp.MustCompile(`\{([^}:]+)(:[^}]+)?\}`)
+
+// Build documents every route registered on router with its endpoint, keyed by method and path
+// template as in "GET /users/{id}". It fails when a route has no endpoint, so the document can't
+// silently fall behind the routes, and when an endpoint's Request fails validate.CheckSchema.
+//
+// A versioned route such as "GET /v2/users/{id}" is documented with its own endpoint when there
+// is one, or else with the unversioned path's. Its operation ID gets the version as a suffix.
+// Unversioned routes that are also served under a version are documented as deprecated.
+func Build(router *mux.Router, info Info, endpoints map[string]Endpoint) (*Document, error) {
+	type route struct{ method, path string }
+	var routes []route
+	err := router.Walk(func(r *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		if r.GetHandler() == nil {
+			return nil // A subrouter; its routes are walked next
+		}
+		template, err := r.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		methods, err := r.GetMethods()
+		if err != nil {
+			return fmt.Errorf("route %s has no methods", template)
+		}
+		for _, method := range methods {
+			routes = append(routes, route{method: method, path: pathVar.ReplaceAllString(template, "{$1}")})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Unversioned keys of the routes served under a version
+	versioned := make(map[string]bool)
+	for _, r := range routes {
+		if version, path := splitVersion(r.path); version != "" {
+			versioned[r.method+" "+path] = true
+		}
+	}
+
+	b := newBuilder()
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   make(map[string]PathItem),
+	}
+	var undocumented []string
+	for _, r := range routes {
+		key := r.method + " " + r.path
+		version, path := splitVersion(r.path)
+		endpoint, ok := endpoints[key]
+		if !ok {
+			endpoint, ok = endpoints[r.method+" "+path]
+		}
+		if !ok {
+			undocumented = append(undocumented, key)
+			continue
+		}
+		if endpoint.Request != nil {
+			if err := validate.CheckSchema(reflect.TypeOf(endpoint.Request)); err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+		}
+
+		op := b.operation(r.path, endpoint)
+		if version != "" {
+			op.OperationID += strings.ToUpper(version)
+		} else if versioned[key] {
+			op.Deprecated = true
+		}
+		if doc.Paths[r.path] == nil {
+			doc.Paths[r.path] = make(PathItem)
+		}
+		doc.Paths[r.path][strings.ToLower(r.method)] = op
+	}
+	if len(undocumented) > 0 {
+		sort.Strings(undocumented)
+		return nil, errors.New("routes without an OpenAPI endpoint: " + strings.Join(undocumented, ", "))
+	}
+
+	doc.Components = b.components()
+	return doc, nil
+}
+
+// splitVersion splits a path such as /v1/movies into its version and the unversioned path.
+// The version is empty when the path has none.
+func splitVersion(path string) (version, unversioned string) {
+	m := versionPrefix.FindStringSubmatch(path)
+	if m == nil {
+		return "", path
+	}
+	return m[1], m[2]
+}
+
+func (b *builder) operation(path string, e Endpoint) *Operation {
+	op := &Operation{
+		OperationID: e.ID,
+		Summary:     e.Summary,
+		Description: e.Description,
+		Responses:   make(map[string]*Response),
+	}
+	if e.Tag != "" {
+		op.Tags = []string{e.Tag}
+	}
+
+	if e.Request != nil {
+		op.Parameters, op.RequestBody = b.request(reflect.TypeOf(e.Request))
+	}
+	// Path variables the schema doesn't declare are still parameters
+	for _, match := range pathVar.FindAllStringSubmatch(path, -1) {
+		if !hasParameter(op.Parameters, match[1]) {
+			op.Parameters = append(op.Parameters, Parameter{
+				Name: match[1], In: "path", Required: true, Schema: &Schema{Type: "string"},
+			})
+		}
+	}
+
+	status := e.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := &Response{Description: http.StatusText(status)}
+	if e.Response != nil {
+		contentType := e.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		success.Content = map[string]MediaType{contentType: {Schema: b.responseSchema(e.Response)}}
+	}
+	op.Responses[strconv.Itoa(status)] = success
+	for other, body := range e.Responses {
+		op.Responses[strconv.Itoa(other)] = &Response{
+			Description: http.StatusText(other),
+			Content:     map[string]MediaType{"application/json": {Schema: b.responseSchema(body)}},
+		}
+	}
+
+	for _, errStatus := range append(e.Errors, http.StatusInternalServerError) {
+		op.Responses[strconv.Itoa(errStatus)] = &Response{Ref: "#/components/responses/" + errorResponse}
+	}
+
+	switch e.Auth {
+	case AuthRequired, AuthAdmin:
+		op.Security = credentials()
+	case AuthOptional:
+		op.Security = append(credentials(), map[string][]string{})
+	}
+	return op
+}
+
+func credentials() []map[string][]string {
+	return []map[string][]string{
+		{SchemeAPIKey: {}},
+		{SchemeBearer: {}},
+		{SchemeSignature: {}},
+	}
+}
+
+func hasParameter(params []Parameter, name string) bool {
+	for _, p := range params {
+		if p.Name == name && p.In == "path" {
+			return true
+		}
+	}
+	return false
+}
+
+// responseSchema returns the schema of a success body, expanding Paged and List
+func (b *builder) responseSchema(response interface{}) *Schema {
+	switch r := response.(type) {
+	case paged:
+		s := b.inline(reflect.TypeOf(utils.PagedResponse{}))
+		s.Properties["data"] = &Schema{Type: "array", Items: b.schema(reflect.TypeOf(r.item))}
+		return s
+	case list:
+		return &Schema{
+			Type:       "object",
+			Properties: map[string]*Schema{"data": {Type: "array", Items: b.schema(reflect.TypeOf(r.item))}},
+			Required:   []string{"data"},
+		}
+	}
+	return b.schema(reflect.TypeOf(response))
+}
+
+func (b *builder) components() Components {
+	errorBody := b.schema(reflect.TypeOf(utils.ErrorResponse{}))
+	problem := b.schema(reflect.TypeOf(utils.Problem{}))
+	return Components{
+		Schemas: b.schemas,
+		Responses: map[string]*Response{
+			errorResponse: {
+				Description: "Error. Clients that accept application/problem+json get RFC 9457 problem details.",
+				Content: map[string]MediaType{
+					"application/json":       {Schema: errorBody},
+					utils.ProblemContentType: {Schema: problem},
+				},
+			},
+		},
+		SecuritySchemes: map[string]SecurityScheme{
+			SchemeAPIKey: {Type: "apiKey", Name: "X-API-Key", In: "header"},
+			SchemeBearer: {
+				Type: "http", Scheme: "bearer", BearerFormat: "JWT",
+				Description: "An access token from /v1/auth/token, /v1/auth/refresh or OIDC login, or an API key",
+			},
+			SchemeSignature: {
+				Type: "apiKey", Name: auth.SignatureHeader, In: "header",
+				Description: "HMAC-SHA256 request signature, sent with the " + auth.SignatureKeyIDHeader + ", " +
+					auth.SignatureTimestampHeader + " and " + auth.SignatureNonceHeader + " headers",
+			},
+		},
+	}
+}
@@ -0,0 +1,235 @@
+package openapi
+
+import (
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	model "github.com/winfr1th/mock-interview/internal/models"
+	"github.com/winfr1th/mock-interview/internal/validate"
+)
+
+// Schema is a JSON Schema as used by OpenAPI 3.1
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 interface{}        `json:"type,omitempty"` // A type name, or a list of them
+	Format               string             `json:"format,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Default              interface{}        `json:"default,omitempty"`
+	Minimum              *int64             `json:"minimum,omitempty"`
+	Maximum              *int64             `json:"maximum,omitempty"`
+	MinLength            *int64             `json:"minLength,omitempty"`
+	MaxLength            *int64             `json:"maxLength,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"` // A schema, or false
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+}
+
+var (
+	uuidType = reflect.TypeOf(uuid.UUID{})
+	timeType = reflect.TypeOf(time.Time{})
+	dateType = reflect.TypeOf(model.Date{})
+)
+
+// builder turns Go types into schemas, collecting named structs as components
+type builder struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+func newBuilder() *builder {
+	return &builder{
+		schemas: make(map[string]*Schema),
+		names:   make(map[reflect.Type]string),
+	}
+}
+
+// schema returns the schema of t, a reference for named structs
+func (b *builder) schema(t reflect.Type) *Schema {
+	switch t {
+	case uuidType:
+		return &Schema{Type: "string", Format: "uuid"}
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case dateType:
+		// Unset dates are null
+		return &Schema{Type: []string{"string", "null"}, Format: "date"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return nullable(b.schema(t.Elem()))
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: b.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: b.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.inline(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + b.component(t)}
+	}
+	return &Schema{} // Any value
+}
+
+// component registers a named struct's schema and returns its component name
+func (b *builder) component(t reflect.Type) string {
+	if name, ok := b.names[t]; ok {
+		return name
+	}
+	name := t.Name()
+	if _, taken := b.schemas[name]; taken {
+		name = path.Base(t.PkgPath()) + "." + name // Same name in another package
+	}
+	b.names[t] = name
+	b.schemas[name] = &Schema{} // Placeholder so recursive types terminate
+	*b.schemas[name] = *b.inline(t)
+	return name
+}
+
+// inline returns the object schema of a struct's JSON fields
+func (b *builder) inline(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	b.addFields(s, t)
+	return s
+}
+
+func (b *builder) addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, hasTag := sf.Tag.Lookup("json")
+		name, _, _ := strings.Cut(tag, ",")
+		if sf.Anonymous && !hasTag && sf.Type.Kind() == reflect.Struct {
+			b.addFields(s, sf.Type) // Embedded fields are encoded inline
+			continue
+		}
+		if !sf.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+
+		rules := validate.FieldRules(sf)
+		s.Properties[name] = withRules(b.schema(sf.Type), rules)
+		if rules.Required {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// request returns the parameters and body declared by a validate.Bind schema
+func (b *builder) request(t reflect.Type) ([]Parameter, *RequestBody) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	var params []Parameter
+	var body *RequestBody
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		rules := validate.FieldRules(sf)
+
+		if name, ok := sf.Tag.Lookup("path"); ok {
+			params = append(params, Parameter{Name: name, In: "path", Required: true, Schema: b.param(sf, rules)})
+			continue
+		}
+		if name, ok := sf.Tag.Lookup("query"); ok {
+			params = append(params, Parameter{Name: name, In: "query", Required: rules.Required, Schema: b.param(sf, rules)})
+			continue
+		}
+		if _, ok := sf.Tag.Lookup("body"); ok {
+			body = &RequestBody{
+				Required: true,
+				Content:  map[string]MediaType{"application/json": {Schema: b.schema(sf.Type)}},
+			}
+			continue
+		}
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			embedded, embeddedBody := b.request(sf.Type)
+			params = append(params, embedded...)
+			if embeddedBody != nil {
+				body = embeddedBody
+			}
+		}
+	}
+	return params, body
+}
+
+// param returns the schema of a path or query parameter, which is never null
+func (b *builder) param(sf reflect.StructField, rules validate.Rules) *Schema {
+	t := sf.Type
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	s := withRules(b.schema(t), rules)
+	if def, ok := validate.Default(sf); ok {
+		s.Default = def
+		if n, err := strconv.Atoi(def); err == nil && isType(s, "integer") {
+			s.Default = n
+		}
+	}
+	return s
+}
+
+// withRules adds a field's validate rules to its schema
+func withRules(s *Schema, rules validate.Rules) *Schema {
+	if s.Ref != "" {
+		return s
+	}
+	for _, v := range rules.Enum {
+		s.Enum = append(s.Enum, v)
+	}
+	if rules.UUID {
+		s.Format = "uuid"
+	}
+	if rules.ISO3166 {
+		s.Pattern = "^[A-Za-z]{2}$"
+	}
+	if isType(s, "string") {
+		s.MinLength, s.MaxLength = rules.Min, rules.Max
+	} else {
+		s.Minimum, s.Maximum = rules.Min, rules.Max
+	}
+	return s
+}
+
+// nullable allows null besides what s allows
+func nullable(s *Schema) *Schema {
+	switch typ := s.Type.(type) {
+	case string:
+		s.Type = []string{typ, "null"}
+		return s
+	case []string:
+		return s
+	}
+	return &Schema{OneOf: []*Schema{s, {Type: "null"}}}
+}
+
+func isType(s *Schema, name string) bool {
+	switch typ := s.Type.(type) {
+	case string:
+		return typ == name
+	case []string:
+		return len(typ) > 0 && typ[0] == name
+	}
+	return false
+}
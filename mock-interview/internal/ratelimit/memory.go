@@ -0,0 +1,83 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often idle buckets are dropped from memory
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	// full is when the bucket will have refilled to its burst
+	full time.Time
+}
+
+// MemoryStore keeps token buckets in process memory. Limits are per instance.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), last: now}
+		s.buckets[key] = b
+	}
+
+	// Refill for the time elapsed since the last take
+	elapsed := now.Sub(b.last).Seconds()
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+elapsed*limit.Rate)
+	b.last = now
+
+	result := Result{Limit: limit.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsToDuration((1 - b.tokens) / limit.Rate)
+	}
+	result.Remaining = int(b.tokens)
+	result.ResetAfter = secondsToDuration((float64(limit.Burst) - b.tokens) / limit.Rate)
+	b.full = now.Add(result.ResetAfter)
+
+	return result, nil
+}
+
+// sweep drops buckets that have refilled completely, since they're equivalent to new ones
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, b := range s.buckets {
+		if now.After(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
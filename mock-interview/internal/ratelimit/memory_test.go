@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func newTestStore(now *time.Time) *MemoryStore {
+	s := NewMemoryStore()
+	s.now = func() time.Time { return *now }
+	return s
+}
+
+func TestTakeSpendsTheBurstThenRefills(t *testing.T) {
+	now := time.Unix(1735689600, 0)
+	s := newTestStore(&now)
+	limit := Limit{Rate: 2, Burst: 3}
+	take := func() Result {
+		t.Helper()
+		result, err := s.Take(context.Background(), "ip:192.0.2.1", limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	for i := range 3 {
+		if result := take(); !result.Allowed || result.Remaining != 2-i || result.Limit != 3 {
+			t.Fatalf("take %d = %+v, want allowed with %d left", i+1, result, 2-i)
+		}
+	}
+	result := take()
+	if result.Allowed || result.RetryAfter != 500*time.Millisecond || result.ResetAfter != 1500*time.Millisecond {
+		t.Errorf("take past the burst = %+v, want denied, retry in 500ms and full in 1.5s", result)
+	}
+
+	// Tokens refill at the rate, up to the burst
+	now = now.Add(500 * time.Millisecond)
+	if result := take(); !result.Allowed || result.Remaining != 0 {
+		t.Errorf("take after one token refilled = %+v", result)
+	}
+	now = now.Add(time.Hour)
+	if result := take(); !result.Allowed || result.Remaining != 2 {
+		t.Errorf("take after a long pause = %+v, want the burst less one", result)
+	}
+
+	// Buckets are independent
+	if result, _ := s.Take(context.Background(), "ip:192.0.2.2", limit); !result.Allowed || result.Remaining != 2 {
+		t.Errorf("another key's first take = %+v", result)
+	}
+}
+
+func TestSweepDropsOnlyFullBuckets(t *testing.T) {
+	now := time.Unix(1735689600, 0)
+	s := newTestStore(&now)
+	slow := Limit{Rate: 0.001, Burst: 2}
+	fast := Limit{Rate: 100, Burst: 2}
+	s.Take(context.Background(), "slow", slow)
+	s.Take(context.Background(), "fast", fast)
+
+	// Sweeps run at most once per interval, on a take
+	now = now.Add(sweepInterval / 2)
+	s.Take(context.Background(), "other", fast)
+	if len(s.buckets) != 3 {
+		t.Fatalf("%d buckets before the sweep interval, want 3", len(s.buckets))
+	}
+	now = now.Add(sweepInterval)
+	s.Take(context.Background(), "other", fast)
+	if _, ok := s.buckets["fast"]; ok {
+		t.Error("refilled bucket kept after a sweep")
+	}
+	if _, ok := s.buckets["slow"]; !ok {
+		t.Error("bucket still refilling dropped by a sweep")
+	}
+}
+
+func TestLimitFor(t *testing.T) {
+	scope := Scope{Name: "user", Limit: DefaultUserLimit, Plans: map[string]Limit{"pro": {Rate: 50, Burst: 200}}}
+	if got := scope.LimitFor("pro"); got != (Limit{Rate: 50, Burst: 200}) {
+		t.Errorf("LimitFor(pro) = %+v", got)
+	}
+	for _, plan := range []string{"", "free"} {
+		if got := scope.LimitFor(plan); got != DefaultUserLimit {
+			t.Errorf("LimitFor(%q) = %+v, want the scope's limit", plan, got)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Limit is a token bucket: Burst tokens of capacity, refilled at Rate tokens per second
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Scope names a group of routes sharing one limit, e.g. "ip" or "user"
+type Scope struct {
+	Name  string
+	Limit Limit
+	// Plans are the limits of users on a plan, replacing Limit for them
+	Plans map[string]Limit
+}
+
+// LimitFor returns the limit of a plan, or the scope's limit for plans without one of their own
+func (s Scope) LimitFor(plan string) Limit {
+	if limit, ok := s.Plans[plan]; ok {
+		return limit
+	}
+	return s.Limit
+}
+
+// Result describes the bucket after a Take
+type Result struct {
+	Allowed bool
+	// Limit is the bucket capacity
+	Limit int
+	// Remaining is the number of whole tokens left
+	Remaining int
+	// ResetAfter is the time until the bucket is full again
+	ResetAfter time.Duration
+	// RetryAfter is the time until the next token when not allowed
+	RetryAfter time.Duration
+}
+
+// Store holds token buckets. Implementations must be safe for concurrent use;
+// a shared backend (e.g. Redis) lets several instances enforce one limit.
+type Store interface {
+	// Take removes one token from the bucket for key
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+var (
+	DefaultIPLimit       = Limit{Rate: 5, Burst: 20}
+	DefaultClientIPLimit = Limit{Rate: 20, Burst: 80}
+	DefaultUserLimit     = Limit{Rate: 10, Burst: 40}
+)
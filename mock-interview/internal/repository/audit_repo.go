@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	model "github.com/winfr1th/mock-interview/internal/models"
+)
+
+// auditVerifyBatchSize is how many events Verify reads per query
+const auditVerifyBatchSize = 1000
+
+type AuditRepository interface {
+	// AppendEvent links the event to the end of the hash chain and stores it
+	AppendEvent(ctx context.Context, event model.AuditEvent) (model.AuditEvent, error)
+	// ListEvents returns events newest first, starting below the cursor ID when it's non-zero
+	ListEvents(ctx context.Context, filter model.AuditFilter, cursor int64, limit int) ([]model.AuditEvent, error)
+	// VerifyChain recomputes every hash in order and reports the first event that doesn't match
+	VerifyChain(ctx context.Context) (model.AuditVerification, error)
+}
+
+type auditRepo struct {
+	db Executor
+}
+
+func NewAuditRepository(db Executor) AuditRepository {
+	return &auditRepo{
+		db: db,
+	}
+}
+
+func (r *auditRepo) AppendEvent(ctx context.Context, event model.AuditEvent) (model.AuditEvent, error) {
+	err := r.db.WithTx(ctx, func(ctx context.Context) error {
+		// Serialize appenders so each event links to the true previous one; readers aren't
+		// blocked. SQLite's units of work are serialized already.
+		if r.db.Dialect() == Postgres {
+			if _, err := r.db.Exec(ctx, `LOCK TABLE audit_events IN EXCLUSIVE MODE`); err != nil {
+				return err
+			}
+		}
+
+		prevHash := model.GenesisAuditHash
+		err := r.db.QueryRow(ctx, `SELECT hash FROM audit_events ORDER BY id DESC LIMIT 1`).Scan(&prevHash)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+
+		if event.Metadata == nil {
+			event.Metadata = map[string]string{}
+		}
+		event.PrevHash = prevHash
+		event.Hash = event.ComputeHash(prevHash)
+
+		query := `
+			INSERT INTO audit_events (occurred_at, event_type, actor_id, target_type, target_id,
+				ip, user_agent, request_id, metadata, prev_hash, hash)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			RETURNING id
+		`
+		return r.db.QueryRow(ctx, query, event.OccurredAt, event.EventType, event.ActorID, event.TargetType,
+			event.TargetID, event.IP, event.UserAgent, event.RequestID, event.Metadata,
+			event.PrevHash, event.Hash).Scan(&event.ID)
+	})
+	if err != nil {
+		return model.AuditEvent{}, err
+	}
+
+	return event, nil
+}
+
+func (r *auditRepo) ListEvents(ctx context.Context, filter model.AuditFilter, cursor int64, limit int) ([]model.AuditEvent, error) {
+	// Build WHERE clause dynamically based on filters
+	var whereConditions []string
+	var args []interface{}
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		whereConditions = append(whereConditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if cursor > 0 {
+		addCondition("id < $%d", cursor)
+	}
+	if filter.EventType != "" {
+		addCondition("event_type = $%d", filter.EventType)
+	}
+	if filter.ActorID != nil {
+		addCondition("actor_id = $%d", *filter.ActorID)
+	}
+	if filter.TargetID != "" {
+		addCondition("target_id = $%d", filter.TargetID)
+	}
+	if filter.Since != nil {
+		addCondition("occurred_at >= $%d", *filter.Since)
+	}
+	if filter.Until != nil {
+		addCondition("occurred_at < $%d", *filter.Until)
+	}
+
+	whereClause := ""
+	if len(whereConditions) > 0 {
+		whereClause = "WHERE " + strings.Join(whereConditions, " AND ")
+	}
+
+	args = append(args, limit)
+	query := fmt.Sprintf(`
+		SELECT id, occurred_at, event_type, actor_id, target_type, target_id,
+			ip, user_agent, request_id, metadata, prev_hash, hash
+		FROM audit_events
+		%s
+		ORDER BY id DESC
+		LIMIT $%d
+	`, whereClause, len(args))
+
+	return r.queryEvents(ctx, query, args...)
+}
+
+func (r *auditRepo) VerifyChain(ctx context.Context) (model.AuditVerification, error) {
+	query := `
+		SELECT id, occurred_at, event_type, actor_id, target_type, target_id,
+			ip, user_agent, request_id, metadata, prev_hash, hash
+		FROM audit_events
+		WHERE id > $1
+		ORDER BY id ASC
+		LIMIT $2
+	`
+
+	result := model.AuditVerification{Valid: true}
+	prevHash := model.GenesisAuditHash
+	var lastID int64
+	for {
+		events, err := r.queryEvents(ctx, query, lastID, auditVerifyBatchSize)
+		if err != nil {
+			return model.AuditVerification{}, err
+		}
+
+		for _, event := range events {
+			result.Checked++
+			if event.PrevHash != prevHash || event.ComputeHash(prevHash) != event.Hash {
+				id := event.ID
+				result.Valid = false
+				result.FirstInvalidID = &id
+				return result, nil
+			}
+			prevHash = event.Hash
+			lastID = event.ID
+		}
+
+		if len(events) < auditVerifyBatchSize {
+			return result, nil
+		}
+	}
+}
+
+func (r *auditRepo) queryEvents(ctx context.Context, query string, args ...interface{}) ([]model.AuditEvent, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []model.AuditEvent
+	for rows.Next() {
+		var event model.AuditEvent
+		if err := rows.Scan(&event.ID, &event.OccurredAt, &event.EventType, &event.ActorID,
+			&event.TargetType, &event.TargetID, &event.IP, &event.UserAgent, &event.RequestID,
+			&event.Metadata, &event.PrevHash, &event.Hash); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
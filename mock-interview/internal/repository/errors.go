@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error kinds. Every error below is one of these kinds, so callers can check either the
+// specific error or its kind with errors.Is.
+var (
+	ErrNotFound         = errors.New("not found")
+	ErrAlreadyExists    = errors.New("already exists")
+	ErrInvalidReference = errors.New("referenced record does not exist")
+	ErrInvalidID        = errors.New("invalid ID format")
+)
+
+var (
+	ErrUserNotFound         = newError(ErrNotFound, "user not found")
+	ErrInvalidAPIKey        = newError(ErrNotFound, "invalid API key")
+	ErrIdentityNotFound     = newError(ErrNotFound, "identity not found")
+	ErrMovieNotFound        = newError(ErrNotFound, "movie not found")
+	ErrProfileNotFound      = newError(ErrNotFound, "profile not found")
+	ErrRefreshTokenNotFound = newError(ErrNotFound, "refresh token not found")
+	ErrMovieNotSaved        = newError(ErrNotFound, "movie not saved")
+	ErrMovieAlreadySaved    = newError(ErrAlreadyExists, "movie already saved")
+	ErrRefreshTokenReused   = newError(ErrAlreadyExists, "refresh token reused")
+	ErrInvalidUserID        = newError(ErrInvalidID, "invalid user ID format")
+)
+
+// SQLSTATE codes mapped to error kinds
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+)
+
+// kindError is a specific error that also matches its kind
+type kindError struct {
+	kind error
+	msg  string
+}
+
+func newError(kind error, msg string) error {
+	return &kindError{kind: kind, msg: msg}
+}
+
+func (e *kindError) Error() string {
+	return e.msg
+}
+
+func (e *kindError) Is(target error) bool {
+	return target == e.kind
+}
+
+// sqlStateError is an error with an SQLSTATE code, as *pgconn.PgError and the SQLite
+// Executor's constraint errors are
+type sqlStateError interface {
+	error
+	SQLState() string
+}
+
+// mapConstraintError wraps constraint violations in their error kind, keeping the original error
+func mapConstraintError(err error) error {
+	var stateErr sqlStateError
+	if !errors.As(err, &stateErr) {
+		return err
+	}
+	switch stateErr.SQLState() {
+	case uniqueViolation:
+		return fmt.Errorf("%w: %w", ErrAlreadyExists, err)
+	case foreignKeyViolation:
+		return fmt.Errorf("%w: %w", ErrInvalidReference, err)
+	default:
+		return err
+	}
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestErrorsMatchTheirKind(t *testing.T) {
+	tests := []struct {
+		err  error
+		kind error
+	}{
+		{ErrUserNotFound, ErrNotFound},
+		{ErrMovieNotSaved, ErrNotFound},
+		{ErrMovieAlreadySaved, ErrAlreadyExists},
+		{ErrInvalidUserID, ErrInvalidID},
+	}
+	for _, tt := range tests {
+		wrapped := fmt.Errorf("context: %w", tt.err)
+		if !errors.Is(wrapped, tt.err) || !errors.Is(wrapped, tt.kind) {
+			t.Errorf("%v: want errors.Is to match the error and %v", tt.err, tt.kind)
+		}
+	}
+	if errors.Is(ErrUserNotFound, ErrAlreadyExists) {
+		t.Error("ErrUserNotFound matched ErrAlreadyExists")
+	}
+	if errors.Is(ErrUserNotFound, ErrMovieNotFound) {
+		t.Error("ErrUserNotFound matched ErrMovieNotFound")
+	}
+}
+
+func TestMapConstraintError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"unique violation", &pgconn.PgError{Code: uniqueViolation}, ErrAlreadyExists},
+		{"foreign key violation", &pgconn.PgError{Code: foreignKeyViolation}, ErrInvalidReference},
+		{"wrapped unique violation", fmt.Errorf("insert: %w", &pgconn.PgError{Code: uniqueViolation}), ErrAlreadyExists},
+		{"other driver's foreign key violation", stateError(foreignKeyViolation), ErrInvalidReference},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mapConstraintError(tt.err)
+			if !errors.Is(got, tt.want) {
+				t.Errorf("mapConstraintError() = %v, want %v", got, tt.want)
+			}
+			if !errors.Is(got, tt.err) {
+				t.Error("mapConstraintError() dropped the original error")
+			}
+		})
+	}
+
+	other := &pgconn.PgError{Code: "42P01"}
+	if got := mapConstraintError(other); got != other {
+		t.Errorf("mapConstraintError(42P01) = %v, want it unchanged", got)
+	}
+	if mapConstraintError(nil) != nil {
+		t.Error("mapConstraintError(nil) != nil")
+	}
+}
+
+// stateError is a driver error that isn't a PgError but has an SQLSTATE
+type stateError string
+
+func (e stateError) Error() string    { return "constraint failed" }
+func (e stateError) SQLState() string { return string(e) }
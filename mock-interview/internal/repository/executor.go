@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Executor runs the SQL repositories' statements. *database.DB is the Postgres one, adding
+// deadlines and retries on top of the pool; *sqlite.DB runs them on an embedded SQLite database.
+// Statements run with a context from WithTx are part of its unit of work.
+type Executor interface {
+	Transactor
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	// Dialect is the SQL the database speaks
+	Dialect() Dialect
+}
+
+// Dialect is a database's flavor of SQL. Statements are written in the SQL both dialects share;
+// the few places they differ ask the Executor's dialect.
+type Dialect string
+
+const (
+	Postgres Dialect = "postgres"
+	SQLite   Dialect = "sqlite"
+)
+
+// now is the current time as an SQL expression. SQLite's CURRENT_TIMESTAMP has whole seconds
+// only, so saved movies added within a second would have no order.
+func (d Dialect) now() string {
+	if d == SQLite {
+		return `strftime('%Y-%m-%d %H:%M:%f', 'now')`
+	}
+	return "CURRENT_TIMESTAMP"
+}
+
+// forUpdate is the clause locking the selected rows until the unit of work ends. SQLite has no
+// row locks; its units of work hold the database's write lock from the start instead.
+func (d Dialect) forUpdate() string {
+	if d == SQLite {
+		return ""
+	}
+	return " FOR UPDATE"
+}
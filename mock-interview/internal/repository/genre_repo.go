@@ -3,7 +3,6 @@ package repository
 import (
 	"context"
 
-	"github.com/jackc/pgx/v5/pgxpool"
 	model "github.com/winfr1th/mock-interview/internal/models"
 )
 
@@ -12,10 +11,10 @@ type GenreRepository interface {
 }
 
 type genreRepo struct {
-	db *pgxpool.Pool
+	db Executor
 }
 
-func NewGenreRepository(db *pgxpool.Pool) GenreRepository {
+func NewGenreRepository(db Executor) GenreRepository {
 	return &genreRepo{
 		db: db,
 	}
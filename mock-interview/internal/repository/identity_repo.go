@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	model "github.com/winfr1th/mock-interview/internal/models"
+)
+
+type IdentityRepository interface {
+	// FindUserByIdentity finds the user linked to an external subject
+	FindUserByIdentity(ctx context.Context, issuer, subject string) (model.User, error)
+	LinkIdentity(ctx context.Context, identity model.UserIdentity) error
+}
+
+type identityRepo struct {
+	db Executor
+}
+
+func NewIdentityRepository(db Executor) IdentityRepository {
+	return &identityRepo{
+		db: db,
+	}
+}
+
+func (r *identityRepo) FindUserByIdentity(ctx context.Context, issuer, subject string) (model.User, error) {
+	query := `
+		SELECT u.id, u.household_id, u.name, u.date_of_birth, u.home_country, u.is_admin,
+			COALESCE(u.plan, ''), COALESCE(u.api_key_id, ''), COALESCE(u.api_key_hash, '')
+		FROM user_identities i
+		JOIN users u ON u.id = i.user_id
+		WHERE i.issuer = $1 AND i.subject = $2
+	`
+	var user model.User
+	err := r.db.QueryRow(ctx, query, issuer, subject).Scan(&user.ID, &user.HouseholdID, &user.Name,
+		&user.DateOfBirth, &user.HomeCountry, &user.IsAdmin, &user.Plan, &user.APIKeyID, &user.APIKeyHash)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return model.User{}, ErrIdentityNotFound
+		}
+		return model.User{}, err
+	}
+
+	return user, nil
+}
+
+func (r *identityRepo) LinkIdentity(ctx context.Context, identity model.UserIdentity) error {
+	query := `INSERT INTO user_identities (issuer, subject, user_id) VALUES ($1, $2, $3)`
+	_, err := r.db.Exec(ctx, query, identity.Issuer, identity.Subject, identity.UserID)
+	return mapConstraintError(err)
+}
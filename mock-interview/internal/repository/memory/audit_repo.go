@@ -0,0 +1,91 @@
+package memory
+
+import (
+	"context"
+	"maps"
+
+	model "github.com/winfr1th/mock-interview/internal/models"
+	"github.com/winfr1th/mock-interview/internal/repository"
+)
+
+type auditRepo struct {
+	s *Store
+}
+
+func NewAuditRepository(s *Store) repository.AuditRepository {
+	return &auditRepo{s: s}
+}
+
+// AppendEvent links the event to the end of the hash chain and stores it
+func (r *auditRepo) AppendEvent(ctx context.Context, event model.AuditEvent) (model.AuditEvent, error) {
+	defer r.s.lock(ctx)()
+	event = cloneEvent(event)
+	if event.Metadata == nil {
+		event.Metadata = map[string]string{}
+	}
+	event.ID = int64(len(r.s.auditEvents)) + 1
+	event.PrevHash = r.s.lastAuditHash()
+	event.Hash = event.ComputeHash(event.PrevHash)
+	r.s.auditEvents = append(r.s.auditEvents, event)
+	return cloneEvent(event), nil
+}
+
+// ListEvents returns events newest first, starting below the cursor ID when it's non-zero
+func (r *auditRepo) ListEvents(ctx context.Context, filter model.AuditFilter, cursor int64, limit int) ([]model.AuditEvent, error) {
+	defer r.s.lock(ctx)()
+	var events []model.AuditEvent
+	for i := len(r.s.auditEvents) - 1; i >= 0 && len(events) < limit; i-- {
+		event := r.s.auditEvents[i]
+		if cursor > 0 && event.ID >= cursor {
+			continue
+		}
+		if filter.EventType != "" && event.EventType != filter.EventType {
+			continue
+		}
+		if filter.ActorID != nil && (event.ActorID == nil || *event.ActorID != *filter.ActorID) {
+			continue
+		}
+		if filter.TargetID != "" && event.TargetID != filter.TargetID {
+			continue
+		}
+		if filter.Since != nil && event.OccurredAt.Before(*filter.Since) {
+			continue
+		}
+		if filter.Until != nil && !event.OccurredAt.Before(*filter.Until) {
+			continue
+		}
+		events = append(events, cloneEvent(event))
+	}
+	return events, nil
+}
+
+// VerifyChain recomputes every hash in order and reports the first event that doesn't match
+func (r *auditRepo) VerifyChain(ctx context.Context) (model.AuditVerification, error) {
+	defer r.s.lock(ctx)()
+	result := model.AuditVerification{Valid: true}
+	prevHash := model.GenesisAuditHash
+	for _, event := range r.s.auditEvents {
+		result.Checked++
+		if event.PrevHash != prevHash || event.ComputeHash(prevHash) != event.Hash {
+			id := event.ID
+			result.Valid = false
+			result.FirstInvalidID = &id
+			return result, nil
+		}
+		prevHash = event.Hash
+	}
+	return result, nil
+}
+
+func (t *tables) lastAuditHash() string {
+	if len(t.auditEvents) == 0 {
+		return model.GenesisAuditHash
+	}
+	return t.auditEvents[len(t.auditEvents)-1].Hash
+}
+
+func cloneEvent(event model.AuditEvent) model.AuditEvent {
+	event.ActorID = clonePtr(event.ActorID)
+	event.Metadata = maps.Clone(event.Metadata)
+	return event
+}
@@ -0,0 +1,28 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	model "github.com/winfr1th/mock-interview/internal/models"
+	"github.com/winfr1th/mock-interview/internal/repository"
+)
+
+type genreRepo struct {
+	s *Store
+}
+
+func NewGenreRepository(s *Store) repository.GenreRepository {
+	return &genreRepo{s: s}
+}
+
+// ListGenres returns a page of genres by name
+func (r *genreRepo) ListGenres(ctx context.Context, page, pageSize int) ([]model.Genre, int, error) {
+	defer r.s.lock(ctx)()
+	genres := make([]model.Genre, 0, len(r.s.genres))
+	for _, genre := range r.s.genres {
+		genres = append(genres, genre)
+	}
+	sort.Slice(genres, func(i, j int) bool { return genres[i].Name < genres[j].Name })
+	return paginate(genres, page, pageSize), len(genres), nil
+}
@@ -0,0 +1,39 @@
+package memory
+
+import (
+	"context"
+
+	model "github.com/winfr1th/mock-interview/internal/models"
+	"github.com/winfr1th/mock-interview/internal/repository"
+)
+
+type identityRepo struct {
+	s *Store
+}
+
+func NewIdentityRepository(s *Store) repository.IdentityRepository {
+	return &identityRepo{s: s}
+}
+
+func (r *identityRepo) FindUserByIdentity(ctx context.Context, issuer, subject string) (model.User, error) {
+	defer r.s.lock(ctx)()
+	identity, ok := r.s.identities[identityKey{issuer, subject}]
+	if !ok {
+		return model.User{}, repository.ErrIdentityNotFound
+	}
+	return cloneUser(r.s.users[identity.UserID]), nil
+}
+
+func (r *identityRepo) LinkIdentity(ctx context.Context, identity model.UserIdentity) error {
+	defer r.s.lock(ctx)()
+	key := identityKey{identity.Issuer, identity.Subject}
+	if _, ok := r.s.identities[key]; ok {
+		return repository.ErrAlreadyExists
+	}
+	if _, ok := r.s.users[identity.UserID]; !ok {
+		return repository.ErrInvalidReference
+	}
+	identity.CreatedAt = r.s.now()
+	r.s.identities[key] = identity
+	return nil
+}
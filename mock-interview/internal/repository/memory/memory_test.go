@@ -0,0 +1,26 @@
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/winfr1th/mock-interview/internal/repository/memory"
+	"github.com/winfr1th/mock-interview/internal/repository/repotest"
+)
+
+func TestConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Backend {
+		store := memory.NewStore()
+		return repotest.Backend{
+			Users:         memory.NewUserRepository(store),
+			Profiles:      memory.NewProfileRepository(store),
+			Identities:    memory.NewIdentityRepository(store),
+			RefreshTokens: memory.NewRefreshTokenRepository(store),
+			Genres:        memory.NewGenreRepository(store),
+			Movies:        memory.NewMovieRepository(store),
+			SavedMovies:   memory.NewSaveMoviesRepository(store),
+			Audit:         memory.NewAuditRepository(store),
+			Transactor:    store,
+			Catalog:       store,
+		}
+	})
+}
@@ -0,0 +1,76 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	model "github.com/winfr1th/mock-interview/internal/models"
+	"github.com/winfr1th/mock-interview/internal/repository"
+)
+
+type movieRepo struct {
+	s *Store
+}
+
+func NewMovieRepository(s *Store) repository.MovieRepository {
+	return &movieRepo{s: s}
+}
+
+func (r *movieRepo) ListMovies(ctx context.Context, countryCode *string, genreID *uuid.UUID, maxAge *int, page, pageSize int, sortBy string) ([]model.Movie, int, error) {
+	country := ""
+	if countryCode != nil {
+		country = strings.ToUpper(*countryCode)
+	}
+
+	defer r.s.lock(ctx)()
+	var movies []model.Movie
+	for _, movie := range r.s.movies {
+		if genreID != nil && movie.GenreID != *genreID {
+			continue
+		}
+		if country != "" && !r.s.available(movie.ID, country) {
+			continue
+		}
+		// With a country only that country's certification counts; without one, any country's does
+		if maxAge != nil && r.s.restricted(movie.ID, country, *maxAge) {
+			continue
+		}
+		movies = append(movies, movie)
+	}
+
+	// Default: -year (newest first)
+	ascending := sortBy == "year"
+	sort.Slice(movies, func(i, j int) bool {
+		if ascending {
+			return movies[i].Year < movies[j].Year
+		}
+		return movies[i].Year > movies[j].Year
+	})
+	return paginate(movies, page, pageSize), len(movies), nil
+}
+
+func (r *movieRepo) GetMovieByID(ctx context.Context, movieID uuid.UUID) (model.Movie, error) {
+	defer r.s.lock(ctx)()
+	movie, ok := r.s.movies[movieID]
+	if !ok {
+		return model.Movie{}, repository.ErrMovieNotFound
+	}
+	return movie, nil
+}
+
+func (r *movieRepo) IsMovieAvailableInCountry(ctx context.Context, movieID uuid.UUID, countryCode string) (bool, error) {
+	defer r.s.lock(ctx)()
+	return r.s.available(movieID, countryCode), nil
+}
+
+func (r *movieRepo) GetCertification(ctx context.Context, movieID uuid.UUID, countryCode string) (*model.Certification, error) {
+	defer r.s.lock(ctx)()
+	cert, ok := r.s.certification(movieID, strings.ToUpper(countryCode))
+	if !ok {
+		// Unrated in this country
+		return nil, nil
+	}
+	return &cert, nil
+}
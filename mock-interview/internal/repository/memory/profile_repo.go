@@ -0,0 +1,119 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/google/uuid"
+	model "github.com/winfr1th/mock-interview/internal/models"
+	"github.com/winfr1th/mock-interview/internal/repository"
+)
+
+type profileRepo struct {
+	s *Store
+}
+
+func NewProfileRepository(s *Store) repository.ProfileRepository {
+	return &profileRepo{s: s}
+}
+
+func (r *profileRepo) CreateProfile(ctx context.Context, profile model.Profile) error {
+	defer r.s.lock(ctx)()
+	if _, ok := r.s.profiles[profile.ID]; ok {
+		return repository.ErrAlreadyExists
+	}
+	if _, ok := r.s.households[profile.HouseholdID]; !ok {
+		return repository.ErrInvalidReference
+	}
+	if profile.IsDefault {
+		if _, ok := r.s.defaultProfile(profile.HouseholdID); ok {
+			return repository.ErrAlreadyExists
+		}
+	}
+	r.s.profiles[profile.ID] = cloneProfile(profile)
+	return nil
+}
+
+func (r *profileRepo) GetProfile(ctx context.Context, householdID, profileID uuid.UUID) (model.Profile, error) {
+	defer r.s.lock(ctx)()
+	profile, ok := r.s.profiles[profileID]
+	if !ok || profile.HouseholdID != householdID {
+		return model.Profile{}, repository.ErrProfileNotFound
+	}
+	return cloneProfile(profile), nil
+}
+
+func (r *profileRepo) GetDefaultProfile(ctx context.Context, householdID uuid.UUID) (model.Profile, error) {
+	defer r.s.lock(ctx)()
+	profile, ok := r.s.defaultProfile(householdID)
+	if !ok {
+		return model.Profile{}, repository.ErrProfileNotFound
+	}
+	return cloneProfile(profile), nil
+}
+
+// ListProfiles returns the household's profiles, the default one first and the others by name
+func (r *profileRepo) ListProfiles(ctx context.Context, householdID uuid.UUID) ([]model.Profile, error) {
+	defer r.s.lock(ctx)()
+	var profiles []model.Profile
+	for _, profile := range r.s.profiles {
+		if profile.HouseholdID == householdID {
+			profiles = append(profiles, cloneProfile(profile))
+		}
+	}
+	sort.Slice(profiles, func(i, j int) bool {
+		if profiles[i].IsDefault != profiles[j].IsDefault {
+			return profiles[i].IsDefault
+		}
+		return profiles[i].Name < profiles[j].Name
+	})
+	return profiles, nil
+}
+
+func (r *profileRepo) UpdateProfile(ctx context.Context, profile model.Profile) error {
+	defer r.s.lock(ctx)()
+	stored, ok := r.s.profiles[profile.ID]
+	if !ok || stored.HouseholdID != profile.HouseholdID {
+		return repository.ErrProfileNotFound
+	}
+	stored.Name = profile.Name
+	stored.MaxAge = clonePtr(profile.MaxAge)
+	stored.PINHash = profile.PINHash
+	r.s.profiles[profile.ID] = stored
+	return nil
+}
+
+// DeleteProfile removes a non-default profile and, through the cascade, its saved movies
+func (r *profileRepo) DeleteProfile(ctx context.Context, householdID, profileID uuid.UUID) error {
+	defer r.s.lock(ctx)()
+	profile, ok := r.s.profiles[profileID]
+	if !ok || profile.HouseholdID != householdID || profile.IsDefault {
+		return repository.ErrProfileNotFound
+	}
+	r.s.deleteProfile(profileID)
+	return nil
+}
+
+func (t *tables) defaultProfile(householdID uuid.UUID) (model.Profile, bool) {
+	for _, profile := range t.profiles {
+		if profile.HouseholdID == householdID && profile.IsDefault {
+			return profile, true
+		}
+	}
+	return model.Profile{}, false
+}
+
+// deleteProfile deletes a profile and its saved movies
+func (t *tables) deleteProfile(profileID uuid.UUID) {
+	for key := range t.saved {
+		if key.profileID == profileID {
+			delete(t.saved, key)
+		}
+	}
+	delete(t.profiles, profileID)
+}
+
+func cloneProfile(profile model.Profile) model.Profile {
+	profile.MaxAge = clonePtr(profile.MaxAge)
+	return profile
+}
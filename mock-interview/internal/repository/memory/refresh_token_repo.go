@@ -0,0 +1,85 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	model "github.com/winfr1th/mock-interview/internal/models"
+	"github.com/winfr1th/mock-interview/internal/repository"
+)
+
+type refreshTokenRepo struct {
+	s *Store
+}
+
+func NewRefreshTokenRepository(s *Store) repository.RefreshTokenRepository {
+	return &refreshTokenRepo{s: s}
+}
+
+func (r *refreshTokenRepo) CreateRefreshToken(ctx context.Context, token model.RefreshToken) error {
+	defer r.s.lock(ctx)()
+	if _, ok := r.s.refreshTokens[token.ID]; ok {
+		return repository.ErrAlreadyExists
+	}
+	if _, ok := r.s.tokenByHash(token.TokenHash); ok {
+		return repository.ErrAlreadyExists
+	}
+	if _, ok := r.s.users[token.UserID]; !ok {
+		return repository.ErrInvalidReference
+	}
+	token.CreatedAt = r.s.now()
+	token.UsedAt, token.RevokedAt = nil, nil
+	r.s.refreshTokens[token.ID] = token
+	return nil
+}
+
+func (r *refreshTokenRepo) UseRefreshToken(ctx context.Context, tokenHash string) (model.RefreshToken, error) {
+	defer r.s.lock(ctx)()
+	token, ok := r.s.tokenByHash(tokenHash)
+	if !ok {
+		return model.RefreshToken{}, repository.ErrRefreshTokenNotFound
+	}
+	now := r.s.now()
+	if token.UsedAt == nil && token.RevokedAt == nil && token.ExpiresAt.After(now) {
+		token.UsedAt = &now
+		r.s.refreshTokens[token.ID] = token
+		return token, nil
+	}
+
+	// Work out why it couldn't be used
+	if token.UsedAt != nil {
+		return token, repository.ErrRefreshTokenReused
+	}
+	return model.RefreshToken{}, repository.ErrRefreshTokenNotFound
+}
+
+func (r *refreshTokenRepo) RevokeFamily(ctx context.Context, familyID uuid.UUID) error {
+	defer r.s.lock(ctx)()
+	r.s.revokeTokens(func(token model.RefreshToken) bool { return token.FamilyID == familyID })
+	return nil
+}
+
+func (r *refreshTokenRepo) RevokeAPIKeyTokens(ctx context.Context, apiKeyID string) error {
+	defer r.s.lock(ctx)()
+	r.s.revokeTokens(func(token model.RefreshToken) bool { return apiKeyID != "" && token.APIKeyID == apiKeyID })
+	return nil
+}
+
+func (s *Store) revokeTokens(match func(model.RefreshToken) bool) {
+	now := s.now()
+	for id, token := range s.refreshTokens {
+		if token.RevokedAt == nil && match(token) {
+			token.RevokedAt = &now
+			s.refreshTokens[id] = token
+		}
+	}
+}
+
+func (t *tables) tokenByHash(tokenHash string) (model.RefreshToken, bool) {
+	for _, token := range t.refreshTokens {
+		if token.TokenHash == tokenHash {
+			return token, true
+		}
+	}
+	return model.RefreshToken{}, false
+}
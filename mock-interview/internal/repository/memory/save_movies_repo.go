@@ -0,0 +1,91 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	model "github.com/winfr1th/mock-interview/internal/models"
+	"github.com/winfr1th/mock-interview/internal/repository"
+)
+
+type saveMoviesRepo struct {
+	s *Store
+}
+
+func NewSaveMoviesRepository(s *Store) repository.SaveMoviesRepository {
+	return &saveMoviesRepo{s: s}
+}
+
+// ListSavedMovies returns a page of the profile's saved movies that are available in the country
+// and, with a maxAge, not certified above it there
+func (r *saveMoviesRepo) ListSavedMovies(ctx context.Context, profileID uuid.UUID, countryCode string, maxAge *int, page, pageSize int, sortBy string) ([]model.Movie, int, error) {
+	country := strings.ToUpper(countryCode)
+
+	defer r.s.lock(ctx)()
+	type saved struct {
+		movie     model.Movie
+		dateAdded int64
+	}
+	var rows []saved
+	for key, dateAdded := range r.s.saved {
+		if key.profileID != profileID || !r.s.available(key.movieID, country) {
+			continue
+		}
+		if maxAge != nil && r.s.restricted(key.movieID, country, *maxAge) {
+			continue
+		}
+		rows = append(rows, saved{movie: r.s.movies[key.movieID], dateAdded: dateAdded.UnixMicro()})
+	}
+
+	// Default: -date_added (newest first)
+	ascending := sortBy == "date_added"
+	sort.Slice(rows, func(i, j int) bool {
+		if ascending {
+			return rows[i].dateAdded < rows[j].dateAdded
+		}
+		return rows[i].dateAdded > rows[j].dateAdded
+	})
+
+	movies := make([]model.Movie, 0, len(rows))
+	for _, row := range paginate(rows, page, pageSize) {
+		movies = append(movies, row.movie)
+	}
+	if len(movies) == 0 {
+		movies = nil
+	}
+	return movies, len(rows), nil
+}
+
+// SaveMovie saves the movie for the profile; a movie already saved is ErrMovieAlreadySaved
+func (r *saveMoviesRepo) SaveMovie(ctx context.Context, profileID, movieID uuid.UUID) error {
+	defer r.s.lock(ctx)()
+	key := savedMovie{profileID, movieID}
+	if _, ok := r.s.saved[key]; ok {
+		return repository.ErrMovieAlreadySaved
+	}
+	_, movieExists := r.s.movies[movieID]
+	_, profileExists := r.s.profiles[profileID]
+	if !movieExists || !profileExists {
+		return repository.ErrMovieNotFound
+	}
+	r.s.saved[key] = r.s.now()
+	return nil
+}
+
+func (r *saveMoviesRepo) RemoveSavedMovie(ctx context.Context, profileID, movieID uuid.UUID) error {
+	defer r.s.lock(ctx)()
+	key := savedMovie{profileID, movieID}
+	if _, ok := r.s.saved[key]; !ok {
+		return repository.ErrMovieNotSaved
+	}
+	delete(r.s.saved, key)
+	return nil
+}
+
+func (r *saveMoviesRepo) IsMovieSaved(ctx context.Context, profileID, movieID uuid.UUID) (bool, error) {
+	defer r.s.lock(ctx)()
+	_, ok := r.s.saved[savedMovie{profileID, movieID}]
+	return ok, nil
+}
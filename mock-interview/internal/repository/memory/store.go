@@ -0,0 +1,246 @@
+// Package memory implements every repository in process memory with the semantics of the
+// Postgres ones: the same errors, sort orders, pagination, country and age filters, and
+// cascading deletes. Handler tests use it to run without a database.
+package memory
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	model "github.com/winfr1th/mock-interview/internal/models"
+	"github.com/winfr1th/mock-interview/internal/repository"
+)
+
+// Store holds the tables the repositories share. It is a repository.Transactor: a unit of work
+// holds the store's lock until it ends, so units of work are serializable, and a failed one
+// leaves no trace. Inside a unit of work, every repository call must be given the context WithTx
+// passed to the function, or it waits for the unit of work to end.
+type Store struct {
+	mu   sync.Mutex
+	last time.Time // Latest time now returned
+	tables
+}
+
+type movieCountry struct {
+	movieID uuid.UUID
+	country string
+}
+
+type savedMovie struct {
+	profileID, movieID uuid.UUID
+}
+
+type certKey struct {
+	country, code string
+}
+
+type identityKey struct {
+	issuer, subject string
+}
+
+// tables are the rows; keys are the Postgres tables' primary keys
+type tables struct {
+	countries      map[string]model.Country
+	genres         map[uuid.UUID]model.Genre
+	movies         map[uuid.UUID]model.Movie
+	availability   map[movieCountry]struct{}
+	certifications map[certKey]int         // Minimum age of a country's rating
+	movieCerts     map[movieCountry]string // Certification code of a movie in a country
+	households     map[uuid.UUID]model.Household
+	users          map[uuid.UUID]model.User
+	profiles       map[uuid.UUID]model.Profile
+	saved          map[savedMovie]time.Time // Date added
+	identities     map[identityKey]model.UserIdentity
+	refreshTokens  map[uuid.UUID]model.RefreshToken
+	auditEvents    []model.AuditEvent // In ID order; the ID of an event is its index plus one
+}
+
+// NewStore returns an empty store
+func NewStore() *Store {
+	return &Store{tables: tables{
+		countries:      map[string]model.Country{},
+		genres:         map[uuid.UUID]model.Genre{},
+		movies:         map[uuid.UUID]model.Movie{},
+		availability:   map[movieCountry]struct{}{},
+		certifications: map[certKey]int{},
+		movieCerts:     map[movieCountry]string{},
+		households:     map[uuid.UUID]model.Household{},
+		users:          map[uuid.UUID]model.User{},
+		profiles:       map[uuid.UUID]model.Profile{},
+		saved:          map[savedMovie]time.Time{},
+		identities:     map[identityKey]model.UserIdentity{},
+		refreshTokens:  map[uuid.UUID]model.RefreshToken{},
+	}}
+}
+
+// clone copies the tables so a failed unit of work can be undone. Rows are values whose
+// pointers are never written through, so copying the maps is enough.
+func (t tables) clone() tables {
+	return tables{
+		countries:      maps.Clone(t.countries),
+		genres:         maps.Clone(t.genres),
+		movies:         maps.Clone(t.movies),
+		availability:   maps.Clone(t.availability),
+		certifications: maps.Clone(t.certifications),
+		movieCerts:     maps.Clone(t.movieCerts),
+		households:     maps.Clone(t.households),
+		users:          maps.Clone(t.users),
+		profiles:       maps.Clone(t.profiles),
+		saved:          maps.Clone(t.saved),
+		identities:     maps.Clone(t.identities),
+		refreshTokens:  maps.Clone(t.refreshTokens),
+		auditEvents:    slices.Clone(t.auditEvents),
+	}
+}
+
+// txKey is the context key marking the unit of work of a store
+type txKey struct{}
+
+var _ repository.Transactor = (*Store)(nil)
+
+// WithTx runs fn as one unit of work, undoing its changes when it returns an error.
+// Called inside a unit of work, WithTx joins it.
+func (s *Store) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx.Value(txKey{}) == s {
+		return fn(ctx)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := s.tables.clone()
+	if err := fn(context.WithValue(ctx, txKey{}, s)); err != nil {
+		s.tables = snapshot
+		return err
+	}
+	return nil
+}
+
+// lock locks the store unless ctx is in its unit of work, which holds the lock already.
+// It returns the unlock function.
+func (s *Store) lock(ctx context.Context) func() {
+	if ctx.Value(txKey{}) == s {
+		return func() {}
+	}
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+// now returns the current time at the database's precision, later than any time it returned
+// before so rows keep the order they were written in
+func (s *Store) now() time.Time {
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	if !now.After(s.last) {
+		now = s.last.Add(time.Microsecond)
+	}
+	s.last = now
+	return now
+}
+
+// AddCountry adds a country movies can be available and certified in, and users can live in
+func (s *Store) AddCountry(ctx context.Context, country model.Country) error {
+	defer s.lock(ctx)()
+	if _, ok := s.countries[country.Code]; ok {
+		return repository.ErrAlreadyExists
+	}
+	s.countries[country.Code] = country
+	return nil
+}
+
+// AddGenre adds a genre to the catalog
+func (s *Store) AddGenre(ctx context.Context, genre model.Genre) error {
+	defer s.lock(ctx)()
+	if _, ok := s.genres[genre.ID]; ok {
+		return repository.ErrAlreadyExists
+	}
+	s.genres[genre.ID] = genre
+	return nil
+}
+
+// AddMovie adds a movie to the catalog, available in the given countries
+func (s *Store) AddMovie(ctx context.Context, movie model.Movie, countries ...string) error {
+	defer s.lock(ctx)()
+	if _, ok := s.movies[movie.ID]; ok {
+		return repository.ErrAlreadyExists
+	}
+	if _, ok := s.genres[movie.GenreID]; !ok {
+		return repository.ErrInvalidReference
+	}
+	for _, country := range countries {
+		if _, ok := s.countries[country]; !ok {
+			return repository.ErrInvalidReference
+		}
+	}
+	s.movies[movie.ID] = movie
+	for _, country := range countries {
+		s.availability[movieCountry{movie.ID, country}] = struct{}{}
+	}
+	return nil
+}
+
+// Certify rates a movie in the certification's country. The certification is added to the
+// country's ratings, replacing the minimum age of one with the same code.
+func (s *Store) Certify(ctx context.Context, movieID uuid.UUID, cert model.Certification) error {
+	defer s.lock(ctx)()
+	if _, ok := s.countries[cert.CountryCode]; !ok {
+		return repository.ErrInvalidReference
+	}
+	if _, ok := s.movies[movieID]; !ok {
+		return repository.ErrInvalidReference
+	}
+	key := movieCountry{movieID, cert.CountryCode}
+	if _, ok := s.movieCerts[key]; ok {
+		return repository.ErrAlreadyExists
+	}
+	s.certifications[certKey{cert.CountryCode, cert.Code}] = cert.MinAge
+	s.movieCerts[key] = cert.Code
+	return nil
+}
+
+// certification returns the movie's certification in country, if it has one
+func (t *tables) certification(movieID uuid.UUID, country string) (model.Certification, bool) {
+	code, ok := t.movieCerts[movieCountry{movieID, country}]
+	if !ok {
+		return model.Certification{}, false
+	}
+	return model.Certification{CountryCode: country, Code: code, MinAge: t.certifications[certKey{country, code}]}, true
+}
+
+// restricted reports whether the movie is certified above maxAge in country, or in any
+// country when country is empty
+func (t *tables) restricted(movieID uuid.UUID, country string, maxAge int) bool {
+	for key, code := range t.movieCerts {
+		if key.movieID == movieID && (country == "" || key.country == country) &&
+			t.certifications[certKey{key.country, code}] > maxAge {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *tables) available(movieID uuid.UUID, country string) bool {
+	_, ok := t.availability[movieCountry{movieID, strings.ToUpper(country)}]
+	return ok
+}
+
+// paginate returns the page of rows, numbered from 1
+func paginate[T any](rows []T, page, pageSize int) []T {
+	start := (page - 1) * pageSize
+	if start >= len(rows) || start < 0 {
+		return nil
+	}
+	return rows[start:min(start+pageSize, len(rows))]
+}
+
+// clonePtr copies the value p points to, so stored rows don't share it with callers
+func clonePtr[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	v := *p
+	return &v
+}
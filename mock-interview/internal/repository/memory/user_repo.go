@@ -0,0 +1,198 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	model "github.com/winfr1th/mock-interview/internal/models"
+	"github.com/winfr1th/mock-interview/internal/repository"
+)
+
+type userRepo struct {
+	s *Store
+}
+
+func NewUserRepository(s *Store) repository.UserRepository {
+	return &userRepo{s: s}
+}
+
+// CreateUser creates the user together with its household and default profile.
+// The default profile reuses the user's ID.
+func (r *userRepo) CreateUser(ctx context.Context, user model.User) error {
+	defer r.s.lock(ctx)()
+	if _, ok := r.s.households[user.HouseholdID]; ok {
+		return repository.ErrAlreadyExists
+	}
+	if _, ok := r.s.users[user.ID]; ok {
+		return repository.ErrAlreadyExists
+	}
+	if _, ok := r.s.profiles[user.ID]; ok {
+		return repository.ErrAlreadyExists
+	}
+	if err := r.s.checkUser(user); err != nil {
+		return err
+	}
+
+	r.s.households[user.HouseholdID] = model.Household{ID: user.HouseholdID, Name: user.Name, CreatedAt: r.s.now()}
+	r.s.users[user.ID] = cloneUser(user)
+	r.s.profiles[user.ID] = model.Profile{ID: user.ID, HouseholdID: user.HouseholdID, Name: user.Name, IsDefault: true}
+	return nil
+}
+
+func (r *userRepo) FindUserByID(ctx context.Context, id string) (model.User, error) {
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		return model.User{}, repository.ErrInvalidUserID
+	}
+
+	defer r.s.lock(ctx)()
+	user, ok := r.s.users[userID]
+	if !ok {
+		return model.User{}, repository.ErrUserNotFound
+	}
+	return cloneUser(user), nil
+}
+
+// FindUserByIDForUpdate is FindUserByID: a unit of work holds the whole store already
+func (r *userRepo) FindUserByIDForUpdate(ctx context.Context, id string) (model.User, error) {
+	return r.FindUserByID(ctx, id)
+}
+
+// FindUserByAPIKeyHash finds the user of a legacy UUID key by the key's hash. Legacy keys have
+// no key ID to find their row by.
+func (r *userRepo) FindUserByAPIKeyHash(ctx context.Context, keyHash string) (model.User, error) {
+	defer r.s.lock(ctx)()
+	for _, user := range r.s.users {
+		if keyHash != "" && user.APIKeyHash == keyHash && user.APIKeyID == "" {
+			return cloneUser(user), nil
+		}
+	}
+	return model.User{}, repository.ErrInvalidAPIKey
+}
+
+// FindUserByAPIKeyID finds the user owning a key ID; the caller verifies the key against APIKeyHash
+func (r *userRepo) FindUserByAPIKeyID(ctx context.Context, keyID string) (model.User, error) {
+	defer r.s.lock(ctx)()
+	if user, ok := r.s.userByAPIKeyID(keyID); ok {
+		return cloneUser(user), nil
+	}
+	return model.User{}, repository.ErrInvalidAPIKey
+}
+
+func (r *userRepo) UpdateUser(ctx context.Context, user model.User) error {
+	defer r.s.lock(ctx)()
+	current, ok := r.s.users[user.ID]
+	if !ok {
+		return repository.ErrUserNotFound
+	}
+	current.Name = user.Name
+	current.DateOfBirth = user.DateOfBirth
+	current.HomeCountry = user.HomeCountry
+	if err := r.s.checkUser(current); err != nil {
+		return err
+	}
+	r.s.users[user.ID] = cloneUser(current)
+	return nil
+}
+
+func (r *userRepo) UpdateAPIKey(ctx context.Context, userID uuid.UUID, keyID, keyHash string) error {
+	defer r.s.lock(ctx)()
+	current, ok := r.s.users[userID]
+	if !ok {
+		return repository.ErrUserNotFound
+	}
+	if owner, ok := r.s.userByAPIKeyID(keyID); ok && owner.ID != userID {
+		return repository.ErrAlreadyExists
+	}
+	current.APIKeyID = keyID
+	current.APIKeyHash = keyHash
+	r.s.users[userID] = current
+	return nil
+}
+
+// RevokeAPIKey removes the user's API key so it can no longer authenticate
+func (r *userRepo) RevokeAPIKey(ctx context.Context, userID uuid.UUID) error {
+	defer r.s.lock(ctx)()
+	current, ok := r.s.users[userID]
+	if !ok {
+		return repository.ErrUserNotFound
+	}
+	current.APIKeyID = ""
+	current.APIKeyHash = ""
+	r.s.users[userID] = current
+	return nil
+}
+
+// DeleteUser deletes the user's household, which cascades to the user, its profiles and saved movies
+func (r *userRepo) DeleteUser(ctx context.Context, id string) error {
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		return repository.ErrInvalidUserID
+	}
+
+	defer r.s.lock(ctx)()
+	user, ok := r.s.users[userID]
+	if !ok {
+		return repository.ErrUserNotFound
+	}
+	r.s.deleteHousehold(user.HouseholdID)
+	return nil
+}
+
+// checkUser checks the user's unique and foreign keys other than its ID
+func (t *tables) checkUser(user model.User) error {
+	if owner, ok := t.userByAPIKeyID(user.APIKeyID); ok && owner.ID != user.ID {
+		return repository.ErrAlreadyExists
+	}
+	if user.HomeCountry != nil {
+		if _, ok := t.countries[*user.HomeCountry]; !ok {
+			return repository.ErrInvalidReference
+		}
+	}
+	return nil
+}
+
+// userByAPIKeyID finds the user owning keyID; no user owns the empty key ID
+func (t *tables) userByAPIKeyID(keyID string) (model.User, bool) {
+	if keyID == "" {
+		return model.User{}, false
+	}
+	for _, user := range t.users {
+		if user.APIKeyID == keyID {
+			return user, true
+		}
+	}
+	return model.User{}, false
+}
+
+// deleteHousehold deletes a household and, as the foreign keys cascade, its users with their
+// identities and refresh tokens, and its profiles with their saved movies
+func (t *tables) deleteHousehold(householdID uuid.UUID) {
+	for id, user := range t.users {
+		if user.HouseholdID != householdID {
+			continue
+		}
+		for key, identity := range t.identities {
+			if identity.UserID == id {
+				delete(t.identities, key)
+			}
+		}
+		for tokenID, token := range t.refreshTokens {
+			if token.UserID == id {
+				delete(t.refreshTokens, tokenID)
+			}
+		}
+		delete(t.users, id)
+	}
+	for id, profile := range t.profiles {
+		if profile.HouseholdID == householdID {
+			t.deleteProfile(id)
+		}
+	}
+	delete(t.households, householdID)
+}
+
+func cloneUser(user model.User) model.User {
+	user.HomeCountry = clonePtr(user.HomeCountry)
+	return user
+}
@@ -8,27 +8,27 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
 	model "github.com/winfr1th/mock-interview/internal/models"
 )
 
 type MovieRepository interface {
-	ListMovies(ctx context.Context, countryCode *string, genreID *uuid.UUID, page, pageSize int, sortBy string) ([]model.Movie, int, error)
+	ListMovies(ctx context.Context, countryCode *string, genreID *uuid.UUID, maxAge *int, page, pageSize int, sortBy string) ([]model.Movie, int, error)
 	GetMovieByID(ctx context.Context, movieID uuid.UUID) (model.Movie, error)
 	IsMovieAvailableInCountry(ctx context.Context, movieID uuid.UUID, countryCode string) (bool, error)
+	GetCertification(ctx context.Context, movieID uuid.UUID, countryCode string) (*model.Certification, error)
 }
 
 type movieRepo struct {
-	db *pgxpool.Pool
+	db Executor
 }
 
-func NewMovieRepository(db *pgxpool.Pool) MovieRepository {
+func NewMovieRepository(db Executor) MovieRepository {
 	return &movieRepo{
 		db: db,
 	}
 }
 
-func (r *movieRepo) ListMovies(ctx context.Context, countryCode *string, genreID *uuid.UUID, page, pageSize int, sortBy string) ([]model.Movie, int, error) {
+func (r *movieRepo) ListMovies(ctx context.Context, countryCode *string, genreID *uuid.UUID, maxAge *int, page, pageSize int, sortBy string) ([]model.Movie, int, error) {
 	// Build WHERE clause dynamically based on filters
 	var whereConditions []string
 	var args []interface{}
@@ -51,6 +51,21 @@ func (r *movieRepo) ListMovies(ctx context.Context, countryCode *string, genreID
 		argIndex++
 	}
 
+	// Filter by viewer age: hide movies certified above maxAge. With a country only that
+	// country's certification counts; without one, any country's certification does.
+	if maxAge != nil {
+		ageCondition := fmt.Sprintf("c.min_age > $%d", argIndex)
+		args = append(args, *maxAge)
+		argIndex++
+		if needsJoin {
+			ageCondition += " AND mc.country_code = ma.country_code"
+		}
+		whereConditions = append(whereConditions, fmt.Sprintf(`NOT EXISTS (
+			SELECT 1 FROM movie_certifications mc
+			INNER JOIN certifications c ON c.country_code = mc.country_code AND c.code = mc.certification
+			WHERE mc.movie_id = m.id AND %s)`, ageCondition))
+	}
+
 	whereClause := ""
 	if len(whereConditions) > 0 {
 		whereClause = "WHERE " + strings.Join(whereConditions, " AND ")
@@ -123,7 +138,7 @@ func (r *movieRepo) GetMovieByID(ctx context.Context, movieID uuid.UUID) (model.
 	err := r.db.QueryRow(ctx, query, movieID).Scan(&movie.ID, &movie.Title, &movie.Year, &movie.GenreID)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return model.Movie{}, errors.New("movie not found")
+			return model.Movie{}, ErrMovieNotFound
 		}
 		return model.Movie{}, err
 	}
@@ -141,3 +156,23 @@ func (r *movieRepo) IsMovieAvailableInCountry(ctx context.Context, movieID uuid.
 
 	return exists, nil
 }
+
+func (r *movieRepo) GetCertification(ctx context.Context, movieID uuid.UUID, countryCode string) (*model.Certification, error) {
+	query := `
+		SELECT c.country_code, c.code, c.min_age
+		FROM movie_certifications mc
+		INNER JOIN certifications c ON c.country_code = mc.country_code AND c.code = mc.certification
+		WHERE mc.movie_id = $1 AND mc.country_code = $2
+	`
+	var cert model.Certification
+	err := r.db.QueryRow(ctx, query, movieID, strings.ToUpper(countryCode)).Scan(&cert.CountryCode, &cert.Code, &cert.MinAge)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			// Unrated in this country
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &cert, nil
+}
@@ -0,0 +1,130 @@
+package repository_test
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/winfr1th/mock-interview/internal/auth"
+	"github.com/winfr1th/mock-interview/internal/database"
+	"github.com/winfr1th/mock-interview/internal/repository"
+	"github.com/winfr1th/mock-interview/internal/repository/repotest"
+	"github.com/winfr1th/mock-interview/migrations"
+)
+
+// testDatabaseURL names the Postgres database the conformance suite runs against; without it the
+// suite is skipped. Each test migrates a schema of its own and drops it afterwards.
+const testDatabaseURL = "TEST_DATABASE_URL"
+
+func TestPostgresConformance(t *testing.T) {
+	url := os.Getenv(testDatabaseURL)
+	if url == "" {
+		t.Skip(testDatabaseURL + " is not set")
+	}
+	repotest.Run(t, func(t *testing.T) repotest.Backend {
+		db := migratedSchema(t, url)
+		return repotest.Backend{
+			Users:         repository.NewUserRepository(db),
+			Profiles:      repository.NewProfileRepository(db),
+			Identities:    repository.NewIdentityRepository(db),
+			RefreshTokens: repository.NewRefreshTokenRepository(db),
+			Genres:        repository.NewGenreRepository(db),
+			Movies:        repository.NewMovieRepository(db),
+			SavedMovies:   repository.NewSaveMoviesRepository(db),
+			Audit:         repository.NewAuditRepository(db),
+			Transactor:    db,
+			Catalog:       repotest.NewSQLCatalog(db),
+		}
+	})
+}
+
+// migratedSchema creates a schema with every migration applied and returns a DB whose
+// connections use it
+func migratedSchema(t *testing.T, url string) *database.DB {
+	t.Helper()
+	pool := newSchema(t, url)
+	migrate(t, pool, 1, migrations.Latest())
+	return database.NewDB(pool, database.DefaultRetryPolicy)
+}
+
+// newSchema creates an empty schema and returns a pool whose connections use it
+func newSchema(t *testing.T, url string) *pgxpool.Pool {
+	t.Helper()
+	ctx := t.Context()
+	schema := "test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+
+	admin, err := pgxpool.New(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(admin.Close)
+	if _, err := admin.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec(context.Background(), "DROP SCHEMA "+schema+" CASCADE"); err != nil {
+			t.Errorf("drop schema %s: %v", schema, err)
+		}
+	})
+
+	config, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.ConnConfig.RuntimeParams["search_path"] = schema
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+// migrate applies the migrations from one version up to and including another
+func migrate(t *testing.T, pool *pgxpool.Pool, from, to int) {
+	t.Helper()
+	all, err := migrations.All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, migration := range all {
+		if migration.Version < from || migration.Version > to {
+			continue
+		}
+		// Without arguments the file's statements run together, as psql would run them
+		if _, err := pool.Exec(t.Context(), migration.SQL); err != nil {
+			t.Fatalf("migration %s: %v", migration.Name, err)
+		}
+	}
+}
+
+func TestPostgresLegacyKeysSurviveMigration013Twice(t *testing.T) {
+	url := os.Getenv(testDatabaseURL)
+	if url == "" {
+		t.Skip(testDatabaseURL + " is not set")
+	}
+	ctx := t.Context()
+	pool := newSchema(t, url)
+	migrate(t, pool, 1, 12)
+	const household, legacyKey = "550e8400-e29b-41d4-a716-446655440001", "550e8400-e29b-41d4-a716-446655440000"
+	if _, err := pool.Exec(ctx, `INSERT INTO households (id, name) VALUES ($1, 'Test')`, household); err != nil {
+		t.Fatal(err)
+	}
+	_, err := pool.Exec(ctx, `INSERT INTO users (id, household_id, name, date_of_birth, api_key_hash)
+		VALUES ($1, $1, 'Test', '1990-01-01', $2)`, household, legacyKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Applying the file again, as rerunning the README's psql steps does, must not hash the key twice
+	migrate(t, pool, 13, 13)
+	migrate(t, pool, 13, 13)
+	users := repository.NewUserRepository(database.NewDB(pool, database.DefaultRetryPolicy))
+	user, err := users.FindUserByAPIKeyHash(ctx, auth.HashAPIKey(legacyKey))
+	if err != nil || user.ID.String() != household {
+		t.Errorf("FindUserByAPIKeyHash = %v, %v; want the legacy key's user", user.ID, err)
+	}
+}
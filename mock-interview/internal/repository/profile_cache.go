@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	model "github.com/winfr1th/mock-interview/internal/models"
+)
+
+// DefaultProfileCacheTTL is how long a cached profile is used before it's read again
+const DefaultProfileCacheTTL = 30 * time.Second
+
+// profileCacheSweepInterval is how many reads pass between sweeps of expired households
+const profileCacheSweepInterval = 1000
+
+// cachedProfiles is a ProfileRepository that keeps the profiles it reads per household, so
+// resolving the active profile of every authenticated request doesn't query the database.
+// Writes through it drop their household's profiles. Writes through another instance, or
+// straight to the database, are seen once the cached profiles expire.
+type cachedProfiles struct {
+	ProfileRepository
+	ttl time.Duration
+	now func() time.Time
+
+	mu         sync.Mutex
+	households map[uuid.UUID]*cachedHousehold
+	// version counts writes, so a read that raced one doesn't cache what it read
+	version uint64
+	reads   int
+}
+
+// cachedHousehold holds the profiles read from one household
+type cachedHousehold struct {
+	profiles  map[uuid.UUID]model.Profile
+	defaultID uuid.UUID
+	expires   time.Time
+}
+
+// NewCachedProfileRepository caches the GetProfile and GetDefaultProfile results of repo for ttl.
+// Writes made inside a transaction drop the cache before the transaction commits, so a read in
+// between can cache the old profile until it expires.
+func NewCachedProfileRepository(repo ProfileRepository, ttl time.Duration) ProfileRepository {
+	return &cachedProfiles{
+		ProfileRepository: repo,
+		ttl:               ttl,
+		now:               time.Now,
+		households:        make(map[uuid.UUID]*cachedHousehold),
+	}
+}
+
+func (c *cachedProfiles) GetProfile(ctx context.Context, householdID, profileID uuid.UUID) (model.Profile, error) {
+	profile, ok, version := c.cached(householdID, profileID)
+	if ok {
+		return profile, nil
+	}
+	profile, err := c.ProfileRepository.GetProfile(ctx, householdID, profileID)
+	if err == nil {
+		c.store(version, profile, false)
+	}
+	return profile, err
+}
+
+func (c *cachedProfiles) GetDefaultProfile(ctx context.Context, householdID uuid.UUID) (model.Profile, error) {
+	profile, ok, version := c.cached(householdID, uuid.Nil)
+	if ok {
+		return profile, nil
+	}
+	profile, err := c.ProfileRepository.GetDefaultProfile(ctx, householdID)
+	if err == nil {
+		c.store(version, profile, true)
+	}
+	return profile, err
+}
+
+func (c *cachedProfiles) CreateProfile(ctx context.Context, profile model.Profile) error {
+	defer c.invalidate(profile.HouseholdID)
+	return c.ProfileRepository.CreateProfile(ctx, profile)
+}
+
+func (c *cachedProfiles) UpdateProfile(ctx context.Context, profile model.Profile) error {
+	defer c.invalidate(profile.HouseholdID)
+	return c.ProfileRepository.UpdateProfile(ctx, profile)
+}
+
+func (c *cachedProfiles) DeleteProfile(ctx context.Context, householdID, profileID uuid.UUID) error {
+	defer c.invalidate(householdID)
+	return c.ProfileRepository.DeleteProfile(ctx, householdID, profileID)
+}
+
+// cached returns a household's profile, or its default profile for uuid.Nil, when it's cached,
+// and the write version to store what's read instead
+func (c *cachedProfiles) cached(householdID, profileID uuid.UUID) (model.Profile, bool, uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	c.reads++
+	if c.reads >= profileCacheSweepInterval {
+		c.reads = 0
+		for id, household := range c.households {
+			if !now.Before(household.expires) {
+				delete(c.households, id)
+			}
+		}
+	}
+
+	household, ok := c.households[householdID]
+	if !ok || !now.Before(household.expires) {
+		return model.Profile{}, false, c.version
+	}
+	if profileID == uuid.Nil {
+		profileID = household.defaultID
+	}
+	profile, ok := household.profiles[profileID]
+	return cloneProfile(profile), ok, c.version
+}
+
+// store caches a profile read at version, unless a write has happened since
+func (c *cachedProfiles) store(version uint64, profile model.Profile, isDefault bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.version != version {
+		return
+	}
+	now := c.now()
+	household, ok := c.households[profile.HouseholdID]
+	if !ok || !now.Before(household.expires) {
+		household = &cachedHousehold{profiles: make(map[uuid.UUID]model.Profile), expires: now.Add(c.ttl)}
+		c.households[profile.HouseholdID] = household
+	}
+	household.profiles[profile.ID] = cloneProfile(profile)
+	if isDefault {
+		household.defaultID = profile.ID
+	}
+}
+
+// invalidate drops a household's profiles after a write
+func (c *cachedProfiles) invalidate(householdID uuid.UUID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.version++
+	delete(c.households, householdID)
+}
+
+// cloneProfile copies a profile, so callers can't change the cached one through MaxAge
+func cloneProfile(profile model.Profile) model.Profile {
+	if profile.MaxAge != nil {
+		maxAge := *profile.MaxAge
+		profile.MaxAge = &maxAge
+	}
+	return profile
+}
@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	model "github.com/winfr1th/mock-interview/internal/models"
+)
+
+// fakeProfiles is a ProfileRepository over a map that counts its reads
+type fakeProfiles struct {
+	ProfileRepository
+	profiles map[uuid.UUID]model.Profile
+	reads    int
+	// duringRead runs in the middle of a read, after the profile has been looked up
+	duringRead func()
+}
+
+func (f *fakeProfiles) GetProfile(ctx context.Context, householdID, profileID uuid.UUID) (model.Profile, error) {
+	f.reads++
+	profile, ok := f.profiles[profileID]
+	if f.duringRead != nil {
+		f.duringRead()
+	}
+	if !ok || profile.HouseholdID != householdID {
+		return model.Profile{}, ErrProfileNotFound
+	}
+	return cloneProfile(profile), nil
+}
+
+func (f *fakeProfiles) GetDefaultProfile(ctx context.Context, householdID uuid.UUID) (model.Profile, error) {
+	f.reads++
+	for _, profile := range f.profiles {
+		if profile.HouseholdID == householdID && profile.IsDefault {
+			return cloneProfile(profile), nil
+		}
+	}
+	return model.Profile{}, ErrProfileNotFound
+}
+
+func (f *fakeProfiles) UpdateProfile(ctx context.Context, profile model.Profile) error {
+	f.profiles[profile.ID] = cloneProfile(profile)
+	return nil
+}
+
+func newCachedFake(t *testing.T) (*cachedProfiles, *fakeProfiles, model.Profile, *time.Time) {
+	t.Helper()
+	maxAge := 12
+	profile := model.Profile{ID: uuid.New(), HouseholdID: uuid.New(), Name: "Kid", MaxAge: &maxAge, IsDefault: true}
+	fake := &fakeProfiles{profiles: map[uuid.UUID]model.Profile{profile.ID: profile}}
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	cache := NewCachedProfileRepository(fake, time.Minute).(*cachedProfiles)
+	cache.now = func() time.Time { return now }
+	return cache, fake, profile, &now
+}
+
+func TestCachedProfilesAreReadOncePerTTL(t *testing.T) {
+	cache, fake, profile, now := newCachedFake(t)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if got, err := cache.GetProfile(ctx, profile.HouseholdID, profile.ID); err != nil || got.Name != "Kid" {
+			t.Fatalf("GetProfile = %+v, %v", got, err)
+		}
+	}
+	if got, err := cache.GetDefaultProfile(ctx, profile.HouseholdID); err != nil || got.ID != profile.ID {
+		t.Fatalf("GetDefaultProfile = %+v, %v", got, err)
+	}
+	if got, err := cache.GetDefaultProfile(ctx, profile.HouseholdID); err != nil || got.ID != profile.ID {
+		t.Fatalf("GetDefaultProfile = %+v, %v", got, err)
+	}
+	if fake.reads != 2 {
+		t.Errorf("%d reads, want one for the profile and one for the default", fake.reads)
+	}
+
+	*now = now.Add(time.Minute)
+	if _, err := cache.GetProfile(ctx, profile.HouseholdID, profile.ID); err != nil {
+		t.Fatal(err)
+	}
+	if fake.reads != 3 {
+		t.Errorf("%d reads, want the expired profile read again", fake.reads)
+	}
+}
+
+func TestCachedProfilesAreDroppedOnWrite(t *testing.T) {
+	cache, fake, profile, _ := newCachedFake(t)
+	ctx := context.Background()
+	if _, err := cache.GetProfile(ctx, profile.HouseholdID, profile.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	profile.PINHash = "hash"
+	if err := cache.UpdateProfile(ctx, profile); err != nil {
+		t.Fatal(err)
+	}
+	got, err := cache.GetProfile(ctx, profile.HouseholdID, profile.ID)
+	if err != nil || !got.HasPIN() {
+		t.Errorf("GetProfile after the update = %+v, %v, want the PIN", got, err)
+	}
+	if fake.reads != 2 {
+		t.Errorf("%d reads, want the updated profile read again", fake.reads)
+	}
+}
+
+func TestCachedProfilesDontKeepReadsThatRacedAWrite(t *testing.T) {
+	cache, fake, profile, _ := newCachedFake(t)
+	ctx := context.Background()
+
+	// The read sees the profile before the PIN is set, and the write lands before it's cached
+	updated := profile
+	updated.PINHash = "hash"
+	fake.duringRead = func() {
+		fake.duringRead = nil
+		if err := cache.UpdateProfile(ctx, updated); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, err := cache.GetProfile(ctx, profile.HouseholdID, profile.ID); err != nil || got.HasPIN() {
+		t.Fatalf("racing GetProfile = %+v, %v, want the profile before the update", got, err)
+	}
+	if got, err := cache.GetProfile(ctx, profile.HouseholdID, profile.ID); err != nil || !got.HasPIN() {
+		t.Errorf("GetProfile = %+v, %v, want the PIN", got, err)
+	}
+}
+
+func TestCachedProfilesArentChangedByCallers(t *testing.T) {
+	cache, _, profile, _ := newCachedFake(t)
+	ctx := context.Background()
+
+	got, err := cache.GetProfile(ctx, profile.HouseholdID, profile.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	*got.MaxAge = 18
+	if got, _ := cache.GetProfile(ctx, profile.HouseholdID, profile.ID); *got.MaxAge != 12 {
+		t.Errorf("cached max age = %d, want 12", *got.MaxAge)
+	}
+}
+
+func TestCachedProfilesDontCacheErrors(t *testing.T) {
+	cache, fake, profile, _ := newCachedFake(t)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := cache.GetProfile(ctx, profile.HouseholdID, uuid.New()); !errors.Is(err, ErrProfileNotFound) {
+			t.Fatalf("GetProfile of a missing profile = %v", err)
+		}
+	}
+	if fake.reads != 2 {
+		t.Errorf("%d reads, want every miss read", fake.reads)
+	}
+}
@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	model "github.com/winfr1th/mock-interview/internal/models"
+)
+
+type ProfileRepository interface {
+	CreateProfile(ctx context.Context, profile model.Profile) error
+	GetProfile(ctx context.Context, householdID, profileID uuid.UUID) (model.Profile, error)
+	GetDefaultProfile(ctx context.Context, householdID uuid.UUID) (model.Profile, error)
+	ListProfiles(ctx context.Context, householdID uuid.UUID) ([]model.Profile, error)
+	// UpdateProfile saves a profile's name, content limit and PIN
+	UpdateProfile(ctx context.Context, profile model.Profile) error
+	DeleteProfile(ctx context.Context, householdID, profileID uuid.UUID) error
+}
+
+type profileRepo struct {
+	db Executor
+}
+
+func NewProfileRepository(db Executor) ProfileRepository {
+	return &profileRepo{
+		db: db,
+	}
+}
+
+func (r *profileRepo) CreateProfile(ctx context.Context, profile model.Profile) error {
+	query := `INSERT INTO profiles (id, household_id, name, max_age, pin_hash, is_default) VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err := r.db.Exec(ctx, query, profile.ID, profile.HouseholdID, profile.Name, profile.MaxAge,
+		nullableString(profile.PINHash), profile.IsDefault)
+	if err != nil {
+		return mapConstraintError(err)
+	}
+	return nil
+}
+
+func (r *profileRepo) GetProfile(ctx context.Context, householdID, profileID uuid.UUID) (model.Profile, error) {
+	query := `
+		SELECT id, household_id, name, max_age, COALESCE(pin_hash, ''), is_default
+		FROM profiles
+		WHERE household_id = $1 AND id = $2
+	`
+	return r.scanProfile(r.db.QueryRow(ctx, query, householdID, profileID))
+}
+
+func (r *profileRepo) GetDefaultProfile(ctx context.Context, householdID uuid.UUID) (model.Profile, error) {
+	query := `
+		SELECT id, household_id, name, max_age, COALESCE(pin_hash, ''), is_default
+		FROM profiles
+		WHERE household_id = $1 AND is_default
+	`
+	return r.scanProfile(r.db.QueryRow(ctx, query, householdID))
+}
+
+func (r *profileRepo) ListProfiles(ctx context.Context, householdID uuid.UUID) ([]model.Profile, error) {
+	query := `
+		SELECT id, household_id, name, max_age, COALESCE(pin_hash, ''), is_default
+		FROM profiles
+		WHERE household_id = $1
+		ORDER BY is_default DESC, name ASC
+	`
+
+	rows, err := r.db.Query(ctx, query, householdID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var profiles []model.Profile
+	for rows.Next() {
+		var profile model.Profile
+		if err := rows.Scan(&profile.ID, &profile.HouseholdID, &profile.Name, &profile.MaxAge,
+			&profile.PINHash, &profile.IsDefault); err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, profile)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return profiles, nil
+}
+
+func (r *profileRepo) UpdateProfile(ctx context.Context, profile model.Profile) error {
+	query := `UPDATE profiles SET name = $3, max_age = $4, pin_hash = $5 WHERE household_id = $1 AND id = $2`
+	result, err := r.db.Exec(ctx, query, profile.HouseholdID, profile.ID, profile.Name, profile.MaxAge,
+		nullableString(profile.PINHash))
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrProfileNotFound
+	}
+
+	return nil
+}
+
+// DeleteProfile removes a non-default profile and, through the cascade, its saved movies
+func (r *profileRepo) DeleteProfile(ctx context.Context, householdID, profileID uuid.UUID) error {
+	query := `DELETE FROM profiles WHERE household_id = $1 AND id = $2 AND NOT is_default`
+	result, err := r.db.Exec(ctx, query, householdID, profileID)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrProfileNotFound
+	}
+
+	return nil
+}
+
+func (r *profileRepo) scanProfile(row pgx.Row) (model.Profile, error) {
+	var profile model.Profile
+	err := row.Scan(&profile.ID, &profile.HouseholdID, &profile.Name, &profile.MaxAge,
+		&profile.PINHash, &profile.IsDefault)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return model.Profile{}, ErrProfileNotFound
+		}
+		return model.Profile{}, err
+	}
+
+	return profile, nil
+}
+
+// nullableString stores empty strings as NULL
+func nullableString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
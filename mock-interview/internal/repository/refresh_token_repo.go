@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	model "github.com/winfr1th/mock-interview/internal/models"
+)
+
+type RefreshTokenRepository interface {
+	CreateRefreshToken(ctx context.Context, token model.RefreshToken) error
+	// UseRefreshToken marks a valid token as used and returns it. A token that was already used is
+	// returned with ErrRefreshTokenReused so the caller can revoke its family.
+	UseRefreshToken(ctx context.Context, tokenHash string) (model.RefreshToken, error)
+	RevokeFamily(ctx context.Context, familyID uuid.UUID) error
+	// RevokeAPIKeyTokens revokes every session exchanged from an API key
+	RevokeAPIKeyTokens(ctx context.Context, apiKeyID string) error
+}
+
+type refreshTokenRepo struct {
+	db Executor
+}
+
+func NewRefreshTokenRepository(db Executor) RefreshTokenRepository {
+	return &refreshTokenRepo{
+		db: db,
+	}
+}
+
+const refreshTokenColumns = `id, user_id, family_id, token_hash, auth_method, COALESCE(api_key_id, ''),
+	created_at, expires_at, used_at, revoked_at`
+
+func (r *refreshTokenRepo) CreateRefreshToken(ctx context.Context, token model.RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (id, user_id, family_id, token_hash, auth_method, api_key_id, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	_, err := r.db.Exec(ctx, query, token.ID, token.UserID, token.FamilyID, token.TokenHash,
+		token.AuthMethod, nullableString(token.APIKeyID), token.ExpiresAt)
+	return mapConstraintError(err)
+}
+
+func (r *refreshTokenRepo) UseRefreshToken(ctx context.Context, tokenHash string) (model.RefreshToken, error) {
+	// Claim the token atomically so two concurrent refreshes can't both succeed
+	now := r.db.Dialect().now()
+	query := `
+		UPDATE refresh_tokens SET used_at = ` + now + `
+		WHERE token_hash = $1 AND used_at IS NULL AND revoked_at IS NULL AND expires_at > ` + now + `
+		RETURNING ` + refreshTokenColumns
+	token, err := scanRefreshToken(r.db.QueryRow(ctx, query, tokenHash))
+	if err == nil {
+		return token, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return model.RefreshToken{}, err
+	}
+
+	// Work out why it couldn't be used
+	query = `SELECT ` + refreshTokenColumns + ` FROM refresh_tokens WHERE token_hash = $1`
+	token, err = scanRefreshToken(r.db.QueryRow(ctx, query, tokenHash))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return model.RefreshToken{}, ErrRefreshTokenNotFound
+		}
+		return model.RefreshToken{}, err
+	}
+	if token.UsedAt != nil {
+		return token, ErrRefreshTokenReused
+	}
+	return model.RefreshToken{}, ErrRefreshTokenNotFound
+}
+
+func (r *refreshTokenRepo) RevokeFamily(ctx context.Context, familyID uuid.UUID) error {
+	query := `UPDATE refresh_tokens SET revoked_at = ` + r.db.Dialect().now() + ` WHERE family_id = $1 AND revoked_at IS NULL`
+	_, err := r.db.Exec(ctx, query, familyID)
+	return err
+}
+
+func (r *refreshTokenRepo) RevokeAPIKeyTokens(ctx context.Context, apiKeyID string) error {
+	query := `UPDATE refresh_tokens SET revoked_at = ` + r.db.Dialect().now() + ` WHERE api_key_id = $1 AND revoked_at IS NULL`
+	_, err := r.db.Exec(ctx, query, apiKeyID)
+	return err
+}
+
+func scanRefreshToken(row pgx.Row) (model.RefreshToken, error) {
+	var token model.RefreshToken
+	err := row.Scan(&token.ID, &token.UserID, &token.FamilyID, &token.TokenHash, &token.AuthMethod,
+		&token.APIKeyID, &token.CreatedAt, &token.ExpiresAt, &token.UsedAt, &token.RevokedAt)
+	return token, err
+}
@@ -0,0 +1,63 @@
+package repotest
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	model "github.com/winfr1th/mock-interview/internal/models"
+	"github.com/winfr1th/mock-interview/internal/repository"
+)
+
+// sqlCatalog writes the catalog tables the SQL repositories only read
+type sqlCatalog struct {
+	db repository.Executor
+}
+
+// NewSQLCatalog returns the Catalog of the database db runs statements on. Its statements are
+// the same for every dialect.
+func NewSQLCatalog(db repository.Executor) Catalog {
+	return sqlCatalog{db: db}
+}
+
+func (c sqlCatalog) AddCountry(ctx context.Context, country model.Country) error {
+	_, err := c.db.Exec(ctx, `INSERT INTO countries (code, name) VALUES ($1, $2)`, country.Code, country.Name)
+	return err
+}
+
+func (c sqlCatalog) AddGenre(ctx context.Context, genre model.Genre) error {
+	_, err := c.db.Exec(ctx, `INSERT INTO genres (id, name) VALUES ($1, $2)`, genre.ID, genre.Name)
+	return err
+}
+
+func (c sqlCatalog) AddMovie(ctx context.Context, movie model.Movie, countries ...string) error {
+	return c.db.WithTx(ctx, func(ctx context.Context) error {
+		_, err := c.db.Exec(ctx, `INSERT INTO movies (id, title, year, genre_id) VALUES ($1, $2, $3, $4)`,
+			movie.ID, movie.Title, movie.Year, movie.GenreID)
+		if err != nil {
+			return err
+		}
+		for _, country := range countries {
+			_, err := c.db.Exec(ctx, `INSERT INTO movie_availability (movie_id, country_code) VALUES ($1, $2)`, movie.ID, country)
+			if err != nil {
+				return fmt.Errorf("available in %s: %w", country, err)
+			}
+		}
+		return nil
+	})
+}
+
+func (c sqlCatalog) Certify(ctx context.Context, movieID uuid.UUID, cert model.Certification) error {
+	return c.db.WithTx(ctx, func(ctx context.Context) error {
+		_, err := c.db.Exec(ctx, `
+			INSERT INTO certifications (country_code, code, min_age) VALUES ($1, $2, $3)
+			ON CONFLICT (country_code, code) DO UPDATE SET min_age = EXCLUDED.min_age
+		`, cert.CountryCode, cert.Code, cert.MinAge)
+		if err != nil {
+			return err
+		}
+		_, err = c.db.Exec(ctx, `INSERT INTO movie_certifications (movie_id, country_code, certification) VALUES ($1, $2, $3)`,
+			movieID, cert.CountryCode, cert.Code)
+		return err
+	})
+}
@@ -0,0 +1,52 @@
+package repotest
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	model "github.com/winfr1th/mock-interview/internal/models"
+)
+
+// errOf returns the error of a call that also returns a value
+func errOf[T any](_ T, err error) error {
+	return err
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func wantErr(t *testing.T, name string, err, want error) {
+	t.Helper()
+	if !errors.Is(err, want) {
+		t.Errorf("%s: err = %v, want %v", name, err, want)
+	}
+}
+
+func movieTitles(movies []model.Movie) string {
+	titles := make([]string, len(movies))
+	for i, movie := range movies {
+		titles[i] = movie.Title
+	}
+	return strings.Join(titles, ",")
+}
+
+func profileNames(profiles []model.Profile) string {
+	names := make([]string, len(profiles))
+	for i, profile := range profiles {
+		names[i] = profile.Name
+	}
+	return strings.Join(names, ",")
+}
+
+func eventTypes(events []model.AuditEvent) string {
+	types := make([]string, len(events))
+	for i, event := range events {
+		types[i] = event.EventType
+	}
+	return strings.Join(types, ",")
+}
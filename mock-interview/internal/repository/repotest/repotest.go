@@ -0,0 +1,629 @@
+// Package repotest is the conformance suite of the repositories. Every implementation runs it, so
+// they agree on errors, sort orders, pagination, filters and cascades.
+package repotest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	model "github.com/winfr1th/mock-interview/internal/models"
+	"github.com/winfr1th/mock-interview/internal/repository"
+)
+
+// Catalog adds the movie catalog, which the repositories only read
+type Catalog interface {
+	AddCountry(ctx context.Context, country model.Country) error
+	AddGenre(ctx context.Context, genre model.Genre) error
+	// AddMovie adds a movie available in the given countries
+	AddMovie(ctx context.Context, movie model.Movie, countries ...string) error
+	// Certify rates a movie in the certification's country
+	Certify(ctx context.Context, movieID uuid.UUID, cert model.Certification) error
+}
+
+// Backend is one implementation of every repository, sharing empty tables
+type Backend struct {
+	Users         repository.UserRepository
+	Profiles      repository.ProfileRepository
+	Identities    repository.IdentityRepository
+	RefreshTokens repository.RefreshTokenRepository
+	Genres        repository.GenreRepository
+	Movies        repository.MovieRepository
+	SavedMovies   repository.SaveMoviesRepository
+	Audit         repository.AuditRepository
+	Transactor    repository.Transactor
+	Catalog       Catalog
+}
+
+// Run runs the suite. newBackend is called for every test and must return empty tables.
+func Run(t *testing.T, newBackend func(t *testing.T) Backend) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, b Backend)
+	}{
+		{"Users", testUsers},
+		{"APIKeys", testAPIKeys},
+		{"DeleteUserCascades", testDeleteUserCascades},
+		{"Profiles", testProfiles},
+		{"Identities", testIdentities},
+		{"RefreshTokens", testRefreshTokens},
+		{"Genres", testGenres},
+		{"Movies", testMovies},
+		{"SavedMovies", testSavedMovies},
+		{"Audit", testAudit},
+		{"UnitOfWork", testUnitOfWork},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newBackend(t))
+		})
+	}
+}
+
+// catalog is the movies the tests list: four movies in two genres, rated in the US and GB
+type catalog struct {
+	action, drama                   model.Genre
+	matrix, inception, up, parasite model.Movie
+}
+
+func seedCatalog(t *testing.T, b Backend) catalog {
+	t.Helper()
+	ctx := t.Context()
+	c := catalog{
+		action: model.Genre{ID: uuid.New(), Name: "Action"},
+		drama:  model.Genre{ID: uuid.New(), Name: "Drama"},
+	}
+	c.matrix = model.Movie{ID: uuid.New(), Title: "The Matrix", Year: 1999, GenreID: c.action.ID}
+	c.inception = model.Movie{ID: uuid.New(), Title: "Inception", Year: 2010, GenreID: c.action.ID}
+	c.up = model.Movie{ID: uuid.New(), Title: "Up", Year: 2009, GenreID: c.drama.ID}
+	c.parasite = model.Movie{ID: uuid.New(), Title: "Parasite", Year: 2019, GenreID: c.drama.ID}
+
+	must(t, b.Catalog.AddCountry(ctx, model.Country{Code: "US", Name: "United States"}))
+	must(t, b.Catalog.AddCountry(ctx, model.Country{Code: "GB", Name: "United Kingdom"}))
+	must(t, b.Catalog.AddGenre(ctx, c.drama))
+	must(t, b.Catalog.AddGenre(ctx, c.action))
+	must(t, b.Catalog.AddMovie(ctx, c.matrix, "US", "GB"))
+	must(t, b.Catalog.AddMovie(ctx, c.inception, "US"))
+	must(t, b.Catalog.AddMovie(ctx, c.up, "US", "GB"))
+	must(t, b.Catalog.AddMovie(ctx, c.parasite, "GB"))
+	// The Matrix is for adults in the US only; Parasite for 15 and up in GB
+	must(t, b.Catalog.Certify(ctx, c.matrix.ID, model.Certification{CountryCode: "US", Code: "R", MinAge: 17}))
+	must(t, b.Catalog.Certify(ctx, c.matrix.ID, model.Certification{CountryCode: "GB", Code: "12", MinAge: 12}))
+	must(t, b.Catalog.Certify(ctx, c.parasite.ID, model.Certification{CountryCode: "GB", Code: "15", MinAge: 15}))
+	return c
+}
+
+// newUser creates a user with its household and default profile
+func newUser(t *testing.T, b Backend, name string) model.User {
+	t.Helper()
+	dateOfBirth, err := model.ParseDate("1990-05-17")
+	must(t, err)
+	user := model.User{
+		ID:          uuid.New(),
+		HouseholdID: uuid.New(),
+		Name:        name,
+		DateOfBirth: dateOfBirth,
+		APIKeyID:    "mvk_test_" + uuid.NewString()[:8],
+		APIKeyHash:  "hash-" + name,
+	}
+	must(t, b.Users.CreateUser(t.Context(), user))
+	return user
+}
+
+func testUsers(t *testing.T, b Backend) {
+	ctx := t.Context()
+	seedCatalog(t, b)
+	user := newUser(t, b, "Ada")
+
+	found, err := b.Users.FindUserByID(ctx, user.ID.String())
+	must(t, err)
+	if found.Name != "Ada" || found.HouseholdID != user.HouseholdID || found.DateOfBirth.String() != "1990-05-17" ||
+		found.HomeCountry != nil || found.IsAdmin || found.Plan != "" {
+		t.Errorf("FindUserByID = %+v, want the created user", found)
+	}
+	pro := model.User{ID: uuid.New(), HouseholdID: uuid.New(), Name: "Pro", Plan: "pro"}
+	must(t, b.Users.CreateUser(ctx, pro))
+	if found, err := b.Users.FindUserByID(ctx, pro.ID.String()); err != nil || found.Plan != "pro" {
+		t.Errorf("FindUserByID = %+v, %v, want the user's plan", found, err)
+	}
+	profile, err := b.Profiles.GetDefaultProfile(ctx, user.HouseholdID)
+	must(t, err)
+	if profile.ID != user.ID || profile.Name != "Ada" || !profile.IsDefault || profile.MaxAge != nil {
+		t.Errorf("default profile = %+v, want one reusing the user's ID and name", profile)
+	}
+
+	wantErr(t, "unknown user", errOf(b.Users.FindUserByID(ctx, uuid.NewString())), repository.ErrUserNotFound)
+	wantErr(t, "malformed ID", errOf(b.Users.FindUserByID(ctx, "not-a-uuid")), repository.ErrInvalidUserID)
+	duplicate := user
+	duplicate.HouseholdID = uuid.New()
+	duplicate.APIKeyID = ""
+	wantErr(t, "duplicate ID", b.Users.CreateUser(ctx, duplicate), repository.ErrAlreadyExists)
+
+	// A failed create leaves nothing behind
+	unknownCountry := "ZZ"
+	orphan := model.User{ID: uuid.New(), HouseholdID: uuid.New(), Name: "Orphan", HomeCountry: &unknownCountry}
+	wantErr(t, "unknown home country", b.Users.CreateUser(ctx, orphan), repository.ErrInvalidReference)
+	wantErr(t, "failed create", errOf(b.Profiles.GetDefaultProfile(ctx, orphan.HouseholdID)), repository.ErrProfileNotFound)
+
+	country := "GB"
+	update := found
+	update.Name = "Ada L."
+	update.HomeCountry = &country
+	must(t, b.Users.UpdateUser(ctx, update))
+	found, err = b.Users.FindUserByID(ctx, user.ID.String())
+	must(t, err)
+	if found.Name != "Ada L." || found.HomeCountry == nil || *found.HomeCountry != "GB" {
+		t.Errorf("after UpdateUser = %+v", found)
+	}
+	update.HomeCountry = &unknownCountry
+	wantErr(t, "update to unknown country", b.Users.UpdateUser(ctx, update), repository.ErrInvalidReference)
+	update.ID = uuid.New()
+	wantErr(t, "update unknown user", b.Users.UpdateUser(ctx, update), repository.ErrUserNotFound)
+
+	locked, err := b.Users.FindUserByIDForUpdate(ctx, user.ID.String())
+	must(t, err)
+	if locked.ID != user.ID {
+		t.Errorf("FindUserByIDForUpdate = %+v", locked)
+	}
+}
+
+func testAPIKeys(t *testing.T, b Backend) {
+	ctx := t.Context()
+	ada := newUser(t, b, "Ada")
+	grace := newUser(t, b, "Grace")
+
+	found, err := b.Users.FindUserByAPIKeyID(ctx, ada.APIKeyID)
+	must(t, err)
+	if found.ID != ada.ID || found.APIKeyHash != "hash-Ada" {
+		t.Errorf("FindUserByAPIKeyID = %+v, want Ada with her key hash", found)
+	}
+	wantErr(t, "unknown key ID", errOf(b.Users.FindUserByAPIKeyID(ctx, "mvk_test_unknown")), repository.ErrInvalidAPIKey)
+	wantErr(t, "prefixed key as legacy", errOf(b.Users.FindUserByAPIKeyHash(ctx, "hash-Ada")), repository.ErrInvalidAPIKey)
+
+	wantErr(t, "taken key ID", b.Users.UpdateAPIKey(ctx, grace.ID, ada.APIKeyID, "other"), repository.ErrAlreadyExists)
+	wantErr(t, "unknown user", b.Users.UpdateAPIKey(ctx, uuid.New(), "mvk_test_new", "new"), repository.ErrUserNotFound)
+	must(t, b.Users.UpdateAPIKey(ctx, ada.ID, "mvk_test_rotated", "rotated"))
+	wantErr(t, "rotated key ID", errOf(b.Users.FindUserByAPIKeyID(ctx, ada.APIKeyID)), repository.ErrInvalidAPIKey)
+	found, err = b.Users.FindUserByAPIKeyID(ctx, "mvk_test_rotated")
+	must(t, err)
+	if found.ID != ada.ID || found.APIKeyHash != "rotated" {
+		t.Errorf("after UpdateAPIKey = %+v", found)
+	}
+
+	// Legacy keys are stored as their hash, without a key ID
+	legacy := model.User{ID: uuid.New(), HouseholdID: uuid.New(), Name: "Legacy", APIKeyHash: "legacy-hash"}
+	must(t, b.Users.CreateUser(ctx, legacy))
+	found, err = b.Users.FindUserByAPIKeyHash(ctx, legacy.APIKeyHash)
+	must(t, err)
+	if found.ID != legacy.ID || found.APIKeyID != "" {
+		t.Errorf("FindUserByAPIKeyHash = %+v, want the legacy user", found)
+	}
+
+	must(t, b.Users.RevokeAPIKey(ctx, legacy.ID))
+	wantErr(t, "revoked key", errOf(b.Users.FindUserByAPIKeyHash(ctx, legacy.APIKeyHash)), repository.ErrInvalidAPIKey)
+	wantErr(t, "revoke unknown user", b.Users.RevokeAPIKey(ctx, uuid.New()), repository.ErrUserNotFound)
+}
+
+func testDeleteUserCascades(t *testing.T, b Backend) {
+	ctx := t.Context()
+	c := seedCatalog(t, b)
+	ada := newUser(t, b, "Ada")
+	grace := newUser(t, b, "Grace")
+
+	kid := model.Profile{ID: uuid.New(), HouseholdID: ada.HouseholdID, Name: "Kid"}
+	must(t, b.Profiles.CreateProfile(ctx, kid))
+	must(t, b.SavedMovies.SaveMovie(ctx, kid.ID, c.up.ID))
+	must(t, b.SavedMovies.SaveMovie(ctx, grace.ID, c.up.ID))
+	must(t, b.Identities.LinkIdentity(ctx, model.UserIdentity{Issuer: "https://idp", Subject: "ada", UserID: ada.ID}))
+	token := newRefreshToken(ada, time.Hour)
+	must(t, b.RefreshTokens.CreateRefreshToken(ctx, token))
+
+	must(t, b.Users.DeleteUser(ctx, ada.ID.String()))
+	wantErr(t, "deleted user", errOf(b.Users.FindUserByID(ctx, ada.ID.String())), repository.ErrUserNotFound)
+	wantErr(t, "deleted household's profile", errOf(b.Profiles.GetProfile(ctx, ada.HouseholdID, kid.ID)), repository.ErrProfileNotFound)
+	wantErr(t, "deleted user's identity", errOf(b.Identities.FindUserByIdentity(ctx, "https://idp", "ada")), repository.ErrIdentityNotFound)
+	wantErr(t, "deleted user's refresh token", errOf(b.RefreshTokens.UseRefreshToken(ctx, token.TokenHash)), repository.ErrRefreshTokenNotFound)
+	if saved, err := b.SavedMovies.IsMovieSaved(ctx, kid.ID, c.up.ID); err != nil || saved {
+		t.Errorf("saved movie of a deleted profile: saved %v, err %v", saved, err)
+	}
+
+	// Other households are untouched
+	if saved, err := b.SavedMovies.IsMovieSaved(ctx, grace.ID, c.up.ID); err != nil || !saved {
+		t.Errorf("other household's saved movie: saved %v, err %v", saved, err)
+	}
+
+	wantErr(t, "delete again", b.Users.DeleteUser(ctx, ada.ID.String()), repository.ErrUserNotFound)
+	wantErr(t, "malformed ID", b.Users.DeleteUser(ctx, "not-a-uuid"), repository.ErrInvalidUserID)
+}
+
+func testProfiles(t *testing.T, b Backend) {
+	ctx := t.Context()
+	c := seedCatalog(t, b)
+	ada := newUser(t, b, "Ada")
+	grace := newUser(t, b, "Grace")
+
+	maxAge := 12
+	zoe := model.Profile{ID: uuid.New(), HouseholdID: ada.HouseholdID, Name: "Zoe", MaxAge: &maxAge, PINHash: "pin-hash"}
+	ben := model.Profile{ID: uuid.New(), HouseholdID: ada.HouseholdID, Name: "Ben"}
+	must(t, b.Profiles.CreateProfile(ctx, zoe))
+	must(t, b.Profiles.CreateProfile(ctx, ben))
+
+	profiles, err := b.Profiles.ListProfiles(ctx, ada.HouseholdID)
+	must(t, err)
+	if names := profileNames(profiles); names != "Ada,Ben,Zoe" {
+		t.Errorf("ListProfiles = %s, want the default first and the others by name", names)
+	}
+	got, err := b.Profiles.GetProfile(ctx, ada.HouseholdID, zoe.ID)
+	must(t, err)
+	if got.MaxAge == nil || *got.MaxAge != 12 || got.PINHash != "pin-hash" || got.IsDefault {
+		t.Errorf("GetProfile = %+v, want Zoe's limit and PIN", got)
+	}
+
+	wantErr(t, "other household's profile", errOf(b.Profiles.GetProfile(ctx, grace.HouseholdID, zoe.ID)), repository.ErrProfileNotFound)
+	wantErr(t, "duplicate ID", b.Profiles.CreateProfile(ctx, zoe), repository.ErrAlreadyExists)
+	secondDefault := model.Profile{ID: uuid.New(), HouseholdID: ada.HouseholdID, Name: "Second", IsDefault: true}
+	wantErr(t, "second default", b.Profiles.CreateProfile(ctx, secondDefault), repository.ErrAlreadyExists)
+	unknownHousehold := model.Profile{ID: uuid.New(), HouseholdID: uuid.New(), Name: "Nobody"}
+	wantErr(t, "unknown household", b.Profiles.CreateProfile(ctx, unknownHousehold), repository.ErrInvalidReference)
+	wantErr(t, "default household profile", errOf(b.Profiles.GetDefaultProfile(ctx, uuid.New())), repository.ErrProfileNotFound)
+
+	// The default profile can get a limit and a PIN; the ID and the default flag stay
+	adaProfile, err := b.Profiles.GetDefaultProfile(ctx, ada.HouseholdID)
+	must(t, err)
+	adaProfile.Name, adaProfile.MaxAge, adaProfile.PINHash, adaProfile.IsDefault = "Parents", &maxAge, "parents-pin-hash", false
+	must(t, b.Profiles.UpdateProfile(ctx, adaProfile))
+	got, err = b.Profiles.GetDefaultProfile(ctx, ada.HouseholdID)
+	must(t, err)
+	if got.ID != ada.ID || got.Name != "Parents" || got.MaxAge == nil || *got.MaxAge != 12 || got.PINHash != "parents-pin-hash" || !got.IsDefault {
+		t.Errorf("updated default profile = %+v, want Parents with a limit and a PIN, still the default", got)
+	}
+	got.MaxAge, got.PINHash = nil, ""
+	must(t, b.Profiles.UpdateProfile(ctx, got))
+	got, err = b.Profiles.GetDefaultProfile(ctx, ada.HouseholdID)
+	must(t, err)
+	if got.MaxAge != nil || got.HasPIN() {
+		t.Errorf("cleared default profile = %+v, want no limit and no PIN", got)
+	}
+	wantErr(t, "update in another household", b.Profiles.UpdateProfile(ctx, model.Profile{ID: zoe.ID, HouseholdID: grace.HouseholdID, Name: "Zoe"}), repository.ErrProfileNotFound)
+
+	must(t, b.SavedMovies.SaveMovie(ctx, zoe.ID, c.up.ID))
+	wantErr(t, "delete the default profile", b.Profiles.DeleteProfile(ctx, ada.HouseholdID, ada.ID), repository.ErrProfileNotFound)
+	wantErr(t, "delete from another household", b.Profiles.DeleteProfile(ctx, grace.HouseholdID, zoe.ID), repository.ErrProfileNotFound)
+	must(t, b.Profiles.DeleteProfile(ctx, ada.HouseholdID, zoe.ID))
+	wantErr(t, "deleted profile", errOf(b.Profiles.GetProfile(ctx, ada.HouseholdID, zoe.ID)), repository.ErrProfileNotFound)
+	if saved, err := b.SavedMovies.IsMovieSaved(ctx, zoe.ID, c.up.ID); err != nil || saved {
+		t.Errorf("saved movie of a deleted profile: saved %v, err %v", saved, err)
+	}
+}
+
+func testIdentities(t *testing.T, b Backend) {
+	ctx := t.Context()
+	ada := newUser(t, b, "Ada")
+
+	identity := model.UserIdentity{Issuer: "https://idp", Subject: "subject-1", UserID: ada.ID}
+	must(t, b.Identities.LinkIdentity(ctx, identity))
+	found, err := b.Identities.FindUserByIdentity(ctx, "https://idp", "subject-1")
+	must(t, err)
+	if found.ID != ada.ID || found.Name != "Ada" {
+		t.Errorf("FindUserByIdentity = %+v, want Ada", found)
+	}
+
+	wantErr(t, "other issuer", errOf(b.Identities.FindUserByIdentity(ctx, "https://other", "subject-1")), repository.ErrIdentityNotFound)
+	identity.UserID = newUser(t, b, "Grace").ID
+	wantErr(t, "subject linked twice", b.Identities.LinkIdentity(ctx, identity), repository.ErrAlreadyExists)
+	unknownUser := model.UserIdentity{Issuer: "https://idp", Subject: "subject-2", UserID: uuid.New()}
+	wantErr(t, "unknown user", b.Identities.LinkIdentity(ctx, unknownUser), repository.ErrInvalidReference)
+}
+
+func newRefreshToken(user model.User, ttl time.Duration) model.RefreshToken {
+	return model.RefreshToken{
+		ID:         uuid.New(),
+		UserID:     user.ID,
+		FamilyID:   uuid.New(),
+		TokenHash:  uuid.NewString(),
+		AuthMethod: "api_key",
+		APIKeyID:   user.APIKeyID,
+		ExpiresAt:  time.Now().Add(ttl),
+	}
+}
+
+func testRefreshTokens(t *testing.T, b Backend) {
+	ctx := t.Context()
+	ada := newUser(t, b, "Ada")
+
+	token := newRefreshToken(ada, time.Hour)
+	must(t, b.RefreshTokens.CreateRefreshToken(ctx, token))
+	used, err := b.RefreshTokens.UseRefreshToken(ctx, token.TokenHash)
+	must(t, err)
+	if used.ID != token.ID || used.FamilyID != token.FamilyID || used.APIKeyID != ada.APIKeyID ||
+		used.UsedAt == nil || used.RevokedAt != nil || used.CreatedAt.IsZero() {
+		t.Errorf("UseRefreshToken = %+v, want the token marked used", used)
+	}
+	reused, err := b.RefreshTokens.UseRefreshToken(ctx, token.TokenHash)
+	wantErr(t, "reused token", err, repository.ErrRefreshTokenReused)
+	if reused.FamilyID != token.FamilyID {
+		t.Errorf("reused token = %+v, want it returned so its family can be revoked", reused)
+	}
+
+	wantErr(t, "unknown token", errOf(b.RefreshTokens.UseRefreshToken(ctx, "unknown")), repository.ErrRefreshTokenNotFound)
+	wantErr(t, "duplicate hash", b.RefreshTokens.CreateRefreshToken(ctx, model.RefreshToken{
+		ID: uuid.New(), UserID: ada.ID, FamilyID: uuid.New(), TokenHash: token.TokenHash, AuthMethod: "api_key", ExpiresAt: token.ExpiresAt,
+	}), repository.ErrAlreadyExists)
+	orphan := newRefreshToken(ada, time.Hour)
+	orphan.UserID = uuid.New()
+	wantErr(t, "unknown user", b.RefreshTokens.CreateRefreshToken(ctx, orphan), repository.ErrInvalidReference)
+
+	expired := newRefreshToken(ada, -time.Minute)
+	must(t, b.RefreshTokens.CreateRefreshToken(ctx, expired))
+	wantErr(t, "expired token", errOf(b.RefreshTokens.UseRefreshToken(ctx, expired.TokenHash)), repository.ErrRefreshTokenNotFound)
+
+	// Revoking a family revokes every token in it, used or not
+	next := newRefreshToken(ada, time.Hour)
+	next.FamilyID = token.FamilyID
+	must(t, b.RefreshTokens.CreateRefreshToken(ctx, next))
+	must(t, b.RefreshTokens.RevokeFamily(ctx, token.FamilyID))
+	wantErr(t, "revoked family", errOf(b.RefreshTokens.UseRefreshToken(ctx, next.TokenHash)), repository.ErrRefreshTokenNotFound)
+
+	fromKey := newRefreshToken(ada, time.Hour)
+	oidc := newRefreshToken(ada, time.Hour)
+	oidc.AuthMethod, oidc.APIKeyID = "oidc", ""
+	must(t, b.RefreshTokens.CreateRefreshToken(ctx, fromKey))
+	must(t, b.RefreshTokens.CreateRefreshToken(ctx, oidc))
+	must(t, b.RefreshTokens.RevokeAPIKeyTokens(ctx, ada.APIKeyID))
+	wantErr(t, "revoked key's session", errOf(b.RefreshTokens.UseRefreshToken(ctx, fromKey.TokenHash)), repository.ErrRefreshTokenNotFound)
+	if _, err := b.RefreshTokens.UseRefreshToken(ctx, oidc.TokenHash); err != nil {
+		t.Errorf("OIDC session revoked with the API key's: %v", err)
+	}
+}
+
+func testGenres(t *testing.T, b Backend) {
+	ctx := t.Context()
+	for _, name := range []string{"Western", "Comedy", "Horror", "Animation", "Documentary"} {
+		must(t, b.Catalog.AddGenre(ctx, model.Genre{ID: uuid.New(), Name: name}))
+	}
+
+	genres, total, err := b.Genres.ListGenres(ctx, 1, 2)
+	must(t, err)
+	if total != 5 || len(genres) != 2 || genres[0].Name != "Animation" || genres[1].Name != "Comedy" {
+		t.Errorf("first page = %+v of %d, want Animation and Comedy of 5", genres, total)
+	}
+	genres, _, err = b.Genres.ListGenres(ctx, 3, 2)
+	must(t, err)
+	if len(genres) != 1 || genres[0].Name != "Western" {
+		t.Errorf("last page = %+v, want Western", genres)
+	}
+	genres, total, err = b.Genres.ListGenres(ctx, 4, 2)
+	must(t, err)
+	if len(genres) != 0 || total != 5 {
+		t.Errorf("page past the end = %+v of %d, want none of 5", genres, total)
+	}
+}
+
+func testMovies(t *testing.T, b Backend) {
+	ctx := t.Context()
+	c := seedCatalog(t, b)
+	us, lowerGB := "US", "gb"
+	age := func(n int) *int { return &n }
+
+	tests := []struct {
+		name     string
+		country  *string
+		genreID  *uuid.UUID
+		maxAge   *int
+		page     int
+		pageSize int
+		sort     string
+		want     string
+		total    int
+	}{
+		{"newest first by default", nil, nil, nil, 1, 10, "", "Parasite,Inception,Up,The Matrix", 4},
+		{"oldest first", nil, nil, nil, 1, 10, "year", "The Matrix,Up,Inception,Parasite", 4},
+		{"newest first", nil, nil, nil, 1, 10, "-year", "Parasite,Inception,Up,The Matrix", 4},
+		{"second page", nil, nil, nil, 2, 3, "year", "Parasite", 4},
+		{"country", &us, nil, nil, 1, 10, "", "Inception,Up,The Matrix", 3},
+		{"lowercase country", &lowerGB, nil, nil, 1, 10, "", "Parasite,Up,The Matrix", 3},
+		{"genre", nil, &c.drama.ID, nil, 1, 10, "", "Parasite,Up", 2},
+		{"genre and country", &us, &c.action.ID, nil, 1, 10, "year", "The Matrix,Inception", 2},
+		{"age in a country", &us, nil, age(12), 1, 10, "", "Inception,Up", 2},
+		{"age in another country", &lowerGB, nil, age(12), 1, 10, "", "Up,The Matrix", 2},
+		{"age in any country", nil, nil, age(16), 1, 10, "", "Parasite,Inception,Up", 3},
+		{"young viewer", nil, nil, age(0), 1, 10, "", "Inception,Up", 2},
+	}
+	for _, tt := range tests {
+		movies, total, err := b.Movies.ListMovies(ctx, tt.country, tt.genreID, tt.maxAge, tt.page, tt.pageSize, tt.sort)
+		must(t, err)
+		if got := movieTitles(movies); got != tt.want || total != tt.total {
+			t.Errorf("%s: ListMovies = %s of %d, want %s of %d", tt.name, got, total, tt.want, tt.total)
+		}
+	}
+
+	movie, err := b.Movies.GetMovieByID(ctx, c.matrix.ID)
+	must(t, err)
+	if movie != c.matrix {
+		t.Errorf("GetMovieByID = %+v, want %+v", movie, c.matrix)
+	}
+	wantErr(t, "unknown movie", errOf(b.Movies.GetMovieByID(ctx, uuid.New())), repository.ErrMovieNotFound)
+
+	if available, err := b.Movies.IsMovieAvailableInCountry(ctx, c.parasite.ID, "gb"); err != nil || !available {
+		t.Errorf("Parasite in gb: available %v, err %v", available, err)
+	}
+	if available, err := b.Movies.IsMovieAvailableInCountry(ctx, c.parasite.ID, "US"); err != nil || available {
+		t.Errorf("Parasite in US: available %v, err %v", available, err)
+	}
+
+	cert, err := b.Movies.GetCertification(ctx, c.matrix.ID, "us")
+	must(t, err)
+	if cert == nil || *cert != (model.Certification{CountryCode: "US", Code: "R", MinAge: 17}) {
+		t.Errorf("GetCertification = %+v, want R in the US", cert)
+	}
+	cert, err = b.Movies.GetCertification(ctx, c.up.ID, "US")
+	if err != nil || cert != nil {
+		t.Errorf("unrated movie: certification %+v, err %v", cert, err)
+	}
+}
+
+func testSavedMovies(t *testing.T, b Backend) {
+	ctx := t.Context()
+	c := seedCatalog(t, b)
+	ada := newUser(t, b, "Ada")
+	grace := newUser(t, b, "Grace")
+
+	for _, movie := range []model.Movie{c.up, c.matrix, c.parasite, c.inception} {
+		must(t, b.SavedMovies.SaveMovie(ctx, ada.ID, movie.ID))
+		// Saves in separate statements get distinct dates added
+		time.Sleep(2 * time.Millisecond)
+	}
+	must(t, b.SavedMovies.SaveMovie(ctx, grace.ID, c.up.ID))
+
+	wantErr(t, "duplicate save", b.SavedMovies.SaveMovie(ctx, ada.ID, c.up.ID), repository.ErrMovieAlreadySaved)
+	wantErr(t, "unknown movie", b.SavedMovies.SaveMovie(ctx, ada.ID, uuid.New()), repository.ErrMovieNotFound)
+	if saved, err := b.SavedMovies.IsMovieSaved(ctx, ada.ID, c.matrix.ID); err != nil || !saved {
+		t.Errorf("IsMovieSaved: saved %v, err %v", saved, err)
+	}
+
+	age := func(n int) *int { return &n }
+	tests := []struct {
+		name     string
+		country  string
+		maxAge   *int
+		page     int
+		pageSize int
+		sort     string
+		want     string
+		total    int
+	}{
+		{"newest first by default", "US", nil, 1, 10, "", "Inception,The Matrix,Up", 3},
+		{"oldest first", "US", nil, 1, 10, "date_added", "Up,The Matrix,Inception", 3},
+		{"newest first", "us", nil, 1, 10, "-date_added", "Inception,The Matrix,Up", 3},
+		{"second page", "GB", nil, 2, 2, "date_added", "Parasite", 3},
+		{"age in the country", "US", age(12), 1, 10, "date_added", "Up,Inception", 2},
+		{"age in another country", "GB", age(12), 1, 10, "date_added", "Up,The Matrix", 2},
+	}
+	for _, tt := range tests {
+		movies, total, err := b.SavedMovies.ListSavedMovies(ctx, ada.ID, tt.country, tt.maxAge, tt.page, tt.pageSize, tt.sort)
+		must(t, err)
+		if got := movieTitles(movies); got != tt.want || total != tt.total {
+			t.Errorf("%s: ListSavedMovies = %s of %d, want %s of %d", tt.name, got, total, tt.want, tt.total)
+		}
+	}
+
+	must(t, b.SavedMovies.RemoveSavedMovie(ctx, ada.ID, c.up.ID))
+	wantErr(t, "remove again", b.SavedMovies.RemoveSavedMovie(ctx, ada.ID, c.up.ID), repository.ErrMovieNotSaved)
+	if saved, err := b.SavedMovies.IsMovieSaved(ctx, grace.ID, c.up.ID); err != nil || !saved {
+		t.Errorf("other profile's save removed: saved %v, err %v", saved, err)
+	}
+}
+
+func testAudit(t *testing.T, b Backend) {
+	ctx := t.Context()
+	actor := uuid.New()
+	start := time.Now().UTC().Truncate(time.Microsecond)
+	events := []model.AuditEvent{
+		{OccurredAt: start, EventType: "user.registered", ActorID: &actor, TargetType: "user", TargetID: actor.String()},
+		{OccurredAt: start.Add(time.Second), EventType: "auth.failed", IP: "192.0.2.1", Metadata: map[string]string{"reason": "invalid_key"}},
+		{OccurredAt: start.Add(2 * time.Second), EventType: "api_key.rotated", ActorID: &actor, TargetType: "user", TargetID: actor.String(), RequestID: "req-1"},
+	}
+	var appended []model.AuditEvent
+	for _, event := range events {
+		stored, err := b.Audit.AppendEvent(ctx, event)
+		must(t, err)
+		appended = append(appended, stored)
+	}
+	if appended[0].PrevHash != model.GenesisAuditHash || appended[1].PrevHash != appended[0].Hash ||
+		appended[2].PrevHash != appended[1].Hash || appended[0].ID >= appended[1].ID || appended[1].ID >= appended[2].ID {
+		t.Errorf("appended events aren't chained in order: %+v", appended)
+	}
+	if appended[0].Metadata == nil {
+		t.Error("nil metadata should be stored as an empty object")
+	}
+
+	listed, err := b.Audit.ListEvents(ctx, model.AuditFilter{}, 0, 10)
+	must(t, err)
+	if eventTypes(listed) != "api_key.rotated,auth.failed,user.registered" {
+		t.Errorf("ListEvents = %s, want newest first", eventTypes(listed))
+	}
+	if listed[1].Metadata["reason"] != "invalid_key" || listed[1].IP != "192.0.2.1" || listed[0].RequestID != "req-1" ||
+		!listed[2].OccurredAt.Equal(start) {
+		t.Errorf("listed events lost fields: %+v", listed)
+	}
+
+	until := start.Add(2 * time.Second)
+	since := start.Add(time.Second)
+	tests := []struct {
+		name   string
+		filter model.AuditFilter
+		cursor int64
+		limit  int
+		want   string
+	}{
+		{"limit", model.AuditFilter{}, 0, 2, "api_key.rotated,auth.failed"},
+		{"cursor", model.AuditFilter{}, appended[2].ID, 10, "auth.failed,user.registered"},
+		{"event type", model.AuditFilter{EventType: "auth.failed"}, 0, 10, "auth.failed"},
+		{"actor", model.AuditFilter{ActorID: &actor}, 0, 10, "api_key.rotated,user.registered"},
+		{"target", model.AuditFilter{TargetID: actor.String()}, appended[2].ID, 10, "user.registered"},
+		{"since is inclusive, until exclusive", model.AuditFilter{Since: &since, Until: &until}, 0, 10, "auth.failed"},
+	}
+	for _, tt := range tests {
+		listed, err := b.Audit.ListEvents(ctx, tt.filter, tt.cursor, tt.limit)
+		must(t, err)
+		if got := eventTypes(listed); got != tt.want {
+			t.Errorf("%s: ListEvents = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	verification, err := b.Audit.VerifyChain(ctx)
+	must(t, err)
+	if !verification.Valid || verification.Checked != 3 || verification.FirstInvalidID != nil {
+		t.Errorf("VerifyChain = %+v, want 3 valid events", verification)
+	}
+}
+
+func testUnitOfWork(t *testing.T, b Backend) {
+	ctx := t.Context()
+	c := seedCatalog(t, b)
+	ada := newUser(t, b, "Ada")
+
+	// A failed unit of work undoes everything it did, in every repository
+	errAbort := errors.New("abort")
+	grace := model.User{ID: uuid.New(), HouseholdID: uuid.New(), Name: "Grace"}
+	err := b.Transactor.WithTx(ctx, func(ctx context.Context) error {
+		must(t, b.Users.CreateUser(ctx, grace))
+		must(t, b.SavedMovies.SaveMovie(ctx, ada.ID, c.up.ID))
+		// Writes are visible inside the unit of work
+		if _, err := b.Users.FindUserByID(ctx, grace.ID.String()); err != nil {
+			t.Errorf("user created in the unit of work: %v", err)
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Errorf("WithTx = %v, want fn's error", err)
+	}
+	wantErr(t, "user of a failed unit of work", errOf(b.Users.FindUserByID(ctx, grace.ID.String())), repository.ErrUserNotFound)
+	if saved, err := b.SavedMovies.IsMovieSaved(ctx, ada.ID, c.up.ID); err != nil || saved {
+		t.Errorf("save of a failed unit of work: saved %v, err %v", saved, err)
+	}
+
+	// A failed statement fails the unit of work it's in
+	err = b.Transactor.WithTx(ctx, func(ctx context.Context) error {
+		must(t, b.SavedMovies.SaveMovie(ctx, ada.ID, c.up.ID))
+		return b.Identities.LinkIdentity(ctx, model.UserIdentity{Issuer: "https://idp", Subject: "x", UserID: uuid.New()})
+	})
+	wantErr(t, "unit of work with a failed statement", err, repository.ErrInvalidReference)
+	if saved, err := b.SavedMovies.IsMovieSaved(ctx, ada.ID, c.up.ID); err != nil || saved {
+		t.Errorf("save before a failed statement: saved %v, err %v", saved, err)
+	}
+
+	// Nested units of work join the outer one and commit with it
+	err = b.Transactor.WithTx(ctx, func(ctx context.Context) error {
+		must(t, b.Users.CreateUser(ctx, grace))
+		return b.Transactor.WithTx(ctx, func(ctx context.Context) error {
+			return b.SavedMovies.SaveMovie(ctx, grace.ID, c.up.ID)
+		})
+	})
+	must(t, err)
+	if saved, err := b.SavedMovies.IsMovieSaved(ctx, grace.ID, c.up.ID); err != nil || !saved {
+		t.Errorf("save of a committed unit of work: saved %v, err %v", saved, err)
+	}
+}
@@ -7,28 +7,28 @@ import (
 	"strings"
 
 	"github.com/google/uuid"
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5"
 	model "github.com/winfr1th/mock-interview/internal/models"
 )
 
 type SaveMoviesRepository interface {
-	ListSavedMovies(ctx context.Context, userID uuid.UUID, countryCode string, page, pageSize int, sortBy string) ([]model.Movie, int, error)
-	SaveMovie(ctx context.Context, userID, movieID uuid.UUID) error
-	RemoveSavedMovie(ctx context.Context, userID, movieID uuid.UUID) error
-	IsMovieSaved(ctx context.Context, userID, movieID uuid.UUID) (bool, error)
+	ListSavedMovies(ctx context.Context, profileID uuid.UUID, countryCode string, maxAge *int, page, pageSize int, sortBy string) ([]model.Movie, int, error)
+	SaveMovie(ctx context.Context, profileID, movieID uuid.UUID) error
+	RemoveSavedMovie(ctx context.Context, profileID, movieID uuid.UUID) error
+	IsMovieSaved(ctx context.Context, profileID, movieID uuid.UUID) (bool, error)
 }
 
 type saveMoviesRepo struct {
-	db *pgxpool.Pool
+	db Executor
 }
 
-func NewSaveMoviesRepository(db *pgxpool.Pool) SaveMoviesRepository {
+func NewSaveMoviesRepository(db Executor) SaveMoviesRepository {
 	return &saveMoviesRepo{
 		db: db,
 	}
 }
 
-func (r *saveMoviesRepo) ListSavedMovies(ctx context.Context, userID uuid.UUID, countryCode string, page, pageSize int, sortBy string) ([]model.Movie, int, error) {
+func (r *saveMoviesRepo) ListSavedMovies(ctx context.Context, profileID uuid.UUID, countryCode string, maxAge *int, page, pageSize int, sortBy string) ([]model.Movie, int, error) {
 	// Validate and set sort order
 	var sortClause string
 	switch sortBy {
@@ -40,9 +40,18 @@ func (r *saveMoviesRepo) ListSavedMovies(ctx context.Context, userID uuid.UUID,
 		sortClause = "ORDER BY sm.date_added DESC" // Default: -date_added (newest first)
 	}
 
-	// Build WHERE clause - filter by user_id and country
-	whereClause := "WHERE sm.user_id = $1 AND ma.country_code = $2"
-	args := []interface{}{userID, strings.ToUpper(countryCode)}
+	// Build WHERE clause - filter by profile_id and country
+	whereClause := "WHERE sm.profile_id = $1 AND ma.country_code = $2"
+	args := []interface{}{profileID, strings.ToUpper(countryCode)}
+
+	// Filter by viewer age: hide movies certified above maxAge in the country
+	if maxAge != nil {
+		args = append(args, *maxAge)
+		whereClause += fmt.Sprintf(` AND NOT EXISTS (
+			SELECT 1 FROM movie_certifications mc
+			INNER JOIN certifications c ON c.country_code = mc.country_code AND c.code = mc.certification
+			WHERE mc.movie_id = sm.movie_id AND mc.country_code = ma.country_code AND c.min_age > $%d)`, len(args))
+	}
 
 	// Get total count
 	countQuery := fmt.Sprintf(`
@@ -61,16 +70,17 @@ func (r *saveMoviesRepo) ListSavedMovies(ctx context.Context, userID uuid.UUID,
 	// Calculate offset
 	offset := (page - 1) * pageSize
 
-	// Build main query - only return movies available in the specified country
+	// Build main query - only return movies available in the specified country. A movie is
+	// available in a country at most once, so each saved movie is one row.
 	query := fmt.Sprintf(`
-		SELECT DISTINCT m.id, m.title, m.year, m.genre_id
+		SELECT m.id, m.title, m.year, m.genre_id
 		FROM save_movies sm
 		INNER JOIN movies m ON sm.movie_id = m.id
 		INNER JOIN movie_availability ma ON m.id = ma.movie_id
 		%s
 		%s
-		LIMIT $3 OFFSET $4
-	`, whereClause, sortClause)
+		LIMIT $%d OFFSET $%d
+	`, whereClause, sortClause, len(args)+1, len(args)+2)
 
 	args = append(args, pageSize, offset)
 
@@ -96,23 +106,22 @@ func (r *saveMoviesRepo) ListSavedMovies(ctx context.Context, userID uuid.UUID,
 	return movies, total, nil
 }
 
-func (r *saveMoviesRepo) SaveMovie(ctx context.Context, userID, movieID uuid.UUID) error {
-	// Check if movie is already saved (duplicate prevention)
-	isSaved, err := r.IsMovieSaved(ctx, userID, movieID)
-	if err != nil {
-		return err
+// SaveMovie saves the movie for the profile; a movie already saved is ErrMovieAlreadySaved
+func (r *saveMoviesRepo) SaveMovie(ctx context.Context, profileID, movieID uuid.UUID) error {
+	query := `
+		INSERT INTO save_movies (profile_id, movie_id, date_added) VALUES ($1, $2, ` + r.db.Dialect().now() + `)
+		ON CONFLICT (profile_id, movie_id) DO NOTHING
+		RETURNING movie_id
+	`
+	var saved uuid.UUID
+	err := r.db.QueryRow(ctx, query, profileID, movieID).Scan(&saved)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrMovieAlreadySaved
 	}
-	if isSaved {
-		return errors.New("movie already saved")
-	}
-
-	// Insert the saved movie
-	query := `INSERT INTO save_movies (user_id, movie_id, date_added) VALUES ($1, $2, CURRENT_TIMESTAMP)`
-	_, err = r.db.Exec(ctx, query, userID, movieID)
 	if err != nil {
-		// Check for unique constraint violation (additional safety)
-		if strings.Contains(err.Error(), "duplicate") || strings.Contains(err.Error(), "unique") {
-			return errors.New("movie already saved")
+		err = mapConstraintError(err)
+		if errors.Is(err, ErrInvalidReference) {
+			return ErrMovieNotFound
 		}
 		return err
 	}
@@ -120,24 +129,24 @@ func (r *saveMoviesRepo) SaveMovie(ctx context.Context, userID, movieID uuid.UUI
 	return nil
 }
 
-func (r *saveMoviesRepo) RemoveSavedMovie(ctx context.Context, userID, movieID uuid.UUID) error {
-	query := `DELETE FROM save_movies WHERE user_id = $1 AND movie_id = $2`
-	result, err := r.db.Exec(ctx, query, userID, movieID)
+func (r *saveMoviesRepo) RemoveSavedMovie(ctx context.Context, profileID, movieID uuid.UUID) error {
+	query := `DELETE FROM save_movies WHERE profile_id = $1 AND movie_id = $2`
+	result, err := r.db.Exec(ctx, query, profileID, movieID)
 	if err != nil {
 		return err
 	}
 
 	if result.RowsAffected() == 0 {
-		return errors.New("movie not saved")
+		return ErrMovieNotSaved
 	}
 
 	return nil
 }
 
-func (r *saveMoviesRepo) IsMovieSaved(ctx context.Context, userID, movieID uuid.UUID) (bool, error) {
-	query := `SELECT EXISTS(SELECT 1 FROM save_movies WHERE user_id = $1 AND movie_id = $2)`
+func (r *saveMoviesRepo) IsMovieSaved(ctx context.Context, profileID, movieID uuid.UUID) (bool, error) {
+	query := `SELECT EXISTS(SELECT 1 FROM save_movies WHERE profile_id = $1 AND movie_id = $2)`
 	var exists bool
-	err := r.db.QueryRow(ctx, query, userID, movieID).Scan(&exists)
+	err := r.db.QueryRow(ctx, query, profileID, movieID).Scan(&exists)
 	if err != nil {
 		return false, err
 	}
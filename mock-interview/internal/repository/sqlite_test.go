@@ -0,0 +1,87 @@
+package repository_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/winfr1th/mock-interview/internal/database/sqlite"
+	model "github.com/winfr1th/mock-interview/internal/models"
+	"github.com/winfr1th/mock-interview/internal/repository"
+	"github.com/winfr1th/mock-interview/internal/repository/repotest"
+)
+
+func TestSQLiteConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Backend {
+		db := migratedSQLite(t)
+		return repotest.Backend{
+			Users:         repository.NewUserRepository(db),
+			Profiles:      repository.NewProfileRepository(db),
+			Identities:    repository.NewIdentityRepository(db),
+			RefreshTokens: repository.NewRefreshTokenRepository(db),
+			Genres:        repository.NewGenreRepository(db),
+			Movies:        repository.NewMovieRepository(db),
+			SavedMovies:   repository.NewSaveMoviesRepository(db),
+			Audit:         repository.NewAuditRepository(db),
+			Transactor:    db,
+			Catalog:       repotest.NewSQLCatalog(db),
+		}
+	})
+}
+
+// migratedSQLite opens an in-memory database with every SQLite migration applied
+func migratedSQLite(t *testing.T) *sqlite.DB {
+	t.Helper()
+	db, err := sqlite.Open(t.Context(), "sqlite::memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestAuditChainVerificationFindsEditedEvents(t *testing.T) {
+	ctx := t.Context()
+	db := migratedSQLite(t)
+	audit := repository.NewAuditRepository(db)
+	start := time.Now().UTC().Truncate(time.Microsecond)
+	// More events than one verification batch, so the chain is followed across batches
+	for i := range 1001 {
+		event := model.AuditEvent{OccurredAt: start.Add(time.Duration(i) * time.Millisecond), EventType: "auth.failed",
+			Metadata: map[string]string{"attempts": strconv.Itoa(i)}}
+		if _, err := audit.AppendEvent(ctx, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	verification, err := audit.VerifyChain(ctx)
+	if err != nil || !verification.Valid || verification.Checked != 1001 {
+		t.Fatalf("VerifyChain = %+v, %v; want 1001 valid events", verification, err)
+	}
+
+	// Edits made around the append-only triggers break the chain at the edited event
+	for _, statement := range []string{
+		`DROP TRIGGER audit_events_no_update`,
+		`DROP TRIGGER audit_events_no_delete`,
+		`UPDATE audit_events SET metadata = '{"attempts":"0"}' WHERE id = 1001`,
+	} {
+		if _, err := db.Exec(ctx, statement); err != nil {
+			t.Fatal(err)
+		}
+	}
+	verification, err = audit.VerifyChain(ctx)
+	if err != nil || verification.Valid || verification.FirstInvalidID == nil || *verification.FirstInvalidID != 1001 {
+		t.Errorf("VerifyChain after an edit = %+v, %v; want event 1001 invalid", verification, err)
+	}
+
+	// A deleted event breaks the link of the one after it
+	if _, err := db.Exec(ctx, `DELETE FROM audit_events WHERE id = 10`); err != nil {
+		t.Fatal(err)
+	}
+	verification, err = audit.VerifyChain(ctx)
+	if err != nil || verification.Valid || *verification.FirstInvalidID != 11 || verification.Checked != 10 {
+		t.Errorf("VerifyChain after a deletion = %+v, %v; want event 11 invalid after 10 checked", verification, err)
+	}
+}
@@ -0,0 +1,10 @@
+package repository
+
+import "context"
+
+// Transactor runs units of work spanning repositories. The repositories' methods called with the
+// context fn is given share one transaction, which is committed only if fn returns nil.
+// *database.DB is a Transactor.
+type Transactor interface {
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
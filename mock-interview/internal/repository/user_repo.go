@@ -6,50 +6,91 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
 	model "github.com/winfr1th/mock-interview/internal/models"
 )
 
 type UserRepository interface {
 	CreateUser(ctx context.Context, user model.User) error
 	FindUserByID(ctx context.Context, id string) (model.User, error)
-	FindUserByAPIKey(ctx context.Context, apiKey string) (model.User, error)
+	// FindUserByIDForUpdate is FindUserByID locking the user until the unit of work ctx is in ends
+	FindUserByIDForUpdate(ctx context.Context, id string) (model.User, error)
+	FindUserByAPIKeyHash(ctx context.Context, keyHash string) (model.User, error)
+	FindUserByAPIKeyID(ctx context.Context, keyID string) (model.User, error)
 	UpdateUser(ctx context.Context, user model.User) error
-	UpdateAPIKey(ctx context.Context, userID uuid.UUID, apiKey string) error
+	UpdateAPIKey(ctx context.Context, userID uuid.UUID, keyID, keyHash string) error
+	RevokeAPIKey(ctx context.Context, userID uuid.UUID) error
 	DeleteUser(ctx context.Context, id string) error
 }
 
 type userRepo struct {
-	db *pgxpool.Pool
+	db Executor
 }
 
-func NewUserRepository(db *pgxpool.Pool) UserRepository {
+func NewUserRepository(db Executor) UserRepository {
 	return &userRepo{
 		db: db,
 	}
 }
 
+// CreateUser creates the user together with its household and default profile.
+// The default profile reuses the user's ID.
 func (r *userRepo) CreateUser(ctx context.Context, user model.User) error {
-	query := `INSERT INTO users (id, name, date_of_birth, api_key_hash) VALUES ($1, $2, $3, $4)`
-	_, err := r.db.Exec(ctx, query, user.ID, user.Name, user.DateOfBirth, user.APIKeyHash)
-	if err != nil {
-		return err
-	}
-	return nil
+	return r.db.WithTx(ctx, func(ctx context.Context) error {
+		householdQuery := `INSERT INTO households (id, name) VALUES ($1, $2)`
+		if _, err := r.db.Exec(ctx, householdQuery, user.HouseholdID, user.Name); err != nil {
+			return mapConstraintError(err)
+		}
+
+		query := `INSERT INTO users (id, household_id, name, date_of_birth, home_country, plan, api_key_id, api_key_hash) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+		if _, err := r.db.Exec(ctx, query, user.ID, user.HouseholdID, user.Name, user.DateOfBirth, user.HomeCountry,
+			nullableString(user.Plan), nullableString(user.APIKeyID), nullableString(user.APIKeyHash)); err != nil {
+			return mapConstraintError(err)
+		}
+
+		profileQuery := `INSERT INTO profiles (id, household_id, name, is_default) VALUES ($1, $2, $3, TRUE)`
+		if _, err := r.db.Exec(ctx, profileQuery, user.ID, user.HouseholdID, user.Name); err != nil {
+			return mapConstraintError(err)
+		}
+		return nil
+	})
 }
 
 func (r *userRepo) FindUserByID(ctx context.Context, id string) (model.User, error) {
+	return r.findUserByID(ctx, id, "")
+}
+
+func (r *userRepo) FindUserByIDForUpdate(ctx context.Context, id string) (model.User, error) {
+	return r.findUserByID(ctx, id, r.db.Dialect().forUpdate())
+}
+
+func (r *userRepo) findUserByID(ctx context.Context, id, lock string) (model.User, error) {
 	userID, err := uuid.Parse(id)
 	if err != nil {
-		return model.User{}, errors.New("invalid user ID format")
+		return model.User{}, ErrInvalidUserID
+	}
+
+	query := `SELECT id, household_id, name, date_of_birth, home_country, is_admin, COALESCE(plan, ''), COALESCE(api_key_id, ''), COALESCE(api_key_hash, '') FROM users WHERE id = $1` + lock
+	var user model.User
+	err = r.db.QueryRow(ctx, query, userID).Scan(&user.ID, &user.HouseholdID, &user.Name, &user.DateOfBirth, &user.HomeCountry, &user.IsAdmin, &user.Plan, &user.APIKeyID, &user.APIKeyHash)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return model.User{}, ErrUserNotFound
+		}
+		return model.User{}, err
 	}
 
-	query := `SELECT id, name, date_of_birth, api_key_hash FROM users WHERE id = $1`
+	return user, nil
+}
+
+// FindUserByAPIKeyHash finds the user of a legacy UUID key by the key's hash. Legacy keys have
+// no key ID to find their row by.
+func (r *userRepo) FindUserByAPIKeyHash(ctx context.Context, keyHash string) (model.User, error) {
+	query := `SELECT id, household_id, name, date_of_birth, home_country, is_admin, COALESCE(plan, ''), COALESCE(api_key_id, ''), COALESCE(api_key_hash, '') FROM users WHERE api_key_hash = $1 AND api_key_id IS NULL`
 	var user model.User
-	err = r.db.QueryRow(ctx, query, userID).Scan(&user.ID, &user.Name, &user.DateOfBirth, &user.APIKeyHash)
+	err := r.db.QueryRow(ctx, query, keyHash).Scan(&user.ID, &user.HouseholdID, &user.Name, &user.DateOfBirth, &user.HomeCountry, &user.IsAdmin, &user.Plan, &user.APIKeyID, &user.APIKeyHash)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return model.User{}, errors.New("user not found")
+			return model.User{}, ErrInvalidAPIKey
 		}
 		return model.User{}, err
 	}
@@ -57,13 +98,14 @@ func (r *userRepo) FindUserByID(ctx context.Context, id string) (model.User, err
 	return user, nil
 }
 
-func (r *userRepo) FindUserByAPIKey(ctx context.Context, apiKey string) (model.User, error) {
-	query := `SELECT id, name, date_of_birth, api_key_hash FROM users WHERE api_key_hash = $1`
+// FindUserByAPIKeyID finds the user owning a key ID; the caller verifies the key against APIKeyHash
+func (r *userRepo) FindUserByAPIKeyID(ctx context.Context, keyID string) (model.User, error) {
+	query := `SELECT id, household_id, name, date_of_birth, home_country, is_admin, COALESCE(plan, ''), COALESCE(api_key_id, ''), COALESCE(api_key_hash, '') FROM users WHERE api_key_id = $1`
 	var user model.User
-	err := r.db.QueryRow(ctx, query, apiKey).Scan(&user.ID, &user.Name, &user.DateOfBirth, &user.APIKeyHash)
+	err := r.db.QueryRow(ctx, query, keyID).Scan(&user.ID, &user.HouseholdID, &user.Name, &user.DateOfBirth, &user.HomeCountry, &user.IsAdmin, &user.Plan, &user.APIKeyID, &user.APIKeyHash)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return model.User{}, errors.New("invalid API key")
+			return model.User{}, ErrInvalidAPIKey
 		}
 		return model.User{}, err
 	}
@@ -72,47 +114,63 @@ func (r *userRepo) FindUserByAPIKey(ctx context.Context, apiKey string) (model.U
 }
 
 func (r *userRepo) UpdateUser(ctx context.Context, user model.User) error {
-	query := `UPDATE users SET name = $1, date_of_birth = $2 WHERE id = $3`
-	result, err := r.db.Exec(ctx, query, user.Name, user.DateOfBirth, user.ID)
+	query := `UPDATE users SET name = $1, date_of_birth = $2, home_country = $3 WHERE id = $4`
+	result, err := r.db.Exec(ctx, query, user.Name, user.DateOfBirth, user.HomeCountry, user.ID)
 	if err != nil {
-		return err
+		return mapConstraintError(err)
 	}
 
 	if result.RowsAffected() == 0 {
-		return errors.New("user not found")
+		return ErrUserNotFound
 	}
 
 	return nil
 }
 
-func (r *userRepo) UpdateAPIKey(ctx context.Context, userID uuid.UUID, apiKey string) error {
-	query := `UPDATE users SET api_key_hash = $1 WHERE id = $2`
-	result, err := r.db.Exec(ctx, query, apiKey, userID)
+func (r *userRepo) UpdateAPIKey(ctx context.Context, userID uuid.UUID, keyID, keyHash string) error {
+	query := `UPDATE users SET api_key_id = $1, api_key_hash = $2 WHERE id = $3`
+	result, err := r.db.Exec(ctx, query, keyID, keyHash, userID)
+	if err != nil {
+		return mapConstraintError(err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// RevokeAPIKey removes the user's API key so it can no longer authenticate
+func (r *userRepo) RevokeAPIKey(ctx context.Context, userID uuid.UUID) error {
+	query := `UPDATE users SET api_key_id = NULL, api_key_hash = NULL WHERE id = $1`
+	result, err := r.db.Exec(ctx, query, userID)
 	if err != nil {
 		return err
 	}
 
 	if result.RowsAffected() == 0 {
-		return errors.New("user not found")
+		return ErrUserNotFound
 	}
 
 	return nil
 }
 
+// DeleteUser deletes the user's household, which cascades to the user, its profiles and saved movies
 func (r *userRepo) DeleteUser(ctx context.Context, id string) error {
 	userID, err := uuid.Parse(id)
 	if err != nil {
-		return errors.New("invalid user ID format")
+		return ErrInvalidUserID
 	}
 
-	query := `DELETE FROM users WHERE id = $1`
+	query := `DELETE FROM households WHERE id = (SELECT household_id FROM users WHERE id = $1)`
 	result, err := r.db.Exec(ctx, query, userID)
 	if err != nil {
 		return err
 	}
 
 	if result.RowsAffected() == 0 {
-		return errors.New("user not found")
+		return ErrUserNotFound
 	}
 
 	return nil
@@ -0,0 +1,182 @@
+// Package server runs the HTTP server and shuts it down in order: readiness goes false first
+// so load balancers stop sending traffic, then in-flight requests finish, then background
+// workers drain, and the resources they all use are closed last.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Timeouts of the HTTP server and its shutdown. Zero durations mean no limit.
+type Timeouts struct {
+	ReadHeader time.Duration // Reading request headers; limits slow clients holding connections
+	Read       time.Duration // Reading the whole request
+	Write      time.Duration // From the end of the request headers to the end of the response
+	Idle       time.Duration // Keep-alive connections between requests
+	// DrainDelay is how long the server keeps serving after readiness goes false,
+	// so load balancers notice before connections are refused
+	DrainDelay time.Duration
+	// Shutdown is how long in-flight requests get to finish
+	Shutdown time.Duration
+	// WorkerDrain is how long background workers then get to return
+	WorkerDrain time.Duration
+}
+
+// DefaultTimeouts suit an API behind a load balancer
+var DefaultTimeouts = Timeouts{
+	ReadHeader:  5 * time.Second,
+	Read:        15 * time.Second,
+	Write:       30 * time.Second,
+	Idle:        120 * time.Second,
+	DrainDelay:  5 * time.Second,
+	Shutdown:    30 * time.Second,
+	WorkerDrain: 10 * time.Second,
+}
+
+// Server is an HTTP server with ordered shutdown
+type Server struct {
+	Addr     string
+	Handler  http.Handler
+	Timeouts Timeouts
+	// Workers are drained after the HTTP server has shut down
+	Workers *Workers
+	// Closers run last, in order, e.g. to close the database pool, once the workers have
+	// returned or been abandoned at their deadline
+	Closers []func()
+
+	draining atomic.Bool
+}
+
+// Ready reports whether the server accepts new traffic; it goes false when shutdown starts
+func (s *Server) Ready() bool {
+	return !s.draining.Load()
+}
+
+// ListenAndServe listens on s.Addr and serves until ctx is done, then shuts down
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve serves on ln until ctx is done, then shuts down. It returns once the closers have run.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	timeouts := s.Timeouts
+	srv := &http.Server{
+		Handler:           s.Handler,
+		ReadHeaderTimeout: timeouts.ReadHeader,
+		ReadTimeout:       timeouts.Read,
+		WriteTimeout:      timeouts.Write,
+		IdleTimeout:       timeouts.Idle,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.Serve(ln)
+	}()
+
+	select {
+	case err := <-serveErr:
+		s.close()
+		return err
+	case <-ctx.Done():
+	}
+
+	// Fail readiness first, and keep serving while load balancers take the instance out
+	s.draining.Store(true)
+	slog.Info("Shutting down server...")
+	time.Sleep(timeouts.DrainDelay)
+
+	shutdownCtx, cancel := withTimeout(timeouts.Shutdown)
+	defer cancel()
+
+	var errs []error
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		// Cut off what's left so nothing outlives the resources closed below
+		srv.Close()
+		errs = append(errs, fmt.Errorf("shutting down HTTP server: %w", err))
+	}
+	if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		errs = append(errs, err)
+	}
+	// The workers get their own deadline, so requests that used up the shutdown timeout
+	// don't leave them none
+	if s.Workers != nil {
+		drainCtx, cancel := withTimeout(timeouts.WorkerDrain)
+		err := s.Workers.Drain(drainCtx)
+		cancel()
+		if err != nil {
+			// They may still use what the closers close, but shutdown can't wait any longer
+			slog.Error("Abandoning background workers that didn't return", "error", err)
+			errs = append(errs, fmt.Errorf("draining background workers: %w", err))
+		}
+	}
+	s.close()
+	return errors.Join(errs...)
+}
+
+// withTimeout returns a context that's done after d, or never when d is zero
+func withTimeout(d time.Duration) (context.Context, context.CancelFunc) {
+	if d > 0 {
+		return context.WithTimeout(context.Background(), d)
+	}
+	return context.WithCancel(context.Background())
+}
+
+func (s *Server) close() {
+	for _, closer := range s.Closers {
+		closer()
+	}
+}
+
+// Workers runs background goroutines that shutdown waits for
+type Workers struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	running atomic.Int64
+}
+
+func NewWorkers() *Workers {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Workers{ctx: ctx, cancel: cancel}
+}
+
+// Go runs fn in a goroutine. Its context is canceled when draining starts, and fn should
+// return soon after.
+func (w *Workers) Go(fn func(ctx context.Context)) {
+	w.wg.Add(1)
+	w.running.Add(1)
+	go func() {
+		defer w.wg.Done()
+		defer w.running.Add(-1)
+		fn(w.ctx)
+	}()
+}
+
+// Drain cancels the workers' context and waits for them to return, or for ctx to be done.
+// Its error then says how many are still running.
+func (w *Workers) Drain(ctx context.Context) error {
+	w.cancel()
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%d still running: %w", w.running.Load(), ctx.Err())
+	}
+}
@@ -0,0 +1,227 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShutdownFinishesInFlightRequests(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+	record := func(step string) {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, step)
+	}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(w, "done")
+		record("request")
+	})
+
+	workers := NewWorkers()
+	workers.Go(func(ctx context.Context) {
+		<-ctx.Done()
+		record("worker")
+	})
+	srv := &Server{
+		Handler:  handler,
+		Timeouts: Timeouts{Shutdown: 5 * time.Second},
+		Workers:  workers,
+		Closers:  []func(){func() { record("pool") }},
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(ctx, ln) }()
+
+	type result struct {
+		body string
+		err  error
+	}
+	response := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			response <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		response <- result{body: string(body), err: err}
+	}()
+	<-started
+
+	if !srv.Ready() {
+		t.Fatal("not ready before shutdown")
+	}
+	cancel()
+	deadline := time.Now().Add(time.Second)
+	for srv.Ready() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if srv.Ready() {
+		t.Fatal("still ready after shutdown started")
+	}
+
+	// The server must not stop while the request is in flight
+	select {
+	case err := <-served:
+		t.Fatalf("Serve returned with a request in flight: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	res := <-response
+	if res.err != nil || res.body != "done" {
+		t.Fatalf("in-flight request: body %q, error %v", res.body, res.err)
+	}
+	if err := <-served; err != nil {
+		t.Fatalf("Serve: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"request", "worker", "pool"}
+	if len(order) != len(want) {
+		t.Fatalf("shutdown order %v, want %v", order, want)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("shutdown order %v, want %v", order, want)
+		}
+	}
+}
+
+func TestShutdownGivesUpAtDeadline(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	srv := &Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+		}),
+		Timeouts: Timeouts{Shutdown: 50 * time.Millisecond},
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(ctx, ln) }()
+	go http.Get("http://" + ln.Addr().String())
+	<-started
+
+	cancel()
+	select {
+	case err := <-served:
+		if err == nil {
+			t.Error("Serve returned no error for a request that outlived the deadline")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Serve didn't return after the shutdown deadline")
+	}
+}
+
+// shutDown serves srv, starts a request it holds until the test ends, and shuts down,
+// returning Serve's error
+func shutDown(t *testing.T, srv *Server) error {
+	t.Helper()
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	started := make(chan struct{})
+	srv.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(ctx, ln) }()
+	go http.Get("http://" + ln.Addr().String())
+	<-started
+
+	cancel()
+	select {
+	case err := <-served:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve didn't return")
+		return nil
+	}
+}
+
+func TestWorkersDrainAfterRequestsUsedTheShutdownTimeout(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+	record := func(step string) {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, step)
+	}
+
+	workers := NewWorkers()
+	workers.Go(func(ctx context.Context) {
+		<-ctx.Done()
+		time.Sleep(50 * time.Millisecond) // Flushing what it has
+		record("worker")
+	})
+	err := shutDown(t, &Server{
+		Timeouts: Timeouts{Shutdown: 20 * time.Millisecond, WorkerDrain: 5 * time.Second},
+		Workers:  workers,
+		Closers:  []func(){func() { record("pool") }},
+	})
+	if err == nil || strings.Contains(err.Error(), "workers") {
+		t.Errorf("Serve: %v, want only the request's deadline", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(order) != 2 || order[0] != "worker" || order[1] != "pool" {
+		t.Errorf("shutdown order %v, want the worker to return before the pool closes", order)
+	}
+}
+
+func TestWorkersAreAbandonedAtTheirDeadline(t *testing.T) {
+	hang := make(chan struct{})
+	defer close(hang)
+	closed := make(chan struct{})
+
+	workers := NewWorkers()
+	workers.Go(func(ctx context.Context) { <-hang })
+	workers.Go(func(ctx context.Context) { <-ctx.Done() })
+	err := shutDown(t, &Server{
+		Timeouts: Timeouts{Shutdown: 20 * time.Millisecond, WorkerDrain: 20 * time.Millisecond},
+		Workers:  workers,
+		Closers:  []func(){func() { close(closed) }},
+	})
+	if err == nil || !strings.Contains(err.Error(), "draining background workers: 1 still running") {
+		t.Errorf("Serve: %v, want the worker that didn't return reported", err)
+	}
+	select {
+	case <-closed:
+	default:
+		t.Error("closers didn't run after the workers were abandoned")
+	}
+}
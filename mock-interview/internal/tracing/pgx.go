@@ -0,0 +1,45 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/winfr1th/mock-interview/internal/database"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RowsAttribute is the span attribute holding the rows a query returned or affected
+const RowsAttribute = "db.rows"
+
+// QueryTracer is a pgx tracer starting a child span of the request's span for every query,
+// named by database.StatementName
+type QueryTracer struct{}
+
+func (QueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	name := database.StatementName(data.SQL)
+	ctx, _ = Tracer().Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBQueryText(data.SQL),
+			semconv.DBOperationName(name),
+		),
+	)
+	return ctx
+}
+
+func (QueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	if data.Err != nil && !errors.Is(data.Err, pgx.ErrNoRows) {
+		span.RecordError(data.Err)
+		span.SetStatus(codes.Error, data.Err.Error())
+		return
+	}
+	span.SetAttributes(attribute.Int64(RowsAttribute, data.CommandTag.RowsAffected()))
+}
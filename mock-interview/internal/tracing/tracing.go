@@ -0,0 +1,89 @@
+// Package tracing sets up OpenTelemetry tracing: W3C trace context propagation, a span per
+// request (see middleware.Tracing) and a child span per database query.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName names the tracer of this service's spans
+const InstrumentationName = "github.com/winfr1th/mock-interview"
+
+// ServiceName is the service.name resource attribute unless OTEL_SERVICE_NAME sets another
+const ServiceName = "mock-interview"
+
+// Exporters
+const (
+	ExporterOTLP   = "otlp"   // OTLP over HTTP, configured by the OTEL_EXPORTER_OTLP_* variables
+	ExporterStdout = "stdout" // Pretty-printed JSON on stdout, for development
+	ExporterOff    = "off"    // No tracing
+)
+
+// exporterAliases are the OTEL_TRACES_EXPORTER names of the exporters
+var exporterAliases = map[string]string{
+	"console": ExporterStdout,
+	"none":    ExporterOff,
+}
+
+// Tracer returns the tracer for this service's spans
+func Tracer() trace.Tracer {
+	return otel.Tracer(InstrumentationName)
+}
+
+// Setup installs a global tracer provider exporting to exporter, and W3C trace context and
+// baggage propagation. The returned shutdown flushes pending spans; call it after the server
+// has stopped.
+func Setup(ctx context.Context, exporter string) (shutdown func(context.Context) error, err error) {
+	// Propagate incoming trace context even when this instance doesn't export spans
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{},
+	))
+
+	if alias, ok := exporterAliases[exporter]; ok {
+		exporter = alias
+	}
+	var spanExporter sdktrace.SpanExporter
+	switch exporter {
+	case ExporterOff, "":
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		spanExporter, err = otlptracehttp.New(ctx)
+	case ExporterStdout:
+		spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout), stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q, want %s, %s or %s", exporter, ExporterOTLP, ExporterStdout, ExporterOff)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("creating %s trace exporter: %w", exporter, err)
+	}
+
+	res, err := resource.Merge(
+		resource.Default(),
+		resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(ServiceName)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES take precedence
+	if fromEnv, err := resource.New(ctx, resource.WithFromEnv()); err == nil {
+		res, _ = resource.Merge(res, fromEnv)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
@@ -0,0 +1,115 @@
+package tracing_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/winfr1th/mock-interview/internal/middleware"
+	"github.com/winfr1th/mock-interview/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// inMemory installs a tracer provider recording spans in memory
+func inMemory(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		provider.Shutdown(t.Context())
+		otel.SetTracerProvider(previous)
+	})
+	return exporter
+}
+
+func TestRequestSpanContinuesTraceWithQuerySpans(t *testing.T) {
+	exporter := inMemory(t)
+
+	// A handler running a count query and a page query, like ListSavedMovies
+	router := mux.NewRouter()
+	router.Use(middleware.Tracing)
+	router.HandleFunc("/v1/users/{user_id}/movies", func(w http.ResponseWriter, r *http.Request) {
+		var tracer tracing.QueryTracer
+		ctx := tracer.TraceQueryStart(r.Context(), nil, pgx.TraceQueryStartData{
+			SQL: "SELECT COUNT(*) FROM saved_movies WHERE profile_id = $1",
+		})
+		tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{CommandTag: pgconn.NewCommandTag("SELECT 1")})
+
+		ctx = tracer.TraceQueryStart(r.Context(), nil, pgx.TraceQueryStartData{
+			SQL: "SELECT m.id, m.title, m.year FROM movies m JOIN saved_movies s ON s.movie_id = m.id",
+		})
+		tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{CommandTag: pgconn.NewCommandTag("SELECT 20")})
+	})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	const parentID = "00f067aa0ba902b7"
+	req := httptest.NewRequest(http.MethodGet, "/v1/users/550e8400-e29b-41d4-a716-446655440000/movies", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-"+parentID+"-01")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 3 {
+		t.Fatalf("got %d spans, want 3", len(spans))
+	}
+	count, page, server := spans[0], spans[1], spans[2]
+
+	if server.Name != "GET /v1/users/{user_id}/movies" || server.SpanKind != trace.SpanKindServer {
+		t.Errorf("server span %q kind %v", server.Name, server.SpanKind)
+	}
+	if got := server.SpanContext.TraceID().String(); got != traceID {
+		t.Errorf("trace ID %s, want the traceparent's %s", got, traceID)
+	}
+	if got := server.Parent.SpanID().String(); got != parentID {
+		t.Errorf("server span parent %s, want the traceparent's %s", got, parentID)
+	}
+
+	for _, tt := range []struct {
+		span tracetest.SpanStub
+		rows int64
+	}{{count, 1}, {page, 20}} {
+		if tt.span.Parent.SpanID() != server.SpanContext.SpanID() {
+			t.Errorf("%s isn't a child of the request span", tt.span.Name)
+		}
+		if rows, ok := attr(tt.span.Attributes, tracing.RowsAttribute); !ok || rows.AsInt64() != tt.rows {
+			t.Errorf("%s rows = %v, want %d", tt.span.Name, rows.AsInterface(), tt.rows)
+		}
+	}
+	if count.Name != "select saved_movies" || page.Name != "select movies" {
+		t.Errorf("query spans named %q and %q", count.Name, page.Name)
+	}
+}
+
+func TestFailedQuerySpanHasErrorStatus(t *testing.T) {
+	exporter := inMemory(t)
+
+	var tracer tracing.QueryTracer
+	ctx := tracer.TraceQueryStart(t.Context(), nil, pgx.TraceQueryStartData{SQL: "INSERT INTO users (id) VALUES ($1)"})
+	tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{Err: errors.New("duplicate key")})
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 || spans[0].Status.Code != codes.Error {
+		t.Fatalf("spans %+v, want one with an error status", spans)
+	}
+}
+
+func attr(attrs []attribute.KeyValue, key string) (attribute.Value, bool) {
+	for _, kv := range attrs {
+		if string(kv.Key) == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
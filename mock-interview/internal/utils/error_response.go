@@ -5,6 +5,9 @@ import (
 	"net/http"
 )
 
+// RequestIDHeader carries the request ID, set on every response by middleware.RequestID
+const RequestIDHeader = "X-Request-ID"
+
 // ErrorResponse represents the standard error response format
 type ErrorResponse struct {
 	Error ErrorDetail `json:"error"`
@@ -12,22 +15,42 @@ type ErrorResponse struct {
 
 // ErrorDetail contains error information
 type ErrorDetail struct {
-	Code    string                 `json:"code"`
-	Message string                 `json:"message"`
-	Details map[string]interface{} `json:"details"`
+	Code      string                 `json:"code"`
+	Message   string                 `json:"message"`
+	Details   map[string]interface{} `json:"details"`
+	RequestID string                 `json:"request_id,omitempty"` // To quote when reporting the error
+}
+
+// FieldError describes one invalid part of a request. Body fields are located by a JSON
+// pointer such as #/name, and query and path parameters by name.
+type FieldError struct {
+	Pointer   string `json:"pointer,omitempty"`
+	Parameter string `json:"parameter,omitempty"`
+	Code      string `json:"code"`
+	Detail    string `json:"detail"`
 }
 
-// WriteErrorResponse writes a standardized error response
+// fieldErrorsDetail is the details key holding a validation error's field errors
+const fieldErrorsDetail = "errors"
+
+// WriteErrorResponse writes a standardized error response, or problem details when the
+// response was set up by WithProblemDetails
 func WriteErrorResponse(w http.ResponseWriter, statusCode int, code, message string, details map[string]interface{}) {
+	if instance, ok := problemInstance(w); ok {
+		writeProblem(w, instance, statusCode, code, message, details)
+		return
+	}
+
 	if details == nil {
 		details = make(map[string]interface{})
 	}
 
 	response := ErrorResponse{
 		Error: ErrorDetail{
-			Code:    code,
-			Message: message,
-			Details: details,
+			Code:      code,
+			Message:   message,
+			Details:   details,
+			RequestID: w.Header().Get(RequestIDHeader),
 		},
 	}
 
@@ -35,3 +58,11 @@ func WriteErrorResponse(w http.ResponseWriter, statusCode int, code, message str
 	w.WriteHeader(statusCode)
 	json.NewEncoder(w).Encode(response)
 }
+
+// WriteValidationError writes a 400 for fieldErrors. The first field error gives the response's
+// code and message, so clients that only read those see the same error as before.
+func WriteValidationError(w http.ResponseWriter, fieldErrors []FieldError) {
+	first := fieldErrors[0]
+	WriteErrorResponse(w, http.StatusBadRequest, first.Code, first.Detail,
+		map[string]interface{}{fieldErrorsDetail: fieldErrors})
+}
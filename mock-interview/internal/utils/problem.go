@@ -0,0 +1,147 @@
+package utils
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ProblemContentType is the media type of RFC 9457 problem details
+const ProblemContentType = "application/problem+json"
+
+// ProblemTypeBase prefixes the type URI of every problem; the rest is the error code in kebab case
+const ProblemTypeBase = "/problems/"
+
+// Problem is an RFC 9457 problem details body. Details of the error are extension members.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail"`
+	Instance  string       `json:"instance"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+
+	extensions map[string]interface{}
+}
+
+// MarshalJSON writes the extension members next to the standard ones
+func (p Problem) MarshalJSON() ([]byte, error) {
+	type problem Problem
+	body, err := json.Marshal(problem(p))
+	if err != nil || len(p.extensions) == 0 {
+		return body, err
+	}
+
+	members := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(body, &members); err != nil {
+		return nil, err
+	}
+	for name, value := range p.extensions {
+		// Extensions never replace a standard member
+		if _, ok := members[name]; ok {
+			continue
+		}
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		members[name] = raw
+	}
+	return json.Marshal(members)
+}
+
+// ProblemType returns the type URI for an error code, e.g. /problems/missing-fields
+func ProblemType(code string) string {
+	return ProblemTypeBase + strings.ReplaceAll(strings.ToLower(code), "_", "-")
+}
+
+// problemWriter marks a response whose errors are written as problem details
+type problemWriter struct {
+	http.ResponseWriter
+	instance string
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (pw *problemWriter) Unwrap() http.ResponseWriter {
+	return pw.ResponseWriter
+}
+
+// WithProblemDetails returns w set up so WriteErrorResponse writes problem details,
+// when the request's Accept header prefers them over application/json
+func WithProblemDetails(w http.ResponseWriter, r *http.Request) http.ResponseWriter {
+	if !PrefersProblemDetails(r.Header.Get("Accept")) {
+		return w
+	}
+	return &problemWriter{ResponseWriter: w, instance: r.URL.Path}
+}
+
+// PrefersProblemDetails reports whether an Accept header asks for application/problem+json
+// with at least the quality of application/json
+func PrefersProblemDetails(accept string) bool {
+	problemQ, jsonQ := -1.0, -1.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		switch mediaType {
+		case ProblemContentType:
+			problemQ = max(problemQ, q)
+		case "application/json":
+			jsonQ = max(jsonQ, q)
+		}
+	}
+	return problemQ > 0 && problemQ >= jsonQ
+}
+
+// problemInstance returns the request path when w was set up by WithProblemDetails
+func problemInstance(w http.ResponseWriter) (string, bool) {
+	for {
+		if pw, ok := w.(*problemWriter); ok {
+			return pw.instance, true
+		}
+		unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return "", false
+		}
+		w = unwrapper.Unwrap()
+	}
+}
+
+// writeProblem writes an error as problem details. Field errors become the errors member
+// and any other details become extension members.
+func writeProblem(w http.ResponseWriter, instance string, statusCode int, code, message string, details map[string]interface{}) {
+	problem := Problem{
+		Type:      ProblemType(code),
+		Title:     http.StatusText(statusCode),
+		Status:    statusCode,
+		Detail:    message,
+		Instance:  instance,
+		Code:      code,
+		RequestID: w.Header().Get(RequestIDHeader),
+	}
+	for name, value := range details {
+		if fieldErrors, ok := value.([]FieldError); ok && name == fieldErrorsDetail {
+			problem.Errors = fieldErrors
+			continue
+		}
+		if problem.extensions == nil {
+			problem.extensions = make(map[string]interface{})
+		}
+		problem.extensions[name] = value
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(problem)
+}
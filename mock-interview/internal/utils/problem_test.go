@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPrefersProblemDetails(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{"", false},
+		{"*/*", false},
+		{"application/json", false},
+		{"application/problem+json", true},
+		{"application/json, application/problem+json", true},
+		{"application/problem+json;q=0.5, application/json", false},
+		{"application/problem+json, application/json;q=0.9", true},
+		{"application/problem+json;q=0.8, application/json;q=0.8", true},
+		{"application/problem+json;q=0", false},
+		{"application/problem+json;q=oops", true},
+		{"not a media type, application/problem+json", true},
+	}
+	for _, tt := range tests {
+		if got := PrefersProblemDetails(tt.accept); got != tt.want {
+			t.Errorf("PrefersProblemDetails(%q) = %v, want %v", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestProblemType(t *testing.T) {
+	if got := ProblemType("MISSING_FIELDS"); got != "/problems/missing-fields" {
+		t.Errorf("ProblemType = %q", got)
+	}
+}
+
+// wrappingWriter is a middleware's response writer around the one WithProblemDetails returned
+type wrappingWriter struct {
+	http.ResponseWriter
+}
+
+func (w *wrappingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// writeError writes an error through WithProblemDetails for a request with accept, then
+// through a middleware's wrapper when wrap is set, and decodes the body
+func writeError(t *testing.T, accept string, wrap bool, details map[string]interface{}) (*httptest.ResponseRecorder, map[string]interface{}) {
+	t.Helper()
+	r := httptest.NewRequest("POST", "/v1/users", nil)
+	r.Header.Set("Accept", accept)
+	rec := httptest.NewRecorder()
+	rec.Header().Set(RequestIDHeader, "req-1")
+	w := WithProblemDetails(rec, r)
+	if wrap {
+		w = &wrappingWriter{w}
+	}
+	WriteErrorResponse(w, http.StatusBadRequest, "MISSING_FIELDS", "name is required", details)
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("body %q: %v", rec.Body, err)
+	}
+	return rec, body
+}
+
+func TestErrorsAreProblemDetailsThroughWrappedWriters(t *testing.T) {
+	for _, wrap := range []bool{false, true} {
+		rec, body := writeError(t, ProblemContentType, wrap, nil)
+		if rec.Header().Get("Content-Type") != ProblemContentType || rec.Code != http.StatusBadRequest {
+			t.Errorf("wrapped %v: %d %s, want 400 problem details", wrap, rec.Code, rec.Header().Get("Content-Type"))
+		}
+		want := map[string]interface{}{
+			"type":       "/problems/missing-fields",
+			"title":      "Bad Request",
+			"status":     float64(400),
+			"detail":     "name is required",
+			"instance":   "/v1/users",
+			"code":       "MISSING_FIELDS",
+			"request_id": "req-1",
+		}
+		for name, value := range want {
+			if body[name] != value {
+				t.Errorf("wrapped %v: %s = %v, want %v", wrap, name, body[name], value)
+			}
+		}
+	}
+}
+
+func TestProblemExtensionsNeverReplaceStandardMembers(t *testing.T) {
+	fieldErrors := []FieldError{{Pointer: "/name", Code: "MISSING_FIELDS", Detail: "name is required"}}
+	_, body := writeError(t, ProblemContentType, false, map[string]interface{}{
+		"status":          "overridden",
+		"code":            "OVERRIDDEN",
+		"retry_after":     30,
+		fieldErrorsDetail: fieldErrors,
+	})
+	if body["status"] != float64(400) || body["code"] != "MISSING_FIELDS" {
+		t.Errorf("standard members replaced: status %v, code %v", body["status"], body["code"])
+	}
+	if body["retry_after"] != float64(30) {
+		t.Errorf("retry_after = %v, want the extension", body["retry_after"])
+	}
+	errors, ok := body["errors"].([]interface{})
+	if !ok || len(errors) != 1 || errors[0].(map[string]interface{})["pointer"] != "/name" {
+		t.Errorf("errors = %v, want the field errors", body["errors"])
+	}
+}
+
+func TestErrorsAreJSONUnlessProblemDetailsArePreferred(t *testing.T) {
+	rec, body := writeError(t, "application/json", true, map[string]interface{}{"field": "name"})
+	if rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Content-Type = %q", rec.Header().Get("Content-Type"))
+	}
+	detail, ok := body["error"].(map[string]interface{})
+	if !ok || detail["code"] != "MISSING_FIELDS" || detail["request_id"] != "req-1" ||
+		detail["details"].(map[string]interface{})["field"] != "name" {
+		t.Errorf("body = %v, want the error object", body)
+	}
+}
@@ -0,0 +1,643 @@
+package validate
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/winfr1th/mock-interview/internal/country"
+	"github.com/winfr1th/mock-interview/internal/utils"
+)
+
+// MaxBodyBytes is the largest request body Bind reads
+const MaxBodyBytes = 1 << 20
+
+// Error codes Bind reports besides the ones a schema declares
+const (
+	CodeInvalidField    = "INVALID_FIELD"
+	CodeUnknownField    = "UNKNOWN_FIELD"
+	CodeInvalidRequest  = "INVALID_REQUEST"
+	CodeRequestTooLarge = "REQUEST_TOO_LARGE"
+)
+
+// Checker is implemented by schemas with checks that tags can't express.
+// Check runs after the tags are applied, so its field errors are reported with theirs.
+type Checker interface {
+	Check(v *Validator)
+}
+
+var (
+	errInvalidBody  = errors.New("invalid request body")
+	errBodyTooLarge = errors.New("request body too large")
+)
+
+// Bind fills dst, a pointer to an endpoint's request schema, from the request and checks it.
+// It writes a 400 with a field error for every invalid field, or a 413 for an oversized body,
+// and reports whether the handler should go on.
+//
+// Schema fields are read from where their tag says:
+//
+//	path:"user_id"     a mux path variable
+//	query:"page"       a query parameter; default:"1" is used when it's absent
+//	body:"strict"      the JSON body, decoded into the field's struct by json tags.
+//	                   strict rejects fields the struct doesn't declare.
+//
+// Path and query values are trimmed and parsed into the field's type: strings, integers,
+// bools, uuid.UUID, time.Time (RFC 3339), pointers to these, or encoding.TextUnmarshaler.
+// Struct fields without a tag, such as an embedded Pagination, are read the same way.
+//
+// The validate tag lists rules, which apply when a value is given:
+//
+//	required       the value must be given and not be empty
+//	nonempty       a given string must not be empty
+//	trim           trim spaces from a string before the other rules
+//	uuid           a string must be a UUID
+//	iso3166        a string must be an ISO-3166-1 alpha-2 code; it's upper-cased
+//	enum=a|b       the value must be one of the listed ones
+//	min=N, max=N   bounds of a number or the length of a string
+//
+// The code tag sets the error code of a field, and of single rules with rule=CODE:
+// code:"INVALID_PAGE_SIZE,max=PAGE_SIZE_TOO_LARGE".
+//
+// Defaults and rule arguments may refer to a setting, as in max=$max_page_size (see SetPageSizes).
+func Bind(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	var v Validator
+	if err := bindStruct(w, r, reflect.ValueOf(dst).Elem(), &v); err != nil {
+		if errors.Is(err, errBodyTooLarge) {
+			utils.WriteErrorResponse(w, http.StatusRequestEntityTooLarge, CodeRequestTooLarge,
+				"Request body must not exceed "+strconv.Itoa(MaxBodyBytes)+" bytes", nil)
+			return false
+		}
+		utils.WriteErrorResponse(w, http.StatusBadRequest, CodeInvalidRequest, "Invalid request body", nil)
+		return false
+	}
+	if checker, ok := dst.(Checker); ok {
+		checker.Check(&v)
+	}
+	return !v.Failed(w)
+}
+
+// Pagination is embedded in the query schema of paged endpoints
+type Pagination struct {
+	Page     int `query:"page" default:"1" validate:"min=1" code:"INVALID_PAGE"`
+	PageSize int `query:"page_size" default:"$default_page_size" validate:"min=1,max=$max_page_size" code:"INVALID_PAGE_SIZE,max=PAGE_SIZE_TOO_LARGE"`
+}
+
+// page_size of paged endpoints until SetPageSizes changes them
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// tagSettings are the values of $name references in default and validate tags, so settings
+// made at startup apply to binding and to the OpenAPI document alike
+var tagSettings = map[string]string{
+	"default_page_size": strconv.Itoa(DefaultPageSize),
+	"max_page_size":     strconv.Itoa(MaxPageSize),
+}
+
+// SetPageSizes sets the default and largest page_size of paged endpoints. Call it before
+// routes are registered.
+func SetPageSizes(defaultSize, maxSize int) {
+	tagSettings["default_page_size"] = strconv.Itoa(defaultSize)
+	tagSettings["max_page_size"] = strconv.Itoa(maxSize)
+}
+
+// expand resolves a $name tag value
+func expand(value string) string {
+	if name, ok := strings.CutPrefix(value, "$"); ok {
+		return tagSettings[name]
+	}
+	return value
+}
+
+// Default returns the default tag of a query parameter, with $name references resolved
+func Default(sf reflect.StructField) (string, bool) {
+	def, ok := sf.Tag.Lookup("default")
+	return expand(def), ok
+}
+
+func bindStruct(w http.ResponseWriter, r *http.Request, sv reflect.Value, v *Validator) error {
+	st := sv.Type()
+	for i := 0; i < st.NumField(); i++ {
+		sf := st.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		field := sv.Field(i)
+
+		if name, ok := sf.Tag.Lookup("path"); ok {
+			bindParam(field, sf, Param(name), mux.Vars(r)[name], v)
+			continue
+		}
+		if name, ok := sf.Tag.Lookup("query"); ok {
+			bindParam(field, sf, Param(name), r.URL.Query().Get(name), v)
+			continue
+		}
+		if opts, ok := sf.Tag.Lookup("body"); ok {
+			if err := bindBody(w, r, field, opts == "strict", v); err != nil {
+				return err
+			}
+			continue
+		}
+		if sf.Type.Kind() == reflect.Struct && sf.Anonymous {
+			if err := bindStruct(w, r, field, v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// bindParam parses a path or query value into field and applies its rules
+func bindParam(field reflect.Value, sf reflect.StructField, at Location, raw string, v *Validator) {
+	rules := parseRules(sf, false)
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		raw, _ = Default(sf)
+	}
+	if raw == "" {
+		rules.missing(at, v)
+		return
+	}
+
+	if err := setText(field, raw); err != nil {
+		v.Add(at, rules.code("", CodeInvalidParameter), "Invalid "+at.name+": must be "+describe(field.Type()))
+		return
+	}
+	rules.apply(at, field, v)
+}
+
+// bindBody decodes the JSON body into the struct field one member at a time, so each
+// member that doesn't fit its field gets its own field error
+func bindBody(w http.ResponseWriter, r *http.Request, field reflect.Value, strict bool, v *Validator) error {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxBodyBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return errBodyTooLarge
+		}
+		return errInvalidBody
+	}
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil || members == nil {
+		return errInvalidBody
+	}
+
+	known := make(map[string]bool)
+	bt := field.Type()
+	for i := 0; i < bt.NumField(); i++ {
+		sf := bt.Field(i)
+		name := jsonName(sf)
+		if name == "" {
+			continue
+		}
+		known[name] = true
+		at := Field(name)
+		rules := parseRules(sf, true)
+
+		raw, ok := members[name]
+		if !ok || string(raw) == "null" {
+			rules.missing(at, v)
+			continue
+		}
+		target := field.Field(i)
+		if err := json.Unmarshal(raw, target.Addr().Interface()); err != nil {
+			target.Set(reflect.Zero(target.Type()))
+			v.Add(at, rules.code("", CodeInvalidField), "Invalid "+name+": must be "+describe(target.Type()))
+			continue
+		}
+		rules.apply(at, target, v)
+	}
+
+	if strict {
+		var unknown []string
+		for name := range members {
+			if !known[name] {
+				unknown = append(unknown, name)
+			}
+		}
+		sort.Strings(unknown)
+		for _, name := range unknown {
+			v.Add(Field(name), CodeUnknownField, "Unknown field "+name)
+		}
+	}
+	return nil
+}
+
+// jsonName returns the member name of a body field, or "" when it isn't decoded
+func jsonName(sf reflect.StructField) string {
+	if !sf.IsExported() {
+		return ""
+	}
+	name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return sf.Name
+	}
+	return name
+}
+
+var (
+	uuidType = reflect.TypeOf(uuid.UUID{})
+	timeType = reflect.TypeOf(time.Time{})
+)
+
+// setText parses s into field according to its type
+func setText(field reflect.Value, s string) error {
+	if field.Kind() == reflect.Pointer {
+		elem := reflect.New(field.Type().Elem())
+		if err := setText(elem.Elem(), s); err != nil {
+			return err
+		}
+		field.Set(elem)
+		return nil
+	}
+
+	switch field.Type() {
+	case uuidType:
+		id, err := uuid.Parse(s)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(id))
+		return nil
+	case timeType:
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(t))
+		return nil
+	}
+	if unmarshaler, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText([]byte(s))
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(s)
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	default:
+		return errors.New("unsupported field type " + field.Type().String())
+	}
+	return nil
+}
+
+// describe names what a value of type t must look like, for error details
+func describe(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t {
+	case uuidType:
+		return "a valid UUID"
+	case timeType:
+		return "an RFC 3339 timestamp"
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return "an integer"
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Struct, reflect.Map:
+		return "an object"
+	case reflect.Slice:
+		return "an array"
+	}
+	return "a valid " + t.String()
+}
+
+// fieldRules are the parsed validate and code tags of a field
+type fieldRules struct {
+	body        bool
+	rules       []rule
+	defaultCode string
+	codes       map[string]string
+	isRequired  bool
+}
+
+type rule struct {
+	name  string
+	arg   string
+	bound int64 // The argument of min and max
+}
+
+// knownRules are the rules of validate tags
+var knownRules = map[string]bool{
+	"required": true, "nonempty": true, "trim": true, "uuid": true, "iso3166": true,
+	"enum": true, "min": true, "max": true,
+}
+
+func parseRules(sf reflect.StructField, body bool) fieldRules {
+	fr := fieldRules{body: body, codes: make(map[string]string)}
+	if tag := sf.Tag.Get("validate"); tag != "" {
+		for _, part := range strings.Split(tag, ",") {
+			name, arg, _ := strings.Cut(part, "=")
+			if name == "required" {
+				fr.isRequired = true
+				continue
+			}
+			rl := rule{name: name, arg: expand(arg)}
+			if name == "min" || name == "max" {
+				bound, err := strconv.ParseInt(rl.arg, 10, 64)
+				if err != nil {
+					continue // Reported by CheckSchema
+				}
+				rl.bound = bound
+			}
+			fr.rules = append(fr.rules, rl)
+		}
+	}
+	if tag := sf.Tag.Get("code"); tag != "" {
+		for i, part := range strings.Split(tag, ",") {
+			name, code, ok := strings.Cut(part, "=")
+			if i == 0 && !ok {
+				fr.defaultCode = part
+				continue
+			}
+			fr.codes[name] = code
+		}
+	}
+	return fr
+}
+
+// code returns the error code for a rule: its own code, the field's code, or fallback
+func (fr fieldRules) code(rule, fallback string) string {
+	if code, ok := fr.codes[rule]; ok {
+		return code
+	}
+	if fr.defaultCode != "" {
+		return fr.defaultCode
+	}
+	return fallback
+}
+
+// missing records a missing value when the field is required
+func (fr fieldRules) missing(at Location, v *Validator) {
+	if fr.isRequired {
+		v.Add(at, fr.code("required", CodeMissingFields), at.name+" is required")
+	}
+}
+
+// apply checks a given value against the field's rules
+func (fr fieldRules) apply(at Location, field reflect.Value, v *Validator) {
+	for field.Kind() == reflect.Pointer {
+		if field.IsNil() {
+			return
+		}
+		field = field.Elem()
+	}
+	invalid := fr.code("", CodeInvalidParameter)
+	if fr.body {
+		invalid = fr.code("", CodeInvalidField)
+	}
+
+	if field.Kind() == reflect.String && fr.has("trim") {
+		field.SetString(strings.TrimSpace(field.String()))
+	}
+	if fr.isRequired && field.Kind() == reflect.String && field.String() == "" {
+		fr.missing(at, v)
+		return
+	}
+
+	for _, rl := range fr.rules {
+		switch rl.name {
+		case "trim":
+		case "nonempty":
+			if !v.Check(field.String() != "", at, fr.code(rl.name, CodeMissingFields), at.name+" must not be empty") {
+				return
+			}
+		case "uuid":
+			if field.String() == "" {
+				continue
+			}
+			if _, err := uuid.Parse(field.String()); err != nil {
+				v.Add(at, fr.code(rl.name, invalid), "Invalid "+at.name+": must be a valid UUID")
+				return
+			}
+		case "iso3166":
+			if field.String() == "" {
+				continue
+			}
+			code, err := country.Normalize(field.String())
+			if err != nil {
+				v.Add(at, fr.code(rl.name, CodeInvalidCountryCode), invalidCountryDetail(at))
+				return
+			}
+			field.SetString(code)
+		case "enum":
+			if !v.OneOf(at, field.String(), fr.code(rl.name, invalid), strings.Split(rl.arg, "|")...) {
+				return
+			}
+		case "min", "max":
+			if !fr.checkBound(at, field, rl, invalid, v) {
+				return
+			}
+		}
+	}
+}
+
+func (fr fieldRules) has(name string) bool {
+	for _, rl := range fr.rules {
+		if rl.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// checkBound checks a min or max rule against a number, or the length of a string
+func (fr fieldRules) checkBound(at Location, field reflect.Value, rl rule, invalid string, v *Validator) bool {
+	var n int64
+	unit := ""
+	switch field.Kind() {
+	case reflect.String:
+		n, unit = int64(len(field.String())), " characters"
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		n = field.Int()
+	default:
+		return true
+	}
+
+	ok, limit := n >= rl.bound, "at least"
+	if rl.name == "max" {
+		ok, limit = n <= rl.bound, "at most"
+	}
+	return v.Check(ok, at, fr.code(rl.name, invalid), at.name+" must be "+limit+" "+rl.arg+unit)
+}
+
+// Rules are the validate rules a schema field declares, for documenting it
+type Rules struct {
+	Required bool
+	Enum     []string
+	Min, Max *int64
+	UUID     bool
+	ISO3166  bool
+}
+
+// FieldRules returns the rules declared by a schema field's validate tag
+func FieldRules(sf reflect.StructField) Rules {
+	fr := parseRules(sf, false)
+	rules := Rules{Required: fr.isRequired}
+	for _, rl := range fr.rules {
+		switch rl.name {
+		case "enum":
+			rules.Enum = strings.Split(rl.arg, "|")
+		case "min", "max":
+			bound := rl.bound
+			if rl.name == "min" {
+				rules.Min = &bound
+			} else {
+				rules.Max = &bound
+			}
+		case "uuid":
+			rules.UUID = true
+		case "iso3166":
+			rules.ISO3166 = true
+		}
+	}
+	return rules
+}
+
+// CheckSchema reports the tags of a request schema that Bind can't apply: unknown rules, enum,
+// min and max rules without a valid argument, $name references to no setting, and path or query
+// parameters of a type Bind doesn't parse. Routes are checked when they're registered, so a
+// mistyped tag stops the server from starting rather than failing requests.
+func CheckSchema(t reflect.Type) error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return errors.New("validate: schema " + t.String() + " is not a struct")
+	}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		_, isPath := sf.Tag.Lookup("path")
+		_, isQuery := sf.Tag.Lookup("query")
+		_, isBody := sf.Tag.Lookup("body")
+		var err error
+		switch {
+		case isPath || isQuery:
+			err = checkParam(sf)
+		case isBody:
+			err = checkBody(sf)
+		case sf.Type.Kind() == reflect.Struct && sf.Anonymous:
+			err = CheckSchema(sf.Type)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkParam checks the tags and type of a path or query parameter
+func checkParam(sf reflect.StructField) error {
+	if def, ok := sf.Tag.Lookup("default"); ok {
+		if err := checkSetting(sf, def); err != nil {
+			return err
+		}
+	}
+	if !parsesText(sf.Type) {
+		return errors.New("validate: " + sf.Name + " has unsupported parameter type " + sf.Type.String())
+	}
+	return checkRules(sf)
+}
+
+// checkBody checks the rules of every member of a body
+func checkBody(sf reflect.StructField) error {
+	if sf.Type.Kind() != reflect.Struct {
+		return errors.New("validate: body " + sf.Name + " is not a struct")
+	}
+	for i := 0; i < sf.Type.NumField(); i++ {
+		if member := sf.Type.Field(i); jsonName(member) != "" {
+			if err := checkRules(member); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkRules checks a field's validate tag
+func checkRules(sf reflect.StructField) error {
+	tag := sf.Tag.Get("validate")
+	if tag == "" {
+		return nil
+	}
+	for _, part := range strings.Split(tag, ",") {
+		name, arg, _ := strings.Cut(part, "=")
+		if !knownRules[name] {
+			return errors.New("validate: " + sf.Name + " has unknown rule " + strconv.Quote(name))
+		}
+		if err := checkSetting(sf, arg); err != nil {
+			return err
+		}
+		switch name {
+		case "enum":
+			if arg == "" {
+				return errors.New("validate: " + sf.Name + " has an enum rule without values")
+			}
+		case "min", "max":
+			if _, err := strconv.ParseInt(expand(arg), 10, 64); err != nil {
+				return errors.New("validate: " + sf.Name + " has a " + name + " rule that isn't an integer: " + strconv.Quote(arg))
+			}
+		}
+	}
+	return nil
+}
+
+// checkSetting checks that a $name tag value refers to a setting
+func checkSetting(sf reflect.StructField, value string) error {
+	if name, ok := strings.CutPrefix(value, "$"); ok {
+		if _, ok := tagSettings[name]; !ok {
+			return errors.New("validate: " + sf.Name + " refers to unknown setting " + strconv.Quote(value))
+		}
+	}
+	return nil
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// parsesText reports whether setText parses values of type t
+func parsesText(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == uuidType || t == timeType || reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Int, reflect.Int32, reflect.Int64, reflect.Bool:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,248 @@
+package validate
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/winfr1th/mock-interview/internal/utils"
+)
+
+// bind runs Bind on a request for dst and returns the response and the field errors it reports,
+// each as code@name
+func bind(t *testing.T, dst interface{}, target, body string, vars map[string]string) (*httptest.ResponseRecorder, []string) {
+	t.Helper()
+	r := httptest.NewRequest("POST", target, strings.NewReader(body))
+	r = mux.SetURLVars(r, vars)
+	rec := httptest.NewRecorder()
+	ok := Bind(rec, r, dst)
+	if ok != (rec.Body.Len() == 0) {
+		t.Fatalf("Bind = %v with response %d %s", ok, rec.Code, rec.Body)
+	}
+	if ok || rec.Code != http.StatusBadRequest {
+		return rec, nil
+	}
+
+	var response struct {
+		Error struct {
+			Details struct {
+				Errors []utils.FieldError `json:"errors"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, fe := range response.Error.Details.Errors {
+		got = append(got, fe.Code+"@"+fe.Pointer+fe.Parameter)
+	}
+	return rec, got
+}
+
+type paramSchema struct {
+	Pagination
+	ID      uuid.UUID `path:"id" code:"INVALID_ID"`
+	Country *string   `query:"country" validate:"iso3166"`
+	Sort    string    `query:"sort" default:"-year" validate:"enum=year|-year"`
+	Hidden  bool      `query:"hidden"`
+}
+
+func TestBindParams(t *testing.T) {
+	id := "d9428888-122b-11e1-b85c-61cd3cbb3210"
+	tests := []struct {
+		name, query, id string
+		want            []string
+	}{
+		{"defaults", "", id, nil},
+		{"given", "?page=2&page_size=100&country=gb&sort=year&hidden=true", id, nil},
+		{"bad ID", "", "nope", []string{"INVALID_ID@id"}},
+		{"page below min", "?page=0", id, []string{"INVALID_PAGE@page"}},
+		{"page not a number", "?page=x", id, []string{"INVALID_PAGE@page"}},
+		{"page size above max", "?page_size=101", id, []string{"PAGE_SIZE_TOO_LARGE@page_size"}},
+		{"country", "?country=GBR", id, []string{"INVALID_COUNTRY_CODE@country"}},
+		{"enum", "?sort=title", id, []string{"INVALID_PARAMETER@sort"}},
+		{"bool", "?hidden=maybe", id, []string{"INVALID_PARAMETER@hidden"}},
+		{"every invalid field", "?page=0&sort=title", "nope", []string{"INVALID_PAGE@page", "INVALID_ID@id", "INVALID_PARAMETER@sort"}},
+	}
+	for _, tt := range tests {
+		var req paramSchema
+		_, got := bind(t, &req, "/"+tt.query, "", map[string]string{"id": tt.id})
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: field errors %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	var req paramSchema
+	bind(t, &req, "/", "", map[string]string{"id": id})
+	if req.Page != 1 || req.PageSize != DefaultPageSize || req.Sort != "-year" || req.Country != nil || req.ID.String() != id {
+		t.Errorf("defaults bound as %+v", req)
+	}
+	req = paramSchema{}
+	bind(t, &req, "/?page=2&country=%20gb%20&hidden=true", "", map[string]string{"id": id})
+	if req.Page != 2 || req.Country == nil || *req.Country != "GB" || !req.Hidden {
+		t.Errorf("given values bound as %+v", req)
+	}
+}
+
+type testBody struct {
+	Name   string  `json:"name" validate:"required,trim,max=5"`
+	Ref    string  `json:"ref" validate:"uuid"`
+	Count  *int    `json:"count" validate:"min=1,max=10" code:"INVALID_COUNT,max=COUNT_TOO_LARGE"`
+	Note   *string `json:"note" validate:"nonempty"`
+	Secret string  `json:"-"`
+}
+
+type strictBodySchema struct {
+	Body testBody `body:"strict"`
+}
+
+type bodySchema struct {
+	Body testBody `body:""`
+}
+
+func TestBindBody(t *testing.T) {
+	tests := []struct {
+		name, body string
+		want       []string
+	}{
+		{"valid", `{"name": " Ada ", "ref": "d9428888-122b-11e1-b85c-61cd3cbb3210", "count": 10, "note": "hi"}`, nil},
+		{"required", `{}`, []string{"MISSING_FIELDS@#/name"}},
+		{"required null", `{"name": null}`, []string{"MISSING_FIELDS@#/name"}},
+		{"required after trim", `{"name": "   "}`, []string{"MISSING_FIELDS@#/name"}},
+		{"string max", `{"name": "Adaline"}`, []string{"INVALID_FIELD@#/name"}},
+		{"wrong type", `{"name": 42}`, []string{"INVALID_FIELD@#/name"}},
+		{"uuid", `{"name": "Ada", "ref": "x"}`, []string{"INVALID_FIELD@#/ref"}},
+		{"min", `{"name": "Ada", "count": 0}`, []string{"INVALID_COUNT@#/count"}},
+		{"max with its own code", `{"name": "Ada", "count": 11}`, []string{"COUNT_TOO_LARGE@#/count"}},
+		{"number of the wrong type", `{"name": "Ada", "count": "x"}`, []string{"INVALID_COUNT@#/count"}},
+		{"nonempty", `{"name": "Ada", "note": ""}`, []string{"MISSING_FIELDS@#/note"}},
+		{"unknown fields, sorted", `{"name": "Ada", "zeta": 1, "alpha": 2}`, []string{"UNKNOWN_FIELD@#/alpha", "UNKNOWN_FIELD@#/zeta"}},
+		{"ignored field", `{"name": "Ada", "Secret": "x"}`, []string{"UNKNOWN_FIELD@#/Secret"}},
+		{"every invalid field", `{"ref": "x", "count": 0, "extra": true}`,
+			[]string{"MISSING_FIELDS@#/name", "INVALID_FIELD@#/ref", "INVALID_COUNT@#/count", "UNKNOWN_FIELD@#/extra"}},
+	}
+	for _, tt := range tests {
+		var req strictBodySchema
+		_, got := bind(t, &req, "/", tt.body, nil)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: field errors %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	var req strictBodySchema
+	bind(t, &req, "/", `{"name": " Ada ", "count": 3}`, nil)
+	if req.Body.Name != "Ada" || req.Body.Count == nil || *req.Body.Count != 3 || req.Body.Note != nil {
+		t.Errorf("body bound as %+v", req.Body)
+	}
+
+	// Without strict, unknown members are ignored
+	var lenient bodySchema
+	if _, got := bind(t, &lenient, "/", `{"name": "Ada", "extra": true}`, nil); got != nil {
+		t.Errorf("lenient body: field errors %v", got)
+	}
+}
+
+func TestBindRejectsBodiesThatArentObjectsOrAreTooLarge(t *testing.T) {
+	for _, body := range []string{"", "null", "[]", `"name"`, `{"name": "Ada"`} {
+		var req strictBodySchema
+		rec, _ := bind(t, &req, "/", body, nil)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), CodeInvalidRequest) {
+			t.Errorf("body %q: %d %s, want 400 %s", body, rec.Code, rec.Body, CodeInvalidRequest)
+		}
+	}
+
+	var req strictBodySchema
+	large := `{"name": "` + strings.Repeat("a", MaxBodyBytes) + `"}`
+	rec, _ := bind(t, &req, "/", large, nil)
+	if rec.Code != http.StatusRequestEntityTooLarge || !strings.Contains(rec.Body.String(), CodeRequestTooLarge) {
+		t.Errorf("large body: %d %s, want 413 %s", rec.Code, rec.Body, CodeRequestTooLarge)
+	}
+}
+
+type checkedSchema struct {
+	Body struct {
+		From int `json:"from" validate:"min=0"`
+		To   int `json:"to"`
+	} `body:"strict"`
+}
+
+func (req *checkedSchema) Check(v *Validator) {
+	v.Check(req.Body.From <= req.Body.To, Field("to"), "INVALID_RANGE", "to must not be before from")
+}
+
+func TestBindRunsCheckAfterTheTags(t *testing.T) {
+	var req checkedSchema
+	_, got := bind(t, &req, "/", `{"from": -1, "to": -2}`, nil)
+	if want := []string{"INVALID_FIELD@#/from", "INVALID_RANGE@#/to"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("field errors %v, want %v", got, want)
+	}
+}
+
+func TestCheckSchema(t *testing.T) {
+	for _, schema := range []interface{}{paramSchema{}, &strictBodySchema{}, bodySchema{}, checkedSchema{}} {
+		if err := CheckSchema(reflect.TypeOf(schema)); err != nil {
+			t.Errorf("CheckSchema(%T) = %v", schema, err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		schema interface{}
+	}{
+		{"not a struct", ""},
+		{"unknown rule", struct {
+			Page int `query:"page" validate:"requird"`
+		}{}},
+		{"bound not an integer", struct {
+			Page int `query:"page" validate:"min=one"`
+		}{}},
+		{"unknown setting in a rule", struct {
+			Page int `query:"page" validate:"max=$largest_page"`
+		}{}},
+		{"unknown setting in a default", struct {
+			Page int `query:"page" default:"$first_page"`
+		}{}},
+		{"enum without values", struct {
+			Sort string `query:"sort" validate:"enum"`
+		}{}},
+		{"unsupported parameter type", struct {
+			IDs []string `query:"ids"`
+		}{}},
+		{"body not a struct", struct {
+			Body map[string]string `body:"strict"`
+		}{}},
+		{"body member rule", struct {
+			Body struct {
+				Name string `json:"name" validate:"required,nonemtpy"`
+			} `body:"strict"`
+		}{}},
+		{"embedded struct", struct {
+			BadPagination
+		}{}},
+	}
+	for _, tt := range tests {
+		if err := CheckSchema(reflect.TypeOf(tt.schema)); err == nil {
+			t.Errorf("%s: CheckSchema accepted %T", tt.name, tt.schema)
+		}
+	}
+}
+
+// BadPagination is embedded like Pagination, with a bound that's missing its value
+type BadPagination struct {
+	Page int `query:"page" validate:"min="`
+}
+
+func TestBindSkipsBoundsCheckSchemaReports(t *testing.T) {
+	var req struct {
+		Page int `query:"page" validate:"min=one"`
+	}
+	if _, got := bind(t, &req, "/?page=-5", "", nil); got != nil || req.Page != -5 {
+		t.Errorf("field errors %v with page %d, want the rule skipped", got, req.Page)
+	}
+}
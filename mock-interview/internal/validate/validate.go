@@ -0,0 +1,131 @@
+// Package validate checks request input and collects a field error for every invalid part,
+// so handlers can report all of them in one 400 response. Handlers declare each endpoint's
+// path, query and body in a tagged struct and read it with Bind.
+package validate
+
+import (
+	"net/http"
+
+	"github.com/winfr1th/mock-interview/internal/country"
+	model "github.com/winfr1th/mock-interview/internal/models"
+	"github.com/winfr1th/mock-interview/internal/utils"
+)
+
+// Error codes of field errors that aren't specific to one field
+const (
+	CodeMissingFields      = "MISSING_FIELDS"
+	CodeInvalidParameter   = "INVALID_PARAMETER"
+	CodeInvalidCountryCode = "INVALID_COUNTRY_CODE"
+	CodeInvalidDateOfBirth = "INVALID_DATE_OF_BIRTH"
+)
+
+// Location is where a value came from in the request
+type Location struct {
+	name  string
+	field bool
+}
+
+// Field locates a body field by its JSON name
+func Field(name string) Location {
+	return Location{name: name, field: true}
+}
+
+// Param locates a query or path parameter by name
+func Param(name string) Location {
+	return Location{name: name}
+}
+
+// Name returns the field or parameter name
+func (l Location) Name() string {
+	return l.name
+}
+
+func (l Location) fieldError(code, detail string) utils.FieldError {
+	if l.field {
+		return utils.FieldError{Pointer: "#/" + l.name, Code: code, Detail: detail}
+	}
+	return utils.FieldError{Parameter: l.name, Code: code, Detail: detail}
+}
+
+// Validator collects field errors. The zero value is ready to use.
+type Validator struct {
+	errors []utils.FieldError
+}
+
+// Add records a field error
+func (v *Validator) Add(at Location, code, detail string) {
+	v.errors = append(v.errors, at.fieldError(code, detail))
+}
+
+// Check records a field error unless ok, and returns ok
+func (v *Validator) Check(ok bool, at Location, code, detail string) bool {
+	if !ok {
+		v.Add(at, code, detail)
+	}
+	return ok
+}
+
+// Country normalizes an ISO-3166-1 alpha-2 country code
+func (v *Validator) Country(at Location, value string) string {
+	code, err := country.Normalize(value)
+	if err != nil {
+		v.Add(at, CodeInvalidCountryCode, invalidCountryDetail(at))
+		return ""
+	}
+	return code
+}
+
+func invalidCountryDetail(at Location) string {
+	return "Invalid " + at.name + ": must be ISO-3166-1 alpha-2 format (2 characters)"
+}
+
+// DateOfBirth parses a date of birth
+func (v *Validator) DateOfBirth(at Location, value string) model.Date {
+	dateOfBirth, err := model.ParseDateOfBirth(value)
+	if err != nil {
+		v.Add(at, CodeInvalidDateOfBirth, err.Error())
+		return model.Date{}
+	}
+	return dateOfBirth
+}
+
+// OneOf checks that value is one of allowed, recording code when it isn't
+func (v *Validator) OneOf(at Location, value, code string, allowed ...string) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+	detail := "Invalid " + at.name + ": must be "
+	for i, a := range allowed {
+		switch {
+		case i == 0:
+		case i == len(allowed)-1:
+			detail += " or "
+		default:
+			detail += ", "
+		}
+		detail += "'" + a + "'"
+	}
+	v.Add(at, code, detail)
+	return false
+}
+
+// Valid reports whether no field error was recorded
+func (v *Validator) Valid() bool {
+	return len(v.errors) == 0
+}
+
+// Errors returns the recorded field errors
+func (v *Validator) Errors() []utils.FieldError {
+	return v.errors
+}
+
+// Failed writes a 400 with the recorded field errors and reports true when there are any
+func (v *Validator) Failed(w http.ResponseWriter) bool {
+	if v.Valid() {
+		return false
+	}
+	utils.WriteValidationError(w, v.errors)
+	return true
+}
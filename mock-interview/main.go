@@ -2,70 +2,264 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
-	"net/http"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
-	"github.com/gorilla/mux"
-	"github.com/winfr1th/mock-interview/internal/database"
+	"github.com/winfr1th/mock-interview/internal/audit"
+	"github.com/winfr1th/mock-interview/internal/auth"
+	"github.com/winfr1th/mock-interview/internal/auth/oidc"
+	"github.com/winfr1th/mock-interview/internal/config"
+	"github.com/winfr1th/mock-interview/internal/country"
 	"github.com/winfr1th/mock-interview/internal/handler"
+	"github.com/winfr1th/mock-interview/internal/lockout"
+	"github.com/winfr1th/mock-interview/internal/logging"
 	"github.com/winfr1th/mock-interview/internal/middleware"
+	"github.com/winfr1th/mock-interview/internal/ratelimit"
 	"github.com/winfr1th/mock-interview/internal/repository"
+	"github.com/winfr1th/mock-interview/internal/server"
+	"github.com/winfr1th/mock-interview/internal/tracing"
+	"github.com/winfr1th/mock-interview/internal/validate"
 )
 
 func main() {
+	// "config print" shows the effective configuration instead of serving
+	if len(os.Args) > 2 && os.Args[1] == "config" && os.Args[2] == "print" {
+		printConfig(os.Args[3:])
+		return
+	}
+
+	cfg := loadConfig(os.Args[0], os.Args[1:])
+
 	// Create context
 	ctx := context.Background()
+	startedAt := time.Now()
 
-	// Establish database connection
-	db, err := database.NewConnection(ctx)
+	// JSON logs on stdout. The log package writes through the same handler, so its output
+	// is JSON with credentials redacted too.
+	slog.SetDefault(logging.New(os.Stdout, cfg.Log.Level))
+
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing.Exporter)
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+
+	// Connect to the database DATABASE_URL's scheme selects
+	store, err := openStorage(ctx, cfg.Database)
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
-	defer database.CloseConnection(db)
+	db := store.db
 
 	// Initialize repositories
 	userRepo := repository.NewUserRepository(db)
 	genreRepo := repository.NewGenreRepository(db)
 	movieRepo := repository.NewMovieRepository(db)
 	saveMoviesRepo := repository.NewSaveMoviesRepository(db)
+	// Every authenticated request resolves a profile, so profiles are cached per household.
+	// The auth middleware and the profile handlers share the cache, so edits drop it at once.
+	profileRepo := repository.NewCachedProfileRepository(repository.NewProfileRepository(db), repository.DefaultProfileCacheTTL)
+	auditRepo := repository.NewAuditRepository(db)
+	identityRepo := repository.NewIdentityRepository(db)
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db)
 
-	// Setup router
-	router := mux.NewRouter()
+	// Security audit log
+	auditRecorder := audit.NewRecorder(auditRepo)
 
-	// Public endpoints (no auth required)
-	router.HandleFunc("/register", handler.Register(userRepo)).Methods("POST")
-	router.HandleFunc("/genres", handler.ListGenres(genreRepo)).Methods("GET")
-	router.HandleFunc("/movies", handler.ListMovies(movieRepo)).Methods("GET")
+	// Age gating for certified content
+	agePolicy := handler.AgePolicy{AnonymousAge: cfg.Content.AnonymousViewerAge}
 
-	// Protected endpoints - require API key authentication
-	protectedRouter := router.PathPrefix("").Subrouter()
-	protectedRouter.Use(middleware.APIKeyAuth(userRepo))
+	// Country resolution for endpoints that need a country
+	var geoIP country.GeoIPLookup
+	var geoIPDB *country.MaxMindDB
+	if path := cfg.Content.GeoIPDBPath; path != "" {
+		geoIPDB, err = country.OpenMaxMindDB(path)
+		if err != nil {
+			log.Fatalf("Failed to open GeoIP database: %v", err)
+		}
+		geoIP = geoIPDB
+	}
+	countryResolver := country.NewResolver(cfg.Content.CountryHeader, geoIP)
 
-	// User endpoints
-	protectedRouter.HandleFunc("/users", handler.CreateUser(userRepo)).Methods("POST")
-	protectedRouter.HandleFunc("/users/{id}", handler.GetUserByID(userRepo)).Methods("GET")
+	// Rate limits: public endpoints per client IP, protected endpoints per client IP and then
+	// per user, with the limit of the user's plan
+	rateLimitStore := ratelimit.NewMemoryStore()
+	ipScope := ratelimit.Scope{Name: "ip", Limit: cfg.RateLimit.IP()}
+	clientIPScope := ratelimit.Scope{Name: "client_ip", Limit: cfg.RateLimit.ClientIP()}
+	userScope := ratelimit.Scope{Name: "user", Limit: cfg.RateLimit.User(), Plans: cfg.RateLimit.Plans}
 
-	// Saved movies endpoints
-	protectedRouter.HandleFunc("/users/{user_id}/movies", handler.ListSavedMovies(saveMoviesRepo, movieRepo)).Methods("GET")
-	protectedRouter.HandleFunc("/users/{user_id}/movies", handler.SaveMovie(saveMoviesRepo, movieRepo)).Methods("POST")
-	protectedRouter.HandleFunc("/users/{user_id}/movies/{movie_id}", handler.RemoveSavedMovie(saveMoviesRepo)).Methods("DELETE")
+	// Page sizes apply to binding and the OpenAPI document, so they're set before the routes
+	validate.SetPageSizes(cfg.Pagination.DefaultPageSize, cfg.Pagination.MaxPageSize)
 
-	// Start server
-	log.Println("Server starting on :8080")
+	// Legacy UUID API keys are accepted until the configured day, or indefinitely when unset
+	legacyKeys := auth.LegacyKeyPolicy{Until: cfg.Auth.LegacyAPIKeysUntil.Time}
 
-	// Graceful shutdown
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	// Failed authentication throttling
+	authTracker := lockout.NewTracker(lockout.DefaultConfig, auditRecorder)
 
-	go func() {
-		if err := http.ListenAndServe(":8080", router); err != nil {
-			log.Fatalf("Server failed to start: %v", err)
+	// Without a signing key, a random one is used and tokens stop working when the server restarts
+	signingKey := []byte(cfg.Auth.TokenSigningKey)
+	if len(signingKey) == 0 {
+		slog.Warn("TOKEN_SIGNING_KEY not set, using a random key")
+		signingKey = make([]byte, auth.MinTokenSigningKeyLength)
+		if _, err := rand.Read(signingKey); err != nil {
+			log.Fatalf("Failed to generate token signing key: %v", err)
 		}
-	}()
+	}
+	tokens, err := auth.NewTokens(signingKey, cfg.Auth.AccessTokenTTL)
+	if err != nil {
+		log.Fatalf("Invalid TOKEN_SIGNING_KEY: %v", err)
+	}
+	sessions := handler.Sessions{
+		Tokens:      tokens,
+		Refresh:     refreshTokenRepo,
+		Revocations: auth.NewKeyRevocations(tokens.TTL()),
+		RefreshTTL:  cfg.Auth.RefreshTokenTTL,
+	}
+
+	// OIDC login is enabled when an issuer is configured
+	var oidcProvider *oidc.Provider
+	if cfg.OIDC.IssuerURL != "" {
+		oidcProvider, err = oidc.NewProvider(ctx, oidc.Config{
+			IssuerURL:    cfg.OIDC.IssuerURL,
+			ClientID:     cfg.OIDC.ClientID,
+			ClientSecret: string(cfg.OIDC.ClientSecret),
+			RedirectURL:  cfg.OIDC.RedirectURL,
+			Scopes:       cfg.OIDC.Scopes,
+		})
+		if err != nil {
+			log.Fatalf("Failed to discover OIDC provider: %v", err)
+		}
+	}
+
+	// Requests authenticate with a bearer access token, a request signature or an API key
+	authenticators := []middleware.Authenticator{
+		middleware.AccessTokenAuthenticator{Tokens: tokens, Revocations: sessions.Revocations},
+	}
+	// Signing secrets are derived with REQUEST_SIGNING_KEY, so signed requests need it
+	var signingSecrets *auth.SigningSecrets
+	if cfg.Features.SignedRequests && cfg.Auth.RequestSigningKey == "" {
+		slog.Warn("REQUEST_SIGNING_KEY not set, signed requests are disabled")
+	} else if cfg.Features.SignedRequests {
+		signingSecrets, err = auth.NewSigningSecrets([]byte(cfg.Auth.RequestSigningKey))
+		if err != nil {
+			log.Fatalf("Invalid REQUEST_SIGNING_KEY: %v", err)
+		}
+		maxSkew := cfg.Auth.SignatureMaxSkew
+		authenticators = append(authenticators, middleware.SignatureAuthenticator{
+			Users: userRepo, Secrets: signingSecrets, Nonces: auth.NewNonceCache(maxSkew), MaxSkew: maxSkew,
+		})
+	}
+	authenticators = append(authenticators, middleware.APIKeyAuthenticator{Users: userRepo, Legacy: legacyKeys})
+	authMiddleware := middleware.NewAuth(profileRepo, authTracker, authenticators...)
 
-	<-sigChan
-	log.Println("Shutting down server...")
+	// The sunset is only announced, in the Deprecation, Sunset and Link headers of unversioned
+	// responses; the paths keep working after it until a release removes them
+	unversionedSunset := defaultUnversionedSunset
+	if !cfg.HTTP.UnversionedSunset.IsZero() {
+		unversionedSunset = cfg.HTTP.UnversionedSunset.Time
+	}
+
+	workers := server.NewWorkers()
+	workers.Go(auditRecorder.Run)
+
+	srv := &server.Server{
+		Addr:     cfg.HTTP.Addr,
+		Timeouts: cfg.HTTP.Timeouts(),
+		Workers:  workers,
+		// The database is closed last, once nothing can use it
+		Closers: []func(){
+			func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				if err := shutdownTracing(ctx); err != nil {
+					slog.Error("Failed to flush traces", "error", err)
+				}
+			},
+			func() {
+				if geoIPDB != nil {
+					geoIPDB.Close()
+				}
+			},
+			store.close,
+		},
+	}
+
+	// Setup router
+	router, err := newRouter(routerDeps{
+		transactor:        db,
+		userRepo:          userRepo,
+		genreRepo:         genreRepo,
+		movieRepo:         movieRepo,
+		saveMoviesRepo:    saveMoviesRepo,
+		profileRepo:       profileRepo,
+		auditRepo:         auditRepo,
+		identityRepo:      identityRepo,
+		auditRecorder:     auditRecorder,
+		agePolicy:         agePolicy,
+		countryResolver:   countryResolver,
+		rateLimitStore:    rateLimitStore,
+		ipScope:           ipScope,
+		clientIPScope:     clientIPScope,
+		userScope:         userScope,
+		authTracker:       authTracker,
+		sessions:          sessions,
+		signingSecrets:    signingSecrets,
+		oidcProvider:      oidcProvider,
+		authMiddleware:    authMiddleware,
+		trustProxyHeaders: cfg.HTTP.TrustProxyHeaders,
+		requestTimeout:    cfg.HTTP.RequestTimeout,
+		unversionedSunset: unversionedSunset,
+		apiDocs:           cfg.Features.APIDocs,
+		readiness:         readinessChecks(store, srv),
+		pool:              store.pool,
+		startedAt:         startedAt,
+	})
+	if err != nil {
+		log.Fatalf("Failed to set up routes: %v", err)
+	}
+	srv.Handler = router
+	// Preflight requests match no route, so CORS wraps the router
+	if len(cfg.CORS.AllowedOrigins) > 0 {
+		srv.Handler = middleware.CORS(cfg.CORS.Policy())(router)
+	}
+
+	// Start server; SIGINT or SIGTERM shuts it down gracefully
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	slog.Info("Server starting", "addr", srv.Addr)
+	if err := srv.ListenAndServe(ctx); err != nil {
+		log.Fatalf("Server failed: %v", err)
+	}
+	slog.Info("Server stopped")
+}
+
+// printConfig writes the configuration loaded with args as YAML, with secrets redacted
+func printConfig(args []string) {
+	cfg := loadConfig(os.Args[0]+" config print", args)
+	if err := config.Write(os.Stdout, cfg); err != nil {
+		log.Fatalf("Failed to print configuration: %v", err)
+	}
+}
+
+// loadConfig loads the configuration, or exits with the usage for -h and the errors otherwise
+func loadConfig(name string, args []string) config.Config {
+	cfg, err := config.Load(name, args, os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Print(config.Usage(name))
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration:\n%v\n", err)
+		os.Exit(2)
+	}
+	return cfg
 }
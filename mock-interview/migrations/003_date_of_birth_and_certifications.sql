@@ -0,0 +1,30 @@
+-- Store users.date_of_birth as a real DATE instead of free-form TEXT
+-- Existing values must already be ISO-8601 (YYYY-MM-DD); the cast fails loudly otherwise
+ALTER TABLE users
+    ALTER COLUMN date_of_birth TYPE DATE USING date_of_birth::DATE;
+
+-- Create certifications table based on Certification model
+-- Model fields: CountryCode (string), Code (string), MinAge (int)
+-- One row per rating a country's board can issue, e.g. ('US', 'PG-13', 13) or ('GB', '15', 15)
+CREATE TABLE IF NOT EXISTS certifications (
+    country_code TEXT NOT NULL,
+    code TEXT NOT NULL,
+    min_age INTEGER NOT NULL CHECK (min_age >= 0),
+    PRIMARY KEY (country_code, code),
+    FOREIGN KEY (country_code) REFERENCES countries(code) ON DELETE CASCADE
+);
+
+-- Create movie_certifications table based on MovieCertification model
+-- Junction table: MovieID (uuid.UUID), CountryCode (string), Certification (string)
+-- A movie has at most one certification per country; movies without one are unrestricted there
+CREATE TABLE IF NOT EXISTS movie_certifications (
+    movie_id UUID NOT NULL,
+    country_code TEXT NOT NULL,
+    certification TEXT NOT NULL,
+    PRIMARY KEY (movie_id, country_code),
+    FOREIGN KEY (movie_id) REFERENCES movies(id) ON DELETE CASCADE,
+    FOREIGN KEY (country_code, certification) REFERENCES certifications(country_code, code) ON DELETE CASCADE
+);
+
+-- Index on movie_certifications.country_code for age filtering by country
+CREATE INDEX IF NOT EXISTS idx_movie_certifications_country_code ON movie_certifications(country_code);
@@ -0,0 +1,49 @@
+-- Create households table based on Household model
+-- Model fields: ID (uuid.UUID), Name (string), CreatedAt (time.Time)
+CREATE TABLE IF NOT EXISTS households (
+    id UUID PRIMARY KEY,
+    name TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create profiles table based on Profile model
+-- Model fields: ID (uuid.UUID), HouseholdID (uuid.UUID), Name (string), MaxAge (*int), PINHash (string), IsDefault (bool)
+CREATE TABLE IF NOT EXISTS profiles (
+    id UUID PRIMARY KEY,
+    household_id UUID NOT NULL,
+    name TEXT NOT NULL,
+    max_age INTEGER CHECK (max_age >= 0),
+    pin_hash TEXT,
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    FOREIGN KEY (household_id) REFERENCES households(id) ON DELETE CASCADE
+);
+
+-- Index on profiles.household_id for listing a household's profiles
+CREATE INDEX IF NOT EXISTS idx_profiles_household_id ON profiles(household_id);
+
+-- Each household has exactly one default profile
+CREATE UNIQUE INDEX IF NOT EXISTS idx_profiles_household_default ON profiles(household_id) WHERE is_default;
+
+-- Every login belongs to a household
+ALTER TABLE users ADD COLUMN IF NOT EXISTS household_id UUID REFERENCES households(id) ON DELETE CASCADE;
+
+-- Backfill: one household per existing user, with a default profile that reuses the user's ID
+-- so saved movies keyed by user_id carry over unchanged
+INSERT INTO households (id, name)
+SELECT id, name FROM users
+ON CONFLICT (id) DO NOTHING;
+
+UPDATE users SET household_id = id WHERE household_id IS NULL;
+
+ALTER TABLE users ALTER COLUMN household_id SET NOT NULL;
+
+INSERT INTO profiles (id, household_id, name, is_default)
+SELECT id, household_id, name, TRUE FROM users
+ON CONFLICT (id) DO NOTHING;
+
+-- Saved movies now belong to a profile instead of a user
+ALTER TABLE save_movies DROP CONSTRAINT IF EXISTS save_movies_user_id_fkey;
+ALTER TABLE save_movies RENAME COLUMN user_id TO profile_id;
+ALTER TABLE save_movies ADD CONSTRAINT save_movies_profile_id_fkey
+    FOREIGN KEY (profile_id) REFERENCES profiles(id) ON DELETE CASCADE;
+ALTER INDEX IF EXISTS idx_save_movies_user_id RENAME TO idx_save_movies_profile_id;
@@ -0,0 +1,3 @@
+-- Add home_country to users based on User model
+-- Model field: HomeCountry (*string), ISO-3166-1 alpha-2, used when a request has no ?country=
+ALTER TABLE users ADD COLUMN IF NOT EXISTS home_country TEXT REFERENCES countries(code) ON DELETE SET NULL;
@@ -0,0 +1,3 @@
+-- Add is_admin to users based on User model
+-- Model field: IsAdmin (bool), grants access to /admin endpoints
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_admin BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,42 @@
+-- Create audit_events table based on AuditEvent model
+-- Model fields: ID (int64), OccurredAt (time.Time), EventType (string), ActorID (*uuid.UUID),
+-- TargetType (string), TargetID (string), IP (string), UserAgent (string), RequestID (string),
+-- Metadata (map[string]string), PrevHash (string), Hash (string)
+-- actor_id has no foreign key: events must outlive the users they mention
+CREATE TABLE IF NOT EXISTS audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    occurred_at TIMESTAMPTZ NOT NULL,
+    event_type TEXT NOT NULL,
+    actor_id UUID,
+    target_type TEXT NOT NULL DEFAULT '',
+    target_id TEXT NOT NULL DEFAULT '',
+    ip TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    request_id TEXT NOT NULL DEFAULT '',
+    metadata JSONB NOT NULL DEFAULT '{}',
+    prev_hash TEXT NOT NULL,
+    hash TEXT NOT NULL UNIQUE
+);
+
+-- Indexes for the /admin/audit filters
+CREATE INDEX IF NOT EXISTS idx_audit_events_event_type ON audit_events(event_type);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor_id ON audit_events(actor_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_target_id ON audit_events(target_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_occurred_at ON audit_events(occurred_at);
+
+-- Make the table append-only
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_events_no_update_delete ON audit_events;
+CREATE TRIGGER audit_events_no_update_delete
+    BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+
+DROP TRIGGER IF EXISTS audit_events_no_truncate ON audit_events;
+CREATE TRIGGER audit_events_no_truncate
+    BEFORE TRUNCATE ON audit_events
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only();
@@ -0,0 +1,5 @@
+-- Add api_key_id to users based on User model
+-- Model field: APIKeyID (string), the mvk_<env>_<id> part of a prefixed key, used to find its row
+-- Prefixed keys store their SHA-256 in api_key_hash; legacy UUID keys keep the plain key
+-- there with a NULL api_key_id until they are rotated
+ALTER TABLE users ADD COLUMN IF NOT EXISTS api_key_id TEXT UNIQUE;
@@ -0,0 +1,16 @@
+-- Create user_identities table based on UserIdentity model
+-- Model fields: Issuer (string), Subject (string), UserID (uuid.UUID), CreatedAt (time.Time)
+-- An external OIDC subject is unique per issuer and belongs to one user
+CREATE TABLE IF NOT EXISTS user_identities (
+    issuer TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (issuer, subject)
+);
+
+-- Index on user_identities.user_id for finding a user's linked identities
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities(user_id);
+
+-- Users signing in with OIDC may not share a date of birth; they are treated as anonymous viewers for age gating
+ALTER TABLE users ALTER COLUMN date_of_birth DROP NOT NULL;
@@ -0,0 +1,20 @@
+-- Create refresh_tokens table based on RefreshToken model
+-- Model fields: ID (uuid.UUID), UserID (uuid.UUID), FamilyID (uuid.UUID), TokenHash (string), AuthMethod (string),
+-- APIKeyID (string), CreatedAt (time.Time), ExpiresAt (time.Time), UsedAt (*time.Time), RevokedAt (*time.Time)
+-- Only the SHA-256 of each token is stored. A token is used once; its replacement shares its family_id
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    family_id UUID NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    auth_method TEXT NOT NULL,
+    api_key_id TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ
+);
+
+-- Indexes for revoking a family on reuse and every session of a rotated API key
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens(family_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_api_key_id ON refresh_tokens(api_key_id);
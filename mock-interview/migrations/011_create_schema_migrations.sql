@@ -0,0 +1,11 @@
+-- Create schema_migrations table recording which migrations have been applied
+-- Every migration from this one on ends by inserting its own version. Running this one means
+-- 001 to 010 ran before it, so they are recorded too
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INTEGER PRIMARY KEY,
+    applied_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO schema_migrations (version)
+SELECT generate_series(1, 11)
+ON CONFLICT (version) DO NOTHING;
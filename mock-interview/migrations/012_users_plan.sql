@@ -0,0 +1,5 @@
+-- Add plan to users based on User model
+-- Model field: Plan (string), the rate limit plan; NULL gets the default per-user limits
+ALTER TABLE users ADD COLUMN IF NOT EXISTS plan TEXT;
+
+INSERT INTO schema_migrations (version) VALUES (12) ON CONFLICT (version) DO NOTHING;
@@ -0,0 +1,9 @@
+-- Hash legacy UUID keys, which were stored in plain text in api_key_hash
+-- Their rows keep a NULL api_key_id and are found by the key's SHA-256 hex, as prefixed keys are.
+-- Hashing again would break every legacy key, so the update only runs until 13 is recorded.
+UPDATE users
+SET api_key_hash = encode(sha256(convert_to(api_key_hash, 'UTF8')), 'hex')
+WHERE api_key_id IS NULL AND api_key_hash IS NOT NULL
+  AND NOT EXISTS (SELECT 1 FROM schema_migrations WHERE version = 13);
+
+INSERT INTO schema_migrations (version) VALUES (13) ON CONFLICT (version) DO NOTHING;
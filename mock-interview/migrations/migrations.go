@@ -0,0 +1,73 @@
+// Package migrations embeds the SQL migrations so the server can tell whether the database
+// schema is up to date, and tests can build a schema from them. The SQLite backend's migrations,
+// in sqlite/, are numbered like the Postgres ones and are applied when it opens the database.
+package migrations
+
+import (
+	"embed"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed *.sql sqlite/*.sql
+var files embed.FS
+
+// Migration is one SQL migration file
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// All returns the Postgres migrations in the order they are applied
+func All() ([]Migration, error) {
+	return load(".")
+}
+
+// SQLite returns the SQLite migrations in the order they are applied
+func SQLite() ([]Migration, error) {
+	return load("sqlite")
+}
+
+func load(dir string) ([]Migration, error) {
+	names, err := fs.Glob(files, path.Join(dir, "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+	var all []Migration
+	for _, name := range names {
+		version, ok := versionOf(path.Base(name))
+		if !ok {
+			continue
+		}
+		sql, err := files.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, Migration{Version: version, Name: path.Base(name), SQL: string(sql)})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Version < all[j].Version })
+	return all, nil
+}
+
+// Latest returns the version of the newest migration, the number its file name starts with.
+// Both backends' migrations end at the same version.
+func Latest() int {
+	names, _ := fs.Glob(files, "*.sql")
+	latest := 0
+	for _, name := range names {
+		if version, ok := versionOf(name); ok && version > latest {
+			latest = version
+		}
+	}
+	return latest
+}
+
+func versionOf(name string) (int, bool) {
+	prefix, _, _ := strings.Cut(name, "_")
+	version, err := strconv.Atoi(prefix)
+	return version, err == nil
+}
@@ -0,0 +1,170 @@
+-- The schema of the Postgres migrations up to 011, for SQLite
+-- SQLite databases are new, so instead of replaying 001 to 011 this creates their end result and
+-- records them all. Later migrations are added to both directories under the same version.
+-- UUIDs are stored as text and timestamps as UTC text that sorts in time order
+CREATE TABLE IF NOT EXISTS countries (
+    code TEXT PRIMARY KEY,
+    name TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS genres (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS movies (
+    id TEXT PRIMARY KEY,
+    title TEXT NOT NULL,
+    year INTEGER NOT NULL,
+    genre_id TEXT NOT NULL,
+    FOREIGN KEY (genre_id) REFERENCES genres(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_movies_genre_id ON movies(genre_id);
+CREATE INDEX IF NOT EXISTS idx_movies_year ON movies(year);
+
+CREATE TABLE IF NOT EXISTS movie_availability (
+    movie_id TEXT NOT NULL,
+    country_code TEXT NOT NULL,
+    PRIMARY KEY (movie_id, country_code),
+    FOREIGN KEY (movie_id) REFERENCES movies(id) ON DELETE CASCADE,
+    FOREIGN KEY (country_code) REFERENCES countries(code) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_movie_availability_country_code ON movie_availability(country_code);
+
+CREATE TABLE IF NOT EXISTS certifications (
+    country_code TEXT NOT NULL,
+    code TEXT NOT NULL,
+    min_age INTEGER NOT NULL CHECK (min_age >= 0),
+    PRIMARY KEY (country_code, code),
+    FOREIGN KEY (country_code) REFERENCES countries(code) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS movie_certifications (
+    movie_id TEXT NOT NULL,
+    country_code TEXT NOT NULL,
+    certification TEXT NOT NULL,
+    PRIMARY KEY (movie_id, country_code),
+    FOREIGN KEY (movie_id) REFERENCES movies(id) ON DELETE CASCADE,
+    FOREIGN KEY (country_code, certification) REFERENCES certifications(country_code, code) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_movie_certifications_country_code ON movie_certifications(country_code);
+
+CREATE TABLE IF NOT EXISTS households (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS users (
+    id TEXT PRIMARY KEY,
+    household_id TEXT NOT NULL REFERENCES households(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    date_of_birth DATE,
+    home_country TEXT REFERENCES countries(code) ON DELETE SET NULL,
+    is_admin BOOLEAN NOT NULL DEFAULT FALSE,
+    api_key_id TEXT UNIQUE,
+    api_key_hash TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_users_api_key_hash ON users(api_key_hash);
+
+CREATE TABLE IF NOT EXISTS profiles (
+    id TEXT PRIMARY KEY,
+    household_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    max_age INTEGER CHECK (max_age >= 0),
+    pin_hash TEXT,
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    FOREIGN KEY (household_id) REFERENCES households(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_profiles_household_id ON profiles(household_id);
+
+-- Each household has exactly one default profile
+CREATE UNIQUE INDEX IF NOT EXISTS idx_profiles_household_default ON profiles(household_id) WHERE is_default;
+
+CREATE TABLE IF NOT EXISTS save_movies (
+    profile_id TEXT NOT NULL,
+    movie_id TEXT NOT NULL,
+    date_added TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    PRIMARY KEY (profile_id, movie_id),
+    FOREIGN KEY (profile_id) REFERENCES profiles(id) ON DELETE CASCADE,
+    FOREIGN KEY (movie_id) REFERENCES movies(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_save_movies_date_added ON save_movies(date_added);
+CREATE INDEX IF NOT EXISTS idx_save_movies_profile_id ON save_movies(profile_id);
+
+-- actor_id has no foreign key: events must outlive the users they mention
+CREATE TABLE IF NOT EXISTS audit_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    occurred_at TIMESTAMP NOT NULL,
+    event_type TEXT NOT NULL,
+    actor_id TEXT,
+    target_type TEXT NOT NULL DEFAULT '',
+    target_id TEXT NOT NULL DEFAULT '',
+    ip TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    request_id TEXT NOT NULL DEFAULT '',
+    metadata TEXT NOT NULL DEFAULT '{}',
+    prev_hash TEXT NOT NULL,
+    hash TEXT NOT NULL UNIQUE
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_event_type ON audit_events(event_type);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor_id ON audit_events(actor_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_target_id ON audit_events(target_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_occurred_at ON audit_events(occurred_at);
+
+-- Make the table append-only
+CREATE TRIGGER IF NOT EXISTS audit_events_no_update
+    BEFORE UPDATE ON audit_events
+BEGIN
+    SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS audit_events_no_delete
+    BEFORE DELETE ON audit_events
+BEGIN
+    SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
+
+CREATE TABLE IF NOT EXISTS user_identities (
+    issuer TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    PRIMARY KEY (issuer, subject)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities(user_id);
+
+-- Only the SHA-256 of each token is stored. A token is used once; its replacement shares its family_id
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    family_id TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    auth_method TEXT NOT NULL,
+    api_key_id TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens(family_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_api_key_id ON refresh_tokens(api_key_id);
+
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INTEGER PRIMARY KEY,
+    applied_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+WITH RECURSIVE versions(version) AS (SELECT 1 UNION ALL SELECT version + 1 FROM versions WHERE version < 11)
+INSERT INTO schema_migrations (version)
+SELECT version FROM versions WHERE true
+ON CONFLICT (version) DO NOTHING;
@@ -0,0 +1,4 @@
+-- Add plan to users, as Postgres migration 012 does
+ALTER TABLE users ADD COLUMN plan TEXT;
+
+INSERT INTO schema_migrations (version) VALUES (12);
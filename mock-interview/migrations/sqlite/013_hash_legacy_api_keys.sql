@@ -0,0 +1,8 @@
+-- Hash legacy UUID keys, as Postgres migration 013 does. sha256_hex is registered by the server.
+-- Hashing again would break every legacy key, so the update only runs until 13 is recorded.
+UPDATE users
+SET api_key_hash = sha256_hex(api_key_hash)
+WHERE api_key_id IS NULL AND api_key_hash IS NOT NULL
+  AND NOT EXISTS (SELECT 1 FROM schema_migrations WHERE version = 13);
+
+INSERT INTO schema_migrations (version) VALUES (13) ON CONFLICT (version) DO NOTHING;
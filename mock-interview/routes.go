@@ -0,0 +1,242 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/winfr1th/mock-interview/internal/audit"
+	"github.com/winfr1th/mock-interview/internal/auth"
+	"github.com/winfr1th/mock-interview/internal/auth/oidc"
+	"github.com/winfr1th/mock-interview/internal/country"
+	"github.com/winfr1th/mock-interview/internal/handler"
+	"github.com/winfr1th/mock-interview/internal/health"
+	"github.com/winfr1th/mock-interview/internal/lockout"
+	"github.com/winfr1th/mock-interview/internal/metrics"
+	"github.com/winfr1th/mock-interview/internal/middleware"
+	"github.com/winfr1th/mock-interview/internal/openapi"
+	"github.com/winfr1th/mock-interview/internal/ratelimit"
+	"github.com/winfr1th/mock-interview/internal/repository"
+	"github.com/winfr1th/mock-interview/internal/utils"
+)
+
+// apiInfo describes the API in the OpenAPI document
+var apiInfo = openapi.Info{
+	Title:       "Mock Interview API",
+	Version:     "1.0.0",
+	Description: "Users, household profiles, a movie catalog and saved movies.",
+}
+
+// unversionedDeprecatedAt is when the unversioned paths were deprecated in favour of /v1
+var unversionedDeprecatedAt = time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC)
+
+// defaultUnversionedSunset is the removal date announced for the unversioned paths unless UNVERSIONED_SUNSET says otherwise
+var defaultUnversionedSunset = unversionedDeprecatedAt.AddDate(0, 6, 0)
+
+// routerDeps is what the route handlers are built from
+type routerDeps struct {
+	transactor        repository.Transactor
+	userRepo          repository.UserRepository
+	genreRepo         repository.GenreRepository
+	movieRepo         repository.MovieRepository
+	saveMoviesRepo    repository.SaveMoviesRepository
+	profileRepo       repository.ProfileRepository
+	auditRepo         repository.AuditRepository
+	identityRepo      repository.IdentityRepository
+	auditRecorder     *audit.Recorder
+	agePolicy         handler.AgePolicy
+	countryResolver   *country.Resolver
+	rateLimitStore    ratelimit.Store
+	ipScope           ratelimit.Scope
+	clientIPScope     ratelimit.Scope // Protected endpoints before authentication
+	userScope         ratelimit.Scope // Protected endpoints after authentication
+	authTracker       *lockout.Tracker
+	sessions          handler.Sessions
+	signingSecrets    *auth.SigningSecrets // nil when signed requests are disabled
+	oidcProvider      *oidc.Provider       // nil when OIDC login is disabled
+	authMiddleware    *middleware.Auth
+	trustProxyHeaders bool          // Take the client IP from reverse proxy headers
+	requestTimeout    time.Duration // Deadline of each request's work; 0 for none
+	apiDocs           bool          // Serve /openapi.json and /docs
+	unversionedSunset time.Time     // Announced removal of the unversioned paths
+	readiness         []health.Check
+	pool              *pgxpool.Pool // For /status statistics; may be nil
+	startedAt         time.Time
+}
+
+// newRouter registers every route and builds the OpenAPI document served at /openapi.json.
+// It fails when a route has no entry in handler.Endpoints, or its request schema has tags
+// validate.Bind can't apply.
+func newRouter(d routerDeps) (*mux.Router, error) {
+	router := mux.NewRouter()
+
+	// Request IDs, and request spans, metrics and access logs by route template. Middleware
+	// only runs for matched routes, so the 404 and 405 handlers are instrumented separately.
+	instrumentation := []mux.MiddlewareFunc{middleware.RequestID, middleware.Tracing, middleware.Metrics, middleware.AccessLog}
+	router.Use(instrumentation...)
+	instrument := func(h http.Handler) http.Handler {
+		return chain(h, instrumentation...)
+	}
+	// Errors are problem details for clients that ask for application/problem+json, including
+	// those of the 404 and 405 handlers. Matching the catch-all subrouters of the API clears mux's
+	// method mismatch, so the 404 handler answers 405 itself when the path has other methods.
+	methodNotAllowed := func(w http.ResponseWriter, allowed []string) {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		utils.WriteErrorResponse(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed", nil)
+	}
+	router.NotFoundHandler = instrument(middleware.ProblemDetails(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if allowed := allowedMethods(router, r); len(allowed) > 0 {
+			methodNotAllowed(w, allowed)
+			return
+		}
+		utils.WriteErrorResponse(w, http.StatusNotFound, "NOT_FOUND", "Resource not found", nil)
+	})))
+	router.MethodNotAllowedHandler = instrument(middleware.ProblemDetails(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methodNotAllowed(w, allowedMethods(router, r))
+	})))
+	router.Use(middleware.ProblemDetails)
+
+	// Behind a reverse proxy, take the client IP from its headers
+	if d.trustProxyHeaders {
+		router.Use(middleware.RealIP)
+	}
+	router.Use(middleware.AuditRequestInfo)
+	if d.requestTimeout > 0 {
+		router.Use(middleware.RequestTimeout(d.requestTimeout))
+	}
+
+	// API documentation, not rate limited. The document is filled in once every route is registered.
+	spec := new(openapi.Document)
+	if d.apiDocs {
+		router.HandleFunc("/openapi.json", handler.OpenAPISpec(spec)).Methods("GET")
+		router.HandleFunc("/docs", handler.APIDocs()).Methods("GET")
+	}
+
+	// Probes and metrics, not rate limited so load balancers and scrapers are never throttled
+	router.HandleFunc("/healthz", handler.Healthz()).Methods("GET")
+	router.HandleFunc("/readyz", handler.Readyz(d.readiness)).Methods("GET")
+	router.Handle("/metrics", promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{})).Methods("GET")
+
+	// Operational status, for any authenticated caller
+	router.Handle("/status", chain(handler.Status(d.readiness, d.pool, d.startedAt), d.protection()...)).Methods("GET")
+
+	// The current API, and the versions before it
+	mountAPI(router.PathPrefix("/v1").Subrouter(), d, v1Routes)
+
+	// Unversioned paths from before /v1 are deprecated aliases of v1
+	unversioned := router.PathPrefix("").Subrouter()
+	unversioned.Use(middleware.Deprecation(unversionedDeprecatedAt, d.unversionedSunset, "/v1"))
+	mountAPI(unversioned, d, v1Routes)
+
+	doc, err := openapi.Build(router, apiInfo, handler.Endpoints)
+	if err != nil {
+		return nil, err
+	}
+	*spec = *doc
+	return router, nil
+}
+
+// routeMethods are the methods allowedMethods tries
+var routeMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+
+// allowedMethods lists the methods a route of router matches r's path with
+func allowedMethods(router *mux.Router, r *http.Request) []string {
+	var allowed []string
+	for _, method := range routeMethods {
+		if method == r.Method {
+			continue
+		}
+		probe := r.Clone(r.Context())
+		probe.Method = method
+		var match mux.RouteMatch
+		if router.Match(probe, &match) && match.MatchErr == nil {
+			allowed = append(allowed, method)
+		}
+	}
+	return allowed
+}
+
+// chain wraps h in middlewares, the first outermost as with mux's Use
+func chain(h http.Handler, middlewares ...mux.MiddlewareFunc) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// protection is the middleware of protected endpoints: a limit per client IP that attempts with
+// bad credentials count against, authentication, and a limit per user
+func (d routerDeps) protection() []mux.MiddlewareFunc {
+	return []mux.MiddlewareFunc{
+		middleware.RateLimit(d.rateLimitStore, d.clientIPScope, middleware.RateLimitByIP),
+		d.authMiddleware.Required,
+		middleware.RateLimitByUser(d.rateLimitStore, d.userScope),
+	}
+}
+
+// apiRoutes registers an API version's routes on its public, protected and admin routers
+type apiRoutes func(d routerDeps, public, protected, admin *mux.Router)
+
+// mountAPI registers route sets on api. A route set's routes take precedence over those of the
+// sets after it, so a new version can be mounted as the routes it changes followed by the
+// previous version's: mountAPI(router.PathPrefix("/v2").Subrouter(), d, v2Routes, v1Routes).
+func mountAPI(api *mux.Router, d routerDeps, sets ...apiRoutes) {
+	for _, routes := range sets {
+		// Public endpoints are rate limited per client IP
+		public := api.PathPrefix("").Subrouter()
+		public.Use(middleware.RateLimit(d.rateLimitStore, d.ipScope, middleware.RateLimitByIP))
+
+		// Protected endpoints require an API key or access token
+		protected := api.PathPrefix("").Subrouter()
+		protected.Use(d.protection()...)
+
+		admin := protected.PathPrefix("/admin").Subrouter()
+		admin.Use(middleware.RequireAdmin)
+
+		routes(d, public, protected, admin)
+	}
+}
+
+// v1Routes registers version 1 of the API
+func v1Routes(d routerDeps, public, protected, admin *mux.Router) {
+	// Public endpoints (no auth required)
+	public.HandleFunc("/register", handler.Register(d.userRepo, d.signingSecrets, d.auditRecorder)).Methods("POST")
+	public.HandleFunc("/genres", handler.ListGenres(d.genreRepo)).Methods("GET")
+	public.HandleFunc("/auth/refresh", handler.RefreshToken(d.userRepo, d.sessions, d.auditRecorder)).Methods("POST")
+	public.Handle("/movies", d.authMiddleware.Optional(handler.ListMovies(d.movieRepo, d.agePolicy))).Methods("GET")
+	if d.oidcProvider != nil {
+		public.HandleFunc("/auth/oidc/login", handler.OIDCLogin(d.oidcProvider)).Methods("GET")
+		public.HandleFunc("/auth/oidc/callback", handler.OIDCCallback(d.oidcProvider, d.transactor, d.userRepo, d.identityRepo, d.sessions, d.auditRecorder)).Methods("GET")
+	}
+
+	// Session endpoints
+	protected.HandleFunc("/auth/token", handler.IssueToken(d.sessions, d.auditRecorder)).Methods("POST")
+
+	// User endpoints
+	protected.HandleFunc("/users", handler.CreateUser(d.userRepo, d.signingSecrets, d.auditRecorder)).Methods("POST")
+	protected.HandleFunc("/users/{id}", handler.GetUserByID(d.userRepo)).Methods("GET")
+	protected.HandleFunc("/users/{id}", handler.UpdateUser(d.userRepo, d.auditRecorder)).Methods("PATCH")
+	protected.HandleFunc("/users/{id}", handler.DeleteUser(d.transactor, d.userRepo, d.sessions, d.auditRecorder)).Methods("DELETE")
+	protected.HandleFunc("/users/{id}/api-key", handler.RotateAPIKey(d.userRepo, d.signingSecrets, d.sessions, d.auditRecorder)).Methods("POST")
+	protected.HandleFunc("/users/{id}/api-key", handler.RevokeAPIKey(d.userRepo, d.sessions, d.auditRecorder)).Methods("DELETE")
+
+	// Household profile endpoints
+	protected.HandleFunc("/profiles", handler.ListProfiles(d.profileRepo)).Methods("GET")
+	protected.HandleFunc("/profiles", handler.CreateProfile(d.profileRepo)).Methods("POST")
+	protected.HandleFunc("/profiles/{profile_id}", handler.UpdateProfile(d.profileRepo)).Methods("PATCH")
+	protected.HandleFunc("/profiles/{profile_id}", handler.DeleteProfile(d.profileRepo)).Methods("DELETE")
+
+	// Saved movies endpoints
+	protected.HandleFunc("/users/{user_id}/movies", handler.ListSavedMovies(d.saveMoviesRepo, d.movieRepo, d.agePolicy, d.countryResolver)).Methods("GET")
+	protected.HandleFunc("/users/{user_id}/movies", handler.SaveMovie(d.transactor, d.saveMoviesRepo, d.movieRepo, d.agePolicy, d.countryResolver)).Methods("POST")
+	protected.HandleFunc("/users/{user_id}/movies/{movie_id}", handler.RemoveSavedMovie(d.saveMoviesRepo)).Methods("DELETE")
+
+	// Admin endpoints
+	admin.HandleFunc("/auth-blocks", handler.ListAuthBlocks(d.authTracker)).Methods("GET")
+	admin.HandleFunc("/auth-blocks", handler.ClearAuthBlock(d.authTracker, d.auditRecorder)).Methods("DELETE")
+	admin.HandleFunc("/audit", handler.ListAuditEvents(d.auditRepo)).Methods("GET")
+	admin.HandleFunc("/audit/verify", handler.VerifyAuditChain(d.auditRepo)).Methods("GET")
+}
@@ -0,0 +1,911 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/winfr1th/mock-interview/internal/audit"
+	"github.com/winfr1th/mock-interview/internal/auth"
+	"github.com/winfr1th/mock-interview/internal/auth/oidc"
+	"github.com/winfr1th/mock-interview/internal/country"
+	"github.com/winfr1th/mock-interview/internal/handler"
+	"github.com/winfr1th/mock-interview/internal/health"
+	"github.com/winfr1th/mock-interview/internal/lockout"
+	"github.com/winfr1th/mock-interview/internal/logging"
+	"github.com/winfr1th/mock-interview/internal/middleware"
+	model "github.com/winfr1th/mock-interview/internal/models"
+	"github.com/winfr1th/mock-interview/internal/openapi"
+	"github.com/winfr1th/mock-interview/internal/ratelimit"
+	"github.com/winfr1th/mock-interview/internal/repository"
+	"github.com/winfr1th/mock-interview/internal/repository/memory"
+	"github.com/winfr1th/mock-interview/internal/utils"
+)
+
+// testDeps enables every route, including the optional OIDC ones. The repositories are nil, so
+// only requests that fail before a handler needs its repository can be served.
+func testDeps() routerDeps {
+	return routerDeps{
+		rateLimitStore:    ratelimit.NewMemoryStore(),
+		ipScope:           ratelimit.Scope{Name: "ip", Limit: ratelimit.DefaultIPLimit},
+		clientIPScope:     ratelimit.Scope{Name: "client_ip", Limit: ratelimit.DefaultClientIPLimit},
+		userScope:         ratelimit.Scope{Name: "user", Limit: ratelimit.DefaultUserLimit},
+		oidcProvider:      &oidc.Provider{},
+		authMiddleware:    middleware.NewAuth(nil, nil),
+		unversionedSunset: defaultUnversionedSunset,
+		apiDocs:           true,
+	}
+}
+
+func testRouter(t *testing.T) *mux.Router {
+	t.Helper()
+	router, err := newRouter(testDeps())
+	if err != nil {
+		t.Fatalf("newRouter: %v", err)
+	}
+	return router
+}
+
+func TestEveryRouteIsInOpenAPIDocument(t *testing.T) {
+	router := testRouter(t)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /openapi.json: status %d", rec.Code)
+	}
+	var doc struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decode document: %v", err)
+	}
+	if doc.OpenAPI != openapi.Version {
+		t.Errorf("openapi = %q, want %q", doc.OpenAPI, openapi.Version)
+	}
+
+	router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		if route.GetHandler() == nil {
+			return nil
+		}
+		path, _ := route.GetPathTemplate()
+		methods, _ := route.GetMethods()
+		for _, method := range methods {
+			if _, ok := doc.Paths[path][strings.ToLower(method)]; !ok {
+				t.Errorf("%s %s is not in the document", method, path)
+			}
+		}
+		return nil
+	})
+	var movies struct {
+		Deprecated bool `json:"deprecated"`
+	}
+	json.Unmarshal(doc.Paths["/movies"]["get"], &movies)
+	if !movies.Deprecated {
+		t.Errorf("GET /movies is not documented as deprecated")
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "openapi.json") {
+		t.Errorf("GET /docs: status %d, body doesn't load the document", rec.Code)
+	}
+}
+
+func TestUndocumentedRouteFailsBuild(t *testing.T) {
+	router := mux.NewRouter()
+	router.HandleFunc("/genres", handler.ListGenres(nil)).Methods("GET")
+	router.HandleFunc("/undocumented/{id}", handler.ListGenres(nil)).Methods("GET")
+
+	_, err := openapi.Build(router, apiInfo, handler.Endpoints)
+	if err == nil || !strings.Contains(err.Error(), "GET /undocumented/{id}") {
+		t.Errorf("Build error = %v, want one naming GET /undocumented/{id}", err)
+	}
+}
+
+func TestUnversionedPathsAreDeprecated(t *testing.T) {
+	router := testRouter(t)
+
+	// An invalid page fails before the handler needs its repository
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/genres?page=0", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("GET /v1/genres: status %d, want 400", rec.Code)
+	}
+	if h := rec.Header().Get("Deprecation"); h != "" {
+		t.Errorf("GET /v1/genres: Deprecation %q, want none", h)
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/genres?page=0", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("GET /genres: status %d, want 400", rec.Code)
+	}
+	if h := rec.Header().Get("Deprecation"); h != "@1792281600" {
+		t.Errorf("Deprecation = %q", h)
+	}
+	if h := rec.Header().Get("Sunset"); h != "Sun, 18 Apr 2027 00:00:00 GMT" {
+		t.Errorf("Sunset = %q", h)
+	}
+	if h := rec.Header().Get("Link"); h != `</v1/genres>; rel="successor-version"` {
+		t.Errorf("Link = %q", h)
+	}
+}
+
+func TestNewVersionOverridesPreviousRoutes(t *testing.T) {
+	d := testDeps()
+	v2Routes := func(d routerDeps, public, protected, admin *mux.Router) {
+		public.HandleFunc("/genres", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		}).Methods("GET")
+	}
+	router := mux.NewRouter()
+	mountAPI(router.PathPrefix("/v2").Subrouter(), d, v2Routes, v1Routes)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v2/genres", nil))
+	if rec.Code != http.StatusTeapot {
+		t.Errorf("GET /v2/genres: status %d, want the v2 handler's", rec.Code)
+	}
+
+	// Routes v2 doesn't change are served by v1
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v2/register", strings.NewReader("[]")))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("POST /v2/register: status %d, want v1's 400", rec.Code)
+	}
+}
+
+func TestMetricsAreLabeledWithRouteTemplates(t *testing.T) {
+	router := testRouter(t)
+
+	// Unauthenticated, so the request fails before the handler needs its repository
+	userID := "550e8400-e29b-41d4-a716-446655440000"
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/users/"+userID+"/movies", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("GET /v1/users/{user_id}/movies: status %d, want 401", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	want := `mock_interview_http_request_duration_seconds_count{method="GET",route="/v1/users/{user_id}/movies",status="401"}`
+	if !strings.Contains(body, want) {
+		t.Errorf("metrics don't contain %s", want)
+	}
+	if strings.Contains(body, userID) {
+		t.Errorf("metrics contain the raw path")
+	}
+}
+
+func TestUnmatchedRequestsGetErrorsInTheNegotiatedFormat(t *testing.T) {
+	router := testRouter(t)
+	tests := []struct {
+		method, path, accept string
+		status               int
+		contentType, code    string
+		allow                string
+	}{
+		{http.MethodGet, "/v1/nowhere", "", http.StatusNotFound, "application/json", `"code":"NOT_FOUND"`, ""},
+		{http.MethodGet, "/v1/nowhere", "application/problem+json", http.StatusNotFound, utils.ProblemContentType,
+			`"type":"/problems/not-found"`, ""},
+		{http.MethodPost, "/healthz", "", http.StatusMethodNotAllowed, "application/json", `"code":"METHOD_NOT_ALLOWED"`, "GET"},
+		{http.MethodPost, "/healthz", "application/problem+json", http.StatusMethodNotAllowed, utils.ProblemContentType,
+			`"instance":"/healthz"`, "GET"},
+		{http.MethodPut, "/v1/users/42", "", http.StatusMethodNotAllowed, "application/json", `"code":"METHOD_NOT_ALLOWED"`,
+			"GET, PATCH, DELETE"},
+		{http.MethodGet, "/register", "", http.StatusMethodNotAllowed, "application/json", `"code":"METHOD_NOT_ALLOWED"`, "POST"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.Header.Set("Accept", tt.accept)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != tt.status || rec.Header().Get("Content-Type") != tt.contentType || rec.Header().Get("Allow") != tt.allow ||
+			!strings.Contains(rec.Body.String(), tt.code) || rec.Header().Get(utils.RequestIDHeader) == "" {
+			t.Errorf("%s %s with Accept %q: status %d, %s, Allow %q: %s", tt.method, tt.path, tt.accept,
+				rec.Code, rec.Header().Get("Content-Type"), rec.Header().Get("Allow"), rec.Body)
+		}
+	}
+}
+
+// captureLogs sends the default logger's JSON lines to the returned buffer for the test
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	previous, writer, flags := slog.Default(), log.Writer(), log.Flags()
+	slog.SetDefault(logging.New(&buf, slog.LevelDebug))
+	t.Cleanup(func() {
+		slog.SetDefault(previous)
+		log.SetOutput(writer)
+		log.SetFlags(flags)
+	})
+	return &buf
+}
+
+func TestRequestsAreLoggedWithTheirRequestID(t *testing.T) {
+	logs := captureLogs(t)
+	router := testRouter(t)
+
+	const apiKey = "mvk_live_AbCdEfGhIjKl_0123456789abcdefghijABCDEFGHIJ01zz9x8y"
+	req := httptest.NewRequest(http.MethodGet, "/v1/users/550e8400-e29b-41d4-a716-446655440000/movies", nil)
+	req.Header.Set("X-Request-ID", "edge-7f3a")
+	req.Header.Set("X-API-Key", apiKey)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("X-Request-ID"); got != "edge-7f3a" {
+		t.Errorf("X-Request-ID = %q, want the client's", got)
+	}
+	var body struct {
+		Error struct {
+			RequestID string `json:"request_id"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Error.RequestID != "edge-7f3a" {
+		t.Errorf("error body %s doesn't carry the request ID", rec.Body)
+	}
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(logs.Bytes(), &line); err != nil {
+		t.Fatalf("want one access log line, got %q", logs)
+	}
+	for key, want := range map[string]interface{}{
+		"msg":        "request",
+		"route":      "/v1/users/{user_id}/movies",
+		"status":     float64(rec.Code),
+		"bytes":      float64(rec.Body.Len()),
+		"request_id": "edge-7f3a",
+	} {
+		if line[key] != want {
+			t.Errorf("log %s = %v, want %v", key, line[key], want)
+		}
+	}
+	if strings.Contains(logs.String(), "0123456789abcdefghij") {
+		t.Errorf("API key secret logged: %s", logs)
+	}
+}
+
+func TestInvalidRequestIDIsReplaced(t *testing.T) {
+	captureLogs(t)
+	router := testRouter(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.Header.Set("X-Request-ID", "forged\nlevel=ERROR")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if _, err := uuid.Parse(rec.Header().Get("X-Request-ID")); err != nil {
+		t.Errorf("X-Request-ID = %q, want a generated UUID", rec.Header().Get("X-Request-ID"))
+	}
+}
+
+func TestOIDCCallbackNeedsTheLoginsStateCookie(t *testing.T) {
+	router := testRouter(t)
+
+	for _, cookie := range []string{"", "other-login"} {
+		req := httptest.NewRequest(http.MethodGet, "/v1/auth/oidc/callback?state=attacker-login&code=attacker-code", nil)
+		if cookie != "" {
+			req.AddCookie(&http.Cookie{Name: "oidc_state", Value: cookie})
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), "not started in this browser") {
+			t.Errorf("state cookie %q: %d %s, want 401 before the code is redeemed", cookie, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestReadyzDoesntExposeCheckErrors(t *testing.T) {
+	d := testDeps()
+	d.readiness = []health.Check{{Name: "database", Run: func(ctx context.Context) error {
+		return errors.New(`password authentication failed for user "movies" at db.internal:5432`)
+	}}}
+	router, err := newRouter(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var report health.Report
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil || rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("%d %s, want 503 with the report", rec.Code, rec.Body)
+	}
+	if got := report.Checks["database"]; got.Status != health.StatusFail || strings.Contains(rec.Body.String(), "password") {
+		t.Errorf("report %s, want the failed check without its error", rec.Body)
+	}
+}
+
+func TestCORSPreflightAndResponseHeaders(t *testing.T) {
+	captureLogs(t)
+	h := middleware.CORS(middleware.CORSPolicy{
+		AllowedOrigins: []string{"https://app.example.com"},
+		AllowedMethods: []string{"GET", "POST"},
+		AllowedHeaders: []string{"X-API-Key"},
+		ExposedHeaders: []string{"X-Request-ID"},
+		MaxAge:         10 * time.Minute,
+	})(testRouter(t))
+
+	preflight := httptest.NewRequest(http.MethodOptions, "/v1/users/550e8400-e29b-41d4-a716-446655440000/movies", nil)
+	preflight.Header.Set("Origin", "https://app.example.com")
+	preflight.Header.Set("Access-Control-Request-Method", "GET")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, preflight)
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+		rec.Header().Get("Access-Control-Max-Age") != "600" {
+		t.Errorf("preflight: status %d, headers %v", rec.Code, rec.Header())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("disallowed origin got %q", rec.Header().Get("Access-Control-Allow-Origin"))
+	}
+
+	req.Header.Set("Origin", "https://app.example.com")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Header().Get("Access-Control-Expose-Headers") != "X-Request-ID" {
+		t.Errorf("exposed headers %q", rec.Header().Get("Access-Control-Expose-Headers"))
+	}
+}
+
+// memoryDeps serves every route from in-memory repositories, so handlers run as they do against
+// Postgres. The store is returned to seed the catalog.
+func memoryDeps(t *testing.T) (routerDeps, *memory.Store) {
+	t.Helper()
+	store := memory.NewStore()
+	recorder := audit.NewRecorder(memory.NewAuditRepository(store))
+	users := memory.NewUserRepository(store)
+	tokens, err := auth.NewTokens(bytes.Repeat([]byte("k"), auth.MinTokenSigningKeyLength), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker := lockout.NewTracker(lockout.DefaultConfig, recorder)
+
+	d := testDeps()
+	d.transactor = store
+	d.userRepo = users
+	d.genreRepo = memory.NewGenreRepository(store)
+	d.movieRepo = memory.NewMovieRepository(store)
+	d.saveMoviesRepo = memory.NewSaveMoviesRepository(store)
+	d.profileRepo = memory.NewProfileRepository(store)
+	d.auditRepo = memory.NewAuditRepository(store)
+	d.identityRepo = memory.NewIdentityRepository(store)
+	d.auditRecorder = recorder
+	d.countryResolver = country.NewResolver("", nil)
+	d.authTracker = tracker
+	d.sessions = handler.Sessions{
+		Tokens:      tokens,
+		Refresh:     memory.NewRefreshTokenRepository(store),
+		Revocations: auth.NewKeyRevocations(tokens.TTL()),
+		RefreshTTL:  time.Hour,
+	}
+	d.authMiddleware = middleware.NewAuth(d.profileRepo, tracker, middleware.APIKeyAuthenticator{Users: users})
+	return d, store
+}
+
+func TestSavingMoviesWithMemoryRepositories(t *testing.T) {
+	d, store := memoryDeps(t)
+	router, err := newRouter(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := t.Context()
+	genre := model.Genre{ID: uuid.New(), Name: "Action"}
+	movie := model.Movie{ID: uuid.New(), Title: "The Matrix", Year: 1999, GenreID: genre.ID}
+	for _, err := range []error{
+		store.AddCountry(ctx, model.Country{Code: "US", Name: "United States"}),
+		store.AddGenre(ctx, genre),
+		store.AddMovie(ctx, movie, "US"),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	serve := func(method, path, apiKey, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(http.MethodPost, "/v1/register", "", `{"name": "Ada", "date_of_birth": "1990-05-17"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("register: status %d: %s", rec.Code, rec.Body)
+	}
+	var registered model.RegisterResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &registered); err != nil {
+		t.Fatal(err)
+	}
+
+	path := "/v1/users/" + registered.UserID.String() + "/movies"
+	body := `{"movie_id": "` + movie.ID.String() + `"}`
+	if rec := serve(http.MethodPost, path+"?country=US", registered.APIKey, body); rec.Code != http.StatusOK {
+		t.Fatalf("save: status %d: %s", rec.Code, rec.Body)
+	}
+	if rec := serve(http.MethodPost, path+"?country=US", registered.APIKey, body); rec.Code != http.StatusConflict ||
+		!strings.Contains(rec.Body.String(), handler.ErrorCodeDuplicateSave) {
+		t.Errorf("duplicate save: status %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = serve(http.MethodGet, path+"?country=US", registered.APIKey, "")
+	var listed struct {
+		Data  []handler.MovieResponse `json:"data"`
+		Total int                     `json:"total"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &listed); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("list: status %d: %s", rec.Code, rec.Body)
+	}
+	if listed.Total != 1 || len(listed.Data) != 1 || listed.Data[0].ID != movie.ID.String() {
+		t.Errorf("list = %+v, want the saved movie", listed)
+	}
+
+	if rec := serve(http.MethodDelete, "/v1/users/"+registered.UserID.String(), registered.APIKey, ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete account: status %d: %s", rec.Code, rec.Body)
+	}
+	if rec := serve(http.MethodGet, path+"?country=US", registered.APIKey, ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("deleted account's key: status %d, want 401", rec.Code)
+	}
+}
+
+func TestDefaultProfilePINGuardsFallingBackToIt(t *testing.T) {
+	d, _ := memoryDeps(t)
+	router, err := newRouter(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve := func(method, path, apiKey, body string, headers ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-API-Key", apiKey)
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(http.MethodPost, "/v1/register", "", `{"name": "Ada", "date_of_birth": "1990-05-17"}`)
+	var registered model.RegisterResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &registered); err != nil || rec.Code != http.StatusCreated {
+		t.Fatalf("register: status %d: %s", rec.Code, rec.Body)
+	}
+	key := registered.APIKey
+	rec = serve(http.MethodPost, "/v1/profiles", key, `{"name": "Kid", "max_age": 7}`)
+	var kid model.ProfileResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &kid); err != nil || rec.Code != http.StatusCreated {
+		t.Fatalf("create profile: status %d: %s", rec.Code, rec.Body)
+	}
+	kidHeaders := []string{middleware.ProfileIDHeader, kid.ID.String()}
+
+	defaultPath := "/v1/profiles/" + registered.UserID.String()
+	if rec := serve(http.MethodPatch, defaultPath, key, `{"pin": "4321"}`, kidHeaders...); rec.Code != http.StatusForbidden {
+		t.Errorf("kid updating the default profile: status %d, want 403", rec.Code)
+	}
+	if rec := serve(http.MethodPatch, defaultPath, key, `{"pin": "4321", "clear_pin": true}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("pin and clear_pin: status %d, want 400", rec.Code)
+	}
+	rec = serve(http.MethodPatch, defaultPath, key, `{"name": "Parents", "pin": "4321"}`)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"has_pin":true`) {
+		t.Fatalf("set the default profile's PIN: status %d: %s", rec.Code, rec.Body)
+	}
+
+	if rec := serve(http.MethodGet, "/v1/profiles", key, ""); rec.Code != http.StatusForbidden ||
+		!strings.Contains(rec.Body.String(), "PROFILE_PIN_REQUIRED") {
+		t.Errorf("falling back to the default profile without its PIN: status %d: %s", rec.Code, rec.Body)
+	}
+	if rec := serve(http.MethodGet, "/v1/profiles", key, "", kidHeaders...); rec.Code != http.StatusOK {
+		t.Errorf("kid's profile: status %d: %s", rec.Code, rec.Body)
+	}
+	if rec := serve(http.MethodPatch, defaultPath, key, `{"clear_pin": true}`, middleware.ProfilePINHeader, "4321"); rec.Code != http.StatusOK ||
+		!strings.Contains(rec.Body.String(), `"has_pin":false`) {
+		t.Errorf("clear the default profile's PIN: status %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestProtectedRoutesAreLimitedPerClientIPAndPerUser(t *testing.T) {
+	d, _ := memoryDeps(t)
+	d.clientIPScope.Limit = ratelimit.Limit{Rate: 0.001, Burst: 4}
+	d.userScope.Limit = ratelimit.Limit{Rate: 0.001, Burst: 2}
+	router, err := newRouter(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve := func(method, path, apiKey, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-API-Key", apiKey)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(http.MethodPost, "/v1/register", "", `{"name": "Ada", "date_of_birth": "1990-05-17"}`)
+	var registered model.RegisterResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &registered); err != nil || rec.Code != http.StatusCreated {
+		t.Fatalf("register: status %d: %s", rec.Code, rec.Body)
+	}
+
+	wantCodes := []struct {
+		apiKey string
+		code   int
+	}{
+		{registered.APIKey, http.StatusOK},
+		{registered.APIKey, http.StatusOK},
+		{registered.APIKey, http.StatusTooManyRequests}, // The user's bucket is empty
+		{"mk_guess1_000000000000000000000000", http.StatusUnauthorized},
+		{"mk_guess2_000000000000000000000000", http.StatusTooManyRequests}, // A new key, but the same client IP
+	}
+	for i, want := range wantCodes {
+		if rec := serve(http.MethodGet, "/v1/profiles", want.apiKey, ""); rec.Code != want.code {
+			t.Errorf("request %d: status %d, want %d: %s", i+1, rec.Code, want.code, rec.Body)
+		}
+	}
+}
+
+func TestWrongProfilePINsAreThrottled(t *testing.T) {
+	d, _ := memoryDeps(t)
+	router, err := newRouter(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve := func(method, path, apiKey, body string, headers ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-API-Key", apiKey)
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(http.MethodPost, "/v1/register", "", `{"name": "Ada", "date_of_birth": "1990-05-17"}`)
+	var registered model.RegisterResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &registered); err != nil || rec.Code != http.StatusCreated {
+		t.Fatalf("register: status %d: %s", rec.Code, rec.Body)
+	}
+	rec = serve(http.MethodPost, "/v1/profiles", registered.APIKey, `{"name": "Teen", "max_age": 16, "pin": "2468"}`)
+	var teen model.ProfileResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &teen); err != nil || rec.Code != http.StatusCreated {
+		t.Fatalf("create profile: status %d: %s", rec.Code, rec.Body)
+	}
+
+	asTeen := func(pin string) *httptest.ResponseRecorder {
+		return serve(http.MethodGet, "/v1/profiles", registered.APIKey, "",
+			middleware.ProfileIDHeader, teen.ID.String(), middleware.ProfilePINHeader, pin)
+	}
+	for i := 1; i <= lockout.DefaultConfig.DelayAfter+1; i++ {
+		if rec := asTeen("0000"); rec.Code != http.StatusForbidden {
+			t.Fatalf("wrong PIN %d: status %d, want 403: %s", i, rec.Code, rec.Body)
+		}
+	}
+	if rec := asTeen("2468"); rec.Code != http.StatusTooManyRequests || !strings.Contains(rec.Body.String(), "PIN_THROTTLED") ||
+		rec.Header().Get("Retry-After") == "" {
+		t.Errorf("right PIN right after wrong ones: status %d, want 429 PIN_THROTTLED: %s", rec.Code, rec.Body)
+	}
+	if rec := serve(http.MethodGet, "/v1/profiles", registered.APIKey, ""); rec.Code != http.StatusOK {
+		t.Errorf("other profiles aren't throttled: status %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestLegacyAPIKeysAreFoundByTheirHash(t *testing.T) {
+	d, _ := memoryDeps(t)
+	router, err := newRouter(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve := func(method, path, apiKey, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-API-Key", apiKey)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(http.MethodPost, "/v1/register", "", `{"name": "Ada", "date_of_birth": "1990-05-17"}`)
+	var registered model.RegisterResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &registered); err != nil || rec.Code != http.StatusCreated {
+		t.Fatalf("register: status %d: %s", rec.Code, rec.Body)
+	}
+	legacyKey := uuid.NewString()
+	if err := d.userRepo.UpdateAPIKey(t.Context(), registered.UserID, "", auth.HashAPIKey(legacyKey)); err != nil {
+		t.Fatal(err)
+	}
+
+	rec = serve(http.MethodGet, "/v1/profiles", legacyKey, "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Deprecation") != "true" {
+		t.Errorf("legacy key: status %d, Deprecation %q: %s", rec.Code, rec.Header().Get("Deprecation"), rec.Body)
+	}
+	// The stored hash isn't a credential
+	if rec := serve(http.MethodGet, "/v1/profiles", auth.HashAPIKey(legacyKey), ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("legacy key's hash: status %d, want 401", rec.Code)
+	}
+}
+
+func TestRequestsAreSignedWithTheReturnedSecretNotTheKeyHash(t *testing.T) {
+	d, _ := memoryDeps(t)
+	secrets, err := auth.NewSigningSecrets(bytes.Repeat([]byte("s"), auth.MinTokenSigningKeyLength))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.signingSecrets = secrets
+	d.authMiddleware = middleware.NewAuth(d.profileRepo, d.authTracker,
+		middleware.SignatureAuthenticator{Users: d.userRepo, Secrets: secrets, Nonces: auth.NewNonceCache(time.Minute), MaxSkew: time.Minute},
+		middleware.APIKeyAuthenticator{Users: d.userRepo})
+	router, err := newRouter(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/register",
+		strings.NewReader(`{"name": "Ada", "date_of_birth": "1990-05-17"}`)))
+	var registered model.RegisterResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &registered); err != nil || rec.Code != http.StatusCreated {
+		t.Fatalf("register: status %d: %s", rec.Code, rec.Body)
+	}
+	if registered.SigningSecret == "" {
+		t.Fatal("register returned no signing secret")
+	}
+	keyID, err := auth.ParseAPIKey(registered.APIKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serveSigned := func(secret string) int {
+		req := httptest.NewRequest(http.MethodGet, "/v1/profiles", nil)
+		if err := auth.SignRequest(req, keyID, secret, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := serveSigned(registered.SigningSecret); code != http.StatusOK {
+		t.Errorf("signed with the signing secret: status %d, want 200", code)
+	}
+	if code := serveSigned(auth.HashAPIKey(registered.APIKey)); code != http.StatusUnauthorized {
+		t.Errorf("signed with the stored key hash: status %d, want 401", code)
+	}
+
+	// A signed request is only accepted once, and only near the time it was signed
+	req := httptest.NewRequest(http.MethodGet, "/v1/profiles", nil)
+	if err := auth.SignRequest(req, keyID, registered.SigningSecret, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []int{http.StatusOK, http.StatusUnauthorized} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req.Clone(t.Context()))
+		if rec.Code != want {
+			t.Errorf("signed request sent %d times: status %d, want %d", i+1, rec.Code, want)
+		}
+	}
+	req = httptest.NewRequest(http.MethodGet, "/v1/profiles", nil)
+	if err := auth.SignRequest(req, keyID, registered.SigningSecret, time.Now().Add(-2*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("signed outside the allowed skew: status %d, want 401", rec.Code)
+	}
+}
+
+func TestGuessesAtAKeyIDDontLockItsOwnerOut(t *testing.T) {
+	d, _ := memoryDeps(t)
+	secrets, err := auth.NewSigningSecrets(bytes.Repeat([]byte("s"), auth.MinTokenSigningKeyLength))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.signingSecrets = secrets
+	d.authMiddleware = middleware.NewAuth(d.profileRepo, d.authTracker,
+		middleware.SignatureAuthenticator{Users: d.userRepo, Secrets: secrets, Nonces: auth.NewNonceCache(time.Minute), MaxSkew: time.Minute},
+		middleware.APIKeyAuthenticator{Users: d.userRepo})
+	router, err := newRouter(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/register",
+		strings.NewReader(`{"name": "Ada", "date_of_birth": "1990-05-17"}`)))
+	var registered model.RegisterResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &registered); err != nil || rec.Code != http.StatusCreated {
+		t.Fatalf("register: status %d: %s", rec.Code, rec.Body)
+	}
+	keyID, err := auth.ParseAPIKey(registered.APIKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Key IDs aren't secret, so an attacker signs with a guessed secret from a new IP each time
+	serve := func(req *http.Request, ip int) *httptest.ResponseRecorder {
+		req.RemoteAddr = fmt.Sprintf("192.0.2.%d:1234", ip)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	guess := func(ip int) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/v1/profiles", nil)
+		if err := auth.SignRequest(req, keyID, "guessed", time.Now()); err != nil {
+			t.Fatal(err)
+		}
+		return serve(req, ip)
+	}
+	for i := 1; i <= lockout.DefaultConfig.DelayAfter+1; i++ {
+		if rec := guess(i); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("guess %d: status %d, want 401: %s", i, rec.Code, rec.Body)
+		}
+	}
+	if rec := guess(100); rec.Code != http.StatusTooManyRequests || !strings.Contains(rec.Body.String(), "AUTH_THROTTLED") {
+		t.Errorf("guess past the delay threshold: status %d, want 429 AUTH_THROTTLED: %s", rec.Code, rec.Body)
+	}
+
+	// The owner's key and signatures still verify, and aren't stopped by the key's delay
+	req := httptest.NewRequest(http.MethodGet, "/v1/profiles", nil)
+	req.Header.Set("X-API-Key", registered.APIKey)
+	if rec := serve(req, 200); rec.Code != http.StatusOK {
+		t.Errorf("owner's API key: status %d, want 200: %s", rec.Code, rec.Body)
+	}
+	req = httptest.NewRequest(http.MethodGet, "/v1/profiles", nil)
+	if err := auth.SignRequest(req, keyID, registered.SigningSecret, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if rec := serve(req, 200); rec.Code != http.StatusOK {
+		t.Errorf("owner's signed request: status %d, want 200: %s", rec.Code, rec.Body)
+	}
+}
+
+// countingProfiles counts the profile reads that reach the repository
+type countingProfiles struct {
+	repository.ProfileRepository
+	reads int
+}
+
+func (c *countingProfiles) GetProfile(ctx context.Context, householdID, profileID uuid.UUID) (model.Profile, error) {
+	c.reads++
+	return c.ProfileRepository.GetProfile(ctx, householdID, profileID)
+}
+
+func (c *countingProfiles) GetDefaultProfile(ctx context.Context, householdID uuid.UUID) (model.Profile, error) {
+	c.reads++
+	return c.ProfileRepository.GetDefaultProfile(ctx, householdID)
+}
+
+func TestAccessTokenRequestsUseCachedProfiles(t *testing.T) {
+	d, _ := memoryDeps(t)
+	profiles := &countingProfiles{ProfileRepository: d.profileRepo}
+	d.profileRepo = repository.NewCachedProfileRepository(profiles, time.Minute)
+	d.authMiddleware = middleware.NewAuth(d.profileRepo, d.authTracker,
+		middleware.AccessTokenAuthenticator{Tokens: d.sessions.Tokens, Revocations: d.sessions.Revocations},
+		middleware.APIKeyAuthenticator{Users: d.userRepo})
+	router, err := newRouter(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve := func(method, path, credential, body string, headers ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if credential != "" {
+			req.Header.Set("Authorization", "Bearer "+credential)
+		}
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(http.MethodPost, "/v1/register", "", `{"name": "Ada", "date_of_birth": "1990-05-17"}`)
+	var registered model.RegisterResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &registered); err != nil || rec.Code != http.StatusCreated {
+		t.Fatalf("register: status %d: %s", rec.Code, rec.Body)
+	}
+	rec = serve(http.MethodPost, "/v1/auth/token", registered.APIKey, "")
+	var tokens model.AccessTokenResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &tokens); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("exchange the key: status %d: %s", rec.Code, rec.Body)
+	}
+	rec = serve(http.MethodPost, "/v1/profiles", tokens.AccessToken, `{"name": "Teen", "max_age": 16}`)
+	var teen model.ProfileResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &teen); err != nil || rec.Code != http.StatusCreated {
+		t.Fatalf("create profile: status %d: %s", rec.Code, rec.Body)
+	}
+
+	// Only the first request after the profile was created reads it from the repository
+	profiles.reads = 0
+	for i := 1; i <= 3; i++ {
+		if rec := serve(http.MethodGet, "/v1/profiles", tokens.AccessToken, "", middleware.ProfileIDHeader, teen.ID.String()); rec.Code != http.StatusOK {
+			t.Fatalf("request %d as the teen: status %d: %s", i, rec.Code, rec.Body)
+		}
+	}
+	if profiles.reads != 1 {
+		t.Errorf("%d profile reads for 3 access token requests, want 1", profiles.reads)
+	}
+
+	// Editing a profile drops the household's cached profiles, so a new PIN applies at once
+	if rec := serve(http.MethodPatch, "/v1/profiles/"+teen.ID.String(), tokens.AccessToken, `{"pin": "2468"}`); rec.Code != http.StatusOK {
+		t.Fatalf("set the teen's PIN: status %d: %s", rec.Code, rec.Body)
+	}
+	if rec := serve(http.MethodGet, "/v1/profiles", tokens.AccessToken, "", middleware.ProfileIDHeader, teen.ID.String()); rec.Code != http.StatusForbidden ||
+		!strings.Contains(rec.Body.String(), "PROFILE_PIN_REQUIRED") {
+		t.Errorf("teen without the new PIN: status %d, want 403 PROFILE_PIN_REQUIRED: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestReusedRefreshTokenRevokesItsSession(t *testing.T) {
+	d, _ := memoryDeps(t)
+	router, err := newRouter(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve := func(path, apiKey, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	refresh := func(refreshToken string) (*httptest.ResponseRecorder, model.AccessTokenResponse) {
+		rec := serve("/v1/auth/refresh", "", `{"refresh_token": "`+refreshToken+`"}`)
+		var pair model.AccessTokenResponse
+		json.Unmarshal(rec.Body.Bytes(), &pair)
+		return rec, pair
+	}
+
+	rec := serve("/v1/register", "", `{"name": "Ada", "date_of_birth": "1990-05-17"}`)
+	var registered model.RegisterResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &registered); err != nil || rec.Code != http.StatusCreated {
+		t.Fatalf("register: status %d: %s", rec.Code, rec.Body)
+	}
+	rec = serve("/v1/auth/token", registered.APIKey, "")
+	var first model.AccessTokenResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &first); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("exchange the key: status %d: %s", rec.Code, rec.Body)
+	}
+
+	rec, second := refresh(first.RefreshToken)
+	if rec.Code != http.StatusOK || second.RefreshToken == "" || second.RefreshToken == first.RefreshToken {
+		t.Fatalf("refresh: status %d: %s", rec.Code, rec.Body)
+	}
+	// Presenting the spent token again ends the session, the token it was replaced with included
+	if rec, _ := refresh(first.RefreshToken); rec.Code != http.StatusUnauthorized ||
+		!strings.Contains(rec.Body.String(), "REFRESH_TOKEN_REUSED") {
+		t.Errorf("reused refresh token: status %d: %s", rec.Code, rec.Body)
+	}
+	if rec, _ := refresh(second.RefreshToken); rec.Code != http.StatusUnauthorized ||
+		!strings.Contains(rec.Body.String(), "INVALID_REFRESH_TOKEN") {
+		t.Errorf("refresh token of the revoked session: status %d: %s", rec.Code, rec.Body)
+	}
+}
@@ -1,17 +1,30 @@
 -- Seed data for development/testing
 -- This script creates a test user with a known API key
 
+-- Insert test household
+INSERT INTO households (id, name)
+VALUES ('550e8400-e29b-41d4-a716-446655440001', 'Test User')
+ON CONFLICT (id) DO NOTHING;
+
 -- Insert test user with known API key
 -- API Key: 550e8400-e29b-41d4-a716-446655440000
-INSERT INTO users (id, name, date_of_birth, api_key_hash)
+INSERT INTO users (id, household_id, name, date_of_birth, api_key_hash)
 VALUES (
+    '550e8400-e29b-41d4-a716-446655440001',
     '550e8400-e29b-41d4-a716-446655440001',
     'Test User',
     '1990-01-01',
-    '550e8400-e29b-41d4-a716-446655440000'  -- Plain API key (not hashed)
+    'a3a9e1ed9732cab28868127be00f1ce921acaefdd5c3b23a6e9e0072bd9c1a34'  -- SHA-256 of the API key
 )
 ON CONFLICT (id) DO NOTHING;
 
+-- Insert test user's default profile and a PIN-less kids profile limited to age 12
+INSERT INTO profiles (id, household_id, name, max_age, is_default)
+VALUES
+    ('550e8400-e29b-41d4-a716-446655440001', '550e8400-e29b-41d4-a716-446655440001', 'Test User', NULL, TRUE),
+    ('550e8400-e29b-41d4-a716-446655440002', '550e8400-e29b-41d4-a716-446655440001', 'Kids', 12, FALSE)
+ON CONFLICT (id) DO NOTHING;
+
 -- Insert sample genres
 INSERT INTO genres (id, name)
 VALUES
@@ -50,3 +63,33 @@ CROSS JOIN countries c
 WHERE c.code IN ('US', 'GB')
 ON CONFLICT (movie_id, country_code) DO NOTHING;
 
+
+-- Insert rating boards' certifications
+INSERT INTO certifications (country_code, code, min_age)
+VALUES
+    ('US', 'G', 0),
+    ('US', 'PG', 0),
+    ('US', 'PG-13', 13),
+    ('US', 'R', 17),
+    ('US', 'NC-17', 18),
+    ('GB', 'U', 0),
+    ('GB', 'PG', 0),
+    ('GB', '12A', 12),
+    ('GB', '15', 15),
+    ('GB', '18', 18)
+ON CONFLICT (country_code, code) DO NOTHING;
+
+-- Insert movie certifications for US and GB
+INSERT INTO movie_certifications (movie_id, country_code, certification)
+VALUES
+    ('550e8400-e29b-41d4-a716-446655440020', 'US', 'R'),
+    ('550e8400-e29b-41d4-a716-446655440020', 'GB', '15'),
+    ('550e8400-e29b-41d4-a716-446655440021', 'US', 'PG-13'),
+    ('550e8400-e29b-41d4-a716-446655440021', 'GB', '12A'),
+    ('550e8400-e29b-41d4-a716-446655440022', 'US', 'PG-13'),
+    ('550e8400-e29b-41d4-a716-446655440022', 'GB', '12A'),
+    ('550e8400-e29b-41d4-a716-446655440023', 'US', 'R'),
+    ('550e8400-e29b-41d4-a716-446655440023', 'GB', '18'),
+    ('550e8400-e29b-41d4-a716-446655440024', 'US', 'R'),
+    ('550e8400-e29b-41d4-a716-446655440024', 'GB', '15')
+ON CONFLICT (movie_id, country_code) DO NOTHING;
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/multitracer"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/winfr1th/mock-interview/internal/config"
+	"github.com/winfr1th/mock-interview/internal/database"
+	"github.com/winfr1th/mock-interview/internal/database/sqlite"
+	"github.com/winfr1th/mock-interview/internal/metrics"
+	"github.com/winfr1th/mock-interview/internal/repository"
+	"github.com/winfr1th/mock-interview/internal/tracing"
+)
+
+// storage is the database the repositories run on, Postgres or SQLite as DATABASE_URL's scheme says
+type storage struct {
+	db            repository.Executor
+	pool          *pgxpool.Pool // Postgres' pool, for metrics and /status; nil for SQLite
+	ping          func(ctx context.Context) error
+	schemaVersion func(ctx context.Context) (int, error)
+	close         func()
+}
+
+// openStorage connects to the database cfg names. Postgres queries are timed and traced, and
+// read-only ones are retried on transient errors. An SQLite database is migrated as it's opened:
+// there's no one to run psql for a demo.
+func openStorage(ctx context.Context, cfg config.Database) (storage, error) {
+	if sqlite.IsURL(string(cfg.URL)) {
+		db, err := sqlite.Open(ctx, string(cfg.URL))
+		if err != nil {
+			return storage{}, err
+		}
+		if err := db.Migrate(ctx); err != nil {
+			db.Close()
+			return storage{}, fmt.Errorf("unable to migrate database: %w", err)
+		}
+		return storage{
+			db:            db,
+			ping:          db.Ping,
+			schemaVersion: db.SchemaVersion,
+			close:         func() { db.Close() },
+		}, nil
+	}
+
+	pool, err := database.NewConnection(ctx, cfg.Pool(), multitracer.New(metrics.QueryTracer{}, tracing.QueryTracer{}))
+	if err != nil {
+		return storage{}, err
+	}
+	metrics.Registry.MustRegister(metrics.NewPoolCollector(pool))
+	return storage{
+		db:   database.NewDB(pool, cfg.Retry()),
+		pool: pool,
+		ping: pool.Ping,
+		schemaVersion: func(ctx context.Context) (int, error) {
+			return database.SchemaVersion(ctx, pool)
+		},
+		close: func() { database.CloseConnection(pool) },
+	}, nil
+}